@@ -1,25 +1,84 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/app"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 // @title Online Subscriptions API
 // @description API для управления онлайн подписками
 // @host localhost:8080
 // @BasePath /api/
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Токен администратора в виде "Bearer <token>"
 func main() {
-	cfg := config.Load(os.Getenv("CONFIG_PATH"))
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+
+	var sources config.Sources
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	sources.RegisterFlags(flags)
+	_ = flags.Parse(os.Args[1:])
+
+	cfg, err := config.Load(sources)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
 	application, err := app.NewApp(cfg)
 	if err != nil {
 		log.Fatalf("Failed to init app: %v", err)
 	}
 
-	if err := application.Run(cfg.HTTP.Port); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := application.Run(ctx); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
+
+// configCommand выполняет "config print [-redacted]": выводит итоговые настройки, затем ошибки проверки
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: config print [-redacted] [-config file] [-env name] [-set key=value]")
+		return 2
+	}
+
+	var sources config.Sources
+
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	sources.RegisterFlags(flags)
+	redacted := flags.Bool("redacted", false, "hide secrets")
+	_ = flags.Parse(args[1:])
+
+	cfg, err := config.Read(sources)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := config.Print(os.Stdout, cfg, *redacted); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	return 0
+}
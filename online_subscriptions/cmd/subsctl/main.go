@@ -0,0 +1,95 @@
+// subsctl - утилита администратора: операции с подписками через те же сервисы, что и у сервера,
+// без HTTP. Настройки читаются так же, как сервером: -config, -env, -set и переменные окружения
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: subsctl <command> [flags]
+
+commands:
+  records list      list subscriptions
+  records get       show a subscription by id
+  records create    create a subscription
+  records delete    delete subscriptions by id
+  summary           total price of subscriptions started in a period
+  import            import subscriptions from a CSV or XLSX file
+  export            export subscriptions as CSV, JSONL or XLSX
+  migrate           apply the database schema
+
+run "subsctl <command> -h" for command flags`
+
+// errUsage - неверные аргументы; сообщение уже выведено вместе со справкой
+var errUsage = errors.New("usage")
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"records list":   recordsList,
+	"records get":    recordsGet,
+	"records create": recordsCreate,
+	"records delete": recordsDelete,
+	"summary":        summary,
+	"import":         importRecords,
+	"export":         exportRecords,
+	"migrate":        migrate,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run выполняет команду и возвращает код выхода: 1 - ошибка выполнения, 2 - неверные аргументы или настройки
+func run(args []string) int {
+	cmd, rest, ok := lookup(args)
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cmd(ctx, rest)
+
+	var configErr *configError
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.As(err, &configErr):
+		fmt.Fprintln(os.Stderr, configErr.err)
+		return 2
+	default:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+}
+
+// lookup находит команду по одному или двум первым аргументам
+func lookup(args []string) (command, []string, bool) {
+	if len(args) >= 2 {
+		if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+			return cmd, args[2:], true
+		}
+	}
+
+	if len(args) >= 1 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd, args[1:], true
+		}
+	}
+
+	return nil, nil, false
+}
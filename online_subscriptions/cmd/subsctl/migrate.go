@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/storage"
+)
+
+// migrate применяет схему хранилища, не запуская сервер. Сервер делает то же при старте,
+// команда нужна, чтобы обновить схему отдельным шагом развертывания
+func migrate(_ context.Context, args []string) error {
+	o := newOptions("migrate", "", false)
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if _, err := o.args(0); err != nil {
+		return err
+	}
+
+	cfg, err := o.config()
+	if err != nil {
+		return err
+	}
+
+	// журнал gorm настраивается здесь же, сервисы не нужны
+	o.logger()
+
+	if cfg.Storage.Driver == config.DriverMemory {
+		return fmt.Errorf("storage driver %q has no schema to migrate", cfg.Storage.Driver)
+	}
+
+	db, err := storage.InitDB(cfg, clock.System)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.Close(db); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s schema is up to date\n", cfg.Storage.Driver)
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/app"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	gormlogger "gorm.io/gorm/logger"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// options - флаги, общие для всех команд
+type options struct {
+	sources config.Sources
+	output  string
+	verbose bool
+
+	flags      *flag.FlagSet
+	positional []string
+}
+
+// configError - настройки не прочитались или не прошли проверку
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+// newOptions создает набор флагов команды name с общими флагами. withOutput добавляет -o
+func newOptions(name, args string, withOutput bool) *options {
+	o := &options{flags: flag.NewFlagSet("subsctl "+name, flag.ContinueOnError)}
+
+	o.sources.RegisterFlags(o.flags)
+	o.flags.BoolVar(&o.verbose, "v", false, "log service operations to stderr")
+
+	if withOutput {
+		o.flags.StringVar(&o.output, "o", outputTable, "output format: table or json")
+	}
+
+	o.flags.Usage = func() {
+		fmt.Fprintf(o.flags.Output(), "usage: subsctl %s [flags] %s\n\nflags:\n", name, args)
+		o.flags.PrintDefaults()
+	}
+
+	return o
+}
+
+// parse разбирает аргументы и проверяет общие флаги. Флаги можно указывать и после
+// позиционных аргументов: subsctl records get 1 -o json
+func (o *options) parse(args []string) error {
+	for {
+		if err := o.flags.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return err
+			}
+			return errUsage
+		}
+
+		args = o.flags.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		o.positional = append(o.positional, args[0])
+		args = args[1:]
+	}
+
+	if o.output != "" && o.output != outputTable && o.output != outputJSON {
+		return o.usageError("-o must be table or json, got %q", o.output)
+	}
+
+	return nil
+}
+
+// isSet сообщает, задан ли флаг name в командной строке, чтобы отличить явный 0 от пропущенного флага
+func (o *options) isSet(name string) bool {
+	set := false
+	o.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// usageError выводит сообщение со справкой по флагам команды
+func (o *options) usageError(format string, args ...any) error {
+	fmt.Fprintf(o.flags.Output(), format+"\n", args...)
+	o.flags.Usage()
+
+	return errUsage
+}
+
+// args возвращает ровно n позиционных аргументов
+func (o *options) args(n int) ([]string, error) {
+	if len(o.positional) != n {
+		return nil, o.usageError("expected %d argument(s), got %d", n, len(o.positional))
+	}
+
+	return o.positional, nil
+}
+
+func (o *options) config() (*config.Config, error) {
+	cfg, err := config.Load(o.sources)
+	if err != nil {
+		return nil, &configError{err: err}
+	}
+
+	return cfg, nil
+}
+
+// open читает настройки и собирает сервисы так же, как сервер
+func (o *options) open() (*app.Services, error) {
+	cfg, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+
+	return app.NewServices(cfg, o.logger(), clock.System)
+}
+
+// logger возвращает журнал сервисов. Без -v журналы сервисов и gorm отключены: ошибки команда
+// выводит сама, а stdout остается только для результата
+func (o *options) logger() *slog.Logger {
+	if !o.verbose {
+		gormlogger.Default = gormlogger.Default.LogMode(gormlogger.Silent)
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	gormlogger.Default = gormlogger.New(log.New(os.Stderr, "", log.LstdFlags), gormlogger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  gormlogger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+func parseID(value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid id %q: must be a positive integer", value)
+	}
+
+	return uint(id), nil
+}
+
+func closeServices(services io.Closer) {
+	if err := services.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "close storage:", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// dateLayout - формат дат в аргументах и выводе, как в API
+const dateLayout = "02-01-2006"
+
+// printJSON выводит value с отступами. Записи выводятся в том же виде, что и в ответах API
+func printJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
+
+func printRecords(w io.Writer, output string, records []entity.Record) error {
+	if output == outputJSON {
+		if records == nil {
+			records = []entity.Record{}
+		}
+		return printJSON(w, records)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tUSER\tSERVICE\tCATEGORY\tPRICE\tSTART\tEXPIRES")
+
+	for _, record := range records {
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			record.ID,
+			record.UserID,
+			record.ServiceName,
+			record.Category,
+			record.Price,
+			record.CreatedAt.Format(dateLayout),
+			record.ExpiresAt.Format(dateLayout))
+	}
+
+	return table.Flush()
+}
+
+// printWarnings выводит предупреждения сервиса (превышение бюджета, пересечения) в stderr
+func printWarnings(warnings []services.Warning) {
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning.Message)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"os"
+	"time"
+)
+
+func recordsList(ctx context.Context, args []string) error {
+	o := newOptions("records list", "", true)
+	limit := o.flags.Int("limit", 50, "maximum number of records, 0 - all")
+	offset := o.flags.Int("offset", 0, "number of records to skip")
+	userID := o.flags.String("user", "", "filter by user id")
+	serviceName := o.flags.String("service", "", "filter by service name")
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if _, err := o.args(0); err != nil {
+		return err
+	}
+	if *limit < 0 || *offset < 0 {
+		return o.usageError("-limit and -offset must not be negative")
+	}
+
+	svc, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer closeServices(svc)
+
+	records, err := svc.Records.ListRecords(ctx, *limit, *offset, *userID, *serviceName)
+	if err != nil {
+		return err
+	}
+
+	return printRecords(os.Stdout, o.output, records)
+}
+
+func recordsGet(ctx context.Context, args []string) error {
+	o := newOptions("records get", "<id>", true)
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	positional, err := o.args(1)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(positional[0])
+	if err != nil {
+		return o.usageError("%v", err)
+	}
+
+	svc, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer closeServices(svc)
+
+	record, err := svc.Records.GetRecordByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		return printJSON(os.Stdout, record)
+	}
+
+	return printRecords(os.Stdout, o.output, []entity.Record{*record})
+}
+
+func recordsCreate(ctx context.Context, args []string) error {
+	o := newOptions("records create", "", true)
+	serviceName := o.flags.String("service", "", "service name (required)")
+	price := o.flags.Int("price", 0, "monthly price in rubles (required)")
+	userID := o.flags.String("user", "", "user id, UUID (required)")
+	category := o.flags.String("category", "", "category used in budget limits")
+	expires := o.flags.String("expires", "", "expiration date DD-MM-YYYY (required)")
+	created := o.flags.String("created", "", "start date DD-MM-YYYY, default today")
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if _, err := o.args(0); err != nil {
+		return err
+	}
+	if *serviceName == "" || !o.isSet("price") || *userID == "" || *expires == "" {
+		return o.usageError("-service, -price, -user and -expires are required")
+	}
+
+	record := entity.Record{
+		ServiceName: *serviceName,
+		Price:       *price,
+		UserID:      *userID,
+		Category:    *category,
+	}
+
+	var err error
+
+	record.ExpiresAt, err = time.Parse(dateLayout, *expires)
+	if err != nil {
+		return o.usageError("-expires must be a date in DD-MM-YYYY format")
+	}
+
+	if *created != "" {
+		record.CreatedAt, err = time.Parse(dateLayout, *created)
+		if err != nil {
+			return o.usageError("-created must be a date in DD-MM-YYYY format")
+		}
+	}
+
+	svc, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer closeServices(svc)
+
+	result, err := svc.Records.CreateRecord(ctx, &record)
+	if err != nil {
+		return err
+	}
+
+	printWarnings(result.Warnings)
+
+	if result.Merged {
+		fmt.Fprintln(os.Stderr, "merged into existing record", result.Record.ID)
+	}
+
+	if o.output == outputJSON {
+		return printJSON(os.Stdout, result.Record)
+	}
+
+	return printRecords(os.Stdout, o.output, []entity.Record{*result.Record})
+}
+
+func recordsDelete(ctx context.Context, args []string) error {
+	o := newOptions("records delete", "<id>...", false)
+	atomic := o.flags.Bool("atomic", false, "delete all records or none")
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if len(o.positional) == 0 {
+		return o.usageError("at least one id is required")
+	}
+
+	ids := make([]uint, 0, len(o.positional))
+	for _, arg := range o.positional {
+		id, err := parseID(arg)
+		if err != nil {
+			return o.usageError("%v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	svc, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer closeServices(svc)
+
+	result, err := svc.Records.DeleteRecords(ctx, ids, *atomic)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, item := range result.Items {
+		// в прерванном атомарном пакете ошибка есть у каждого элемента
+		if item.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%d: %v\n", ids[item.Index], item.Err)
+			continue
+		}
+
+		fmt.Printf("%d: deleted\n", ids[item.Index])
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d records were not deleted", failed, len(ids))
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// memoryConfig записывает настройки с хранилищем в памяти и возвращает путь к файлу
+func memoryConfig(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("http:\n  port: \":0\"\nstorage:\n  driver: memory\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestRecordsCreateRequiresPrice(t *testing.T) {
+	expires := time.Now().AddDate(0, 1, 0).Format(dateLayout)
+	args := []string{"-config", memoryConfig(t), "-service", "Netflix", "-user", "60601fee-2bf1-4721-ae6f-7636e79a0cba", "-expires", expires, "-o", "json"}
+
+	if err := recordsCreate(context.Background(), args); !errors.Is(err, errUsage) {
+		t.Fatalf("without -price: expected usage error, got %v", err)
+	}
+
+	// явная цена 0 - бесплатная подписка, а не пропущенный флаг
+	if err := recordsCreate(context.Background(), append(args, "-price", "0")); err != nil {
+		t.Fatalf("with -price 0: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+func summary(ctx context.Context, args []string) error {
+	o := newOptions("summary", "", true)
+	from := o.flags.String("from", "", "period start DD-MM-YYYY (required)")
+	to := o.flags.String("to", "", "period end DD-MM-YYYY (required)")
+	userID := o.flags.String("user", "", "filter by user id")
+	serviceName := o.flags.String("service", "", "filter by service name")
+	source := o.flags.String("source", "records", "records - prices of records started in the period, ledger - ledger charges")
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if _, err := o.args(0); err != nil {
+		return err
+	}
+	if *source != "records" && *source != "ledger" {
+		return o.usageError("-source must be records or ledger, got %q", *source)
+	}
+
+	startTime, err := time.Parse(dateLayout, *from)
+	if err != nil {
+		return o.usageError("-from must be a date in DD-MM-YYYY format")
+	}
+
+	endTime, err := time.Parse(dateLayout, *to)
+	if err != nil {
+		return o.usageError("-to must be a date in DD-MM-YYYY format")
+	}
+
+	if endTime.Before(startTime) {
+		return o.usageError("-to must not be before -from")
+	}
+
+	svc, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer closeServices(svc)
+
+	sum := svc.Records.SummaryPriceOfSelectedRecords
+	if *source == "ledger" {
+		if svc.Ledger == nil {
+			return errors.New("ledger requires postgres storage")
+		}
+		sum = svc.Ledger.SumForPeriod
+	}
+
+	total, err := sum(ctx, startTime, endTime, *userID, *serviceName)
+	if err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		return printJSON(os.Stdout, map[string]int{"total_price": total})
+	}
+
+	fmt.Println(total)
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/exporter"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/importer"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// importRow - строка отчета импорта в выводе json, поля как в ответе /v1/subscriptions/import
+type importRow struct {
+	Line     int      `json:"line"`
+	Action   string   `json:"action"`
+	RecordID uint     `json:"record_id,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type importReport struct {
+	DryRun    bool        `json:"dry_run"`
+	Total     int         `json:"total"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Merged    int         `json:"merged"`
+	Unchanged int         `json:"unchanged"`
+	Failed    int         `json:"failed"`
+	Rows      []importRow `json:"rows"`
+}
+
+func importRecords(ctx context.Context, args []string) error {
+	o := newOptions("import", "<file>", true)
+	formatName := o.flags.String("format", "", "file format: csv or xlsx, default by extension")
+	sheet := o.flags.String("sheet", "", "XLSX sheet, default first")
+	delimiter := o.flags.String("delimiter", "", "CSV delimiter, default comma")
+	mappingJSON := o.flags.String("mapping", "", `field to column mapping as JSON, e.g. {"price": "Стоимость"}`)
+	dryRun := o.flags.Bool("dry-run", false, "only check the file, save nothing")
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	positional, err := o.args(1)
+	if err != nil {
+		return err
+	}
+	path := positional[0]
+
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		return o.usageError("-format must be csv or xlsx")
+	}
+
+	mapping := importer.DefaultColumnMapping()
+	if *mappingJSON != "" {
+		if err := json.Unmarshal([]byte(*mappingJSON), &mapping); err != nil {
+			return o.usageError("-mapping must be a JSON object of field to column name")
+		}
+	}
+
+	var separator rune
+	if *delimiter != "" {
+		if utf8.RuneCountInString(*delimiter) != 1 {
+			return o.usageError("-delimiter must be a single character")
+		}
+		separator, _ = utf8.DecodeRuneInString(*delimiter)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader importer.RowReader
+
+	switch format {
+	case importer.FormatXLSX:
+		reader, err = importer.NewXLSXReader(file, *sheet)
+		if err != nil {
+			return err
+		}
+	default:
+		reader = importer.NewCSVReader(file, separator)
+	}
+	defer reader.Close()
+
+	svc, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer closeServices(svc)
+
+	report, err := svc.Importer.Import(ctx, reader, mapping, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if err := printImportReport(os.Stdout, o.output, report); err != nil {
+		return err
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", report.Failed, report.Total)
+	}
+
+	return nil
+}
+
+// printImportReport выводит итог импорта. В таблице перечисляются только строки с ошибками
+// и предупреждениями, в json - все строки
+func printImportReport(w io.Writer, output string, report *importer.Report) error {
+	result := importReport{
+		DryRun:    report.DryRun,
+		Total:     report.Total,
+		Created:   report.Created,
+		Updated:   report.Updated,
+		Merged:    report.Merged,
+		Unchanged: report.Unchanged,
+		Failed:    report.Failed,
+		Rows:      make([]importRow, 0, len(report.Rows)),
+	}
+
+	for _, row := range report.Rows {
+		item := importRow{Line: row.Line, Action: string(row.Action), RecordID: row.RecordID}
+
+		for _, warning := range row.Warnings {
+			item.Warnings = append(item.Warnings, warning.Message)
+		}
+
+		if row.Err != nil {
+			item.Error = row.Err.Error()
+		}
+
+		result.Rows = append(result.Rows, item)
+	}
+
+	if output == outputJSON {
+		return printJSON(w, result)
+	}
+
+	if result.DryRun {
+		fmt.Fprint(w, "dry run: ")
+	}
+
+	fmt.Fprintf(w, "total %d, created %d, updated %d, merged %d, unchanged %d, failed %d\n",
+		result.Total, result.Created, result.Updated, result.Merged, result.Unchanged, result.Failed)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := false
+
+	for _, row := range result.Rows {
+		if row.Error == "" && len(row.Warnings) == 0 {
+			continue
+		}
+
+		if !header {
+			fmt.Fprintln(table, "LINE\tACTION\tMESSAGE")
+			header = true
+		}
+
+		message := row.Error
+		if message == "" {
+			message = "warning: " + strings.Join(row.Warnings, "; ")
+		}
+
+		fmt.Fprintf(table, "%d\t%s\t%s\n", row.Line, row.Action, message)
+	}
+
+	return table.Flush()
+}
+
+func exportRecords(ctx context.Context, args []string) (err error) {
+	o := newOptions("export", "", false)
+	formatName := o.flags.String("format", "", "file format: csv, jsonl or xlsx, default by -file extension or csv")
+	path := o.flags.String("file", "", "output file, default stdout")
+	userID := o.flags.String("user", "", "filter by user id")
+	serviceName := o.flags.String("service", "", "filter by service name")
+
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if _, err := o.args(0); err != nil {
+		return err
+	}
+
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(*path), ".")
+	}
+	if *formatName == "" {
+		*formatName = string(exporter.FormatCSV)
+	}
+
+	format, err := exporter.ParseFormat(*formatName)
+	if err != nil {
+		return o.usageError("-format must be csv, jsonl or xlsx")
+	}
+
+	if *path == "" && format == exporter.FormatXLSX {
+		return o.usageError("-file is required for xlsx")
+	}
+
+	svc, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer closeServices(svc)
+
+	out := io.Writer(os.Stdout)
+
+	if *path != "" {
+		file, err := os.Create(*path)
+		if err != nil {
+			return err
+		}
+
+		// недописанный файл удаляется, чтобы его не приняли за полную выгрузку
+		defer func() {
+			err = errors.Join(err, file.Close())
+			if err != nil {
+				os.Remove(*path)
+			}
+		}()
+
+		out = file
+	}
+
+	writer, err := exporter.NewWriter(format, out)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+
+	err = svc.Records.ExportRecords(ctx, *userID, *serviceName, func(record *entity.Record) error {
+		count++
+		return writer.WriteRecord(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	if *path != "" {
+		fmt.Fprintf(os.Stderr, "exported %d records to %s\n", count, *path)
+	}
+
+	return nil
+}
@@ -15,353 +15,309 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/create": {
+        "/aggregates/check": {
             "post": {
-                "description": "Создает новую запись онлайн подписки",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
                 ],
+                "description": "Пересчитывает месячные агрегаты по записям и возвращает расхождения с сохраненными.\nС repair=true расходящиеся пары пользователь/сервис пересчитываются",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Подписки"
+                    "Аналитика"
                 ],
-                "summary": "Создать запись подписки",
+                "summary": "Сверка агрегатов",
+                "deprecated": true,
                 "parameters": [
                     {
-                        "description": "Данные подписки",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.RecordCreateUpdateRequest"
-                        }
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Исправить расхождения",
+                        "name": "repair",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Созданная запись",
+                    "200": {
+                        "description": "Результат сверки",
                         "schema": {
-                            "$ref": "#/definitions/entity.Record"
+                            "$ref": "#/definitions/handlers.AggregateCheckResponse"
                         }
                     },
-                    "400": {
-                        "description": "Неверный формат данных",
+                    "401": {
+                        "description": "Нужен токен администратора",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             }
         },
-        "/delete/{id}": {
-            "delete": {
-                "description": "Удаляет запись подписки по указанному ID",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Подписки"
-                ],
-                "summary": "Удалить запись подписки",
-                "parameters": [
+        "/aggregates/rebuild": {
+            "post": {
+                "security": [
                     {
-                        "type": "integer",
-                        "example": 1,
-                        "description": "ID записи",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "AdminToken": []
                     }
                 ],
+                "description": "Строит месячные агрегаты заново по всем записям. На время построения изменения записей ждут его завершения",
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Перестроение агрегатов",
+                "deprecated": true,
                 "responses": {
                     "204": {
-                        "description": "Запись успешно удалена"
-                    },
-                    "400": {
-                        "description": "Неверный ID",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
+                        "description": "Агрегаты построены"
                     },
-                    "404": {
-                        "description": "Запись не найдена",
+                    "401": {
+                        "description": "Нужен токен администратора",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             }
         },
-        "/record/user_service": {
+        "/analytics/churn": {
             "get": {
-                "description": "Возвращает конкретную подписку пользователя по названию сервиса",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Завершившейся считается подписка, срок действия которой истек в этом месяце, но не позже сегодняшнего дня",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Подписки"
+                    "Аналитика"
                 ],
-                "summary": "Найти подписку пользователя",
+                "summary": "Новые и завершившиеся подписки",
+                "deprecated": true,
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "user123",
-                        "description": "ID пользователя",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
                         "name": "user_id",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "string",
                         "example": "Netflix",
-                        "description": "Название сервиса",
+                        "description": "Фильтр по названию сервиса",
                         "name": "service_name",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Запись подписки",
+                        "description": "Подписки по месяцам",
                         "schema": {
-                            "$ref": "#/definitions/entity.Record"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.ChurnPointResponse"
+                            }
                         }
                     },
                     "400": {
                         "description": "Неверные параметры",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
-                    "404": {
-                        "description": "Запись не найдена",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             }
         },
-        "/record/{id}": {
+        "/analytics/cohorts": {
             "get": {
-                "description": "Возвращает запись подписки по указанному ID",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Подписки группируются по месяцу начала. Подписка удержана через N месяцев, если действует на конец N-го месяца после начала когорты",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Подписки"
+                    "Аналитика"
                 ],
-                "summary": "Получить запись подписки",
+                "summary": "Когорты по месяцу начала",
+                "deprecated": true,
                 "parameters": [
                     {
-                        "type": "integer",
-                        "example": 1,
-                        "description": "ID записи",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "type": "string",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Запись подписки",
-                        "schema": {
-                            "$ref": "#/definitions/entity.Record"
-                        }
-                    },
-                    "400": {
-                        "description": "Неверный ID",
+                        "description": "Когорты",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.CohortResponse"
                             }
                         }
                     },
-                    "404": {
-                        "description": "Запись не найдена",
+                    "400": {
+                        "description": "Неверные параметры",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             }
         },
-        "/records": {
+        "/analytics/lifetime": {
             "get": {
-                "description": "Возвращает список подписок с фильтрацией и пагинацией",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Средний и медианный срок жизни в днях подписок, начавшихся в периоде. Для действующих подписок срок считается по сегодняшний день",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Подписки"
+                    "Аналитика"
                 ],
-                "summary": "Список подписок",
+                "summary": "Срок жизни подписок",
+                "deprecated": true,
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "user123",
-                        "description": "Фильтр по ID пользователя",
-                        "name": "user_id",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "example": "Netflix",
-                        "description": "Фильтр по названию сервиса",
-                        "name": "service_name",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
                         "in": "query"
                     },
                     {
-                        "maximum": 100,
-                        "minimum": 1,
-                        "type": "integer",
-                        "default": 20,
-                        "description": "Лимит записей (макс. 100)",
-                        "name": "limit",
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
                         "in": "query"
                     },
                     {
-                        "minimum": 0,
-                        "type": "integer",
-                        "default": 0,
-                        "description": "Смещение",
-                        "name": "offset",
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Список подписок",
+                        "description": "Итог и разбивка по сервисам",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/entity.Record"
-                            }
+                            "$ref": "#/definitions/handlers.LifetimeResponse"
                         }
                     },
                     "400": {
                         "description": "Неверные параметры",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             }
         },
-        "/records/summary": {
+        "/analytics/mrr": {
             "get": {
-                "description": "Возвращает сумму платежей за указанный период с возможностью фильтрации",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Ежемесячная выручка - сумма цен подписок, действующих на последний день месяца, и ее изменение к предыдущему месяцу",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Аналитика"
                 ],
-                "summary": "Сумма платежей за период",
+                "summary": "MRR по месяцам",
+                "deprecated": true,
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "01-01-2023",
-                        "description": "Начальная дата (DD-MM-YYYY)",
-                        "name": "start_time",
-                        "in": "query",
-                        "required": true
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "example": "31-12-2023",
-                        "description": "Конечная дата (DD-MM-YYYY)",
-                        "name": "end_time",
-                        "in": "query",
-                        "required": true
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "example": "user123",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
                         "description": "Фильтр по ID пользователя",
                         "name": "user_id",
                         "in": "query"
@@ -376,176 +332,5728 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "{\"total_price\": 1500}",
+                        "description": "Выручка по месяцам",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.MRRPointResponse"
                             }
                         }
                     },
                     "400": {
                         "description": "Неверные параметры",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             }
         },
-        "/records/user": {
+        "/analytics/top-services": {
             "get": {
-                "description": "Возвращает список подписок для указанного пользователя",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Расходы сервиса - сумма его MRR по месяцам периода, подписчики - уникальные пользователи",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Подписки"
+                    "Аналитика"
                 ],
-                "summary": "Получить подписки пользователя",
+                "summary": "Топ сервисов",
+                "deprecated": true,
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "user123",
-                        "description": "ID пользователя",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
                         "name": "user_id",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "spend",
+                            "subscribers"
+                        ],
+                        "type": "string",
+                        "default": "spend",
+                        "description": "Сортировка",
+                        "name": "by",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Количество сервисов (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Список подписок",
+                        "description": "Сервисы",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/entity.Record"
+                                "$ref": "#/definitions/analytics.ServiceRank"
                             }
                         }
                     },
                     "400": {
-                        "description": "Неверный ID пользователя",
+                        "description": "Неверные параметры",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             }
         },
-        "/update/{id}": {
-            "put": {
-                "description": "Обновляет существующую запись подписки",
-                "consumes": [
-                    "application/json"
+        "/budgets": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Подписки"
+                    "Бюджеты"
                 ],
-                "summary": "Обновить запись подписки",
+                "summary": "Список бюджетов",
+                "deprecated": true,
                 "parameters": [
                     {
+                        "maximum": 100,
+                        "minimum": 1,
                         "type": "integer",
-                        "example": 1,
-                        "description": "ID записи",
-                        "name": "id",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Бюджеты",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.BudgetResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Задает месячный лимит расходов пользователя на подписки и, при необходимости, лимиты по категориям.\nС enforce=true создание подписки, после которого прогноз расходов превысит лимит, отклоняется",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Создание бюджета",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Бюджет",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Бюджет создан",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Бюджет пользователя уже существует",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/budgets/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Бюджет пользователя",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Бюджет",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Бюджет не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
                     },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
                     {
-                        "description": "Данные для обновления",
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Заменяет лимиты бюджета целиком: категории, не переданные в category_limits, удаляются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Изменение бюджета",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Лимиты",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.RecordCreateUpdateRequest"
+                            "$ref": "#/definitions/handlers.BudgetUpdateRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Запись успешно обновлена"
+                    "200": {
+                        "description": "Бюджет изменен",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetResponse"
+                        }
                     },
                     "400": {
                         "description": "Неверные данные",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "404": {
-                        "description": "Запись не найдена",
+                        "description": "Бюджет не найден",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Удаление бюджета",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Бюджет удален"
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Бюджет не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/budgets/{user_id}/status": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Прогноз расходов за месяц - сумма цен подписок, списание по которым приходится на этот месяц",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Состояние бюджета",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "03-2024",
+                        "description": "Месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "period",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Прогноз и лимиты",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Бюджет не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/create": {
+            "post": {
+                "description": "Создает новую запись онлайн подписки",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Создать запись подписки",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RecordCreateUpdateRequest"
                         }
                     }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Запись объединена с пересекающейся подпиской (overlap.policy=merge)",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        }
+                    },
+                    "201": {
+                        "description": "Созданная запись",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        },
+                        "headers": {
+                            "Warning": {
+                                "type": "string",
+                                "description": "Предупреждение о превышении бюджета пользователя"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат данных",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка пересекается с существующей (overlap.policy=reject) или превышает бюджет с enforce=true",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/delete/{id}": {
+            "delete": {
+                "description": "Удаляет запись подписки по указанному ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Удалить запись подписки",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Запись успешно удалена"
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ledger": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Журнал начислений"
+                ],
+                "summary": "Журнал начислений",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "01-01-2024",
+                        "description": "Дата списания от (DD-MM-YYYY)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "31-12-2024",
+                        "description": "Дата списания до (DD-MM-YYYY)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Строки журнала, новые первыми",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.LedgerEntryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ledger/adjustments": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Добавляет в журнал корректировку начисления подписки за месяц. Существующие строки журнала не меняются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Журнал начислений"
+                ],
+                "summary": "Корректировка начисления",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Корректировка",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LedgerAdjustmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Корректировка записана",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LedgerEntryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ledger/backfill": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Записывает недостающие начисления за месяцы с from по to включительно и корректировки\nдля записей, измененных после начисления. Повторный запуск ничего не дублирует, будущие списания не начисляются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Журнал начислений"
+                ],
+                "summary": "Заполнение журнала за период",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Период",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LedgerBackfillRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сколько строк добавлено",
+                        "schema": {
+                            "$ref": "#/definitions/services.MaterializeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/outbox/stats": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Для каждого приемника: последнее опубликованное событие, число ожидающих и возраст самого старого из них",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Отставание публикации событий",
+                "deprecated": true,
+                "responses": {
+                    "200": {
+                        "description": "Состояние outbox",
+                        "schema": {
+                            "$ref": "#/definitions/outbox.Stats"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/record/user_service": {
+            "get": {
+                "description": "Возвращает подписку пользователя по названию сервиса, при нескольких - самую позднюю. Устарело: используйте GET /v1/subscriptions?user_id=\u0026service_name=",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Найти подписку пользователя",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Запись подписки",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/record/{id}": {
+            "get": {
+                "description": "Возвращает запись подписки по указанному ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Получить запись подписки",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Запись подписки",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/record/{id}/price-changes": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Изменения цены подписки",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменения цены по дате вступления в силу",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.PriceChangeResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Новая цена действует для списаний начиная с effective_from и учитывается в прогнозе расходов",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Запланировать изменение цены",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая цена",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PriceChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Изменение цены запланировано",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PriceChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Изменение цены на эту дату уже запланировано",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/record/{id}/price-changes/{change_id}": {
+            "delete": {
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Отменить изменение цены",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID изменения цены",
+                        "name": "change_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Изменение цены отменено"
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Изменение цены не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/records": {
+            "get": {
+                "description": "Возвращает список подписок с фильтрацией и пагинацией",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Список подписок",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список подписок",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Record"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/records/export": {
+            "get": {
+                "description": "Выгружает все подписки, подходящие под фильтры /records, без пагинации.\nЗаписи читаются из БД курсором и отдаются потоком. Колонки CSV и XLSX совпадают с форматом импорта",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Выгрузка подписок",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Формат файла",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Файл с подписками",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/records/forecast": {
+            "get": {
+                "description": "Прогнозирует расходы по месяцам по активным подпискам с учетом срока действия и запланированных изменений цен.\nС auto_renew=true подписки считаются продленными после окончания по последней известной цене",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Прогноз расходов",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "03-2024",
+                        "description": "Первый месяц прогноза (MM-YYYY), по умолчанию текущий",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 60,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 12,
+                        "description": "Количество месяцев (макс. 60)",
+                        "name": "months",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Считать подписки продленными после окончания",
+                        "name": "auto_renew",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Прогноз",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ForecastResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/records/import": {
+            "post": {
+                "description": "Импортирует подписки из CSV или XLSX. Строки проходят ту же валидацию, что и при создании записи.\nПовторный импорт идемпотентен: запись с теми же user_id, service_name и created_at обновляется.\nmapping - JSON вида {\"service_name\": \"Сервис\", \"price\": \"Стоимость\", ...}, по умолчанию колонки называются как поля API",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Импорт подписок из файла",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV или XLSX файл",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "description": "Формат файла, по умолчанию определяется по расширению",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Соответствие полей колонкам файла (JSON)",
+                        "name": "mapping",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Лист XLSX, по умолчанию первый",
+                        "name": "sheet",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "default": ",",
+                        "description": "Разделитель CSV",
+                        "name": "delimiter",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Только проверить файл, ничего не сохраняя",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Отчет об импорте",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный файл или параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/records/summary": {
+            "get": {
+                "description": "Возвращает сумму платежей за указанный период, параметры передаются в теле GET-запроса. Устарело: используйте GET /v1/subscriptions/summary",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Сумма платежей за период",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Период и фильтры",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SumPeriodQuery"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сумма платежей",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/records/user": {
+            "get": {
+                "description": "Возвращает список подписок для указанного пользователя. Устарело: используйте GET /v1/subscriptions?user_id=",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Получить подписки пользователя",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список подписок",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Record"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/records:batch": {
+            "post": {
+                "description": "Создает записи пакетом. По умолчанию атомарно в одной транзакции, с atomic=false каждая запись обрабатывается отдельно",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Пакетное создание подписок",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Применить пакет в одной транзакции",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Записи подписок",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Все записи созданы",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Часть записей не создана (atomic=false)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Пакет отклонен: ошибка в одной из записей (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Пакет отклонен: пересечение подписок (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет записи по списку ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Пакетное удаление подписок",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Применить пакет в одной транзакции",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "ID записей",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchDeleteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Все записи удалены",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Часть записей не удалена (atomic=false)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Пакет отклонен: запись не найдена (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Частично обновляет записи пакетом, в каждом элементе меняются только переданные поля",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Пакетное обновление подписок",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Применить пакет в одной транзакции",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Изменения записей",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchPatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Все записи обновлены",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Часть записей не обновлена (atomic=false)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Пакет отклонен: ошибка в одной из записей (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Пакет отклонен: запись не найдена (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/update/{id}": {
+            "put": {
+                "description": "Заменяет данные существующей записи подписки. Без created_at дата начала остается прежней",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Обновить запись подписки",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Данные для обновления",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RecordCreateUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Запись успешно обновлена"
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка пересекается с существующей",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}/calendar.ics": {
+            "get": {
+                "description": "Лента iCalendar с датами окончания подписок и ежемесячных списаний. UID событий стабильны, календарь обновляет их на месте",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "Календарь"
+                ],
+                "summary": "Лента календаря",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен ленты",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Лента iCalendar",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Токен неверный или отозван",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}/calendar/token": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Возвращает подписанный токен и ссылку на ленту календаря пользователя. Повторный вызов возвращает тот же токен, пока он не отозван",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Календарь"
+                ],
+                "summary": "Токен ленты календаря",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Токен ленты",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FeedTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Отзывает все выданные токены ленты пользователя, ранее добавленные в календари ссылки перестают работать",
+                "tags": [
+                    "Календарь"
+                ],
+                "summary": "Отзыв токена ленты календаря",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Токены отозваны"
+                    },
+                    "400": {
+                        "description": "Неверный ID пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/aggregates/check": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Пересчитывает месячные агрегаты по записям и возвращает расхождения с сохраненными.\nС repair=true расходящиеся пары пользователь/сервис пересчитываются",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Сверка агрегатов",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Исправить расхождения",
+                        "name": "repair",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат сверки",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AggregateCheckResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/aggregates/rebuild": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Строит месячные агрегаты заново по всем записям. На время построения изменения записей ждут его завершения",
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Перестроение агрегатов",
+                "responses": {
+                    "204": {
+                        "description": "Агрегаты построены"
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/analytics/churn": {
+            "get": {
+                "description": "Завершившейся считается подписка, срок действия которой истек в этом месяце, но не позже сегодняшнего дня",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Новые и завершившиеся подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписки по месяцам",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.ChurnPointResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/analytics/cohorts": {
+            "get": {
+                "description": "Подписки группируются по месяцу начала. Подписка удержана через N месяцев, если действует на конец N-го месяца после начала когорты",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Когорты по месяцу начала",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Когорты",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.CohortResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/analytics/lifetime": {
+            "get": {
+                "description": "Средний и медианный срок жизни в днях подписок, начавшихся в периоде. Для действующих подписок срок считается по сегодняшний день",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Срок жизни подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Итог и разбивка по сервисам",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LifetimeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/analytics/mrr": {
+            "get": {
+                "description": "Ежемесячная выручка - сумма цен подписок, действующих на последний день месяца, и ее изменение к предыдущему месяцу",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "MRR по месяцам",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Выручка по месяцам",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.MRRPointResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/analytics/top-services": {
+            "get": {
+                "description": "Расходы сервиса - сумма его MRR по месяцам периода, подписчики - уникальные пользователи",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Топ сервисов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-2024",
+                        "description": "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2024",
+                        "description": "Последний месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "spend",
+                            "subscribers"
+                        ],
+                        "type": "string",
+                        "default": "spend",
+                        "description": "Сортировка",
+                        "name": "by",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Количество сервисов (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сервисы",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/analytics.ServiceRank"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/budgets": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Список бюджетов",
+                "parameters": [
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Бюджеты",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.BudgetResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Задает месячный лимит расходов пользователя на подписки и, при необходимости, лимиты по категориям.\nС enforce=true создание подписки, после которого прогноз расходов превысит лимит, отклоняется",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Создание бюджета",
+                "parameters": [
+                    {
+                        "description": "Бюджет",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Бюджет создан",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Бюджет пользователя уже существует",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/budgets/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Бюджет пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Бюджет",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Бюджет не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Заменяет лимиты бюджета целиком: категории, не переданные в category_limits, удаляются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Изменение бюджета",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Лимиты",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Бюджет изменен",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Бюджет не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Удаление бюджета",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Бюджет удален"
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Бюджет не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/budgets/{user_id}/status": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Прогноз расходов за месяц - сумма цен подписок, списание по которым приходится на этот месяц",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Бюджеты"
+                ],
+                "summary": "Состояние бюджета",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "03-2024",
+                        "description": "Месяц (MM-YYYY), по умолчанию текущий",
+                        "name": "period",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Прогноз и лимиты",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BudgetStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Бюджет не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/ledger": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Журнал начислений"
+                ],
+                "summary": "Журнал начислений",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "01-01-2024",
+                        "description": "Дата списания от (DD-MM-YYYY)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "31-12-2024",
+                        "description": "Дата списания до (DD-MM-YYYY)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Строки журнала, новые первыми",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.LedgerEntryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/ledger/adjustments": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Добавляет в журнал корректировку начисления подписки за месяц. Существующие строки журнала не меняются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Журнал начислений"
+                ],
+                "summary": "Корректировка начисления",
+                "parameters": [
+                    {
+                        "description": "Корректировка",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LedgerAdjustmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Корректировка записана",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LedgerEntryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/ledger/backfill": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Записывает недостающие начисления за месяцы с from по to включительно и корректировки\nдля записей, измененных после начисления. Повторный запуск ничего не дублирует, будущие списания не начисляются",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Журнал начислений"
+                ],
+                "summary": "Заполнение журнала за период",
+                "parameters": [
+                    {
+                        "description": "Период",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LedgerBackfillRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сколько строк добавлено",
+                        "schema": {
+                            "$ref": "#/definitions/services.MaterializeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/outbox/stats": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Для каждого приемника: последнее опубликованное событие, число ожидающих и возраст самого старого из них",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Отставание публикации событий",
+                "responses": {
+                    "200": {
+                        "description": "Состояние outbox",
+                        "schema": {
+                            "$ref": "#/definitions/outbox.Stats"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions": {
+            "get": {
+                "description": "Возвращает список подписок с фильтрацией и пагинацией",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Список подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список подписок",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Record"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Создает новую запись онлайн подписки",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Создать запись подписки",
+                "parameters": [
+                    {
+                        "description": "Данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RecordCreateUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Запись объединена с пересекающейся подпиской (overlap.policy=merge)",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        }
+                    },
+                    "201": {
+                        "description": "Созданная запись",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        },
+                        "headers": {
+                            "Warning": {
+                                "type": "string",
+                                "description": "Предупреждение о превышении бюджета пользователя"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат данных",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка пересекается с существующей (overlap.policy=reject) или превышает бюджет с enforce=true",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/export": {
+            "get": {
+                "description": "Выгружает все подписки, подходящие под фильтры /records, без пагинации.\nЗаписи читаются из БД курсором и отдаются потоком. Колонки CSV и XLSX совпадают с форматом импорта",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Выгрузка подписок",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Формат файла",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Файл с подписками",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/forecast": {
+            "get": {
+                "description": "Прогнозирует расходы по месяцам по активным подпискам с учетом срока действия и запланированных изменений цен.\nС auto_renew=true подписки считаются продленными после окончания по последней известной цене",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Прогноз расходов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "03-2024",
+                        "description": "Первый месяц прогноза (MM-YYYY), по умолчанию текущий",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 60,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 12,
+                        "description": "Количество месяцев (макс. 60)",
+                        "name": "months",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Считать подписки продленными после окончания",
+                        "name": "auto_renew",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Прогноз",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ForecastResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/import": {
+            "post": {
+                "description": "Импортирует подписки из CSV или XLSX. Строки проходят ту же валидацию, что и при создании записи.\nПовторный импорт идемпотентен: запись с теми же user_id, service_name и created_at обновляется.\nmapping - JSON вида {\"service_name\": \"Сервис\", \"price\": \"Стоимость\", ...}, по умолчанию колонки называются как поля API",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Импорт подписок из файла",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV или XLSX файл",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "description": "Формат файла, по умолчанию определяется по расширению",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Соответствие полей колонкам файла (JSON)",
+                        "name": "mapping",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Лист XLSX, по умолчанию первый",
+                        "name": "sheet",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "default": ",",
+                        "description": "Разделитель CSV",
+                        "name": "delimiter",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Только проверить файл, ничего не сохраняя",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Отчет об импорте",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный файл или параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/summary": {
+            "get": {
+                "description": "Возвращает сумму платежей за указанный период с возможностью фильтрации",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Аналитика"
+                ],
+                "summary": "Сумма платежей за период",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-01-2023",
+                        "description": "Начальная дата (DD-MM-YYYY)",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "31-12-2023",
+                        "description": "Конечная дата (DD-MM-YYYY)",
+                        "name": "end_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Netflix",
+                        "description": "Фильтр по названию сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "records",
+                            "ledger"
+                        ],
+                        "type": "string",
+                        "default": "records",
+                        "description": "Источник: records - цены записей, созданных в периоде; ledger - начисления и корректировки из журнала с датой списания в периоде",
+                        "name": "source",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сумма платежей",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SumPeriodResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/{id}": {
+            "get": {
+                "description": "Возвращает запись подписки по указанному ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Получить запись подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Запись подписки",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Заменяет данные существующей записи подписки. Без created_at дата начала остается прежней",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Обновить запись подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Данные для обновления",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RecordCreateUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Запись успешно обновлена"
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка пересекается с существующей",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет запись подписки по указанному ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Удалить запись подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Запись успешно удалена"
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Меняет только переданные поля записи, остальные остаются прежними",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Частично обновить запись подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RecordPatchFields"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновленная запись",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Record"
+                        },
+                        "headers": {
+                            "Warning": {
+                                "type": "string",
+                                "description": "Предупреждение о пересечении с другой подпиской или превышении бюджета"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Подписка пересекается с существующей",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/{id}/price-changes": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Изменения цены подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменения цены по дате вступления в силу",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.PriceChangeResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Новая цена действует для списаний начиная с effective_from и учитывается в прогнозе расходов",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Запланировать изменение цены",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая цена",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PriceChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Изменение цены запланировано",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PriceChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Изменение цены на эту дату уже запланировано",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/{id}/price-changes/{change_id}": {
+            "delete": {
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Отменить изменение цены",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID записи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID изменения цены",
+                        "name": "change_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Изменение цены отменено"
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Изменение цены не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions:batch": {
+            "post": {
+                "description": "Создает записи пакетом. По умолчанию атомарно в одной транзакции, с atomic=false каждая запись обрабатывается отдельно",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Пакетное создание подписок",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Применить пакет в одной транзакции",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Записи подписок",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Все записи созданы",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Часть записей не создана (atomic=false)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Пакет отклонен: ошибка в одной из записей (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Пакет отклонен: пересечение подписок (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет записи по списку ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Пакетное удаление подписок",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Применить пакет в одной транзакции",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "ID записей",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchDeleteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Все записи удалены",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Часть записей не удалена (atomic=false)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Пакет отклонен: запись не найдена (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Частично обновляет записи пакетом, в каждом элементе меняются только переданные поля",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Пакетное обновление подписок",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Применить пакет в одной транзакции",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Изменения записей",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchPatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Все записи обновлены",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Часть записей не обновлена (atomic=false)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Пакет отклонен: ошибка в одной из записей (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Пакет отклонен: запись не найдена (atomic=true)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/users/{id}/calendar.ics": {
+            "get": {
+                "description": "Лента iCalendar с датами окончания подписок и ежемесячных списаний. UID событий стабильны, календарь обновляет их на месте",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "Календарь"
+                ],
+                "summary": "Лента календаря",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен ленты",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Лента iCalendar",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Токен неверный или отозван",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/users/{id}/calendar/token": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Возвращает подписанный токен и ссылку на ленту календаря пользователя. Повторный вызов возвращает тот же токен, пока он не отозван",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Календарь"
+                ],
+                "summary": "Токен ленты календаря",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Токен ленты",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FeedTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Отзывает все выданные токены ленты пользователя, ранее добавленные в календари ссылки перестают работать",
+                "tags": [
+                    "Календарь"
+                ],
+                "summary": "Отзыв токена ленты календаря",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Токены отозваны"
+                    },
+                    "400": {
+                        "description": "Неверный ID пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhook-deliveries/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Доставка по ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID доставки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Доставка, тело события и журнал попыток",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeliveryResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Доставка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhook-deliveries/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Сразу отправляет событие заново, в том числе успешно доставленное. При неудаче доставка снова повторяется по расписанию",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Повторная отправка",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID доставки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат отправки",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeliveryResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Доставка или вебхук не найдены",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Список вебхуков",
+                "responses": {
+                    "200": {
+                        "description": "Вебхуки",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.WebhookResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Подписывает URL на события записей: record.created, record.updated, record.deleted, record.expired.\nТело запроса подписывается HMAC-SHA256: заголовок X-Webhook-Signature: t=\u003cunix\u003e,v1=\u003chex HMAC(secret, \"\u003ct\u003e.\u003cbody\u003e\")\u003e",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Создание вебхука",
+                "parameters": [
+                    {
+                        "description": "Вебхук",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Вебхук создан, secret возвращается только сейчас",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Вебхук по ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Вебхук",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Удаляет вебхук вместе с историей доставок",
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Удаление вебхука",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Вебхук удален"
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Меняет только переданные поля. rotate_secret=true выдает новый секрет, старый сразу перестает действовать",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Изменение вебхука",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменения",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Вебхук изменен",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Доставки вебхука",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "succeeded",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Фильтр по статусу",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Доставки, новые первыми",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.DeliveryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhook-deliveries/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Доставка по ID",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID доставки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Доставка, тело события и журнал попыток",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeliveryResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Доставка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhook-deliveries/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Сразу отправляет событие заново, в том числе успешно доставленное. При неудаче доставка снова повторяется по расписанию",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Повторная отправка",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID доставки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат отправки",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeliveryResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Доставка или вебхук не найдены",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Список вебхуков",
+                "deprecated": true,
+                "responses": {
+                    "200": {
+                        "description": "Вебхуки",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.WebhookResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Подписывает URL на события записей: record.created, record.updated, record.deleted, record.expired.\nТело запроса подписывается HMAC-SHA256: заголовок X-Webhook-Signature: t=\u003cunix\u003e,v1=\u003chex HMAC(secret, \"\u003ct\u003e.\u003cbody\u003e\")\u003e",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Создание вебхука",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Вебхук",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Вебхук создан, secret возвращается только сейчас",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Вебхук по ID",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Вебхук",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Удаляет вебхук вместе с историей доставок",
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Удаление вебхука",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Вебхук удален"
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Меняет только переданные поля. rotate_secret=true выдает новый секрет, старый сразу перестает действовать",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Изменение вебхука",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменения",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Вебхук изменен",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные данные",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Вебхуки"
+                ],
+                "summary": "Доставки вебхука",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "succeeded",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Фильтр по статусу",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Лимит записей (макс. 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Доставки, новые первыми",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.DeliveryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Нужен токен администратора",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "analytics.Lifetime": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer"
+                },
+                "average_days": {
+                    "type": "number"
+                },
+                "median_days": {
+                    "type": "number"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "subscriptions": {
+                    "type": "integer"
+                }
+            }
+        },
+        "analytics.Retention": {
+            "type": "object",
+            "properties": {
+                "offset": {
+                    "type": "integer"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "retained": {
+                    "type": "integer"
+                }
+            }
+        },
+        "analytics.ServiceRank": {
+            "type": "object",
+            "properties": {
+                "service_name": {
+                    "type": "string"
+                },
+                "share": {
+                    "type": "number"
+                },
+                "spend": {
+                    "type": "integer"
+                },
+                "spend_rank": {
+                    "type": "integer"
+                },
+                "subscribers": {
+                    "type": "integer"
+                },
+                "subscribers_rank": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entity.Record": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "serviceName": {
+                    "type": "string"
+                },
+                "userID": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.AggregateCheckResponse": {
+            "type": "object",
+            "properties": {
+                "built": {
+                    "description": "агрегаты построены и используются отчетами",
+                    "type": "boolean"
+                },
+                "mismatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.AggregateMismatchResponse"
+                    }
+                },
+                "repaired": {
+                    "description": "сколько пар пользователь/сервис пересчитано",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.AggregateMismatchResponse": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "$ref": "#/definitions/handlers.AggregateValues"
+                },
+                "expected": {
+                    "$ref": "#/definitions/handlers.AggregateValues"
+                },
+                "month": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.AggregateValues": {
+            "type": "object",
+            "properties": {
+                "active_count": {
+                    "type": "integer"
+                },
+                "active_price": {
+                    "type": "integer"
+                },
+                "new_count": {
+                    "type": "integer"
+                },
+                "new_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.AttemptResponse": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "attempt": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status_code": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BatchCreateRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.RecordCreateUpdateRequest"
+                    }
+                }
+            }
+        },
+        "handlers.BatchDeleteRequest": {
+            "type": "object",
+            "required": [
+                "ids"
+            ],
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "handlers.BatchItemResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/handlers.Problem"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "record": {
+                    "$ref": "#/definitions/entity.Record"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.BatchPatchRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.RecordPatchRequest"
+                    }
+                }
+            }
+        },
+        "handlers.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean"
+                },
+                "committed": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.BatchItemResponse"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BudgetCreateRequest": {
+            "type": "object",
+            "required": [
+                "monthly_limit",
+                "user_id"
+            ],
+            "properties": {
+                "category_limits": {
+                    "description": "лимиты по категориям подписок",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    },
+                    "example": {
+                        "streaming": 1000
+                    }
+                },
+                "enforce": {
+                    "description": "запрещать создание подписок сверх бюджета",
+                    "type": "boolean"
+                },
+                "monthly_limit": {
+                    "type": "integer",
+                    "example": 3000
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+                }
+            }
+        },
+        "handlers.BudgetResponse": {
+            "type": "object",
+            "properties": {
+                "category_limits": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "enforce": {
+                    "type": "boolean"
+                },
+                "monthly_limit": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.BudgetStatusResponse": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.BudgetUsage"
+                    }
+                },
+                "period": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "total": {
+                    "$ref": "#/definitions/services.BudgetUsage"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.BudgetUpdateRequest": {
+            "type": "object",
+            "required": [
+                "monthly_limit"
+            ],
+            "properties": {
+                "category_limits": {
+                    "description": "лимиты по категориям подписок",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    },
+                    "example": {
+                        "streaming": 1000
+                    }
+                },
+                "enforce": {
+                    "description": "запрещать создание подписок сверх бюджета",
+                    "type": "boolean"
+                },
+                "monthly_limit": {
+                    "type": "integer",
+                    "example": 3000
+                }
+            }
+        },
+        "handlers.ChurnPointResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "подписки, действующие на начало месяца",
+                    "type": "integer",
+                    "example": 10
+                },
+                "churn_rate": {
+                    "description": "доля завершившихся от действующих на начало месяца, %",
+                    "type": "number",
+                    "example": 10
+                },
+                "churned": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "cumulative_net": {
+                    "description": "прирост с начала периода",
+                    "type": "integer",
+                    "example": 5
+                },
+                "month": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "net": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "new": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "handlers.CohortResponse": {
+            "type": "object",
+            "properties": {
+                "cohort": {
+                    "type": "string",
+                    "example": "01-2024"
+                },
+                "retention": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/analytics.Retention"
+                    }
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "handlers.DeliveryResponse": {
+            "type": "object",
+            "properties": {
+                "attempt_log": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.AttemptResponse"
+                    }
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "succeeded",
+                        "failed"
+                    ]
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.FeedTokenResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string",
+                    "example": "v1.q7yM3cN0cVd6Ff2b1kq0lqkqN9o0wq2mTjv6S0b1rXk"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "http://localhost:8080/api/users/60601fee-2bf1-4721-ae6f-7636e79a0cba/calendar.ics?token=v1.q7yM3cN0cVd6Ff2b1kq0lqkqN9o0wq2mTjv6S0b1rXk"
+                }
+            }
+        },
+        "handlers.ForecastMonthResponse": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ServiceContribution"
+                    }
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 400
+                }
+            }
+        },
+        "handlers.ForecastResponse": {
+            "type": "object",
+            "properties": {
+                "auto_renew": {
+                    "type": "boolean"
+                },
+                "forecast": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ForecastMonthResponse"
+                    }
+                },
+                "from": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "months": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 4800
+                }
+            }
+        },
+        "handlers.ImportResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "merged": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ImportRowResponse"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ImportRowResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "error": {
+                    "$ref": "#/definitions/handlers.Problem"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "record_id": {
+                    "type": "integer"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.LedgerAdjustmentRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "period",
+                "reason",
+                "record_id"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "отрицательная сумма - возврат, положительная - доначисление",
+                    "type": "integer",
+                    "example": -400
+                },
+                "period": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "refund for service outage"
+                },
+                "record_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "handlers.LedgerBackfillRequest": {
+            "type": "object",
+            "required": [
+                "from",
+                "to"
+            ],
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "01-2024"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "12-2024"
                 }
             }
-        }
-    },
-    "definitions": {
-        "entity.Record": {
+        },
+        "handlers.LedgerEntryResponse": {
             "type": "object",
             "properties": {
-                "createdAt": {
+                "amount": {
+                    "type": "integer"
+                },
+                "charge_date": {
+                    "type": "string",
+                    "example": "15-03-2024"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "expiresAt": {
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "charge",
+                        "adjustment"
+                    ]
+                },
+                "manual": {
+                    "type": "boolean"
+                },
+                "period": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "record_id": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.LifetimeResponse": {
+            "type": "object",
+            "properties": {
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/analytics.Lifetime"
+                    }
+                },
+                "total": {
+                    "$ref": "#/definitions/analytics.Lifetime"
+                }
+            }
+        },
+        "handlers.MRRPointResponse": {
+            "type": "object",
+            "properties": {
+                "change": {
+                    "description": "изменение к предыдущему месяцу",
+                    "type": "integer",
+                    "example": 200
+                },
+                "month": {
+                    "type": "string",
+                    "example": "03-2024"
+                },
+                "mrr": {
+                    "type": "integer",
+                    "example": 1500
+                },
+                "subscriptions": {
+                    "type": "integer",
+                    "example": 4
+                }
+            }
+        },
+        "handlers.PriceChangeRequest": {
+            "type": "object",
+            "required": [
+                "effective_from",
+                "price"
+            ],
+            "properties": {
+                "effective_from": {
+                    "type": "string",
+                    "example": "01-06-2024"
+                },
+                "price": {
+                    "type": "integer",
+                    "example": 499
+                }
+            }
+        },
+        "handlers.PriceChangeResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
                     "type": "string"
                 },
+                "effective_from": {
+                    "type": "string",
+                    "example": "01-06-2024"
+                },
                 "id": {
                     "type": "integer"
                 },
                 "price": {
                     "type": "integer"
                 },
-                "serviceName": {
+                "record_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
                     "type": "string"
                 },
-                "userID": {
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
                     "type": "string"
                 }
             }
@@ -559,12 +6067,68 @@ const docTemplate = `{
                 "user_id"
             ],
             "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "streaming"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RecordPatchFields": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RecordPatchRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "string"
                 },
                 "expires_at": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "integer"
+                },
                 "price": {
                     "type": "integer"
                 },
@@ -575,6 +6139,224 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "handlers.SumPeriodQuery": {
+            "type": "object",
+            "required": [
+                "end_time",
+                "start_time"
+            ],
+            "properties": {
+                "end_time": {
+                    "type": "string"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "records - по записям подписок, ledger - по журналу начислений",
+                    "type": "string",
+                    "enum": [
+                        "records",
+                        "ledger"
+                    ]
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.SumPeriodResponse": {
+            "type": "object",
+            "properties": {
+                "total_price": {
+                    "type": "integer",
+                    "example": 1500
+                }
+            }
+        },
+        "handlers.WebhookCreateRequest": {
+            "type": "object",
+            "required": [
+                "events",
+                "url"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "record.created",
+                        "record.deleted"
+                    ]
+                },
+                "secret": {
+                    "description": "если не передан, секрет генерируется и возвращается один раз в ответе",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://budget.example.com/hooks/subscriptions"
+                }
+            }
+        },
+        "handlers.WebhookResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.WebhookUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "rotate_secret": {
+                    "type": "boolean"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "outbox.SinkStats": {
+            "type": "object",
+            "properties": {
+                "checkpoint_updated_at": {
+                    "type": "string"
+                },
+                "lag_seconds": {
+                    "type": "number"
+                },
+                "last_event_id": {
+                    "type": "integer"
+                },
+                "oldest_pending_at": {
+                    "type": "string"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "sink": {
+                    "type": "string"
+                }
+            }
+        },
+        "outbox.Stats": {
+            "type": "object",
+            "properties": {
+                "head_event_id": {
+                    "type": "integer"
+                },
+                "sinks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/outbox.SinkStats"
+                    }
+                }
+            }
+        },
+        "services.BudgetUsage": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "exceeded": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "projected": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.MaterializeResult": {
+            "type": "object",
+            "properties": {
+                "adjustments": {
+                    "type": "integer"
+                },
+                "charges": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ServiceContribution": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "subscriptions": {
+                    "type": "integer"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "Токен администратора в виде \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,140 @@
+// Package analytics описывает отчеты по подпискам: MRR, отток, топ сервисов, срок жизни и когорты.
+// Отчеты считаются в Postgres оконными функциями (см. repository/analytics.go), здесь - модели
+// строк и сборка результатов.
+//
+// Подписка считается действующей в месяце, если она началась не позже последнего дня месяца
+// и заканчивается после него, - то есть MRR это снимок на конец месяца
+package analytics
+
+import "time"
+
+// Range - отчетный период из целых месяцев [From, To], оба значения - первые числа месяцев
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// NewRange приводит границы к первым числам месяцев
+func NewRange(from, to time.Time) Range {
+	return Range{From: monthStart(from), To: monthStart(to)}
+}
+
+// Months возвращает количество месяцев в периоде
+func (r Range) Months() int {
+	return (r.To.Year()-r.From.Year())*12 + int(r.To.Month()-r.From.Month()) + 1
+}
+
+// End возвращает первое число месяца после периода - исключающую границу
+func (r Range) End() time.Time {
+	return r.To.AddDate(0, 1, 0)
+}
+
+// Filter - необязательные фильтры отчетов, как у суммы за период
+type Filter struct {
+	UserID      string
+	ServiceName string
+}
+
+// MRRPoint - ежемесячная выручка на конец месяца и ее изменение к предыдущему месяцу
+type MRRPoint struct {
+	Month         time.Time
+	MRR           int
+	Change        int
+	Subscriptions int
+}
+
+// ChurnPoint - новые и завершившиеся подписки за месяц.
+// Active - подписки, действующие на начало месяца, ChurnRate - доля завершившихся из них в процентах
+type ChurnPoint struct {
+	Month     time.Time
+	New       int
+	Churned   int
+	Net       int
+	Active    int
+	ChurnRate float64
+	// накопленный прирост с начала периода
+	CumulativeNet int
+}
+
+// ServiceRank - расходы и подписчики сервиса за период. Share - доля в общих расходах, в процентах
+type ServiceRank struct {
+	ServiceName     string  `json:"service_name"`
+	Spend           int     `json:"spend"`
+	Subscribers     int     `json:"subscribers"`
+	SpendRank       int     `json:"spend_rank"`
+	SubscribersRank int     `json:"subscribers_rank"`
+	Share           float64 `json:"share"`
+}
+
+// RankBy - порядок топа сервисов
+type RankBy string
+
+const (
+	RankBySpend       RankBy = "spend"
+	RankBySubscribers RankBy = "subscribers"
+)
+
+// Lifetime - срок жизни подписок, начавшихся в периоде. Для действующих подписок срок считается
+// до текущей даты. Пустой ServiceName - итог по всем сервисам
+type Lifetime struct {
+	ServiceName   string  `json:"service_name,omitempty"`
+	Subscriptions int     `json:"subscriptions"`
+	Active        int     `json:"active"`
+	AverageDays   float64 `json:"average_days"`
+	MedianDays    float64 `json:"median_days"`
+}
+
+// CohortRow - строка отчета по когортам: сколько подписок когорты действует через Offset месяцев
+type CohortRow struct {
+	Cohort   time.Time
+	Size     int
+	Offset   int
+	Retained int
+}
+
+// Cohort - подписки, начавшиеся в одном месяце, и их удержание по месяцам
+type Cohort struct {
+	Month     time.Time
+	Size      int
+	Retention []Retention
+}
+
+// Retention - удержание когорты через Offset месяцев после начала. Rate - в процентах
+type Retention struct {
+	Offset   int     `json:"offset"`
+	Retained int     `json:"retained"`
+	Rate     float64 `json:"rate"`
+}
+
+// BuildCohorts собирает строки отчета в когорты. Строки должны быть отсортированы по когорте и смещению
+func BuildCohorts(rows []CohortRow) []Cohort {
+	cohorts := make([]Cohort, 0)
+
+	for _, row := range rows {
+		if len(cohorts) == 0 || !cohorts[len(cohorts)-1].Month.Equal(row.Cohort) {
+			cohorts = append(cohorts, Cohort{Month: row.Cohort, Size: row.Size})
+		}
+
+		cohort := &cohorts[len(cohorts)-1]
+		cohort.Retention = append(cohort.Retention, Retention{
+			Offset:   row.Offset,
+			Retained: row.Retained,
+			Rate:     percent(row.Retained, row.Size),
+		})
+	}
+
+	return cohorts
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	// два знака после запятой
+	return float64(part*10000/total) / 100
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
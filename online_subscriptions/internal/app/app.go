@@ -1,34 +1,318 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/handlers"
-	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/notify"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/outbox"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/routes"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/scheduler"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
-	"github.com/14kear/effective_mobile/online_subscriptions/internal/storage"
 	"github.com/gin-gonic/gin"
-	"log"
+	"io"
 	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 )
 
-func NewApp(cfg *config.Config) (*gin.Engine, error) {
+// App - HTTP-сервер и фоновые задачи с общим жизненным циклом
+type App struct {
+	log             *slog.Logger
+	server          *http.Server
+	scheduler       *scheduler.Scheduler
+	shutdownTimeout time.Duration
+	// closers освобождаются после остановки фоновых задач
+	closers []io.Closer
+}
+
+// NewApp собирает приложение по настройкам, проверенным config.Validate
+func NewApp(cfg *config.Config) (*App, error) {
 	logger := slog.Default()
+	clk := clock.System
+
+	svc, err := NewServices(cfg, logger, clk)
+	if err != nil {
+		return nil, err
+	}
+
+	if svc.Repository == nil {
+		return newRecordsApp(cfg, logger, svc, clk), nil
+	}
+
+	repo := svc.Repository
 
-	database, err := storage.InitDB(cfg)
+	sinks, closers, err := newSinks(cfg.Outbox, svc.Webhooks)
 	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+		svc.Close()
+		return nil, err
 	}
+	// хранилище закрывается после приемников outbox
+	closers = append(closers, svc)
 
-	repo := repository.NewRepository(database)
+	relay := outbox.NewRelay(logger, repo, sinks, outbox.Config{
+		BatchSize:  cfg.Outbox.BatchSize,
+		GapTimeout: cfg.Outbox.GapTimeout,
+		Retention:  cfg.Outbox.Retention,
+		Clock:      clk,
+	})
 
-	service := services.NewRecordService(logger, repo)
+	jobs := scheduler.New(logger)
+
+	jobs.Add(scheduler.Job{
+		Name:     "outbox-relay",
+		Interval: cfg.Outbox.PollInterval,
+		Run:      relay.Run,
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "expired-events",
+		Interval: cfg.Outbox.ExpiredInterval,
+		Run:      services.NewExpiredEvents(logger, repo, cfg.Outbox.ExpiredLookbackDays, clk).Publish,
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "webhook-deliveries",
+		Interval: cfg.Webhooks.PollInterval,
+		Run:      svc.Webhooks.DeliverDue,
+	})
+
+	jobs.Add(scheduler.Job{
+		Name:     "ledger-charges",
+		Interval: cfg.Ledger.Interval,
+		Run:      svc.Ledger.ChargeDue,
+	})
+
+	jobs.Add(scheduler.Job{
+		Name:     "budget-checks",
+		Interval: cfg.Budgets.Interval,
+		Run:      svc.Budgets.CheckAll,
+	})
+
+	jobs.Add(scheduler.Job{
+		Name:     "aggregates-check",
+		Interval: cfg.Aggregates.CheckInterval,
+		Run:      svc.Aggregates.Maintain,
+	})
+
+	if cfg.Reminders.Enabled {
+		notifiers, err := newNotifiers(logger, cfg.Reminders.Channels, cfg.Reminders)
+		if err != nil {
+			closeAll(closers)
+			return nil, err
+		}
+
+		reminderService, err := services.NewReminderService(logger, repo, notifiers, cfg.Reminders.Windows, clk)
+		if err != nil {
+			closeAll(closers)
+			return nil, err
+		}
+
+		jobs.Add(scheduler.Job{
+			Name:     "expiration-reminders",
+			Interval: cfg.Reminders.Interval,
+			Run:      reminderService.SendDueReminders,
+		})
+	}
 
-	handler := handlers.NewRecordHandler(service)
+	handler := handlers.NewRecordHandler(svc.Records, svc.Ledger, clk)
+	importHandler := handlers.NewImportHandler(svc.Importer)
+	calendarHandler := handlers.NewCalendarHandler(svc.Calendar)
+	webhookHandler := handlers.NewWebhookHandler(svc.Webhooks)
+	outboxHandler := handlers.NewOutboxHandler(relay)
+	ledgerHandler := handlers.NewLedgerHandler(svc.Ledger)
+	budgetHandler := handlers.NewBudgetHandler(svc.Budgets)
+	forecastHandler := handlers.NewForecastHandler(services.NewForecastService(logger, repo, clk))
+	analyticsHandler := handlers.NewAnalyticsHandler(services.NewAnalyticsService(logger, repo, svc.Aggregates, clk), clk)
+	aggregateHandler := handlers.NewAggregateHandler(svc.Aggregates)
 
 	r := gin.Default()
+	r.NoRoute(handlers.NotFound)
 	api := r.Group("/api")
-	routes.RegisterRoutes(api, handler)
+	v1 := api.Group("/v1")
+	adminAuth := handlers.AdminAuth(cfg.Admin.Token)
+
+	routes.RegisterSwagger(api)
+	routes.RegisterRoutes(v1, handler, importHandler, calendarHandler, forecastHandler, analyticsHandler)
+	routes.RegisterAdminRoutes(v1, adminAuth, calendarHandler, webhookHandler, outboxHandler, ledgerHandler, budgetHandler, aggregateHandler)
+
+	if cfg.API.Legacy.Enabled {
+		deprecation := legacyDeprecation(cfg.API.Legacy)
+
+		routes.RegisterLegacyRoutes(api, deprecation, v1.BasePath(), handler, importHandler, calendarHandler, forecastHandler, analyticsHandler)
+		// административные маршруты перенесены в v1 без изменений
+		legacyAdmin := api.Group("", handlers.DeprecatedPrefix(deprecation, api.BasePath(), v1.BasePath()))
+		routes.RegisterAdminRoutes(legacyAdmin, adminAuth, calendarHandler, webhookHandler, outboxHandler, ledgerHandler, budgetHandler, aggregateHandler)
+	}
+
+	return &App{
+		log:             logger,
+		server:          &http.Server{Addr: cfg.HTTP.Port, Handler: r},
+		scheduler:       jobs,
+		shutdownTimeout: cfg.HTTP.ShutdownTimeout,
+		closers:         closers,
+	}, nil
+}
+
+// newRecordsApp собирает приложение над хранилищем без Postgres (memory, sqlite): только операции
+// с записями, без бюджетов, агрегатов, outbox и фоновых задач
+func newRecordsApp(cfg *config.Config, logger *slog.Logger, svc *Services, clk clock.Clock) *App {
+	handler := handlers.NewRecordHandler(svc.Records, nil, clk)
+	importHandler := handlers.NewImportHandler(svc.Importer)
+
+	r := gin.Default()
+	r.NoRoute(handlers.NotFound)
+	api := r.Group("/api")
+	v1 := api.Group("/v1")
+
+	routes.RegisterSwagger(api)
+	routes.RegisterRecordRoutes(v1, handler, importHandler)
+
+	if cfg.API.Legacy.Enabled {
+		routes.RegisterLegacyRecordRoutes(api, legacyDeprecation(cfg.API.Legacy), v1.BasePath(), handler, importHandler)
+	}
+
+	return &App{
+		log:             logger,
+		server:          &http.Server{Addr: cfg.HTTP.Port, Handler: r},
+		scheduler:       scheduler.New(logger),
+		shutdownTimeout: cfg.HTTP.ShutdownTimeout,
+		closers:         []io.Closer{svc},
+	}
+}
+
+// legacyDeprecation - сроки вывода маршрутов без версии. Даты проверены config.Validate
+func legacyDeprecation(cfg config.LegacyAPIConfig) handlers.Deprecation {
+	since, sunset, _ := cfg.Dates()
+
+	return handlers.Deprecation{Since: since, Sunset: sunset}
+}
+
+// Run запускает сервер и фоновые задачи. При отмене ctx сервер дообрабатывает текущие запросы,
+// фоновые задачи останавливаются, Run возвращается после их завершения
+func (a *App) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.scheduler.Run(ctx)
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		a.log.Info("starting http server", slog.String("addr", a.server.Addr))
+		serverErr <- a.server.ListenAndServe()
+	}()
+
+	var runErr error
+
+	select {
+	case <-ctx.Done():
+		a.log.Info("shutting down...")
+	case err := <-serverErr:
+		runErr = fmt.Errorf("http server: %w", err)
+	}
+
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := a.server.Shutdown(shutdownCtx); err != nil && runErr == nil {
+		runErr = fmt.Errorf("http server shutdown: %w", err)
+	}
+
+	wg.Wait()
+
+	for _, closer := range a.closers {
+		if err := closer.Close(); err != nil {
+			a.log.Error("failed to close resource", slog.String("error", err.Error()))
+		}
+	}
+
+	return runErr
+}
+
+// newNotifiers создает каналы уведомлений по именам, настройки webhook и smtp общие для всех уведомлений
+func newNotifiers(log *slog.Logger, channels []string, cfg config.RemindersConfig) ([]notify.Notifier, error) {
+	notifiers := make([]notify.Notifier, 0, len(channels))
+
+	for _, channel := range channels {
+		switch channel {
+		case "log":
+			notifiers = append(notifiers, notify.NewLogNotifier(log))
+		case "webhook":
+			if cfg.Webhook.URL == "" {
+				return nil, errors.New("reminders.webhook.url is required for webhook channel")
+			}
+			notifiers = append(notifiers, notify.NewWebhookNotifier(cfg.Webhook.URL, cfg.Webhook.Secret, cfg.Webhook.Timeout))
+		case "smtp":
+			notifier, err := notify.NewSMTPNotifier(notify.SMTPConfig{
+				Host:     cfg.SMTP.Host,
+				Port:     cfg.SMTP.Port,
+				Username: cfg.SMTP.Username,
+				Password: cfg.SMTP.Password,
+				From:     cfg.SMTP.From,
+				To:       cfg.SMTP.To,
+			})
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, notifier)
+		default:
+			return nil, fmt.Errorf("unknown notification channel %q", channel)
+		}
+	}
+
+	return notifiers, nil
+}
+
+// closeAll освобождает уже открытые ресурсы, когда сборка приложения прервалась ошибкой
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		_ = closer.Close()
+	}
+}
+
+// newSinks создает приемники outbox. Подключения к брокерам возвращаются вторым значением для закрытия
+func newSinks(cfg config.OutboxConfig, webhooks *services.WebhookService) ([]outbox.Sink, []io.Closer, error) {
+	sinks := make([]outbox.Sink, 0, len(cfg.Sinks))
+	var closers []io.Closer
+
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "webhooks":
+			sinks = append(sinks, webhooks)
+		case "stdout":
+			sinks = append(sinks, outbox.NewWriterSink(os.Stdout))
+		case "nats":
+			sink, err := outbox.NewNATSSink(cfg.NATS.URL, cfg.NATS.SubjectPrefix)
+			if err != nil {
+				closeAll(closers)
+				return nil, nil, fmt.Errorf("connect to nats: %w", err)
+			}
+			sinks = append(sinks, sink)
+			closers = append(closers, sink)
+		case "kafka":
+			if len(cfg.Kafka.Brokers) == 0 || cfg.Kafka.Topic == "" {
+				closeAll(closers)
+				return nil, nil, errors.New("outbox.kafka.brokers and outbox.kafka.topic are required for kafka sink")
+			}
+			sink := outbox.NewKafkaSink(cfg.Kafka.Brokers, cfg.Kafka.Topic)
+			sinks = append(sinks, sink)
+			closers = append(closers, sink)
+		default:
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
 
-	return r, nil
+	return sinks, closers, nil
 }
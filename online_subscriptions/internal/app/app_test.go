@@ -0,0 +1,147 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/handlers"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordBody - полугодовая подписка с начала месяца month относительно текущего. Приложение сверяет
+// даты с системными часами, поэтому подписка не должна заканчиваться в прошлом
+func recordBody(month int) string {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month()+time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+
+	return fmt.Sprintf(`{"service_name":"Netflix","price":400,"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","created_at":%q,"expires_at":%q}`,
+		start.Format("02-01-2006"), start.AddDate(0, 6, 0).Format("02-01-2006"))
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	os.Exit(m.Run())
+}
+
+// newTestApp собирает приложение над хранилищем в памяти по настройкам из файла с секцией api
+func newTestApp(t *testing.T, apiYAML string) http.Handler {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "env: test\nhttp:\n  port: \":0\"\nstorage:\n  driver: memory\n" + apiYAML
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(config.Sources{Path: path, Env: "test"})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	t.Cleanup(func() { closeAll(app.closers) })
+
+	return app.server.Handler
+}
+
+func serve(t *testing.T, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestLegacyRoutesDisabled(t *testing.T) {
+	handler := newTestApp(t, "api:\n  legacy:\n    enabled: false\n")
+
+	for _, route := range []struct{ method, path, body string }{
+		{http.MethodPost, "/api/create", recordBody(0)},
+		{http.MethodPut, "/api/update/1", recordBody(0)},
+		{http.MethodGet, "/api/record/1", ""},
+		{http.MethodGet, "/api/records", ""},
+	} {
+		w := serve(t, handler, route.method, route.path, route.body)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("%s %s: status %d, want 404", route.method, route.path, w.Code)
+		}
+
+		var problem handlers.Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s %s: decode problem: %v", route.method, route.path, err)
+		}
+		if problem.Code != services.CodeRouteNotFound {
+			t.Fatalf("%s %s: code %q, want %s", route.method, route.path, problem.Code, services.CodeRouteNotFound)
+		}
+	}
+
+	if w := serve(t, handler, http.MethodPost, "/api/v1/subscriptions", recordBody(0)); w.Code != http.StatusCreated {
+		t.Fatalf("v1 create: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestLegacyRoutesEnabled(t *testing.T) {
+	handler := newTestApp(t, "api:\n  legacy:\n    enabled: true\n    deprecated_at: \"2026-10-18\"\n    sunset: \"2027-04-30\"\n")
+
+	w := serve(t, handler, http.MethodPost, "/api/create", recordBody(0))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("legacy create: status %d, body %s", w.Code, w.Body)
+	}
+
+	var record struct{ ID uint }
+	if err := json.Unmarshal(w.Body.Bytes(), &record); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	id := strconv.FormatUint(uint64(record.ID), 10)
+
+	deprecation := "@" + strconv.FormatInt(time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC).Unix(), 10)
+
+	for _, route := range []struct{ method, path, body, successor string }{
+		{http.MethodPost, "/api/create", recordBody(7), "/api/v1/subscriptions"},
+		{http.MethodGet, "/api/record/" + id, "", "/api/v1/subscriptions/" + id},
+		{http.MethodPut, "/api/update/" + id, recordBody(0), "/api/v1/subscriptions/" + id},
+		{http.MethodGet, "/api/records", "", "/api/v1/subscriptions"},
+	} {
+		w := serve(t, handler, route.method, route.path, route.body)
+		if w.Code >= http.StatusBadRequest {
+			t.Fatalf("%s %s: status %d, body %s", route.method, route.path, w.Code, w.Body)
+		}
+
+		header := w.Header()
+		if got := header.Get("Deprecation"); got != deprecation {
+			t.Fatalf("%s %s: Deprecation %q, want %q", route.method, route.path, got, deprecation)
+		}
+		if got := header.Get("Sunset"); got != "Fri, 30 Apr 2027 00:00:00 GMT" {
+			t.Fatalf("%s %s: Sunset %q", route.method, route.path, got)
+		}
+		if got, want := header.Get("Link"), "<"+route.successor+`>; rel="successor-version"`; got != want {
+			t.Fatalf("%s %s: Link %q, want %q", route.method, route.path, got, want)
+		}
+	}
+
+	// маршруты v1 не помечаются устаревшими
+	w = serve(t, handler, http.MethodGet, "/api/v1/subscriptions/"+id, "")
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" {
+		t.Fatalf("v1 get: status %d, Deprecation %q", w.Code, w.Header().Get("Deprecation"))
+	}
+}
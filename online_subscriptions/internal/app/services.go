@@ -0,0 +1,173 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/importer"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/storage"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/webhook"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+// Services - хранилище и сервисы, собранные по настройкам. Сервер добавляет к ним HTTP и фоновые
+// задачи, subsctl вызывает их напрямую, поэтому записи из обоих проходят одни и те же проверки,
+// попадают в outbox и обновляют агрегаты
+type Services struct {
+	Records  *services.RecordService
+	Importer *importer.Importer
+
+	// остальные поля требуют Postgres и равны nil для memory и sqlite
+	Repository *repository.Repository
+	Webhooks   *services.WebhookService
+	Aggregates *services.AggregateService
+	Budgets    *services.BudgetService
+	Calendar   *services.CalendarService
+	Ledger     *services.LedgerService
+
+	databases []*gorm.DB
+}
+
+// NewServices подключается к хранилищу из storage.driver, применяет схему и собирает сервисы
+func NewServices(cfg *config.Config, logger *slog.Logger, clk clock.Clock) (*Services, error) {
+	overlapPolicy, err := services.ParseOverlapPolicy(cfg.Overlap.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	validator := services.NewRecordValidator(services.ValidationRules{
+		MaxPrice:              cfg.Validation.MaxPrice,
+		MaxServiceNameLength:  cfg.Validation.MaxServiceNameLength,
+		MaxSubscriptionMonths: cfg.Validation.MaxSubscriptionMonths,
+		AllowBackdated:        cfg.Validation.AllowBackdated,
+	})
+
+	recordConfig := services.RecordServiceConfig{
+		OverlapPolicy: overlapPolicy,
+		Batch: services.BatchLimits{
+			MaxItems:        cfg.Batch.MaxItems,
+			InsertChunkSize: cfg.Batch.InsertChunkSize,
+		},
+		Clock: clk,
+	}
+
+	switch cfg.Storage.Driver {
+	case config.DriverPostgres:
+	case config.DriverMemory:
+		logger.Warn("using in-memory storage: data is lost on restart, postgres-only features are disabled")
+		return newRecordServices(cfg, logger, memory.New(overlapPolicy == services.OverlapReject, clk), validator, recordConfig), nil
+	case config.DriverSQLite:
+		database, err := storage.InitDB(cfg, clk)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Warn("using sqlite storage: postgres-only features are disabled", slog.String("path", cfg.Storage.SQLite.Path))
+
+		s := newRecordServices(cfg, logger, repository.NewRepository(database), validator, recordConfig)
+		s.databases = []*gorm.DB{database}
+
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+
+	database, err := storage.InitDB(cfg, clk)
+	if err != nil {
+		return nil, err
+	}
+
+	replica, err := storage.InitReplica(cfg)
+	if err != nil {
+		storage.Close(database)
+		return nil, err
+	}
+
+	s := &Services{databases: []*gorm.DB{database}}
+
+	if replica != nil {
+		logger.Info("reading lists and summaries from replica", slog.String("host", cfg.DB.Replica.Host))
+		s.databases = append(s.databases, replica)
+	}
+
+	repo := repository.NewRepository(database).WithReplica(replica)
+	s.Repository = repo
+
+	s.Webhooks = services.NewWebhookService(logger, repo, webhook.NewSender(cfg.Webhooks.Timeout, clk), services.WebhookConfig{
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		BackoffBase: cfg.Webhooks.BackoffBase,
+		BackoffMax:  cfg.Webhooks.BackoffMax,
+		BatchSize:   cfg.Webhooks.BatchSize,
+		Concurrency: cfg.Webhooks.Concurrency,
+		Lease:       cfg.Webhooks.Timeout * 2,
+		Clock:       clk,
+	})
+
+	s.Aggregates = services.NewAggregateService(logger, repo, services.AggregateConfig{
+		Read:       cfg.Aggregates.Read,
+		Repair:     cfg.Aggregates.Repair,
+		CheckLimit: cfg.Aggregates.CheckLimit,
+	})
+
+	budgetNotifiers, err := newNotifiers(logger, cfg.Budgets.Channels, cfg.Reminders)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	s.Budgets = services.NewBudgetService(logger, repo, budgetNotifiers, services.BudgetConfig{
+		HorizonMonths: cfg.Budgets.HorizonMonths,
+		Clock:         clk,
+	})
+
+	// изменения записей пишутся в outbox в той же транзакции, relay доставляет их в приемники,
+	// месячные агрегаты пересчитываются там же
+	recordRepo := services.WithAggregates(services.WithEvents(repo, repo, clk), repo)
+
+	recordConfig.Budgets = s.Budgets
+	recordConfig.Aggregates = s.Aggregates
+	s.Records = services.NewRecordService(logger, recordRepo, validator, recordConfig)
+	s.Importer = importer.NewImporter(logger, s.Records, cfg.Import.ChunkSize)
+
+	s.Calendar = services.NewCalendarService(logger, repo, repo, services.CalendarConfig{
+		Secret:          []byte(cfg.Calendar.Secret),
+		HistoryMonths:   cfg.Calendar.HistoryMonths,
+		HorizonMonths:   cfg.Calendar.HorizonMonths,
+		RefreshInterval: cfg.Calendar.RefreshInterval,
+		Clock:           clk,
+	})
+
+	s.Ledger = services.NewLedgerService(logger, repo, services.LedgerConfig{
+		LookbackMonths: cfg.Ledger.LookbackMonths,
+		BatchSize:      cfg.Ledger.BatchSize,
+		Clock:          clk,
+	})
+
+	return s, nil
+}
+
+// newRecordServices собирает сервисы над хранилищем без Postgres: только операции с записями
+func newRecordServices(cfg *config.Config, logger *slog.Logger, repo services.Repository, validator *services.RecordValidator, recordConfig services.RecordServiceConfig) *Services {
+	records := services.NewRecordService(logger, repo, validator, recordConfig)
+
+	return &Services{
+		Records:  records,
+		Importer: importer.NewImporter(logger, records, cfg.Import.ChunkSize),
+	}
+}
+
+// Close закрывает подключения к хранилищу
+func (s *Services) Close() error {
+	var errs []error
+
+	for _, db := range s.databases {
+		errs = append(errs, storage.Close(db))
+	}
+
+	return errors.Join(errs...)
+}
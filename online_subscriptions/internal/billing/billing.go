@@ -0,0 +1,48 @@
+package billing
+
+import "time"
+
+// ChargeDate возвращает дату n-го ежемесячного списания подписки, начавшейся start (n = 0 - сам start).
+// Если в месяце нет такого числа, списание переносится на последний день месяца:
+// подписка от 31 января списывается 28(29) февраля и снова 31 марта
+func ChargeDate(start time.Time, n int) time.Time {
+	year, month, day := start.Date()
+
+	first := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	if last := daysIn(first); day > last {
+		day = last
+	}
+
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// ChargeDates возвращает даты списаний подписки [start, end), попадающие в окно [from, to)
+func ChargeDates(start, end, from, to time.Time) []time.Time {
+	var dates []time.Time
+
+	// пропускаем месяцы до начала окна, не перебирая их по одному
+	n := 0
+	if months := MonthsBetween(start, from) - 1; months > 0 {
+		n = months
+	}
+
+	for ; ; n++ {
+		date := ChargeDate(start, n)
+		if !date.Before(end) || !date.Before(to) {
+			return dates
+		}
+
+		if !date.Before(from) {
+			dates = append(dates, date)
+		}
+	}
+}
+
+// MonthsBetween - число полных календарных месяцев между датами без учета дней
+func MonthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+func daysIn(month time.Time) int {
+	return month.AddDate(0, 1, -1).Day()
+}
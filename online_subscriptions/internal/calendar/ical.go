@@ -0,0 +1,153 @@
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateLayout  = "20060102"
+	stampLayout = "20060102T150405Z"
+
+	// RFC 5545: строки длиннее 75 октетов переносятся
+	maxLineOctets = 75
+)
+
+// Event - событие на целый день
+type Event struct {
+	// UID должен быть стабильным, чтобы календарь обновлял событие, а не добавлял новое
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+}
+
+// Calendar - VCALENDAR с событиями, который отдается как .ics
+type Calendar struct {
+	ProdID string
+	Name   string
+	// RefreshInterval подсказывает клиенту, как часто перечитывать ленту
+	RefreshInterval time.Duration
+	// Stamp - время построения ленты, попадает в DTSTAMP событий
+	Stamp  time.Time
+	Events []Event
+}
+
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	cw := &contentWriter{w: bufio.NewWriter(w)}
+	stamp := c.Stamp.UTC().Format(stampLayout)
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", c.ProdID)
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+
+	if c.Name != "" {
+		cw.line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	if c.RefreshInterval > 0 {
+		cw.line("REFRESH-INTERVAL;VALUE=DURATION", duration(c.RefreshInterval))
+		cw.line("X-PUBLISHED-TTL", duration(c.RefreshInterval))
+	}
+
+	for _, event := range c.Events {
+		cw.line("BEGIN", "VEVENT")
+		cw.line("UID", event.UID)
+		cw.line("DTSTAMP", stamp)
+		cw.line("DTSTART;VALUE=DATE", event.Date.Format(dateLayout))
+		cw.line("DTEND;VALUE=DATE", event.Date.AddDate(0, 0, 1).Format(dateLayout))
+		cw.line("SUMMARY", escape(event.Summary))
+
+		if event.Description != "" {
+			cw.line("DESCRIPTION", escape(event.Description))
+		}
+
+		cw.line("TRANSP", "TRANSPARENT")
+		cw.line("END", "VEVENT")
+	}
+
+	cw.line("END", "VCALENDAR")
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+type contentWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// line пишет свойство, перенося длинные строки: продолжение начинается с пробела
+func (cw *contentWriter) line(name, value string) {
+	content := name + ":" + value
+	limit := maxLineOctets
+
+	for len(content) > limit {
+		cut := limit
+		// не разрываем многобайтовый символ
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+
+		cw.write(content[:cut] + "\r\n ")
+		content = content[cut:]
+		// у строк продолжения первый октет занят пробелом
+		limit = maxLineOctets - 1
+	}
+
+	cw.write(content + "\r\n")
+}
+
+func (cw *contentWriter) write(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// duration форматирует интервал как длительность RFC 5545, с точностью до минут
+func duration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes <= 0 {
+		minutes = 1
+	}
+
+	var b strings.Builder
+	b.WriteString("P")
+
+	if days := minutes / (24 * 60); days > 0 {
+		b.WriteString(strconv.Itoa(days) + "D")
+		minutes %= 24 * 60
+	}
+
+	if minutes > 0 {
+		b.WriteString("T")
+		if hours := minutes / 60; hours > 0 {
+			b.WriteString(strconv.Itoa(hours) + "H")
+		}
+		if m := minutes % 60; m > 0 {
+			b.WriteString(strconv.Itoa(m) + "M")
+		}
+	}
+
+	return b.String()
+}
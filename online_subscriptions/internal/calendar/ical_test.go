@@ -0,0 +1,135 @@
+package calendar_test
+
+import (
+	"bytes"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/calendar"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestCalendarWriteTo(t *testing.T) {
+	cal := calendar.Calendar{
+		ProdID:          "-//test//feed//EN",
+		Name:            "Subscriptions",
+		RefreshInterval: 90 * time.Minute,
+		// DTSTAMP пишется в UTC
+		Stamp: time.Date(2025, time.March, 10, 14, 30, 5, 0, time.FixedZone("MSK", 3*60*60)),
+		Events: []calendar.Event{
+			{UID: "record-1-expires@test", Date: time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC), Summary: "Netflix, Okko; Kion", Description: "line\nbreak"},
+			{UID: "record-1-charge-20251231@test", Date: time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC), Summary: `C:\path`},
+		},
+	}
+
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//feed//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Subscriptions",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H30M",
+		"X-PUBLISHED-TTL:PT1H30M",
+		"BEGIN:VEVENT",
+		"UID:record-1-expires@test",
+		"DTSTAMP:20250310T113005Z",
+		"DTSTART;VALUE=DATE:20250331",
+		"DTEND;VALUE=DATE:20250401",
+		`SUMMARY:Netflix\, Okko\; Kion`,
+		`DESCRIPTION:line\nbreak`,
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:record-1-charge-20251231@test",
+		"DTSTAMP:20250310T113005Z",
+		"DTSTART;VALUE=DATE:20251231",
+		"DTEND;VALUE=DATE:20260101",
+		`SUMMARY:C:\\path`,
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n") + "\r\n"
+
+	var buf bytes.Buffer
+	n, err := cal.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("reported %d bytes, wrote %d", n, buf.Len())
+	}
+}
+
+func TestCalendarRefreshInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{0, ""},
+		{30 * time.Second, "PT1M"},
+		{15 * time.Minute, "PT15M"},
+		{time.Hour, "PT1H"},
+		{24 * time.Hour, "P1D"},
+		{26*time.Hour + 5*time.Minute, "P1DT2H5M"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		cal := calendar.Calendar{ProdID: "-//test//EN", RefreshInterval: tt.interval}
+		if _, err := cal.WriteTo(&buf); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		// без интервала свойство не пишется
+		got := ""
+		for _, line := range strings.Split(buf.String(), "\r\n") {
+			if value, ok := strings.CutPrefix(line, "REFRESH-INTERVAL;VALUE=DURATION:"); ok {
+				got = value
+			}
+		}
+
+		if got != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarFoldsLongLines(t *testing.T) {
+	// многобайтовые символы на границе переноса не должны разрываться
+	summary := strings.Repeat("Подписка ", 30)
+
+	var buf bytes.Buffer
+	cal := calendar.Calendar{
+		ProdID: "-//test//EN",
+		Events: []calendar.Event{{UID: "uid@test", Date: time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC), Summary: summary}},
+	}
+	if _, err := cal.WriteTo(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Fatal("output does not end with CRLF")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	for _, line := range lines {
+		if len(line) > 75 {
+			t.Fatalf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Fatalf("line splits a character: %q", line)
+		}
+	}
+
+	// строки продолжения начинаются с пробела, после склейки получается исходное значение
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	if !strings.Contains(unfolded, "\r\nSUMMARY:"+summary+"\r\n") {
+		t.Fatalf("summary does not survive unfolding:\n%s", out)
+	}
+}
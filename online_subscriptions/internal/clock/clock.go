@@ -0,0 +1,63 @@
+// Package clock - источник текущего времени для логики, зависящей от даты: проверки окончания подписок,
+// плановых задач, окон напоминаний. Сервисы получают часы через конструктор, тесты подставляют Fake
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+// System - системные часы
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// OrSystem возвращает c или системные часы, если c не задан
+func OrSystem(c Clock) Clock {
+	if c == nil {
+		return System
+	}
+
+	return c
+}
+
+// Fake - часы, которые идут только вручную. Безопасны для одновременного использования
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Set переводит часы на now
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = now
+}
+
+// Advance переводит часы вперед на d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+}
@@ -1,34 +1,243 @@
 package config
 
 import (
-	"github.com/ilyakaznacheev/cleanenv"
-	"log"
+	"fmt"
+	"time"
 )
 
+// Config - настройки сервиса. Источники по возрастанию приоритета: значения env-default,
+// базовый файл, файл окружения, переменные окружения (env), флаги -set; см. Load.
+// Поля с тегом secret скрываются в выводе config print -redacted
 type Config struct {
-	Env  string     `yaml:"env" env-default:"local"`
-	HTTP HTTPConfig `yaml:"http"`
-	DB   DBConfig   `yaml:"postgres"`
+	Env        string           `yaml:"env" env:"APP_ENV" env-default:"local"`
+	HTTP       HTTPConfig       `yaml:"http"`
+	API        APIConfig        `yaml:"api"`
+	Storage    StorageConfig    `yaml:"storage"`
+	DB         DBConfig         `yaml:"postgres"`
+	Validation ValidationConfig `yaml:"validation"`
+	Overlap    OverlapConfig    `yaml:"overlap"`
+	Batch      BatchConfig      `yaml:"batch"`
+	Import     ImportConfig     `yaml:"import"`
+	Calendar   CalendarConfig   `yaml:"calendar"`
+	Reminders  RemindersConfig  `yaml:"reminders"`
+	Admin      AdminConfig      `yaml:"admin"`
+	Webhooks   WebhooksConfig   `yaml:"webhooks"`
+	Outbox     OutboxConfig     `yaml:"outbox"`
+	Ledger     LedgerConfig     `yaml:"ledger"`
+	Budgets    BudgetsConfig    `yaml:"budgets"`
+	Aggregates AggregatesConfig `yaml:"aggregates"`
 }
 
 type HTTPConfig struct {
-	Port string `yaml:"port"`
+	Port            string        `yaml:"port" env:"HTTP_PORT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" env-default:"10s"`
 }
 
-type DBConfig struct {
-	Host     string `yaml:"host"`
-	Port     string `yaml:"port"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Dbname   string `yaml:"dbname"`
-	Sslmode  string `yaml:"sslmode"`
+// APIConfig - версии HTTP API. Текущая версия обслуживается по /api/v1
+type APIConfig struct {
+	Legacy LegacyAPIConfig `yaml:"legacy"`
+}
+
+// LegacyAPIConfig - маршруты без версии (/api/create, /api/record/:id и т.д.), оставленные для старых клиентов.
+// Их ответы получают заголовки Deprecation и Sunset с датами DeprecatedAt и Sunset (YYYY-MM-DD),
+// пустой Sunset - дата отключения не назначена. Enabled: false снимает маршруты
+type LegacyAPIConfig struct {
+	Enabled      bool   `yaml:"enabled" env:"API_LEGACY_ENABLED" env-default:"true"`
+	DeprecatedAt string `yaml:"deprecated_at" env:"API_LEGACY_DEPRECATED_AT" env-default:"2026-10-18"`
+	Sunset       string `yaml:"sunset" env:"API_LEGACY_SUNSET" env-default:"2027-04-30"`
 }
 
-func Load(path string) *Config {
-	var config Config
-	err := cleanenv.ReadConfig(path, &config)
+// legacyDateLayout - формат дат в LegacyAPIConfig
+const legacyDateLayout = "2006-01-02"
+
+// Dates разбирает DeprecatedAt и Sunset. Нулевой sunset - дата отключения не назначена
+func (c LegacyAPIConfig) Dates() (deprecatedAt, sunset time.Time, err error) {
+	deprecatedAt, err = time.Parse(legacyDateLayout, c.DeprecatedAt)
+	if err != nil {
+		return deprecatedAt, sunset, fmt.Errorf("deprecated_at: must be a date in YYYY-MM-DD format, got %q", c.DeprecatedAt)
+	}
+
+	if c.Sunset == "" {
+		return deprecatedAt, sunset, nil
+	}
+
+	sunset, err = time.Parse(legacyDateLayout, c.Sunset)
 	if err != nil {
-		log.Fatalf("cannot read config: %s", err)
+		return deprecatedAt, sunset, fmt.Errorf("sunset: must be a date in YYYY-MM-DD format, got %q", c.Sunset)
 	}
-	return &config
+
+	return deprecatedAt, sunset, nil
+}
+
+// StorageConfig - хранилище записей: postgres, sqlite или memory. С sqlite и memory доступны
+// только операции с записями, в памяти данные живут до остановки процесса
+type StorageConfig struct {
+	Driver string       `yaml:"driver" env:"STORAGE_DRIVER" env-default:"postgres"`
+	SQLite SQLiteConfig `yaml:"sqlite"`
+}
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// SQLiteConfig - файл базы SQLite, ":memory:" - база в памяти
+type SQLiteConfig struct {
+	Path string `yaml:"path" env:"SQLITE_PATH" env-default:"subscriptions.db"`
+}
+
+type DBConfig struct {
+	Host     string `yaml:"host" env:"POSTGRES_HOST"`
+	Port     string `yaml:"port" env:"POSTGRES_PORT"`
+	User     string `yaml:"user" env:"POSTGRES_USER"`
+	Password string `yaml:"password" env:"POSTGRES_PASSWORD" secret:"true"`
+	Dbname   string `yaml:"dbname" env:"POSTGRES_DBNAME"`
+	Sslmode  string `yaml:"sslmode" env:"POSTGRES_SSLMODE"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"POSTGRES_MAX_OPEN_CONNS" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"POSTGRES_MAX_IDLE_CONNS" env-default:"5"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"POSTGRES_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"POSTGRES_CONN_MAX_IDLE_TIME" env-default:"5m"`
+	// StatementTimeout - предел выполнения одного запроса на сервере, 0 - без ограничения
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"POSTGRES_STATEMENT_TIMEOUT"`
+
+	// при старте подключение повторяется с паузой от ConnectBackoff, удваивая ее до ConnectBackoffMax,
+	// пока не истечет ConnectTimeout
+	ConnectTimeout    time.Duration `yaml:"connect_timeout" env:"POSTGRES_CONNECT_TIMEOUT" env-default:"1m"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff" env:"POSTGRES_CONNECT_BACKOFF" env-default:"500ms"`
+	ConnectBackoffMax time.Duration `yaml:"connect_backoff_max" env:"POSTGRES_CONNECT_BACKOFF_MAX" env-default:"10s"`
+
+	Replica ReplicaConfig `yaml:"replica"`
+}
+
+// ReplicaConfig - реплика для чтения списков и сумм. Без host реплика не используется,
+// незаданные поля берутся у основной базы, настройки пула общие
+type ReplicaConfig struct {
+	Host     string `yaml:"host" env:"POSTGRES_REPLICA_HOST"`
+	Port     string `yaml:"port" env:"POSTGRES_REPLICA_PORT"`
+	User     string `yaml:"user" env:"POSTGRES_REPLICA_USER"`
+	Password string `yaml:"password" env:"POSTGRES_REPLICA_PASSWORD" secret:"true"`
+	Dbname   string `yaml:"dbname" env:"POSTGRES_REPLICA_DBNAME"`
+	Sslmode  string `yaml:"sslmode" env:"POSTGRES_REPLICA_SSLMODE"`
+}
+
+// ValidationConfig - проверки записей для всех запросов API, импорта и subsctl. AllowBackdated отключает
+// для всех них запрет уже закончившихся подписок - включается на время загрузки исторических данных
+type ValidationConfig struct {
+	MaxPrice              int  `yaml:"max_price" env:"VALIDATION_MAX_PRICE" env-default:"1000000"`
+	MaxServiceNameLength  int  `yaml:"max_service_name_length" env:"VALIDATION_MAX_SERVICE_NAME_LENGTH" env-default:"255"`
+	MaxSubscriptionMonths int  `yaml:"max_subscription_months" env:"VALIDATION_MAX_SUBSCRIPTION_MONTHS" env-default:"120"`
+	AllowBackdated        bool `yaml:"allow_backdated" env:"VALIDATION_ALLOW_BACKDATED" env-default:"false"`
+}
+
+// OverlapConfig - правило для пересекающихся подписок: reject, merge или warn
+type OverlapConfig struct {
+	Policy string `yaml:"policy" env:"OVERLAP_POLICY" env-default:"reject"`
+}
+
+type BatchConfig struct {
+	MaxItems        int `yaml:"max_items" env:"BATCH_MAX_ITEMS" env-default:"5000"`
+	InsertChunkSize int `yaml:"insert_chunk_size" env:"BATCH_INSERT_CHUNK_SIZE" env-default:"500"`
+}
+
+type ImportConfig struct {
+	ChunkSize int `yaml:"chunk_size" env:"IMPORT_CHUNK_SIZE" env-default:"500"`
+}
+
+// CalendarConfig - лента календаря. Secret подписывает токены лент и обязателен
+type CalendarConfig struct {
+	Secret          string        `yaml:"secret" env:"CALENDAR_SECRET" secret:"true"`
+	HistoryMonths   int           `yaml:"history_months" env:"CALENDAR_HISTORY_MONTHS" env-default:"1"`
+	HorizonMonths   int           `yaml:"horizon_months" env:"CALENDAR_HORIZON_MONTHS" env-default:"12"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"CALENDAR_REFRESH_INTERVAL" env-default:"12h"`
+}
+
+// RemindersConfig - напоминания об окончании подписок. Windows - за сколько дней до окончания
+// напоминать, Channels - каналы доставки: log, webhook, smtp
+type RemindersConfig struct {
+	Enabled  bool          `yaml:"enabled" env:"REMINDERS_ENABLED" env-default:"false"`
+	Interval time.Duration `yaml:"interval" env:"REMINDERS_INTERVAL" env-default:"1h"`
+	Windows  []int         `yaml:"windows" env:"REMINDERS_WINDOWS" env-default:"7,1"`
+	Channels []string      `yaml:"channels" env:"REMINDERS_CHANNELS" env-default:"log"`
+	Webhook  WebhookConfig `yaml:"webhook"`
+	SMTP     SMTPConfig    `yaml:"smtp"`
+}
+
+type WebhookConfig struct {
+	URL     string        `yaml:"url" env:"REMINDERS_WEBHOOK_URL"`
+	Secret  string        `yaml:"secret" env:"REMINDERS_WEBHOOK_SECRET" secret:"true"`
+	Timeout time.Duration `yaml:"timeout" env:"REMINDERS_WEBHOOK_TIMEOUT" env-default:"10s"`
+}
+
+type SMTPConfig struct {
+	Host     string   `yaml:"host" env:"SMTP_HOST"`
+	Port     string   `yaml:"port" env:"SMTP_PORT" env-default:"587"`
+	Username string   `yaml:"username" env:"SMTP_USERNAME"`
+	Password string   `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string   `yaml:"from" env:"SMTP_FROM"`
+	To       []string `yaml:"to" env:"SMTP_TO"`
+}
+
+// AdminConfig - токен административного API, пустой токен отключает его
+type AdminConfig struct {
+	Token string `yaml:"token" env:"ADMIN_TOKEN" secret:"true"`
+}
+
+// WebhooksConfig - доставка событий во внешние системы
+type WebhooksConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOKS_POLL_INTERVAL" env-default:"5s"`
+	BatchSize    int           `yaml:"batch_size" env:"WEBHOOKS_BATCH_SIZE" env-default:"50"`
+	Concurrency  int           `yaml:"concurrency" env:"WEBHOOKS_CONCURRENCY" env-default:"4"`
+	Timeout      time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" env-default:"10s"`
+	MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"8"`
+	BackoffBase  time.Duration `yaml:"backoff_base" env:"WEBHOOKS_BACKOFF_BASE" env-default:"30s"`
+	BackoffMax   time.Duration `yaml:"backoff_max" env:"WEBHOOKS_BACKOFF_MAX" env-default:"6h"`
+}
+
+// OutboxConfig - публикация событий из outbox. Sinks - приемники: webhooks, stdout, nats, kafka
+type OutboxConfig struct {
+	Sinks               []string      `yaml:"sinks" env:"OUTBOX_SINKS" env-default:"webhooks"`
+	PollInterval        time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" env-default:"1s"`
+	BatchSize           int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+	GapTimeout          time.Duration `yaml:"gap_timeout" env:"OUTBOX_GAP_TIMEOUT" env-default:"30s"`
+	Retention           time.Duration `yaml:"retention" env:"OUTBOX_RETENTION" env-default:"168h"`
+	ExpiredInterval     time.Duration `yaml:"expired_interval" env:"OUTBOX_EXPIRED_INTERVAL" env-default:"1h"`
+	ExpiredLookbackDays int           `yaml:"expired_lookback_days" env:"OUTBOX_EXPIRED_LOOKBACK_DAYS" env-default:"2"`
+	NATS                NATSConfig    `yaml:"nats"`
+	Kafka               KafkaConfig   `yaml:"kafka"`
+}
+
+type NATSConfig struct {
+	URL           string `yaml:"url" env:"NATS_URL" env-default:"nats://localhost:4222"`
+	SubjectPrefix string `yaml:"subject_prefix" env:"NATS_SUBJECT_PREFIX" env-default:"subscriptions"`
+}
+
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS"`
+	Topic   string   `yaml:"topic" env:"KAFKA_TOPIC" env-default:"subscriptions.events"`
+}
+
+// LedgerConfig - журнал начислений. Плановый запуск сверяет текущий месяц и LookbackMonths предыдущих
+type LedgerConfig struct {
+	Interval       time.Duration `yaml:"interval" env:"LEDGER_INTERVAL" env-default:"1h"`
+	LookbackMonths int           `yaml:"lookback_months" env:"LEDGER_LOOKBACK_MONTHS" env-default:"1"`
+	BatchSize      int           `yaml:"batch_size" env:"LEDGER_BATCH_SIZE" env-default:"500"`
+}
+
+// BudgetsConfig - проверка бюджетов. Channels - каналы уведомлений о превышении,
+// настройки каналов webhook и smtp берутся из секции reminders
+type BudgetsConfig struct {
+	Interval      time.Duration `yaml:"interval" env:"BUDGETS_INTERVAL" env-default:"6h"`
+	HorizonMonths int           `yaml:"horizon_months" env:"BUDGETS_HORIZON_MONTHS" env-default:"12"`
+	Channels      []string      `yaml:"channels" env:"BUDGETS_CHANNELS" env-default:"log"`
+}
+
+// AggregatesConfig - месячные агрегаты. Они обновляются при каждом изменении записей,
+// плановая задача строит их при первом запуске и затем сверяет с записями
+type AggregatesConfig struct {
+	Read          bool          `yaml:"read" env:"AGGREGATES_READ" env-default:"true"`
+	CheckInterval time.Duration `yaml:"check_interval" env:"AGGREGATES_CHECK_INTERVAL" env-default:"24h"`
+	Repair        bool          `yaml:"repair" env:"AGGREGATES_REPAIR" env-default:"true"`
+	CheckLimit    int           `yaml:"check_limit" env:"AGGREGATES_CHECK_LIMIT" env-default:"100"`
 }
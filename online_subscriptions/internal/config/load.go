@@ -0,0 +1,303 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sources - откуда читаются настройки. Незаданные поля берутся из окружения процесса
+type Sources struct {
+	// Path - базовый файл, по умолчанию CONFIG_PATH
+	Path string
+	// Env - окружение, по умолчанию APP_ENV или env из базового файла. Файл окружения <env>.yaml
+	// ищется рядом с базовым и необязателен
+	Env string
+	// Overrides - значения вида section.key=value, применяются последними
+	Overrides []string
+}
+
+// RegisterFlags добавляет в набор флаги -config, -env и -set
+func (s *Sources) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&s.Path, "config", os.Getenv("CONFIG_PATH"), "base config file")
+	flags.StringVar(&s.Env, "env", "", "environment name, selects <env>.yaml next to the base file")
+	flags.Func("set", "override a value, e.g. -set http.port=:9090 (repeatable)", func(value string) error {
+		s.Overrides = append(s.Overrides, value)
+		return nil
+	})
+}
+
+// Load читает настройки из всех источников и проверяет их
+func Load(sources Sources) (*Config, error) {
+	config, err := Read(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Read читает настройки без проверки. Источники накладываются по порядку: значения env-default,
+// файлы, *_FILE и переменные окружения, флаги -set. Каждый источник заменяет только заданные в нем
+// значения, поэтому явные false и 0 в файле не возвращаются к значениям по умолчанию
+func Read(sources Sources) (*Config, error) {
+	var config Config
+
+	if sources.Path == "" {
+		return nil, errors.New("config file is not set: use -config or CONFIG_PATH")
+	}
+
+	if err := setDefaults(&config); err != nil {
+		return nil, err
+	}
+
+	if err := decodeFile(sources.Path, &config, true); err != nil {
+		return nil, err
+	}
+
+	env := sources.Env
+	if env == "" {
+		env = os.Getenv("APP_ENV")
+	}
+	if env == "" {
+		env = config.Env
+	}
+	if env == "" {
+		env = "local"
+	}
+
+	envPath := filepath.Join(filepath.Dir(sources.Path), env+".yaml")
+	if filepath.Clean(envPath) != filepath.Clean(sources.Path) {
+		if err := decodeFile(envPath, &config, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := readSecretFiles(&config); err != nil {
+		return nil, err
+	}
+
+	if err := readEnv(&config); err != nil {
+		return nil, fmt.Errorf("read environment: %w", err)
+	}
+	config.Env = env
+
+	for _, override := range sources.Overrides {
+		if err := applyOverride(&config, override); err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+// decodeFile накладывает YAML-файл на config: заданные в нем ключи заменяют прежние значения.
+// Неизвестные ключи - ошибка, чтобы опечатка не оставляла значение по умолчанию
+func decodeFile(path string, config *Config, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// setDefaults заполняет поля значениями из тегов env-default
+func setDefaults(config *Config) error {
+	err := setFields(reflect.ValueOf(config).Elem(), func(field reflect.StructField) (string, bool) {
+		return field.Tag.Lookup("env-default")
+	})
+	if err != nil {
+		return fmt.Errorf("default value: %w", err)
+	}
+
+	return nil
+}
+
+// readEnv заменяет значения полей, для которых задана переменная окружения из тега env
+func readEnv(config *Config) error {
+	return setFields(reflect.ValueOf(config).Elem(), func(field reflect.StructField) (string, bool) {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return "", false
+		}
+
+		return os.LookupEnv(name)
+	})
+}
+
+// setFields записывает в поля структуры v значения, которые возвращает lookup, и обходит вложенные структуры
+func setFields(v reflect.Value, lookup func(field reflect.StructField) (string, bool)) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if raw, ok := lookup(field); ok {
+			if err := parseValue(v.Field(i), raw); err != nil {
+				return fmt.Errorf("%s: %w", field.Tag.Get("env"), err)
+			}
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := setFields(v.Field(i), lookup); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseValue разбирает строковое значение переменной окружения. Списки задаются через запятую
+func parseValue(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(duration))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(value)
+	case reflect.Slice:
+		if strings.TrimSpace(raw) == "" {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+			return nil
+		}
+
+		items := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := parseValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// readSecretFiles поддерживает NAME_FILE для любой переменной NAME из тегов env: значение читается
+// из файла (например, docker secret), завершающий перевод строки отбрасывается
+func readSecretFiles(config *Config) error {
+	for _, name := range envNames(reflect.TypeOf(config).Elem()) {
+		path, ok := os.LookupEnv(name + "_FILE")
+		if !ok {
+			continue
+		}
+
+		if _, set := os.LookupEnv(name); set {
+			return fmt.Errorf("both %s and %s_FILE are set", name, name)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s_FILE: %w", name, err)
+		}
+
+		if err := os.Setenv(name, strings.TrimRight(string(data), "\r\n")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func envNames(t reflect.Type) []string {
+	var names []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if name := field.Tag.Get("env"); name != "" {
+			names = append(names, name)
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			names = append(names, envNames(field.Type)...)
+		}
+	}
+
+	return names
+}
+
+// applyOverride применяет значение section.key=value. Значение разбирается как YAML,
+// поэтому списки задаются как [a, b], а длительности как 10s
+func applyOverride(config *Config, override string) error {
+	path, raw, ok := strings.Cut(override, "=")
+	if !ok || path == "" {
+		return fmt.Errorf("invalid override %q: expected section.key=value", override)
+	}
+
+	// пустое значение очищает поле, а не оставляет его прежним, как YAML null
+	var value any = ""
+	if raw != "" {
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+			return fmt.Errorf("invalid override %q: %w", override, err)
+		}
+	}
+
+	keys := strings.Split(path, ".")
+	for i := len(keys) - 1; i >= 0; i-- {
+		value = map[string]any{keys[i]: value}
+	}
+
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("invalid override %q: %w", override, err)
+	}
+
+	return nil
+}
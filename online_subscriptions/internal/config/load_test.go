@@ -0,0 +1,172 @@
+package config_test
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// overrideValues - поля, значения по умолчанию которых не нулевые: true, true и 1000000
+type overrideValues struct {
+	legacyEnabled  bool
+	aggregatesRead bool
+	maxPrice       int
+}
+
+func valuesOf(cfg *config.Config) overrideValues {
+	return overrideValues{
+		legacyEnabled:  cfg.API.Legacy.Enabled,
+		aggregatesRead: cfg.Aggregates.Read,
+		maxPrice:       cfg.Validation.MaxPrice,
+	}
+}
+
+const zeroYAML = `
+api:
+  legacy:
+    enabled: false
+aggregates:
+  read: false
+validation:
+  max_price: 0
+`
+
+// writeConfig записывает базовый файл и файл окружения test.yaml, пустое содержимое - файла окружения нет
+func writeConfig(t *testing.T, base, env string) config.Sources {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	if err := os.WriteFile(path, []byte("env: test\n"+base), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if env != "" {
+		if err := os.WriteFile(filepath.Join(dir, "test.yaml"), []byte(env), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return config.Sources{Path: path, Env: "test"}
+}
+
+func TestReadLayers(t *testing.T) {
+	defaults := overrideValues{legacyEnabled: true, aggregatesRead: true, maxPrice: 1000000}
+	zero := overrideValues{}
+
+	tests := []struct {
+		name      string
+		base      string
+		env       string
+		vars      map[string]string
+		overrides []string
+		want      overrideValues
+	}{
+		{
+			name: "defaults",
+			want: defaults,
+		},
+		{
+			name: "base file",
+			base: zeroYAML,
+			want: zero,
+		},
+		{
+			name: "env file",
+			env:  zeroYAML,
+			want: zero,
+		},
+		{
+			name: "environment",
+			vars: map[string]string{"API_LEGACY_ENABLED": "false", "AGGREGATES_READ": "false", "VALIDATION_MAX_PRICE": "0"},
+			want: zero,
+		},
+		{
+			name:      "set flags",
+			overrides: []string{"api.legacy.enabled=false", "aggregates.read=false", "validation.max_price=0"},
+			want:      zero,
+		},
+		{
+			name: "env file over base file",
+			base: zeroYAML,
+			env:  "api:\n  legacy:\n    enabled: true\n",
+			want: overrideValues{legacyEnabled: true},
+		},
+		{
+			name: "environment over files",
+			base: zeroYAML,
+			vars: map[string]string{"VALIDATION_MAX_PRICE": "500"},
+			want: overrideValues{maxPrice: 500},
+		},
+		{
+			name:      "set flags over environment",
+			vars:      map[string]string{"AGGREGATES_READ": "false"},
+			overrides: []string{"aggregates.read=true"},
+			want:      defaults,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.vars {
+				t.Setenv(name, value)
+			}
+
+			sources := writeConfig(t, tt.base, tt.env)
+			sources.Overrides = tt.overrides
+
+			cfg, err := config.Read(sources)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+
+			if got := valuesOf(cfg); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadEnvironmentTypes(t *testing.T) {
+	t.Setenv("HTTP_SHUTDOWN_TIMEOUT", "3s")
+	t.Setenv("REMINDERS_WINDOWS", "14, 3")
+	t.Setenv("OUTBOX_SINKS", "stdout,nats")
+
+	cfg, err := config.Read(writeConfig(t, "", ""))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if cfg.HTTP.ShutdownTimeout != 3*time.Second {
+		t.Fatalf("shutdown timeout: got %s", cfg.HTTP.ShutdownTimeout)
+	}
+	if !reflect.DeepEqual(cfg.Reminders.Windows, []int{14, 3}) {
+		t.Fatalf("reminder windows: got %v", cfg.Reminders.Windows)
+	}
+	if !reflect.DeepEqual(cfg.Outbox.Sinks, []string{"stdout", "nats"}) {
+		t.Fatalf("outbox sinks: got %v", cfg.Outbox.Sinks)
+	}
+}
+
+func TestReadDefaultListReplacedByFile(t *testing.T) {
+	cfg, err := config.Read(writeConfig(t, "reminders:\n  windows: [30]\n", ""))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if !reflect.DeepEqual(cfg.Reminders.Windows, []int{30}) {
+		t.Fatalf("reminder windows: got %v, want [30]", cfg.Reminders.Windows)
+	}
+}
+
+func TestReadInvalidEnvironment(t *testing.T) {
+	t.Setenv("VALIDATION_MAX_PRICE", "lots")
+
+	if _, err := config.Read(writeConfig(t, "", "")); err == nil {
+		t.Fatal("expected error for invalid VALIDATION_MAX_PRICE")
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+	"io"
+	"reflect"
+)
+
+// redactedValue заменяет непустые секреты в выводе
+const redactedValue = "[redacted]"
+
+// Print выводит итоговые настройки в YAML. С redacted значения полей с тегом secret скрываются
+func Print(w io.Writer, config *Config, redacted bool) error {
+	printed := *config
+
+	if redacted {
+		redact(reflect.ValueOf(&printed).Elem())
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&printed); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// redact скрывает секреты в копии настроек. Вложенные секции - значения, а не указатели,
+// поэтому исходные настройки не меняются
+func redact(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+
+		switch {
+		case value.Type().Field(i).Tag.Get("secret") == "true":
+			if field.Kind() == reflect.String && field.String() != "" {
+				field.SetString(redactedValue)
+			}
+		case field.Kind() == reflect.Struct:
+			redact(field)
+		}
+	}
+}
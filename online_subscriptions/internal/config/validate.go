@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValidationError перечисляет все ошибки настроек, по одной на строку
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate проверяет настройки целиком, чтобы сервис не падал на первой же задаче с неверным значением
+func (c *Config) Validate() error {
+	var problems []string
+
+	add := func(key, format string, args ...any) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	required := func(key, value string) {
+		if value == "" {
+			add(key, "is required")
+		}
+	}
+
+	positive := func(key string, value time.Duration) {
+		if value <= 0 {
+			add(key, "must be positive, got %s", value)
+		}
+	}
+
+	atLeastOne := func(key string, value int) {
+		if value < 1 {
+			add(key, "must be at least 1, got %d", value)
+		}
+	}
+
+	nonNegative := func(key string, value int) {
+		if value < 0 {
+			add(key, "must not be negative, got %d", value)
+		}
+	}
+
+	oneOf := func(key, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			add(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+		}
+	}
+
+	required("http.port", c.HTTP.Port)
+	positive("http.shutdown_timeout", c.HTTP.ShutdownTimeout)
+
+	if c.API.Legacy.Enabled {
+		deprecatedAt, sunset, err := c.API.Legacy.Dates()
+		switch {
+		case err != nil:
+			add("api.legacy", "%s", err)
+		case !sunset.IsZero() && !sunset.After(deprecatedAt):
+			add("api.legacy.sunset", "must be after deprecated_at (%s), got %s", c.API.Legacy.DeprecatedAt, c.API.Legacy.Sunset)
+		}
+	}
+
+	oneOf("storage.driver", c.Storage.Driver, DriverPostgres, DriverSQLite, DriverMemory)
+	oneOf("overlap.policy", c.Overlap.Policy, "reject", "merge", "warn")
+
+	atLeastOne("batch.max_items", c.Batch.MaxItems)
+	atLeastOne("batch.insert_chunk_size", c.Batch.InsertChunkSize)
+	atLeastOne("import.chunk_size", c.Import.ChunkSize)
+
+	switch c.Storage.Driver {
+	case DriverSQLite:
+		required("storage.sqlite.path", c.Storage.SQLite.Path)
+	case DriverPostgres:
+		// остальные секции используются только с Postgres
+		required("postgres.host", c.DB.Host)
+		required("postgres.port", c.DB.Port)
+		required("postgres.user", c.DB.User)
+		required("postgres.dbname", c.DB.Dbname)
+
+		nonNegative("postgres.max_open_conns", c.DB.MaxOpenConns)
+		nonNegative("postgres.max_idle_conns", c.DB.MaxIdleConns)
+		if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+			add("postgres.max_idle_conns", "must not exceed max_open_conns (%d), got %d", c.DB.MaxOpenConns, c.DB.MaxIdleConns)
+		}
+		if c.DB.StatementTimeout < 0 {
+			add("postgres.statement_timeout", "must not be negative, got %s", c.DB.StatementTimeout)
+		}
+		positive("postgres.connect_timeout", c.DB.ConnectTimeout)
+		positive("postgres.connect_backoff", c.DB.ConnectBackoff)
+		if c.DB.ConnectBackoffMax < c.DB.ConnectBackoff {
+			add("postgres.connect_backoff_max", "must not be less than connect_backoff (%s), got %s", c.DB.ConnectBackoff, c.DB.ConnectBackoffMax)
+		}
+
+		required("calendar.secret", c.Calendar.Secret)
+		positive("calendar.refresh_interval", c.Calendar.RefreshInterval)
+
+		positive("webhooks.poll_interval", c.Webhooks.PollInterval)
+		positive("webhooks.timeout", c.Webhooks.Timeout)
+		atLeastOne("webhooks.max_attempts", c.Webhooks.MaxAttempts)
+		atLeastOne("webhooks.concurrency", c.Webhooks.Concurrency)
+
+		positive("outbox.poll_interval", c.Outbox.PollInterval)
+		positive("outbox.expired_interval", c.Outbox.ExpiredInterval)
+
+		positive("ledger.interval", c.Ledger.Interval)
+		positive("budgets.interval", c.Budgets.Interval)
+		positive("aggregates.check_interval", c.Aggregates.CheckInterval)
+
+		if c.Reminders.Enabled {
+			positive("reminders.interval", c.Reminders.Interval)
+
+			if len(c.Reminders.Windows) == 0 {
+				add("reminders.windows", "at least one window is required")
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package entity
+
+import "time"
+
+// MonthlyAggregate - итоги подписок пользователя на сервис за месяц.
+// New* - подписки, начавшиеся в месяце (как сумма за период), Active* - действующие
+// на последний день месяца (как MRR). Строка без новых и действующих подписок не хранится
+type MonthlyAggregate struct {
+	Month       time.Time `gorm:"type:date;primaryKey"`
+	UserID      string    `gorm:"primaryKey;index:idx_monthly_aggregates_key,priority:1"`
+	ServiceName string    `gorm:"primaryKey;index:idx_monthly_aggregates_key,priority:2"`
+	NewCount    int       `gorm:"not null;default:0"`
+	NewPrice    int       `gorm:"not null;default:0"`
+	ActiveCount int       `gorm:"not null;default:0"`
+	ActivePrice int       `gorm:"not null;default:0"`
+}
+
+// AggregateState - отметка о полном построении агрегатов. Пока ее нет, отчеты читают записи напрямую
+type AggregateState struct {
+	ID      uint `gorm:"primaryKey"`
+	BuiltAt time.Time
+}
+
+// AggregateMismatch - расхождение сохраненного агрегата с пересчитанным по записям
+type AggregateMismatch struct {
+	Month               time.Time
+	UserID              string
+	ServiceName         string
+	ExpectedNewCount    int
+	ActualNewCount      int
+	ExpectedNewPrice    int
+	ActualNewPrice      int
+	ExpectedActiveCount int
+	ActualActiveCount   int
+	ExpectedActivePrice int
+	ActualActivePrice   int
+}
+
+// AggregateKey - пользователь и сервис, агрегаты которых пересчитываются вместе
+type AggregateKey struct {
+	UserID      string
+	ServiceName string
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// Budget - месячный лимит расходов пользователя на подписки. CategoryLimits ограничивают
+// отдельные категории, Enforce запрещает создавать подписки сверх лимита
+type Budget struct {
+	ID             uint                  `gorm:"primaryKey"`
+	UserID         string                `gorm:"not null;uniqueIndex"`
+	MonthlyLimit   int                   `gorm:"not null;check:monthly_limit >= 0"`
+	Enforce        bool                  `gorm:"not null;default:false"`
+	CategoryLimits []BudgetCategoryLimit `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type BudgetCategoryLimit struct {
+	ID           uint   `gorm:"primaryKey"`
+	BudgetID     uint   `gorm:"not null;uniqueIndex:idx_budget_category_limits_category"`
+	Category     string `gorm:"not null;uniqueIndex:idx_budget_category_limits_category"`
+	MonthlyLimit int    `gorm:"not null;check:monthly_limit >= 0"`
+}
+
+// BudgetAlert - отправленное уведомление о превышении бюджета. Уникальный ключ не дает
+// повторить уведомление за тот же месяц по тому же лимиту и каналу
+type BudgetAlert struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    string    `gorm:"not null;uniqueIndex:idx_budget_alerts_key"`
+	Period    time.Time `gorm:"type:date;not null;uniqueIndex:idx_budget_alerts_key"`
+	Category  string    `gorm:"not null;uniqueIndex:idx_budget_alerts_key"`
+	Channel   string    `gorm:"not null;uniqueIndex:idx_budget_alerts_key"`
+	Limit     int       `gorm:"not null"`
+	Projected int       `gorm:"not null"`
+	SentAt    time.Time `gorm:"not null"`
+}
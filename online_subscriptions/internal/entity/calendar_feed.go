@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// CalendarFeed - лента календаря пользователя. Токен ленты подписывается вместе с Version,
+// поэтому увеличение версии отзывает все выданные ранее токены
+type CalendarFeed struct {
+	UserID    string `gorm:"primaryKey"`
+	Version   int    `gorm:"not null;default:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// виды записей журнала начислений
+const (
+	LedgerCharge     = "charge"
+	LedgerAdjustment = "adjustment"
+)
+
+// LedgerEntry - строка журнала начислений. На каждую подписку в каждом расчетном месяце
+// приходится ровно одно начисление (частичный уникальный индекс), исправления оформляются
+// корректировками с суммой разницы, записи журнала не изменяются и не удаляются
+type LedgerEntry struct {
+	ID       uint `gorm:"primaryKey"`
+	RecordID uint `gorm:"not null;index;uniqueIndex:idx_ledger_entries_charge,where:kind = 'charge'"`
+	// Period - первое число расчетного месяца
+	Period      time.Time `gorm:"type:date;not null;uniqueIndex:idx_ledger_entries_charge,where:kind = 'charge'"`
+	Kind        string    `gorm:"not null"`
+	UserID      string    `gorm:"not null;index"`
+	ServiceName string    `gorm:"not null"`
+	// ChargeDate - дата списания, по ней журнал суммируется за период
+	ChargeDate time.Time `gorm:"type:date;not null;index"`
+	Amount     int       `gorm:"not null"`
+	// Manual - корректировка внесена вручную, автоматическая сверка ее не трогает
+	Manual    bool `gorm:"not null;default:false"`
+	Reason    string
+	CreatedAt time.Time
+}
+
+// LedgerBalance - итог журнала по подписке за расчетный месяц
+type LedgerBalance struct {
+	RecordID uint
+	Period   time.Time
+	Amount   int
+	// Charged - начисление за месяц уже записано
+	Charged    bool
+	ChargeDate time.Time
+}
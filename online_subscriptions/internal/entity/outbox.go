@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// OutboxEvent - событие, записанное в одной транзакции с изменением записи.
+// ID монотонно растет и задает порядок публикации
+type OutboxEvent struct {
+	ID          uint   `gorm:"primaryKey"`
+	EventID     string `gorm:"not null;uniqueIndex"`
+	Type        string `gorm:"not null"`
+	AggregateID string `gorm:"not null"`
+	Payload     string `gorm:"type:text;not null"`
+	CreatedAt   time.Time
+}
+
+// RelayCheckpoint - последнее событие outbox, опубликованное в приемник
+type RelayCheckpoint struct {
+	Sink        string `gorm:"primaryKey"`
+	LastEventID uint   `gorm:"not null;default:0"`
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// PriceChange - запланированное изменение цены подписки с даты EffectiveFrom.
+// Удаляется вместе с записью
+type PriceChange struct {
+	ID            uint      `gorm:"primaryKey"`
+	RecordID      uint      `gorm:"not null;uniqueIndex:idx_price_changes_effective"`
+	Record        *Record   `gorm:"constraint:OnDelete:CASCADE"`
+	Price         int       `gorm:"not null;check:price >= 0"`
+	EffectiveFrom time.Time `gorm:"type:date;not null;uniqueIndex:idx_price_changes_effective"`
+	CreatedAt     time.Time
+}
@@ -2,11 +2,13 @@ package entity
 
 import "time"
 
+// Record - подписка пользователя. Category необязательна и используется в лимитах бюджета
 type Record struct {
 	ID          uint      `gorm:"primaryKey"`
 	ServiceName string    `gorm:"not null"`
 	Price       int       `gorm:"not null;check:price >= 0"`
 	UserID      string    `gorm:"not null;index"`
+	Category    string    `gorm:"not null;default:''"`
 	CreatedAt   time.Time `gorm:"type:date;not null;default:CURRENT_DATE"`
 	ExpiresAt   time.Time `gorm:"type:date;not null"`
 }
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// SentNotification - отправленное напоминание. Уникальный ключ не дает повторить напоминание
+// после перезапуска; ExpiresAt входит в ключ, чтобы после продления подписки напоминания пришли снова
+type SentNotification struct {
+	ID         uint      `gorm:"primaryKey"`
+	RecordID   uint      `gorm:"not null;uniqueIndex:idx_sent_notifications_key"`
+	Channel    string    `gorm:"not null;uniqueIndex:idx_sent_notifications_key"`
+	WindowDays int       `gorm:"not null;uniqueIndex:idx_sent_notifications_key"`
+	ExpiresAt  time.Time `gorm:"type:date;not null;uniqueIndex:idx_sent_notifications_key"`
+	SentAt     time.Time `gorm:"not null"`
+}
@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+// Webhook - подписка внешней системы на события записей. Events - типы событий через запятую
+type Webhook struct {
+	ID        uint   `gorm:"primaryKey"`
+	URL       string `gorm:"not null"`
+	Secret    string `gorm:"not null"`
+	Events    string `gorm:"not null"`
+	Active    bool   `gorm:"not null;default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookDelivery - доставка одного события одному вебхуку. Пара (WebhookID, EventID) уникальна,
+// поэтому повторная публикация того же события не создает вторую доставку
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primaryKey"`
+	WebhookID      uint      `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	EventID        string    `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	Event          string    `gorm:"not null"`
+	Payload        string    `gorm:"type:text;not null"`
+	Status         string    `gorm:"not null;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts       int       `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time `gorm:"not null;index:idx_webhook_deliveries_due,priority:2"`
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// WebhookAttempt - журнал попыток доставки
+type WebhookAttempt struct {
+	ID         uint `gorm:"primaryKey"`
+	DeliveryID uint `gorm:"not null;index"`
+	Attempt    int  `gorm:"not null"`
+	StatusCode int
+	Error      string
+	Duration   time.Duration `gorm:"not null"`
+	CreatedAt  time.Time
+}
@@ -0,0 +1,200 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// даты выгружаются в том же формате, что принимает API и импорт
+const dateLayout = "02-01-2006"
+
+// Format - формат файла выгрузки
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	FormatXLSX  Format = "xlsx"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatCSV, FormatJSONL, FormatXLSX:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q", value)
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// колонки CSV и XLSX совпадают с колонками импорта по умолчанию, поэтому выгрузку можно загрузить обратно
+var header = []string{"id", "service_name", "price", "user_id", "created_at", "expires_at", "category"}
+
+// Writer пишет записи по одной. Close дописывает хвост файла и должен вызываться после последней записи
+type Writer interface {
+	WriteRecord(record *entity.Record) error
+	Close() error
+}
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	rows   int
+}
+
+// сбрасываем буфер csv каждые flushEvery строк, чтобы клиент получал данные по мере чтения курсора
+const flushEvery = 500
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &csvWriter{writer: writer}, nil
+}
+
+func (c *csvWriter) WriteRecord(record *entity.Record) error {
+	err := c.writer.Write([]string{
+		strconv.FormatUint(uint64(record.ID), 10),
+		record.ServiceName,
+		strconv.Itoa(record.Price),
+		record.UserID,
+		record.CreatedAt.Format(dateLayout),
+		record.ExpiresAt.Format(dateLayout),
+		record.Category,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.rows++
+	if c.rows%flushEvery == 0 {
+		c.writer.Flush()
+		return c.writer.Error()
+	}
+
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// jsonlWriter пишет по одному JSON-объекту в строке, в том же виде, что отдает /records
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonlWriter) WriteRecord(record *entity.Record) error {
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+// xlsxWriter пишет лист потоково: excelize держит в памяти только буфер строк,
+// остальное сбрасывается во временный файл, книга собирается в Close
+type xlsxWriter struct {
+	out       io.Writer
+	file      *excelize.File
+	stream    *excelize.StreamWriter
+	dateStyle int
+	row       int
+}
+
+const xlsxSheet = "Sheet1"
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	dateFormat := "dd-mm-yyyy"
+
+	dateStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	cells := make([]any, len(header))
+	for i, name := range header {
+		cells[i] = name
+	}
+
+	if err := stream.SetRow("A1", cells); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &xlsxWriter{out: w, file: file, stream: stream, dateStyle: dateStyle, row: 1}, nil
+}
+
+func (x *xlsxWriter) WriteRecord(record *entity.Record) error {
+	x.row++
+
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	return x.stream.SetRow(cell, []any{
+		record.ID,
+		record.ServiceName,
+		record.Price,
+		record.UserID,
+		excelize.Cell{StyleID: x.dateStyle, Value: record.CreatedAt},
+		excelize.Cell{StyleID: x.dateStyle, Value: record.ExpiresAt},
+		record.Category,
+	})
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+
+	_, err := x.file.WriteTo(x.out)
+	return err
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+// AdminAuth пускает к административным маршрутам только с заголовком Authorization: Bearer <token>.
+// Пустой token отключает административный API целиком
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token == "" {
+			respondError(ctx, services.NewForbiddenError(services.CodeForbidden, "admin api is disabled", nil))
+			return
+		}
+
+		scheme, credentials, _ := strings.Cut(ctx.GetHeader("Authorization"), " ")
+
+		if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(credentials), []byte(token)) != 1 {
+			ctx.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			respondError(ctx, services.NewUnauthorizedError(services.CodeUnauthorized, "valid admin token is required"))
+			return
+		}
+
+		ctx.Next()
+	}
+}
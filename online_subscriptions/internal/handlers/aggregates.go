@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// AggregateCheckResponse результат сверки месячных агрегатов с записями
+type AggregateCheckResponse struct {
+	// агрегаты построены и используются отчетами
+	Built      bool                        `json:"built"`
+	Mismatches []AggregateMismatchResponse `json:"mismatches"`
+	// сколько пар пользователь/сервис пересчитано
+	Repaired int `json:"repaired"`
+}
+
+// AggregateMismatchResponse расхождение агрегата за месяц
+type AggregateMismatchResponse struct {
+	Month       string          `json:"month" example:"03-2024"`
+	UserID      string          `json:"user_id"`
+	ServiceName string          `json:"service_name"`
+	Expected    AggregateValues `json:"expected"`
+	Actual      AggregateValues `json:"actual"`
+}
+
+// AggregateValues значения агрегата за месяц
+type AggregateValues struct {
+	NewCount    int `json:"new_count"`
+	NewPrice    int `json:"new_price"`
+	ActiveCount int `json:"active_count"`
+	ActivePrice int `json:"active_price"`
+}
+
+// AggregateHandler - административный API месячных агрегатов
+type AggregateHandler struct {
+	AggregateService *services.AggregateService
+}
+
+// NewAggregateHandler создает новый экземпляр AggregateHandler
+func NewAggregateHandler(aggregateService *services.AggregateService) *AggregateHandler {
+	return &AggregateHandler{AggregateService: aggregateService}
+}
+
+// Check сверяет агрегаты с записями
+// @Summary Сверка агрегатов
+// @Description Пересчитывает месячные агрегаты по записям и возвращает расхождения с сохраненными.
+// @Description С repair=true расходящиеся пары пользователь/сервис пересчитываются
+// @Tags Аналитика
+// @Produce json
+// @Security AdminToken
+// @Param repair query bool false "Исправить расхождения" default(false)
+// @Success 200 {object} AggregateCheckResponse "Результат сверки"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/aggregates/check [post]
+// @DeprecatedRouter /aggregates/check [post]
+func (h *AggregateHandler) Check(ctx *gin.Context) {
+	var req struct {
+		Repair bool `form:"repair"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	check, err := h.AggregateService.Check(ctx.Request.Context(), req.Repair)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := AggregateCheckResponse{
+		Built:      check.Built,
+		Mismatches: make([]AggregateMismatchResponse, len(check.Mismatches)),
+		Repaired:   check.Repaired,
+	}
+
+	for i, mismatch := range check.Mismatches {
+		resp.Mismatches[i] = AggregateMismatchResponse{
+			Month:       mismatch.Month.Format(periodLayout),
+			UserID:      mismatch.UserID,
+			ServiceName: mismatch.ServiceName,
+			Expected: AggregateValues{
+				NewCount:    mismatch.ExpectedNewCount,
+				NewPrice:    mismatch.ExpectedNewPrice,
+				ActiveCount: mismatch.ExpectedActiveCount,
+				ActivePrice: mismatch.ExpectedActivePrice,
+			},
+			Actual: AggregateValues{
+				NewCount:    mismatch.ActualNewCount,
+				NewPrice:    mismatch.ActualNewPrice,
+				ActiveCount: mismatch.ActualActiveCount,
+				ActivePrice: mismatch.ActualActivePrice,
+			},
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// Rebuild строит агрегаты заново
+// @Summary Перестроение агрегатов
+// @Description Строит месячные агрегаты заново по всем записям. На время построения изменения записей ждут его завершения
+// @Tags Аналитика
+// @Security AdminToken
+// @Success 204 "Агрегаты построены"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/aggregates/rebuild [post]
+// @DeprecatedRouter /aggregates/rebuild [post]
+func (h *AggregateHandler) Rebuild(ctx *gin.Context) {
+	if err := h.AggregateService.Rebuild(ctx.Request.Context()); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
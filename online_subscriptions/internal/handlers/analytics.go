@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// MRRPointResponse выручка на конец месяца
+type MRRPointResponse struct {
+	Month string `json:"month" example:"03-2024"`
+	MRR   int    `json:"mrr" example:"1500"`
+	// изменение к предыдущему месяцу
+	Change        int `json:"change" example:"200"`
+	Subscriptions int `json:"subscriptions" example:"4"`
+}
+
+// ChurnPointResponse новые и завершившиеся подписки за месяц
+type ChurnPointResponse struct {
+	Month   string `json:"month" example:"03-2024"`
+	New     int    `json:"new" example:"3"`
+	Churned int    `json:"churned" example:"1"`
+	Net     int    `json:"net" example:"2"`
+	// подписки, действующие на начало месяца
+	Active int `json:"active" example:"10"`
+	// доля завершившихся от действующих на начало месяца, %
+	ChurnRate float64 `json:"churn_rate" example:"10"`
+	// прирост с начала периода
+	CumulativeNet int `json:"cumulative_net" example:"5"`
+}
+
+// LifetimeResponse срок жизни подписок
+type LifetimeResponse struct {
+	Total    analytics.Lifetime   `json:"total"`
+	Services []analytics.Lifetime `json:"services"`
+}
+
+// CohortResponse удержание когорты подписок
+type CohortResponse struct {
+	Cohort    string                `json:"cohort" example:"01-2024"`
+	Size      int                   `json:"size" example:"12"`
+	Retention []analytics.Retention `json:"retention"`
+}
+
+// AnalyticsHandler - отчеты по подпискам
+type AnalyticsHandler struct {
+	AnalyticsService *services.AnalyticsService
+	// Clock задает текущий месяц периода по умолчанию
+	Clock clock.Clock
+}
+
+// NewAnalyticsHandler создает новый экземпляр AnalyticsHandler, clk == nil - системные часы
+func NewAnalyticsHandler(analyticsService *services.AnalyticsService, clk clock.Clock) *AnalyticsHandler {
+	return &AnalyticsHandler{AnalyticsService: analyticsService, Clock: clock.OrSystem(clk)}
+}
+
+// analyticsQuery - общие параметры отчетов. По умолчанию период - 12 месяцев по текущий включительно
+type analyticsQuery struct {
+	From        string `form:"from" binding:"omitempty,datetime=01-2006"`
+	To          string `form:"to" binding:"omitempty,datetime=01-2006"`
+	UserID      string `form:"user_id"`
+	ServiceName string `form:"service_name"`
+}
+
+// MRR возвращает ежемесячную выручку
+// @Summary MRR по месяцам
+// @Description Ежемесячная выручка - сумма цен подписок, действующих на последний день месяца, и ее изменение к предыдущему месяцу
+// @Tags Аналитика
+// @Produce json
+// @Param from query string false "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад" example(01-2024)
+// @Param to query string false "Последний месяц (MM-YYYY), по умолчанию текущий" example(12-2024)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
+// @Success 200 {array} MRRPointResponse "Выручка по месяцам"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/analytics/mrr [get]
+// @DeprecatedRouter /analytics/mrr [get]
+func (h *AnalyticsHandler) MRR(ctx *gin.Context) {
+	period, filter, ok := h.bindQuery(ctx, nil)
+	if !ok {
+		return
+	}
+
+	points, err := h.AnalyticsService.MRR(ctx.Request.Context(), period, filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]MRRPointResponse, len(points))
+	for i, point := range points {
+		resp[i] = MRRPointResponse{
+			Month:         point.Month.Format(periodLayout),
+			MRR:           point.MRR,
+			Change:        point.Change,
+			Subscriptions: point.Subscriptions,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// Churn возвращает новые и завершившиеся подписки по месяцам
+// @Summary Новые и завершившиеся подписки
+// @Description Завершившейся считается подписка, срок действия которой истек в этом месяце, но не позже сегодняшнего дня
+// @Tags Аналитика
+// @Produce json
+// @Param from query string false "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад" example(01-2024)
+// @Param to query string false "Последний месяц (MM-YYYY), по умолчанию текущий" example(12-2024)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
+// @Success 200 {array} ChurnPointResponse "Подписки по месяцам"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/analytics/churn [get]
+// @DeprecatedRouter /analytics/churn [get]
+func (h *AnalyticsHandler) Churn(ctx *gin.Context) {
+	period, filter, ok := h.bindQuery(ctx, nil)
+	if !ok {
+		return
+	}
+
+	points, err := h.AnalyticsService.Churn(ctx.Request.Context(), period, filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]ChurnPointResponse, len(points))
+	for i, point := range points {
+		resp[i] = ChurnPointResponse{
+			Month:         point.Month.Format(periodLayout),
+			New:           point.New,
+			Churned:       point.Churned,
+			Net:           point.Net,
+			Active:        point.Active,
+			ChurnRate:     point.ChurnRate,
+			CumulativeNet: point.CumulativeNet,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// TopServices возвращает сервисы с наибольшими расходами или числом подписчиков
+// @Summary Топ сервисов
+// @Description Расходы сервиса - сумма его MRR по месяцам периода, подписчики - уникальные пользователи
+// @Tags Аналитика
+// @Produce json
+// @Param from query string false "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад" example(01-2024)
+// @Param to query string false "Последний месяц (MM-YYYY), по умолчанию текущий" example(12-2024)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param by query string false "Сортировка" Enums(spend, subscribers) default(spend)
+// @Param limit query int false "Количество сервисов (макс. 100)" minimum(1) maximum(100) default(10)
+// @Success 200 {array} analytics.ServiceRank "Сервисы"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/analytics/top-services [get]
+// @DeprecatedRouter /analytics/top-services [get]
+func (h *AnalyticsHandler) TopServices(ctx *gin.Context) {
+	var req struct {
+		By    string `form:"by"`
+		Limit *int   `form:"limit"`
+	}
+
+	period, filter, ok := h.bindQuery(ctx, &req)
+	if !ok {
+		return
+	}
+
+	by := analytics.RankBySpend
+	if req.By != "" {
+		by = analytics.RankBy(req.By)
+	}
+
+	limit := 10
+	if req.Limit != nil {
+		limit = *req.Limit
+	}
+
+	ranks, err := h.AnalyticsService.TopServices(ctx.Request.Context(), period, filter, by, limit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if ranks == nil {
+		ranks = []analytics.ServiceRank{}
+	}
+
+	ctx.JSON(http.StatusOK, ranks)
+}
+
+// Lifetime возвращает средний срок жизни подписок
+// @Summary Срок жизни подписок
+// @Description Средний и медианный срок жизни в днях подписок, начавшихся в периоде. Для действующих подписок срок считается по сегодняшний день
+// @Tags Аналитика
+// @Produce json
+// @Param from query string false "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад" example(01-2024)
+// @Param to query string false "Последний месяц (MM-YYYY), по умолчанию текущий" example(12-2024)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
+// @Success 200 {object} LifetimeResponse "Итог и разбивка по сервисам"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/analytics/lifetime [get]
+// @DeprecatedRouter /analytics/lifetime [get]
+func (h *AnalyticsHandler) Lifetime(ctx *gin.Context) {
+	period, filter, ok := h.bindQuery(ctx, nil)
+	if !ok {
+		return
+	}
+
+	report, err := h.AnalyticsService.Lifetime(ctx.Request.Context(), period, filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, LifetimeResponse{Total: report.Total, Services: report.Services})
+}
+
+// Cohorts возвращает удержание когорт подписок
+// @Summary Когорты по месяцу начала
+// @Description Подписки группируются по месяцу начала. Подписка удержана через N месяцев, если действует на конец N-го месяца после начала когорты
+// @Tags Аналитика
+// @Produce json
+// @Param from query string false "Первый месяц (MM-YYYY), по умолчанию 11 месяцев назад" example(01-2024)
+// @Param to query string false "Последний месяц (MM-YYYY), по умолчанию текущий" example(12-2024)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
+// @Success 200 {array} CohortResponse "Когорты"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/analytics/cohorts [get]
+// @DeprecatedRouter /analytics/cohorts [get]
+func (h *AnalyticsHandler) Cohorts(ctx *gin.Context) {
+	period, filter, ok := h.bindQuery(ctx, nil)
+	if !ok {
+		return
+	}
+
+	cohorts, err := h.AnalyticsService.Cohorts(ctx.Request.Context(), period, filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]CohortResponse, len(cohorts))
+	for i, cohort := range cohorts {
+		resp[i] = CohortResponse{
+			Cohort:    cohort.Month.Format(periodLayout),
+			Size:      cohort.Size,
+			Retention: cohort.Retention,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// bindQuery разбирает период и фильтры отчета, extra - дополнительные параметры конкретного отчета.
+// При ошибке ответ уже отправлен
+func (h *AnalyticsHandler) bindQuery(ctx *gin.Context, extra any) (analytics.Range, analytics.Filter, bool) {
+	var req analyticsQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return analytics.Range{}, analytics.Filter{}, false
+	}
+
+	if extra != nil {
+		if err := ctx.ShouldBindQuery(extra); err != nil {
+			respondBindingError(ctx, err)
+			return analytics.Range{}, analytics.Filter{}, false
+		}
+	}
+
+	to := h.Clock.Now()
+	if req.To != "" {
+		parsed, err := time.Parse(periodLayout, req.To)
+		if err != nil {
+			respondFieldError(ctx, "to", "datetime", "must be a month in MM-YYYY format")
+			return analytics.Range{}, analytics.Filter{}, false
+		}
+		to = parsed
+	}
+
+	from := time.Date(to.Year(), to.Month()-11, 1, 0, 0, 0, 0, time.UTC)
+	if req.From != "" {
+		parsed, err := time.Parse(periodLayout, req.From)
+		if err != nil {
+			respondFieldError(ctx, "from", "datetime", "must be a month in MM-YYYY format")
+			return analytics.Range{}, analytics.Filter{}, false
+		}
+		from = parsed
+	}
+
+	return analytics.NewRange(from, to), analytics.Filter{UserID: req.UserID, ServiceName: req.ServiceName}, true
+}
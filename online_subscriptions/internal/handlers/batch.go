@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// BatchCreateRequest для пакетного создания записей
+type BatchCreateRequest struct {
+	Items []RecordCreateUpdateRequest `json:"items" binding:"required,dive"`
+}
+
+// RecordPatchFields - изменяемые поля записи, отсутствующие поля не меняются
+type RecordPatchFields struct {
+	ServiceName *string `json:"service_name"`
+	Price       *int    `json:"price"`
+	UserID      *string `json:"user_id"`
+	Category    *string `json:"category"`
+	ExpiresAt   *string `json:"expires_at" binding:"omitempty,datetime=02-01-2006"`
+	CreatedAt   *string `json:"created_at" binding:"omitempty,datetime=02-01-2006"`
+}
+
+// RecordPatchRequest для частичного обновления записи в пакете
+type RecordPatchRequest struct {
+	ID uint `json:"id" binding:"required"`
+	RecordPatchFields
+}
+
+// BatchPatchRequest для пакетного частичного обновления записей
+type BatchPatchRequest struct {
+	Items []RecordPatchRequest `json:"items" binding:"required,dive"`
+}
+
+// BatchDeleteRequest для пакетного удаления записей
+type BatchDeleteRequest struct {
+	IDs []uint `json:"ids" binding:"required,dive,gt=0"`
+}
+
+// BatchItemResponse результат обработки одного элемента пакета
+type BatchItemResponse struct {
+	Index    int            `json:"index"`
+	Status   int            `json:"status"`
+	Record   *entity.Record `json:"record,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+	Error    *Problem       `json:"error,omitempty"`
+}
+
+// BatchResponse результат пакетной операции
+type BatchResponse struct {
+	Atomic    bool                `json:"atomic"`
+	Committed bool                `json:"committed"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Items     []BatchItemResponse `json:"items"`
+}
+
+type batchQuery struct {
+	// по умолчанию пакет применяется атомарно
+	Atomic *bool `form:"atomic"`
+}
+
+func (q batchQuery) atomic() bool {
+	return q.Atomic == nil || *q.Atomic
+}
+
+func (r RecordCreateUpdateRequest) toRecord() (entity.Record, error) {
+	record := entity.Record{
+		ServiceName: r.ServiceName,
+		Price:       *r.Price,
+		UserID:      r.UserID,
+		Category:    r.Category,
+	}
+
+	var err error
+
+	record.ExpiresAt, err = time.Parse(dateLayout, r.ExpiresAt)
+	if err != nil {
+		return record, dateFieldError("expires_at")
+	}
+
+	if r.CreatedAt != "" {
+		record.CreatedAt, err = time.Parse(dateLayout, r.CreatedAt)
+		if err != nil {
+			return record, dateFieldError("created_at")
+		}
+	}
+
+	return record, nil
+}
+
+func (r RecordPatchRequest) toPatch() (services.RecordPatch, error) {
+	return r.RecordPatchFields.toPatch(r.ID)
+}
+
+func (r RecordPatchFields) toPatch(id uint) (services.RecordPatch, error) {
+	patch := services.RecordPatch{
+		ID:          id,
+		ServiceName: r.ServiceName,
+		Price:       r.Price,
+		UserID:      r.UserID,
+		Category:    r.Category,
+	}
+
+	for _, field := range []struct {
+		name  string
+		value *string
+		dst   **time.Time
+	}{
+		{"expires_at", r.ExpiresAt, &patch.ExpiresAt},
+		{"created_at", r.CreatedAt, &patch.CreatedAt},
+	} {
+		if field.value == nil {
+			continue
+		}
+
+		parsed, err := time.Parse(dateLayout, *field.value)
+		if err != nil {
+			return patch, dateFieldError(field.name)
+		}
+		*field.dst = &parsed
+	}
+
+	return patch, nil
+}
+
+func dateFieldError(field string) error {
+	return services.NewValidationError("request validation failed", services.FieldError{
+		Field:   field,
+		Code:    "datetime",
+		Message: "must be a date in DD-MM-YYYY format",
+	})
+}
+
+// BatchCreateRecords создает записи пакетом
+// @Summary Пакетное создание подписок
+// @Description Создает записи пакетом. По умолчанию атомарно в одной транзакции, с atomic=false каждая запись обрабатывается отдельно
+// @Tags Подписки
+// @Accept json
+// @Produce json
+// @Param atomic query bool false "Применить пакет в одной транзакции" default(true)
+// @Param input body BatchCreateRequest true "Записи подписок"
+// @Success 200 {object} BatchResponse "Все записи созданы"
+// @Success 207 {object} BatchResponse "Часть записей не создана (atomic=false)"
+// @Failure 400 {object} BatchResponse "Пакет отклонен: ошибка в одной из записей (atomic=true)"
+// @Failure 409 {object} BatchResponse "Пакет отклонен: пересечение подписок (atomic=true)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions:batch [post]
+// @DeprecatedRouter /records:batch [post]
+func (h *RecordHandler) BatchCreateRecords(ctx *gin.Context) {
+	var query batchQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req BatchCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	records := make([]entity.Record, len(req.Items))
+	for i, item := range req.Items {
+		record, err := item.toRecord()
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		records[i] = record
+	}
+
+	result, err := h.RecordService.CreateRecords(ctx.Request.Context(), records, query.atomic())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondBatch(ctx, result, func(item services.BatchItemResult) int {
+		if item.Merged {
+			return http.StatusOK
+		}
+		return http.StatusCreated
+	})
+}
+
+// BatchPatchRecords частично обновляет записи пакетом
+// @Summary Пакетное обновление подписок
+// @Description Частично обновляет записи пакетом, в каждом элементе меняются только переданные поля
+// @Tags Подписки
+// @Accept json
+// @Produce json
+// @Param atomic query bool false "Применить пакет в одной транзакции" default(true)
+// @Param input body BatchPatchRequest true "Изменения записей"
+// @Success 200 {object} BatchResponse "Все записи обновлены"
+// @Success 207 {object} BatchResponse "Часть записей не обновлена (atomic=false)"
+// @Failure 400 {object} BatchResponse "Пакет отклонен: ошибка в одной из записей (atomic=true)"
+// @Failure 404 {object} BatchResponse "Пакет отклонен: запись не найдена (atomic=true)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions:batch [patch]
+// @DeprecatedRouter /records:batch [patch]
+func (h *RecordHandler) BatchPatchRecords(ctx *gin.Context) {
+	var query batchQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req BatchPatchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	patches := make([]services.RecordPatch, len(req.Items))
+	for i, item := range req.Items {
+		patch, err := item.toPatch()
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		patches[i] = patch
+	}
+
+	result, err := h.RecordService.PatchRecords(ctx.Request.Context(), patches, query.atomic())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondBatch(ctx, result, func(services.BatchItemResult) int {
+		return http.StatusOK
+	})
+}
+
+// BatchDeleteRecords удаляет записи пакетом
+// @Summary Пакетное удаление подписок
+// @Description Удаляет записи по списку ID
+// @Tags Подписки
+// @Accept json
+// @Produce json
+// @Param atomic query bool false "Применить пакет в одной транзакции" default(true)
+// @Param input body BatchDeleteRequest true "ID записей"
+// @Success 200 {object} BatchResponse "Все записи удалены"
+// @Success 207 {object} BatchResponse "Часть записей не удалена (atomic=false)"
+// @Failure 404 {object} BatchResponse "Пакет отклонен: запись не найдена (atomic=true)"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions:batch [delete]
+// @DeprecatedRouter /records:batch [delete]
+func (h *RecordHandler) BatchDeleteRecords(ctx *gin.Context) {
+	var query batchQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req BatchDeleteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	result, err := h.RecordService.DeleteRecords(ctx.Request.Context(), req.IDs, query.atomic())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondBatch(ctx, result, func(services.BatchItemResult) int {
+		return http.StatusNoContent
+	})
+}
+
+// respondBatch отдает поэлементный результат пакета. Статус ответа: 200 - все применено,
+// 207 - применено частично, для отклоненного атомарного пакета - статус первой настоящей ошибки
+func respondBatch(ctx *gin.Context, result *services.BatchResult, successStatus func(services.BatchItemResult) int) {
+	resp := BatchResponse{
+		Atomic:    result.Atomic,
+		Committed: result.Committed,
+		Items:     make([]BatchItemResponse, 0, len(result.Items)),
+	}
+
+	status := http.StatusOK
+
+	for _, item := range result.Items {
+		itemResp := BatchItemResponse{Index: item.Index}
+
+		if item.Err != nil {
+			problem := problemFor(ctx, item.Err)
+			itemResp.Status = problem.Status
+			itemResp.Error = &problem
+			resp.Failed++
+
+			if e, ok := services.AsError(item.Err); (!ok || e.Code != services.CodeBatchAborted) && status == http.StatusOK {
+				status = problem.Status
+			}
+		} else {
+			itemResp.Status = successStatus(item)
+			itemResp.Record = item.Record
+			resp.Succeeded++
+
+			for _, w := range item.Warnings {
+				itemResp.Warnings = append(itemResp.Warnings, w.Message)
+			}
+		}
+
+		resp.Items = append(resp.Items, itemResp)
+	}
+
+	if !result.Atomic && resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	ctx.JSON(status, resp)
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// BudgetCreateRequest для создания бюджета пользователя
+type BudgetCreateRequest struct {
+	UserID string `json:"user_id" binding:"required" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	BudgetUpdateRequest
+}
+
+// BudgetUpdateRequest для замены лимитов бюджета
+type BudgetUpdateRequest struct {
+	MonthlyLimit *int `json:"monthly_limit" binding:"required" example:"3000"`
+	// запрещать создание подписок сверх бюджета
+	Enforce bool `json:"enforce"`
+	// лимиты по категориям подписок
+	CategoryLimits map[string]int `json:"category_limits" example:"streaming:1000"`
+}
+
+// BudgetResponse бюджет пользователя
+type BudgetResponse struct {
+	UserID         string         `json:"user_id"`
+	MonthlyLimit   int            `json:"monthly_limit"`
+	Enforce        bool           `json:"enforce"`
+	CategoryLimits map[string]int `json:"category_limits"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// BudgetStatusResponse прогноз расходов пользователя за месяц против бюджета
+type BudgetStatusResponse struct {
+	UserID     string                 `json:"user_id"`
+	Period     string                 `json:"period" example:"03-2024"`
+	Total      services.BudgetUsage   `json:"total"`
+	Categories []services.BudgetUsage `json:"categories"`
+}
+
+// BudgetHandler - административный API бюджетов
+type BudgetHandler struct {
+	BudgetService *services.BudgetService
+}
+
+// NewBudgetHandler создает новый экземпляр BudgetHandler
+func NewBudgetHandler(budgetService *services.BudgetService) *BudgetHandler {
+	return &BudgetHandler{BudgetService: budgetService}
+}
+
+type userIDURI struct {
+	UserID string `uri:"user_id" binding:"required"`
+}
+
+// CreateBudget создает бюджет пользователя
+// @Summary Создание бюджета
+// @Description Задает месячный лимит расходов пользователя на подписки и, при необходимости, лимиты по категориям.
+// @Description С enforce=true создание подписки, после которого прогноз расходов превысит лимит, отклоняется
+// @Tags Бюджеты
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param input body BudgetCreateRequest true "Бюджет"
+// @Success 201 {object} BudgetResponse "Бюджет создан"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 409 {object} Problem "Бюджет пользователя уже существует"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/budgets [post]
+// @DeprecatedRouter /budgets [post]
+func (h *BudgetHandler) CreateBudget(ctx *gin.Context) {
+	var req BudgetCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	budget, err := h.BudgetService.CreateBudget(ctx.Request.Context(), req.input(req.UserID))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, budgetResponse(budget))
+}
+
+// ListBudgets возвращает бюджеты
+// @Summary Список бюджетов
+// @Tags Бюджеты
+// @Produce json
+// @Security AdminToken
+// @Param limit query int false "Лимит записей (макс. 100)" minimum(1) maximum(100) default(20)
+// @Param offset query int false "Смещение" minimum(0) default(0)
+// @Success 200 {array} BudgetResponse "Бюджеты"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/budgets [get]
+// @DeprecatedRouter /budgets [get]
+func (h *BudgetHandler) ListBudgets(ctx *gin.Context) {
+	var req struct {
+		Limit  int `form:"limit"`
+		Offset int `form:"offset"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > 100 {
+		req.Limit = 100
+	}
+
+	budgets, err := h.BudgetService.ListBudgets(ctx.Request.Context(), req.Limit, req.Offset)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]BudgetResponse, len(budgets))
+	for i := range budgets {
+		resp[i] = budgetResponse(&budgets[i])
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// GetBudget возвращает бюджет пользователя
+// @Summary Бюджет пользователя
+// @Tags Бюджеты
+// @Produce json
+// @Security AdminToken
+// @Param user_id path string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Success 200 {object} BudgetResponse "Бюджет"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Бюджет не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/budgets/{user_id} [get]
+// @DeprecatedRouter /budgets/{user_id} [get]
+func (h *BudgetHandler) GetBudget(ctx *gin.Context) {
+	var uri userIDURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	budget, err := h.BudgetService.GetBudget(ctx.Request.Context(), uri.UserID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, budgetResponse(budget))
+}
+
+// UpdateBudget заменяет лимиты бюджета
+// @Summary Изменение бюджета
+// @Description Заменяет лимиты бюджета целиком: категории, не переданные в category_limits, удаляются
+// @Tags Бюджеты
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param user_id path string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param input body BudgetUpdateRequest true "Лимиты"
+// @Success 200 {object} BudgetResponse "Бюджет изменен"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Бюджет не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/budgets/{user_id} [put]
+// @DeprecatedRouter /budgets/{user_id} [put]
+func (h *BudgetHandler) UpdateBudget(ctx *gin.Context) {
+	var uri userIDURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req BudgetUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	budget, err := h.BudgetService.UpdateBudget(ctx.Request.Context(), req.input(uri.UserID))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, budgetResponse(budget))
+}
+
+// DeleteBudget удаляет бюджет пользователя
+// @Summary Удаление бюджета
+// @Tags Бюджеты
+// @Security AdminToken
+// @Param user_id path string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Success 204 "Бюджет удален"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Бюджет не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/budgets/{user_id} [delete]
+// @DeprecatedRouter /budgets/{user_id} [delete]
+func (h *BudgetHandler) DeleteBudget(ctx *gin.Context) {
+	var uri userIDURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if err := h.BudgetService.DeleteBudget(ctx.Request.Context(), uri.UserID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// BudgetStatus возвращает прогноз расходов против бюджета
+// @Summary Состояние бюджета
+// @Description Прогноз расходов за месяц - сумма цен подписок, списание по которым приходится на этот месяц
+// @Tags Бюджеты
+// @Produce json
+// @Security AdminToken
+// @Param user_id path string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param period query string false "Месяц (MM-YYYY), по умолчанию текущий" example(03-2024)
+// @Success 200 {object} BudgetStatusResponse "Прогноз и лимиты"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Бюджет не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/budgets/{user_id}/status [get]
+// @DeprecatedRouter /budgets/{user_id}/status [get]
+func (h *BudgetHandler) BudgetStatus(ctx *gin.Context) {
+	var uri userIDURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req struct {
+		Period string `form:"period" binding:"omitempty,datetime=01-2006"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	// нулевой период - текущий месяц по часам сервиса
+	var period time.Time
+	if req.Period != "" {
+		parsed, err := time.Parse(periodLayout, req.Period)
+		if err != nil {
+			respondFieldError(ctx, "period", "datetime", "must be a month in MM-YYYY format")
+			return
+		}
+		period = parsed
+	}
+
+	status, err := h.BudgetService.Status(ctx.Request.Context(), uri.UserID, period)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	categories := status.Categories
+	if categories == nil {
+		categories = []services.BudgetUsage{}
+	}
+
+	ctx.JSON(http.StatusOK, BudgetStatusResponse{
+		UserID:     status.UserID,
+		Period:     status.Period.Format(periodLayout),
+		Total:      status.Total,
+		Categories: categories,
+	})
+}
+
+func (r BudgetUpdateRequest) input(userID string) services.BudgetInput {
+	return services.BudgetInput{
+		UserID:         userID,
+		MonthlyLimit:   *r.MonthlyLimit,
+		Enforce:        r.Enforce,
+		CategoryLimits: r.CategoryLimits,
+	}
+}
+
+func budgetResponse(budget *entity.Budget) BudgetResponse {
+	limits := make(map[string]int, len(budget.CategoryLimits))
+	for _, limit := range budget.CategoryLimits {
+		limits[limit.Category] = limit.MonthlyLimit
+	}
+
+	return BudgetResponse{
+		UserID:         budget.UserID,
+		MonthlyLimit:   budget.MonthlyLimit,
+		Enforce:        budget.Enforce,
+		CategoryLimits: limits,
+		CreatedAt:      budget.CreatedAt,
+		UpdatedAt:      budget.UpdatedAt,
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FeedTokenResponse токен ленты календаря и готовая ссылка для подписки в календаре
+type FeedTokenResponse struct {
+	Token string `json:"token" example:"v1.q7yM3cN0cVd6Ff2b1kq0lqkqN9o0wq2mTjv6S0b1rXk"`
+	URL   string `json:"url" example:"http://localhost:8080/api/users/60601fee-2bf1-4721-ae6f-7636e79a0cba/calendar.ics?token=v1.q7yM3cN0cVd6Ff2b1kq0lqkqN9o0wq2mTjv6S0b1rXk"`
+}
+
+// CalendarHandler отдает ленты календаря пользователей
+type CalendarHandler struct {
+	CalendarService *services.CalendarService
+}
+
+// NewCalendarHandler создает новый экземпляр CalendarHandler
+func NewCalendarHandler(calendarService *services.CalendarService) *CalendarHandler {
+	return &CalendarHandler{CalendarService: calendarService}
+}
+
+type userURI struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+// IssueFeedToken выдает токен ленты календаря
+// @Summary Токен ленты календаря
+// @Description Возвращает подписанный токен и ссылку на ленту календаря пользователя. Повторный вызов возвращает тот же токен, пока он не отозван
+// @Tags Календарь
+// @Produce json
+// @Security AdminToken
+// @Param id path string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Success 200 {object} FeedTokenResponse "Токен ленты"
+// @Failure 400 {object} Problem "Неверный ID пользователя"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/users/{id}/calendar/token [post]
+// @DeprecatedRouter /users/{id}/calendar/token [post]
+func (h *CalendarHandler) IssueFeedToken(ctx *gin.Context) {
+	var req userURI
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	token, err := h.CalendarService.IssueFeedToken(ctx.Request.Context(), req.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FeedTokenResponse{
+		Token: token,
+		URL:   feedURL(ctx, token),
+	})
+}
+
+// RevokeFeedToken отзывает токены ленты календаря
+// @Summary Отзыв токена ленты календаря
+// @Description Отзывает все выданные токены ленты пользователя, ранее добавленные в календари ссылки перестают работать
+// @Tags Календарь
+// @Security AdminToken
+// @Param id path string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Success 204 "Токены отозваны"
+// @Failure 400 {object} Problem "Неверный ID пользователя"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/users/{id}/calendar/token [delete]
+// @DeprecatedRouter /users/{id}/calendar/token [delete]
+func (h *CalendarHandler) RevokeFeedToken(ctx *gin.Context) {
+	var req userURI
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if err := h.CalendarService.RevokeFeedToken(ctx.Request.Context(), req.ID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// CalendarFeed отдает ленту календаря в формате iCalendar
+// @Summary Лента календаря
+// @Description Лента iCalendar с датами окончания подписок и ежемесячных списаний. UID событий стабильны, календарь обновляет их на месте
+// @Tags Календарь
+// @Produce text/calendar
+// @Param id path string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param token query string true "Токен ленты"
+// @Success 200 {string} string "Лента iCalendar"
+// @Failure 403 {object} Problem "Токен неверный или отозван"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/users/{id}/calendar.ics [get]
+// @DeprecatedRouter /users/{id}/calendar.ics [get]
+func (h *CalendarHandler) CalendarFeed(ctx *gin.Context) {
+	var req struct {
+		ID    string `uri:"id" binding:"required"`
+		Token string `form:"token"`
+	}
+
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	cal, err := h.CalendarService.Feed(ctx.Request.Context(), req.ID, req.Token)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", "text/calendar; charset=utf-8")
+	ctx.Header("Content-Disposition", `inline; filename="subscriptions.ics"`)
+	ctx.Header("Cache-Control", "private, no-store")
+	ctx.Status(http.StatusOK)
+
+	if _, err := cal.WriteTo(ctx.Writer); err != nil {
+		_ = ctx.Error(err)
+	}
+}
+
+// feedURL строит ссылку на ленту относительно пути запроса на выдачу токена
+func feedURL(ctx *gin.Context, token string) string {
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := ctx.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	feed := url.URL{
+		Scheme:   scheme,
+		Host:     ctx.Request.Host,
+		Path:     strings.TrimSuffix(ctx.Request.URL.Path, "/calendar/token") + "/calendar.ics",
+		RawQuery: url.Values{"token": {token}}.Encode(),
+	}
+
+	return feed.String()
+}
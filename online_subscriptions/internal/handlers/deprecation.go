@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deprecation - сроки вывода устаревших маршрутов. Нулевой Sunset - дата отключения не назначена
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+}
+
+// Deprecated помечает ответы устаревшего маршрута заголовками Deprecation (RFC 9745), Sunset (RFC 8594)
+// и ссылкой на замену. Параметры пути в successor (:id) подставляются из запроса
+func Deprecated(d Deprecation, successor string) gin.HandlerFunc {
+	return d.middleware(func(ctx *gin.Context) string {
+		return successor
+	})
+}
+
+// DeprecatedPrefix - Deprecated для группы маршрутов, перенесенных без изменений из префикса from в to
+func DeprecatedPrefix(d Deprecation, from, to string) gin.HandlerFunc {
+	return d.middleware(func(ctx *gin.Context) string {
+		return to + strings.TrimPrefix(ctx.FullPath(), from)
+	})
+}
+
+func (d Deprecation) middleware(successor func(ctx *gin.Context) string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(d.Since.Unix(), 10)
+
+	var sunset string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+
+		header.Set("Deprecation", deprecation)
+		if sunset != "" {
+			header.Set("Sunset", sunset)
+		}
+		header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, expandPath(successor(ctx), ctx.Params)))
+
+		ctx.Next()
+	}
+}
+
+// expandPath подставляет в шаблон пути значения сегментов вида :name
+func expandPath(template string, params gin.Params) string {
+	segments := strings.Split(template, "/")
+
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+
+		if value, found := params.Get(name); found {
+			segments[i] = url.PathEscape(value)
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
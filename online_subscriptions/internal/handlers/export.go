@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/exporter"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportRecords выгружает записи файлом
+// @Summary Выгрузка подписок
+// @Description Выгружает все подписки, подходящие под фильтры /records, без пагинации.
+// @Description Записи читаются из БД курсором и отдаются потоком. Колонки CSV и XLSX совпадают с форматом импорта
+// @Tags Подписки
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "Формат файла" Enums(csv, jsonl, xlsx) default(csv)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
+// @Success 200 {file} file "Файл с подписками"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/export [get]
+// @DeprecatedRouter /records/export [get]
+func (h *RecordHandler) ExportRecords(ctx *gin.Context) {
+	var req struct {
+		Format      string `form:"format"`
+		UserID      string `form:"user_id"`
+		ServiceName string `form:"service_name"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if req.Format == "" {
+		req.Format = string(exporter.FormatCSV)
+	}
+
+	format, err := exporter.ParseFormat(req.Format)
+	if err != nil {
+		respondFieldError(ctx, "format", "oneof", "must be one of: csv jsonl xlsx")
+		return
+	}
+
+	filename := fmt.Sprintf("subscriptions-%s.%s", h.Clock.Now().UTC().Format("20060102-150405"), format.Extension())
+
+	ctx.Header("Content-Type", format.ContentType())
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+
+	writer, err := exporter.NewWriter(format, ctx.Writer)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	err = h.RecordService.ExportRecords(ctx.Request.Context(), req.UserID, req.ServiceName, func(record *entity.Record) error {
+		return writer.WriteRecord(record)
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		// пока в ответ ничего не ушло, можно вернуть ошибку; иначе статус уже отправлен
+		// и клиент получит обрезанный файл
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Disposition")
+			respondError(ctx, err)
+			return
+		}
+
+		_ = ctx.Error(err)
+		ctx.Abort()
+	}
+}
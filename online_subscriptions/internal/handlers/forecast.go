@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// ForecastResponse прогноз расходов по месяцам
+type ForecastResponse struct {
+	From      string                  `json:"from" example:"03-2024"`
+	Months    int                     `json:"months" example:"12"`
+	AutoRenew bool                    `json:"auto_renew"`
+	Total     int                     `json:"total" example:"4800"`
+	Forecast  []ForecastMonthResponse `json:"forecast"`
+}
+
+// ForecastMonthResponse прогноз за месяц с вкладом сервисов
+type ForecastMonthResponse struct {
+	Month    string                         `json:"month" example:"03-2024"`
+	Total    int                            `json:"total" example:"400"`
+	Services []services.ServiceContribution `json:"services"`
+}
+
+// PriceChangeRequest для планирования изменения цены подписки
+type PriceChangeRequest struct {
+	Price         *int   `json:"price" binding:"required" example:"499"`
+	EffectiveFrom string `json:"effective_from" binding:"required,datetime=02-01-2006" example:"01-06-2024"`
+}
+
+// PriceChangeResponse запланированное изменение цены
+type PriceChangeResponse struct {
+	ID            uint      `json:"id"`
+	RecordID      uint      `json:"record_id"`
+	Price         int       `json:"price"`
+	EffectiveFrom string    `json:"effective_from" example:"01-06-2024"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ForecastHandler - прогноз расходов и изменения цен подписок
+type ForecastHandler struct {
+	ForecastService *services.ForecastService
+}
+
+// NewForecastHandler создает новый экземпляр ForecastHandler
+func NewForecastHandler(forecastService *services.ForecastService) *ForecastHandler {
+	return &ForecastHandler{ForecastService: forecastService}
+}
+
+// Forecast возвращает прогноз расходов по месяцам
+// @Summary Прогноз расходов
+// @Description Прогнозирует расходы по месяцам по активным подпискам с учетом срока действия и запланированных изменений цен.
+// @Description С auto_renew=true подписки считаются продленными после окончания по последней известной цене
+// @Tags Аналитика
+// @Produce json
+// @Param from query string false "Первый месяц прогноза (MM-YYYY), по умолчанию текущий" example(03-2024)
+// @Param months query int false "Количество месяцев (макс. 60)" minimum(1) maximum(60) default(12)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
+// @Param auto_renew query bool false "Считать подписки продленными после окончания" default(false)
+// @Success 200 {object} ForecastResponse "Прогноз"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/forecast [get]
+// @DeprecatedRouter /records/forecast [get]
+func (h *ForecastHandler) Forecast(ctx *gin.Context) {
+	var req struct {
+		From        string `form:"from" binding:"omitempty,datetime=01-2006"`
+		Months      *int   `form:"months"`
+		UserID      string `form:"user_id"`
+		ServiceName string `form:"service_name"`
+		AutoRenew   bool   `form:"auto_renew"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	// без from прогноз начинается с текущего месяца по часам сервиса
+	query := services.ForecastQuery{
+		Months:      12,
+		UserID:      req.UserID,
+		ServiceName: req.ServiceName,
+		AutoRenew:   req.AutoRenew,
+	}
+
+	if req.Months != nil {
+		query.Months = *req.Months
+	}
+
+	if req.From != "" {
+		from, err := time.Parse(periodLayout, req.From)
+		if err != nil {
+			respondFieldError(ctx, "from", "datetime", "must be a month in MM-YYYY format")
+			return
+		}
+		query.From = from
+	}
+
+	forecast, err := h.ForecastService.Forecast(ctx.Request.Context(), query)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := ForecastResponse{
+		From:      forecast.From.Format(periodLayout),
+		Months:    len(forecast.Months),
+		AutoRenew: forecast.AutoRenew,
+		Total:     forecast.Total,
+		Forecast:  make([]ForecastMonthResponse, len(forecast.Months)),
+	}
+
+	for i, month := range forecast.Months {
+		resp.Forecast[i] = ForecastMonthResponse{
+			Month:    month.Month.Format(periodLayout),
+			Total:    month.Total,
+			Services: month.Services,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// SchedulePriceChange планирует изменение цены подписки
+// @Summary Запланировать изменение цены
+// @Description Новая цена действует для списаний начиная с effective_from и учитывается в прогнозе расходов
+// @Tags Подписки
+// @Accept json
+// @Produce json
+// @Param id path int true "ID записи" example(1)
+// @Param input body PriceChangeRequest true "Новая цена"
+// @Success 201 {object} PriceChangeResponse "Изменение цены запланировано"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 409 {object} Problem "Изменение цены на эту дату уже запланировано"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/{id}/price-changes [post]
+// @DeprecatedRouter /record/{id}/price-changes [post]
+func (h *ForecastHandler) SchedulePriceChange(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req PriceChangeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	effectiveFrom, err := time.Parse(dateLayout, req.EffectiveFrom)
+	if err != nil {
+		respondFieldError(ctx, "effective_from", "datetime", "must be a date in DD-MM-YYYY format")
+		return
+	}
+
+	change, err := h.ForecastService.SchedulePriceChange(ctx.Request.Context(), uri.ID, *req.Price, effectiveFrom)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, priceChangeResponse(change))
+}
+
+// ListPriceChanges возвращает запланированные изменения цены подписки
+// @Summary Изменения цены подписки
+// @Tags Подписки
+// @Produce json
+// @Param id path int true "ID записи" example(1)
+// @Success 200 {array} PriceChangeResponse "Изменения цены по дате вступления в силу"
+// @Failure 400 {object} Problem "Неверный ID"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/{id}/price-changes [get]
+// @DeprecatedRouter /record/{id}/price-changes [get]
+func (h *ForecastHandler) ListPriceChanges(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	changes, err := h.ForecastService.ListPriceChanges(ctx.Request.Context(), uri.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]PriceChangeResponse, len(changes))
+	for i := range changes {
+		resp[i] = priceChangeResponse(&changes[i])
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// DeletePriceChange отменяет запланированное изменение цены
+// @Summary Отменить изменение цены
+// @Tags Подписки
+// @Param id path int true "ID записи" example(1)
+// @Param change_id path int true "ID изменения цены" example(1)
+// @Success 204 "Изменение цены отменено"
+// @Failure 400 {object} Problem "Неверный ID"
+// @Failure 404 {object} Problem "Изменение цены не найдено"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/{id}/price-changes/{change_id} [delete]
+// @DeprecatedRouter /record/{id}/price-changes/{change_id} [delete]
+func (h *ForecastHandler) DeletePriceChange(ctx *gin.Context) {
+	var uri struct {
+		ID       uint `uri:"id" binding:"required"`
+		ChangeID uint `uri:"change_id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if err := h.ForecastService.DeletePriceChange(ctx.Request.Context(), uri.ID, uri.ChangeID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func priceChangeResponse(change *entity.PriceChange) PriceChangeResponse {
+	return PriceChangeResponse{
+		ID:            change.ID,
+		RecordID:      change.RecordID,
+		Price:         change.Price,
+		EffectiveFrom: change.EffectiveFrom.Format(dateLayout),
+		CreatedAt:     change.CreatedAt,
+	}
+}
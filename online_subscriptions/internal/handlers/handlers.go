@@ -1,23 +1,27 @@
 package handlers
 
 import (
-	"errors"
+	"context"
 	_ "github.com/14kear/effective_mobile/online_subscriptions/docs"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 	"net/http"
 	"time"
 )
 
+// dateLayout - формат дат в запросах (DD-MM-YYYY)
+const dateLayout = "02-01-2006"
+
 // RecordCreateUpdateRequest для создания и обновления записи
 type RecordCreateUpdateRequest struct {
 	ServiceName string `json:"service_name" binding:"required"`
-	Price       int    `json:"price" binding:"required"`
+	Price       *int   `json:"price" binding:"required"`
 	UserID      string `json:"user_id" binding:"required"`
 	ExpiresAt   string `json:"expires_at" binding:"required,datetime=02-01-2006"`
 	CreatedAt   string `json:"created_at" binding:"omitempty,datetime=02-01-2006"`
+	Category    string `json:"category" example:"streaming"`
 }
 
 // RecordQuery для поиска записи по пользователю и сервису
@@ -26,22 +30,55 @@ type RecordQuery struct {
 	UserID      string `json:"user_id" binding:"required"`
 }
 
-// SumPeriodQuery для суммирования платежей за период
+// SumPeriodQuery для суммирования платежей за период: параметры запроса в /v1, тело в /records/summary
 type SumPeriodQuery struct {
-	StartTime   string `json:"start_time" binding:"required,datetime=02-01-2006"`
-	EndTime     string `json:"end_time" binding:"required,datetime=02-01-2006"`
-	UserID      string `json:"user_id"`
-	ServiceName string `json:"service_name"`
+	StartTime   string `json:"start_time" form:"start_time" binding:"required,datetime=02-01-2006"`
+	EndTime     string `json:"end_time" form:"end_time" binding:"required,datetime=02-01-2006"`
+	UserID      string `json:"user_id" form:"user_id"`
+	ServiceName string `json:"service_name" form:"service_name"`
+	// records - по записям подписок, ledger - по журналу начислений
+	Source string `json:"source" form:"source" binding:"omitempty,oneof=records ledger" enums:"records,ledger"`
+}
+
+// SumPeriodResponse сумма платежей за период
+type SumPeriodResponse struct {
+	TotalPrice int `json:"total_price" example:"1500"`
+}
+
+// RecordService - операции с записями, которые использует RecordHandler. Реализуется services.RecordService
+type RecordService interface {
+	CreateRecord(ctx context.Context, record *entity.Record) (*services.WriteResult, error)
+	UpdateRecord(ctx context.Context, record *entity.Record) (*services.WriteResult, error)
+	PatchRecord(ctx context.Context, patch services.RecordPatch) (*services.WriteResult, error)
+	DeleteRecordByID(ctx context.Context, id uint) error
+	GetRecordByID(ctx context.Context, id uint) (*entity.Record, error)
+	GetRecordsByUserID(ctx context.Context, userID string) ([]entity.Record, error)
+	GetRecordByUserIDAndServiceName(ctx context.Context, userID, serviceName string) (*entity.Record, error)
+	ListRecords(ctx context.Context, limit, offset int, userID, serviceName string) ([]entity.Record, error)
+	ExportRecords(ctx context.Context, userID, serviceName string, fn func(record *entity.Record) error) error
+	SummaryPriceOfSelectedRecords(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error)
+	CreateRecords(ctx context.Context, records []entity.Record, atomic bool) (*services.BatchResult, error)
+	PatchRecords(ctx context.Context, patches []services.RecordPatch, atomic bool) (*services.BatchResult, error)
+	DeleteRecords(ctx context.Context, ids []uint, atomic bool) (*services.BatchResult, error)
+}
+
+// LedgerTotals - сумма по журналу начислений для /v1/subscriptions/summary?source=ledger. Реализуется services.LedgerService
+type LedgerTotals interface {
+	SumForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error)
 }
 
 // RecordHandler обрабатывает запросы для записей подписок
 type RecordHandler struct {
-	RecordService *services.RecordService
+	RecordService RecordService
+	LedgerService LedgerTotals
+	// Clock задает время в имени файла выгрузки
+	Clock clock.Clock
 }
 
-// NewRecordHandler создает новый экземпляр RecordHandler
-func NewRecordHandler(recordService *services.RecordService) *RecordHandler {
-	return &RecordHandler{RecordService: recordService}
+// NewRecordHandler создает новый экземпляр RecordHandler. ledgerService может быть nil,
+// тогда сумма по журналу начислений недоступна
+func NewRecordHandler(recordService RecordService, ledgerService LedgerTotals, clk clock.Clock) *RecordHandler {
+	return &RecordHandler{RecordService: recordService, LedgerService: ledgerService, Clock: clock.OrSystem(clk)}
 }
 
 // CreateRecord создает новую запись подписки
@@ -52,54 +89,69 @@ func NewRecordHandler(recordService *services.RecordService) *RecordHandler {
 // @Produce json
 // @Param input body RecordCreateUpdateRequest true "Данные подписки"
 // @Success 201 {object} entity.Record "Созданная запись"
-// @Failure 400 {object} map[string]string "Неверный формат данных"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /create [post]
+// @Success 200 {object} entity.Record "Запись объединена с пересекающейся подпиской (overlap.policy=merge)"
+// @Header 201 {string} Warning "Предупреждение о пересечении с другой подпиской (overlap.policy=warn)"
+// @Failure 400 {object} Problem "Неверный формат данных"
+// @Header 201 {string} Warning "Предупреждение о превышении бюджета пользователя"
+// @Failure 409 {object} Problem "Подписка пересекается с существующей (overlap.policy=reject) или превышает бюджет с enforce=true"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions [post]
+// @DeprecatedRouter /create [post]
 func (h *RecordHandler) CreateRecord(ctx *gin.Context) {
 	var createdAt time.Time
 
 	var req struct {
 		ServiceName string `json:"service_name" binding:"required"`
-		Price       int    `json:"price" binding:"required"`
+		Price       *int   `json:"price" binding:"required"`
 		UserID      string `json:"user_id" binding:"required"`
 		ExpiresAt   string `json:"expires_at" binding:"required,datetime=02-01-2006"`
 		CreatedAt   string `json:"created_at" binding:"omitempty,datetime=02-01-2006"`
+		Category    string `json:"category"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
-	expiresAt, err := time.Parse("02-01-2006", req.ExpiresAt)
+	expiresAt, err := time.Parse(dateLayout, req.ExpiresAt)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Date must be DD-MM-YYYY"})
+		respondFieldError(ctx, "expires_at", "datetime", "must be a date in DD-MM-YYYY format")
 		return
 	}
 
 	if req.CreatedAt != "" {
-		createdAt, err = time.Parse("02-01-2006", req.CreatedAt)
+		createdAt, err = time.Parse(dateLayout, req.CreatedAt)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Date must be DD-MM-YYYY"})
+			respondFieldError(ctx, "created_at", "datetime", "must be a date in DD-MM-YYYY format")
 			return
 		}
 	}
 
 	record := entity.Record{
 		ServiceName: req.ServiceName,
-		Price:       req.Price,
+		Price:       *req.Price,
 		UserID:      req.UserID,
+		Category:    req.Category,
 		ExpiresAt:   expiresAt,
 		CreatedAt:   createdAt,
 	}
 
-	err = h.RecordService.CreateRecord(ctx.Request.Context(), &record)
+	result, err := h.RecordService.CreateRecord(ctx.Request.Context(), &record)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
+		return
+	}
+
+	writeWarnings(ctx, result.Warnings)
+
+	// при объединении с существующей подпиской новая запись не создается
+	if result.Merged {
+		ctx.JSON(http.StatusOK, result.Record)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, record)
+	ctx.JSON(http.StatusCreated, result.Record)
 }
 
 // DeleteRecord удаляет запись подписки
@@ -110,28 +162,24 @@ func (h *RecordHandler) CreateRecord(ctx *gin.Context) {
 // @Produce json
 // @Param id path int true "ID записи" example(1)
 // @Success 204 "Запись успешно удалена"
-// @Failure 400 {object} map[string]string "Неверный ID"
-// @Failure 404 {object} map[string]string "Запись не найдена"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /delete/{id} [delete]
+// @Failure 400 {object} Problem "Неверный ID"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/{id} [delete]
+// @DeprecatedRouter /delete/{id} [delete]
 func (h *RecordHandler) DeleteRecord(ctx *gin.Context) {
 	var req struct {
 		ID uint `uri:"id" binding:"required"`
 	}
 
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
 	err := h.RecordService.DeleteRecordByID(ctx.Request.Context(), req.ID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
-			return
-		}
-
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -140,17 +188,19 @@ func (h *RecordHandler) DeleteRecord(ctx *gin.Context) {
 
 // UpdateRecord обновляет запись подписки
 // @Summary Обновить запись подписки
-// @Description Обновляет существующую запись подписки
+// @Description Заменяет данные существующей записи подписки. Без created_at дата начала остается прежней
 // @Tags Подписки
 // @Accept json
 // @Produce json
 // @Param id path int true "ID записи" example(1)
 // @Param input body RecordCreateUpdateRequest true "Данные для обновления"
 // @Success 204 "Запись успешно обновлена"
-// @Failure 400 {object} map[string]string "Неверные данные"
-// @Failure 404 {object} map[string]string "Запись не найдена"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /update/{id} [put]
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 409 {object} Problem "Подписка пересекается с существующей"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/{id} [put]
+// @DeprecatedRouter /update/{id} [put]
 func (h *RecordHandler) UpdateRecord(ctx *gin.Context) {
 	var createdAt time.Time
 
@@ -159,38 +209,39 @@ func (h *RecordHandler) UpdateRecord(ctx *gin.Context) {
 	}
 
 	if err := ctx.ShouldBindUri(&uri); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
 	if uri.ID == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "id must be > 0"})
+		respondFieldError(ctx, "id", "min", "must be greater than 0")
 		return
 	}
 
 	var req struct {
 		ServiceName string `json:"service_name" binding:"required"`
-		Price       int    `json:"price" binding:"required"`
+		Price       *int   `json:"price" binding:"required"`
 		UserID      string `json:"user_id" binding:"required"`
 		ExpiresAt   string `json:"expires_at" binding:"required,datetime=02-01-2006"`
 		CreatedAt   string `json:"created_at" binding:"omitempty,datetime=02-01-2006"`
+		Category    string `json:"category"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
-	expiresAt, err := time.Parse("02-01-2006", req.ExpiresAt)
+	expiresAt, err := time.Parse(dateLayout, req.ExpiresAt)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Date must be DD-MM-YYYY"})
+		respondFieldError(ctx, "expires_at", "datetime", "must be a date in DD-MM-YYYY format")
 		return
 	}
 
 	if req.CreatedAt != "" {
-		createdAt, err = time.Parse("02-01-2006", req.CreatedAt)
+		createdAt, err = time.Parse(dateLayout, req.CreatedAt)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Date must be DD-MM-YYYY"})
+			respondFieldError(ctx, "created_at", "datetime", "must be a date in DD-MM-YYYY format")
 			return
 		}
 	}
@@ -198,26 +249,73 @@ func (h *RecordHandler) UpdateRecord(ctx *gin.Context) {
 	record := entity.Record{
 		ID:          uri.ID,
 		ServiceName: req.ServiceName,
-		Price:       req.Price,
+		Price:       *req.Price,
 		UserID:      req.UserID,
+		Category:    req.Category,
 		ExpiresAt:   expiresAt,
 		CreatedAt:   createdAt,
 	}
 
-	err = h.RecordService.UpdateRecord(ctx.Request.Context(), &record)
+	result, err := h.RecordService.UpdateRecord(ctx.Request.Context(), &record)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
-			return
-		}
-
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
+	writeWarnings(ctx, result.Warnings)
+
 	ctx.Status(http.StatusNoContent)
 }
 
+// PatchRecord частично обновляет запись подписки
+// @Summary Частично обновить запись подписки
+// @Description Меняет только переданные поля записи, остальные остаются прежними
+// @Tags Подписки
+// @Accept json
+// @Produce json
+// @Param id path int true "ID записи" example(1)
+// @Param input body RecordPatchFields true "Изменяемые поля"
+// @Success 200 {object} entity.Record "Обновленная запись"
+// @Header 200 {string} Warning "Предупреждение о пересечении с другой подпиской или превышении бюджета"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 409 {object} Problem "Подписка пересекается с существующей"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/{id} [patch]
+func (h *RecordHandler) PatchRecord(ctx *gin.Context) {
+	var uri struct {
+		ID uint `uri:"id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req RecordPatchFields
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	patch, err := req.toPatch(uri.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	result, err := h.RecordService.PatchRecord(ctx.Request.Context(), patch)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	writeWarnings(ctx, result.Warnings)
+
+	ctx.JSON(http.StatusOK, result.Record)
+}
+
 // GetRecordByID получает запись подписки по ID
 // @Summary Получить запись подписки
 // @Description Возвращает запись подписки по указанному ID
@@ -226,33 +324,29 @@ func (h *RecordHandler) UpdateRecord(ctx *gin.Context) {
 // @Produce json
 // @Param id path int true "ID записи" example(1)
 // @Success 200 {object} entity.Record "Запись подписки"
-// @Failure 400 {object} map[string]string "Неверный ID"
-// @Failure 404 {object} map[string]string "Запись не найдена"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /record/{id} [get]
+// @Failure 400 {object} Problem "Неверный ID"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/{id} [get]
+// @DeprecatedRouter /record/{id} [get]
 func (h *RecordHandler) GetRecordByID(ctx *gin.Context) {
 	var uri struct {
 		ID uint `uri:"id" binding:"required"`
 	}
 
 	if err := ctx.ShouldBindUri(&uri); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
 	if uri.ID == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "id must be > 0"})
+		respondFieldError(ctx, "id", "min", "must be greater than 0")
 		return
 	}
 
 	record, err := h.RecordService.GetRecordByID(ctx.Request.Context(), uri.ID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
-			return
-		}
-
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -261,15 +355,15 @@ func (h *RecordHandler) GetRecordByID(ctx *gin.Context) {
 
 // GetRecordsByUserID получает записи подписок пользователя
 // @Summary Получить подписки пользователя
-// @Description Возвращает список подписок для указанного пользователя
+// @Description Возвращает список подписок для указанного пользователя. Устарело: используйте GET /v1/subscriptions?user_id=
 // @Tags Подписки
 // @Accept json
 // @Produce json
-// @Param user_id query string true "ID пользователя" example(user123)
+// @Param user_id query string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
 // @Success 200 {array} entity.Record "Список подписок"
-// @Failure 400 {object} map[string]string "Неверный ID пользователя"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /records/user [get]
+// @Failure 400 {object} Problem "Неверный ID пользователя"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @DeprecatedRouter /records/user [get]
 func (h *RecordHandler) GetRecordsByUserID(ctx *gin.Context) {
 	// в ТЗ указано, что управление пользователями вне зоны ответственности моего сервиса,
 	// поэтому в данный момент общаемся json, uri, query, в полной версии я бы доставал uuid из контекста
@@ -278,15 +372,14 @@ func (h *RecordHandler) GetRecordsByUserID(ctx *gin.Context) {
 	}
 
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
 	records, err := h.RecordService.GetRecordsByUserID(ctx.Request.Context(), req.UserID)
 	if err != nil {
 		// 404 возвращать не буду, логичнее здесь просто вернуть пустой список
-
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -295,17 +388,17 @@ func (h *RecordHandler) GetRecordsByUserID(ctx *gin.Context) {
 
 // GetRecordByUserIDAndServiceName получает запись подписки
 // @Summary Найти подписку пользователя
-// @Description Возвращает конкретную подписку пользователя по названию сервиса
+// @Description Возвращает подписку пользователя по названию сервиса, при нескольких - самую позднюю. Устарело: используйте GET /v1/subscriptions?user_id=&service_name=
 // @Tags Подписки
 // @Accept json
 // @Produce json
-// @Param user_id query string true "ID пользователя" example(user123)
+// @Param user_id query string true "ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
 // @Param service_name query string true "Название сервиса" example(Netflix)
 // @Success 200 {object} entity.Record "Запись подписки"
-// @Failure 400 {object} map[string]string "Неверные параметры"
-// @Failure 404 {object} map[string]string "Запись не найдена"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /record/user_service [get]
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @DeprecatedRouter /record/user_service [get]
 func (h *RecordHandler) GetRecordByUserIDAndServiceName(ctx *gin.Context) {
 	var req struct {
 		ServiceName string `form:"service_name" binding:"required"`
@@ -313,18 +406,13 @@ func (h *RecordHandler) GetRecordByUserIDAndServiceName(ctx *gin.Context) {
 	}
 
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
 	record, err := h.RecordService.GetRecordByUserIDAndServiceName(ctx.Request.Context(), req.UserID, req.ServiceName)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
-			return
-		}
-
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -337,14 +425,15 @@ func (h *RecordHandler) GetRecordByUserIDAndServiceName(ctx *gin.Context) {
 // @Tags Подписки
 // @Accept json
 // @Produce json
-// @Param user_id query string false "Фильтр по ID пользователя" example(user123)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
 // @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
 // @Param limit query int false "Лимит записей (макс. 100)" minimum(1) maximum(100) default(20)
 // @Param offset query int false "Смещение" minimum(0) default(0)
 // @Success 200 {array} entity.Record "Список подписок"
-// @Failure 400 {object} map[string]string "Неверные параметры"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /records [get]
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions [get]
+// @DeprecatedRouter /records [get]
 func (h *RecordHandler) ListRecords(ctx *gin.Context) {
 	var req struct {
 		UserID      string `form:"user_id"`
@@ -354,7 +443,7 @@ func (h *RecordHandler) ListRecords(ctx *gin.Context) {
 	}
 
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(ctx, err)
 		return
 	}
 
@@ -369,67 +458,108 @@ func (h *RecordHandler) ListRecords(ctx *gin.Context) {
 
 	records, err := h.RecordService.ListRecords(ctx.Request.Context(), req.Limit, req.Offset, req.UserID, req.ServiceName)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, records)
 }
 
-// SumPriceForPeriod вычисляет сумму платежей
+// SumPriceForPeriod вычисляет сумму платежей по параметрам в теле запроса
 // @Summary Сумма платежей за период
-// @Description Возвращает сумму платежей за указанный период с возможностью фильтрации
+// @Description Возвращает сумму платежей за указанный период, параметры передаются в теле GET-запроса. Устарело: используйте GET /v1/subscriptions/summary
 // @Tags Аналитика
 // @Accept json
 // @Produce json
+// @Param input body SumPeriodQuery true "Период и фильтры"
+// @Success 200 {integer} int "Сумма платежей"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @DeprecatedRouter /records/summary [get]
+func (h *RecordHandler) SumPriceForPeriod(ctx *gin.Context) {
+	var req SumPeriodQuery
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	total, ok := h.sumForPeriod(ctx, req)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, total)
+}
+
+// Summary вычисляет сумму платежей за период
+// @Summary Сумма платежей за период
+// @Description Возвращает сумму платежей за указанный период с возможностью фильтрации
+// @Tags Аналитика
+// @Produce json
 // @Param start_time query string true "Начальная дата (DD-MM-YYYY)" example(01-01-2023)
 // @Param end_time query string true "Конечная дата (DD-MM-YYYY)" example(31-12-2023)
-// @Param user_id query string false "Фильтр по ID пользователя" example(user123)
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
 // @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
-// @Success 200 {object} map[string]int "{"total_price": 1500}"
-// @Failure 400 {object} map[string]string "Неверные параметры"
-// @Failure 500 {object} map[string]string "Внутренняя ошибка сервера"
-// @Router /records/summary [get]
-func (h *RecordHandler) SumPriceForPeriod(ctx *gin.Context) {
-	var req struct {
-		StartTime   string `json:"start_time" binding:"required,datetime=02-01-2006"`
-		EndTime     string `json:"end_time" binding:"required,datetime=02-01-2006"`
-		UserID      string `json:"user_id"`
-		ServiceName string `json:"service_name"`
+// @Param source query string false "Источник: records - цены записей, созданных в периоде; ledger - начисления и корректировки из журнала с датой списания в периоде" Enums(records, ledger) default(records)
+// @Success 200 {object} SumPeriodResponse "Сумма платежей"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/summary [get]
+func (h *RecordHandler) Summary(ctx *gin.Context) {
+	var req SumPeriodQuery
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
 	}
 
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	total, ok := h.sumForPeriod(ctx, req)
+	if !ok {
 		return
 	}
 
-	startTime, err := time.Parse("02-01-2006", req.StartTime)
+	ctx.JSON(http.StatusOK, SumPeriodResponse{TotalPrice: total})
+}
+
+// sumForPeriod проверяет период и считает сумму. При ошибке ответ уже записан и ok = false
+func (h *RecordHandler) sumForPeriod(ctx *gin.Context, req SumPeriodQuery) (total int, ok bool) {
+	startTime, err := time.Parse(dateLayout, req.StartTime)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Date must be DD-MM-YYYY"})
-		return
+		respondFieldError(ctx, "start_time", "datetime", "must be a date in DD-MM-YYYY format")
+		return 0, false
 	}
 
-	endTime, err := time.Parse("02-01-2006", req.EndTime)
+	endTime, err := time.Parse(dateLayout, req.EndTime)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Date must be DD-MM-YYYY"})
-		return
+		respondFieldError(ctx, "end_time", "datetime", "must be a date in DD-MM-YYYY format")
+		return 0, false
 	}
 
 	if endTime.Before(startTime) {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "end time must be greater than start time"})
-		return
+		respondFieldError(ctx, "end_time", "invalid_range", "end time must be greater than start time")
+		return 0, false
 	}
 
-	total, err := h.RecordService.SummaryPriceOfSelectedRecords(
+	sum := h.RecordService.SummaryPriceOfSelectedRecords
+	if req.Source == "ledger" {
+		if h.LedgerService == nil {
+			respondFieldError(ctx, "source", "unsupported", "ledger requires postgres storage")
+			return 0, false
+		}
+		sum = h.LedgerService.SumForPeriod
+	}
+
+	total, err = sum(
 		ctx.Request.Context(),
 		startTime,
 		endTime,
 		req.UserID,
 		req.ServiceName)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		respondError(ctx, err)
+		return 0, false
 	}
 
-	ctx.JSON(http.StatusOK, total)
+	return total, true
 }
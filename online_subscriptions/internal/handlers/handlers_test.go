@@ -0,0 +1,628 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/handlers"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/importer"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/routes"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testUser = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+// testNow - показания часов обработчиков в тестах
+var testNow = time.Date(2025, time.March, 10, 14, 30, 5, 0, time.UTC)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fakeRecords - RecordService над картой записей. Отсутствующая запись - ошибка record_not_found, как у сервиса
+type fakeRecords struct {
+	records map[uint]entity.Record
+	nextID  uint
+
+	// аргументы последнего вызова списка и суммы
+	listLimit, listOffset int
+	sumStart, sumEnd      time.Time
+	sumUser, sumService   string
+}
+
+func newFakeRecords(seed ...entity.Record) *fakeRecords {
+	f := &fakeRecords{records: make(map[uint]entity.Record), nextID: 1}
+	for _, record := range seed {
+		record.ID = f.nextID
+		f.records[record.ID] = record
+		f.nextID++
+	}
+
+	return f
+}
+
+func notFound() error {
+	return services.NewNotFoundError(services.CodeRecordNotFound, "record not found", nil)
+}
+
+func (f *fakeRecords) CreateRecord(ctx context.Context, record *entity.Record) (*services.WriteResult, error) {
+	record.ID = f.nextID
+	f.nextID++
+	f.records[record.ID] = *record
+
+	return &services.WriteResult{Record: record}, nil
+}
+
+func (f *fakeRecords) UpdateRecord(ctx context.Context, record *entity.Record) (*services.WriteResult, error) {
+	if _, ok := f.records[record.ID]; !ok {
+		return nil, notFound()
+	}
+	f.records[record.ID] = *record
+
+	return &services.WriteResult{Record: record}, nil
+}
+
+func (f *fakeRecords) PatchRecord(ctx context.Context, patch services.RecordPatch) (*services.WriteResult, error) {
+	record, ok := f.records[patch.ID]
+	if !ok {
+		return nil, notFound()
+	}
+	patch.Apply(&record)
+	f.records[record.ID] = record
+
+	return &services.WriteResult{Record: &record}, nil
+}
+
+func (f *fakeRecords) DeleteRecordByID(ctx context.Context, id uint) error {
+	if _, ok := f.records[id]; !ok {
+		return notFound()
+	}
+	delete(f.records, id)
+
+	return nil
+}
+
+func (f *fakeRecords) GetRecordByID(ctx context.Context, id uint) (*entity.Record, error) {
+	record, ok := f.records[id]
+	if !ok {
+		return nil, notFound()
+	}
+
+	return &record, nil
+}
+
+func (f *fakeRecords) GetRecordsByUserID(ctx context.Context, userID string) ([]entity.Record, error) {
+	return f.filter(userID, ""), nil
+}
+
+func (f *fakeRecords) GetRecordByUserIDAndServiceName(ctx context.Context, userID, serviceName string) (*entity.Record, error) {
+	records := f.filter(userID, serviceName)
+	if len(records) == 0 {
+		return nil, notFound()
+	}
+
+	return &records[0], nil
+}
+
+func (f *fakeRecords) ListRecords(ctx context.Context, limit, offset int, userID, serviceName string) ([]entity.Record, error) {
+	f.listLimit, f.listOffset = limit, offset
+
+	records := f.filter(userID, serviceName)
+	if offset >= len(records) {
+		return []entity.Record{}, nil
+	}
+	records = records[offset:]
+	if limit < len(records) {
+		records = records[:limit]
+	}
+
+	return records, nil
+}
+
+func (f *fakeRecords) ExportRecords(ctx context.Context, userID, serviceName string, fn func(record *entity.Record) error) error {
+	for _, record := range f.filter(userID, serviceName) {
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeRecords) SummaryPriceOfSelectedRecords(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
+	f.sumStart, f.sumEnd, f.sumUser, f.sumService = startTime, endTime, userID, serviceName
+
+	total := 0
+	for _, record := range f.filter(userID, serviceName) {
+		total += record.Price
+	}
+
+	return total, nil
+}
+
+func (f *fakeRecords) CreateRecords(ctx context.Context, records []entity.Record, atomic bool) (*services.BatchResult, error) {
+	result := &services.BatchResult{Atomic: atomic, Committed: true}
+	for i := range records {
+		written, _ := f.CreateRecord(ctx, &records[i])
+		result.Items = append(result.Items, services.BatchItemResult{Index: i, Record: written.Record})
+	}
+
+	return result, nil
+}
+
+func (f *fakeRecords) PatchRecords(ctx context.Context, patches []services.RecordPatch, atomic bool) (*services.BatchResult, error) {
+	result := &services.BatchResult{Atomic: atomic, Committed: true}
+	for i, patch := range patches {
+		item := services.BatchItemResult{Index: i}
+		written, err := f.PatchRecord(ctx, patch)
+		if err != nil {
+			item.Err = err
+			result.Committed = false
+		} else {
+			item.Record = written.Record
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	return result, nil
+}
+
+func (f *fakeRecords) DeleteRecords(ctx context.Context, ids []uint, atomic bool) (*services.BatchResult, error) {
+	result := &services.BatchResult{Atomic: atomic, Committed: true}
+	for i, id := range ids {
+		item := services.BatchItemResult{Index: i}
+		if err := f.DeleteRecordByID(ctx, id); err != nil {
+			item.Err = err
+			result.Committed = false
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	return result, nil
+}
+
+func (f *fakeRecords) ImportRecords(ctx context.Context, rows []services.ImportRow, dryRun bool) ([]services.ImportRowResult, error) {
+	results := make([]services.ImportRowResult, len(rows))
+	for i, row := range rows {
+		results[i] = services.ImportRowResult{Line: row.Line, Action: services.ImportFailed, Err: row.Err}
+		if row.Err != nil {
+			continue
+		}
+
+		results[i].Action = services.ImportCreated
+		if !dryRun {
+			written, _ := f.CreateRecord(ctx, &row.Record)
+			results[i].RecordID = written.Record.ID
+		}
+	}
+
+	return results, nil
+}
+
+func (f *fakeRecords) filter(userID, serviceName string) []entity.Record {
+	records := []entity.Record{}
+	for _, record := range f.records {
+		if (userID == "" || record.UserID == userID) && (serviceName == "" || record.ServiceName == serviceName) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	return records
+}
+
+// fakeLedger - сумма по журналу начислений, всегда одна и та же
+type fakeLedger struct {
+	total int
+}
+
+func (l fakeLedger) SumForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
+	return l.total, nil
+}
+
+// newRouter собирает маршруты API v1 так же, как приложение. Обработчики календаря, прогноза и аналитики
+// без сервисов: тесты обращаются только к операциям с подписками
+func newRouter(records *fakeRecords, ledger handlers.LedgerTotals) *gin.Engine {
+	return newRouterWithReports(records, ledger, newReports())
+}
+
+// newRouterWithReports собирает API v1, отчеты читают хранилища rep
+func newRouterWithReports(records *fakeRecords, ledger handlers.LedgerTotals, rep *reports) *gin.Engine {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	calendarHandler, forecastHandler, analyticsHandler := rep.handlers()
+
+	r := gin.New()
+	r.NoRoute(handlers.NotFound)
+	v1 := r.Group("/api/v1")
+
+	routes.RegisterRoutes(v1,
+		handlers.NewRecordHandler(records, ledger, clock.NewFake(testNow)),
+		handlers.NewImportHandler(importer.NewImporter(log, records, 0)),
+		calendarHandler,
+		forecastHandler,
+		analyticsHandler,
+	)
+
+	return r
+}
+
+func seedRecord() entity.Record {
+	return entity.Record{
+		ServiceName: "Netflix",
+		Price:       400,
+		UserID:      testUser,
+		CreatedAt:   time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		ExpiresAt:   time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+type request struct {
+	method      string
+	path        string
+	body        string
+	contentType string
+}
+
+func serve(r http.Handler, req request) *httptest.ResponseRecorder {
+	var body io.Reader
+	if req.body != "" {
+		body = strings.NewReader(req.body)
+	}
+
+	httpReq := httptest.NewRequest(req.method, req.path, body)
+	switch {
+	case req.contentType != "":
+		httpReq.Header.Set("Content-Type", req.contentType)
+	case req.body != "":
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httpReq)
+
+	return rec
+}
+
+// assertProblem проверяет ответ problem+json: статус, код и стандартные поля RFC 7807
+func assertProblem(t *testing.T, rec *httptest.ResponseRecorder, path string, status int, code string) handlers.Problem {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("status: got %d, want %d, body %s", rec.Code, status, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/problem+json") {
+		t.Fatalf("content type: got %q, want application/problem+json", got)
+	}
+
+	var problem handlers.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v, body %s", err, rec.Body)
+	}
+
+	if problem.Code != code {
+		t.Fatalf("problem code: got %q, want %q, body %s", problem.Code, code, rec.Body)
+	}
+	if problem.Status != status {
+		t.Fatalf("problem status: got %d, want %d", problem.Status, status)
+	}
+	if problem.Type != "urn:online-subscriptions:problem:"+code {
+		t.Fatalf("problem type: got %q", problem.Type)
+	}
+	if wantInstance, _, _ := strings.Cut(path, "?"); problem.Instance != wantInstance {
+		t.Fatalf("problem instance: got %q, want %q", problem.Instance, wantInstance)
+	}
+
+	return problem
+}
+
+func TestRecordRoutes(t *testing.T) {
+	const record = `{"service_name":"Spotify","price":%s,"user_id":"` + testUser + `","expires_at":"01-06-2025"}`
+
+	valid := strings.Replace(record, "%s", "300", 1)
+
+	tests := []struct {
+		name   string
+		req    request
+		status int
+		// code - код problem+json, пустой для успешного ответа
+		code string
+		// field - поле, которое должно быть в errors ответа
+		field string
+	}{
+		{"create", request{method: http.MethodPost, path: "/api/v1/subscriptions", body: valid}, http.StatusCreated, "", ""},
+		{"create zero price", request{method: http.MethodPost, path: "/api/v1/subscriptions", body: strings.Replace(record, "%s", "0", 1)}, http.StatusCreated, "", ""},
+		{"create missing price", request{method: http.MethodPost, path: "/api/v1/subscriptions", body: `{"service_name":"Spotify","user_id":"` + testUser + `","expires_at":"01-06-2025"}`}, http.StatusBadRequest, services.CodeValidationFailed, "price"},
+		{"create wrong type", request{method: http.MethodPost, path: "/api/v1/subscriptions", body: strings.Replace(record, "%s", `"300"`, 1)}, http.StatusBadRequest, services.CodeValidationFailed, "price"},
+		{"create bad date", request{method: http.MethodPost, path: "/api/v1/subscriptions", body: strings.Replace(valid, "01-06-2025", "2025-06-01", 1)}, http.StatusBadRequest, services.CodeValidationFailed, "expires_at"},
+		{"create malformed json", request{method: http.MethodPost, path: "/api/v1/subscriptions", body: `{"service_name":`}, http.StatusBadRequest, services.CodeMalformedRequest, ""},
+
+		{"list", request{method: http.MethodGet, path: "/api/v1/subscriptions?user_id=" + testUser}, http.StatusOK, "", ""},
+		{"list bad limit", request{method: http.MethodGet, path: "/api/v1/subscriptions?limit=ten"}, http.StatusBadRequest, services.CodeMalformedRequest, ""},
+
+		{"get", request{method: http.MethodGet, path: "/api/v1/subscriptions/1"}, http.StatusOK, "", ""},
+		{"get missing", request{method: http.MethodGet, path: "/api/v1/subscriptions/42"}, http.StatusNotFound, services.CodeRecordNotFound, ""},
+		{"get zero id", request{method: http.MethodGet, path: "/api/v1/subscriptions/0"}, http.StatusBadRequest, services.CodeValidationFailed, "id"},
+		{"get bad id", request{method: http.MethodGet, path: "/api/v1/subscriptions/abc"}, http.StatusBadRequest, services.CodeMalformedRequest, ""},
+
+		{"update", request{method: http.MethodPut, path: "/api/v1/subscriptions/1", body: valid}, http.StatusNoContent, "", ""},
+		{"update missing", request{method: http.MethodPut, path: "/api/v1/subscriptions/42", body: valid}, http.StatusNotFound, services.CodeRecordNotFound, ""},
+		{"update missing field", request{method: http.MethodPut, path: "/api/v1/subscriptions/1", body: `{"price":300}`}, http.StatusBadRequest, services.CodeValidationFailed, "service_name"},
+
+		{"patch", request{method: http.MethodPatch, path: "/api/v1/subscriptions/1", body: `{"price":0}`}, http.StatusOK, "", ""},
+		{"patch missing", request{method: http.MethodPatch, path: "/api/v1/subscriptions/42", body: `{"price":500}`}, http.StatusNotFound, services.CodeRecordNotFound, ""},
+		{"patch bad date", request{method: http.MethodPatch, path: "/api/v1/subscriptions/1", body: `{"expires_at":"2025-06-01"}`}, http.StatusBadRequest, services.CodeValidationFailed, "expires_at"},
+
+		{"delete", request{method: http.MethodDelete, path: "/api/v1/subscriptions/1"}, http.StatusNoContent, "", ""},
+		{"delete missing", request{method: http.MethodDelete, path: "/api/v1/subscriptions/42"}, http.StatusNotFound, services.CodeRecordNotFound, ""},
+
+		{"export", request{method: http.MethodGet, path: "/api/v1/subscriptions/export?format=jsonl"}, http.StatusOK, "", ""},
+		{"export bad format", request{method: http.MethodGet, path: "/api/v1/subscriptions/export?format=pdf"}, http.StatusBadRequest, services.CodeValidationFailed, "format"},
+
+		{"import missing file", request{method: http.MethodPost, path: "/api/v1/subscriptions/import", body: "--x--\r\n", contentType: "multipart/form-data; boundary=x"}, http.StatusBadRequest, services.CodeValidationFailed, "file"},
+
+		{"batch create", request{method: http.MethodPost, path: "/api/v1/subscriptions:batch", body: `{"items":[` + valid + `]}`}, http.StatusOK, "", ""},
+		{"batch create invalid item", request{method: http.MethodPost, path: "/api/v1/subscriptions:batch", body: `{"items":[{"price":1}]}`}, http.StatusBadRequest, services.CodeValidationFailed, "items[0].service_name"},
+		{"batch patch", request{method: http.MethodPatch, path: "/api/v1/subscriptions:batch", body: `{"items":[{"id":1,"price":500}]}`}, http.StatusOK, "", ""},
+		{"batch delete missing", request{method: http.MethodDelete, path: "/api/v1/subscriptions:batch?atomic=false", body: `{"ids":[42]}`}, http.StatusMultiStatus, "", ""},
+		{"batch delete zero id", request{method: http.MethodDelete, path: "/api/v1/subscriptions:batch", body: `{"ids":[0]}`}, http.StatusBadRequest, services.CodeValidationFailed, "ids[0]"},
+		{"unknown custom method", request{method: http.MethodPost, path: "/api/v1/subscriptions:purge", body: `{}`}, http.StatusNotFound, services.CodeRouteNotFound, ""},
+
+		{"unknown route", request{method: http.MethodGet, path: "/api/v1/records"}, http.StatusNotFound, services.CodeRouteNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newRouter(newFakeRecords(seedRecord()), nil), tt.req)
+
+			if tt.code == "" {
+				if rec.Code != tt.status {
+					t.Fatalf("status: got %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+				}
+				return
+			}
+
+			problem := assertProblem(t, rec, tt.req.path, tt.status, tt.code)
+
+			if tt.field == "" {
+				return
+			}
+			for _, field := range problem.Errors {
+				if field.Field == tt.field {
+					return
+				}
+			}
+			t.Fatalf("expected error for field %q, got %+v", tt.field, problem.Errors)
+		})
+	}
+}
+
+func TestExportFilenameUsesClock(t *testing.T) {
+	rec := serve(newRouter(newFakeRecords(seedRecord()), nil), request{method: http.MethodGet, path: "/api/v1/subscriptions/export?format=jsonl"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body)
+	}
+
+	want := `attachment; filename="subscriptions-20250310-143005.jsonl"`
+	if got := rec.Header().Get("Content-Disposition"); got != want {
+		t.Fatalf("Content-Disposition: got %q, want %q", got, want)
+	}
+}
+
+func TestGetRecord(t *testing.T) {
+	rec := serve(newRouter(newFakeRecords(seedRecord()), nil), request{method: http.MethodGet, path: "/api/v1/subscriptions/1"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body)
+	}
+
+	var got entity.Record
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+
+	want := seedRecord()
+	want.ID = 1
+	if got.ID != want.ID || got.ServiceName != want.ServiceName || got.Price != want.Price || got.UserID != want.UserID {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestListRecordsLimit(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantLimit int
+	}{
+		{"", 20},
+		{"?limit=5&offset=2", 5},
+		{"?limit=1000", 100},
+	}
+
+	for _, tt := range tests {
+		records := newFakeRecords(seedRecord())
+
+		rec := serve(newRouter(records, nil), request{method: http.MethodGet, path: "/api/v1/subscriptions" + tt.query})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d, body %s", tt.query, rec.Code, rec.Body)
+		}
+		if records.listLimit != tt.wantLimit {
+			t.Fatalf("%q: limit passed to service %d, want %d", tt.query, records.listLimit, tt.wantLimit)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	records := newFakeRecords(seedRecord())
+	r := newRouter(records, fakeLedger{total: 777})
+
+	rec := serve(r, request{method: http.MethodGet, path: "/api/v1/subscriptions/summary?start_time=01-01-2025&end_time=01-03-2025&user_id=" + testUser + "&service_name=Netflix"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body)
+	}
+
+	var resp handlers.SumPeriodResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if resp.TotalPrice != 400 {
+		t.Fatalf("total_price: got %d, want 400", resp.TotalPrice)
+	}
+
+	wantStart := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	wantEnd := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if !records.sumStart.Equal(wantStart) || !records.sumEnd.Equal(wantEnd) || records.sumUser != testUser || records.sumService != "Netflix" {
+		t.Fatalf("service called with %s..%s user %q service %q", records.sumStart, records.sumEnd, records.sumUser, records.sumService)
+	}
+
+	// по журналу начислений сумму считает LedgerTotals
+	rec = serve(r, request{method: http.MethodGet, path: "/api/v1/subscriptions/summary?start_time=01-01-2025&end_time=01-03-2025&source=ledger"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ledger status: got %d, body %s", rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if resp.TotalPrice != 777 {
+		t.Fatalf("ledger total_price: got %d, want 777", resp.TotalPrice)
+	}
+}
+
+func TestSummaryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		ledger handlers.LedgerTotals
+		field  string
+	}{
+		{"missing start", "end_time=01-03-2025", fakeLedger{}, "start_time"},
+		{"bad date", "start_time=2025-01-01&end_time=01-03-2025", fakeLedger{}, "start_time"},
+		{"reversed period", "start_time=01-03-2025&end_time=01-01-2025", fakeLedger{}, "end_time"},
+		{"unknown source", "start_time=01-01-2025&end_time=01-03-2025&source=cache", fakeLedger{}, "source"},
+		{"ledger unavailable", "start_time=01-01-2025&end_time=01-03-2025&source=ledger", nil, "source"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/api/v1/subscriptions/summary?" + tt.query
+			rec := serve(newRouter(newFakeRecords(), tt.ledger), request{method: http.MethodGet, path: path})
+
+			problem := assertProblem(t, rec, path, http.StatusBadRequest, services.CodeValidationFailed)
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field {
+				t.Fatalf("expected error for field %q, got %+v", tt.field, problem.Errors)
+			}
+		})
+	}
+}
+
+func TestImportRecords(t *testing.T) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	file, err := form.CreateFormFile("file", "records.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.WriteString(file, "service_name,price,user_id,created_at,expires_at\nSpotify,300,"+testUser+",01-01-2025,01-06-2025\n")
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := newFakeRecords()
+	rec := serve(newRouter(records, nil), request{
+		method:      http.MethodPost,
+		path:        "/api/v1/subscriptions/import",
+		body:        body.String(),
+		contentType: form.FormDataContentType(),
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body)
+	}
+
+	var resp handlers.ImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode import report: %v", err)
+	}
+	if resp.Total != 1 || resp.Created != 1 || len(records.records) != 1 {
+		t.Fatalf("import report %+v, stored %d records", resp, len(records.records))
+	}
+}
+
+func TestBatchDeleteAtomicNotFound(t *testing.T) {
+	rec := serve(newRouter(newFakeRecords(seedRecord()), nil), request{method: http.MethodDelete, path: "/api/v1/subscriptions:batch", body: `{"ids":[1,42]}`})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status: got %d, want 404, body %s", rec.Code, rec.Body)
+	}
+
+	var resp handlers.BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode batch response: %v", err)
+	}
+	if resp.Failed != 1 || resp.Items[1].Error == nil || resp.Items[1].Error.Code != services.CodeRecordNotFound {
+		t.Fatalf("unexpected batch response %+v", resp)
+	}
+}
+
+func TestLegacyRecordRoutes(t *testing.T) {
+	newLegacyRouter := func() *gin.Engine {
+		records := newFakeRecords(seedRecord())
+		log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+		r := gin.New()
+		r.NoRoute(handlers.NotFound)
+		deprecation := handlers.Deprecation{Since: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)}
+		routes.RegisterLegacyRecordRoutes(r.Group("/api"), deprecation, "/api/v1",
+			handlers.NewRecordHandler(records, nil, nil),
+			handlers.NewImportHandler(importer.NewImporter(log, records, 0)),
+		)
+
+		return r
+	}
+
+	tests := []struct {
+		name      string
+		req       request
+		status    int
+		code      string
+		successor string
+	}{
+		{"by user", request{method: http.MethodGet, path: "/api/records/user?user_id=" + testUser}, http.StatusOK, "", "/api/v1/subscriptions"},
+		{"by user missing param", request{method: http.MethodGet, path: "/api/records/user"}, http.StatusBadRequest, services.CodeValidationFailed, "/api/v1/subscriptions"},
+		{"by user and service", request{method: http.MethodGet, path: "/api/record/user_service?user_id=" + testUser + "&service_name=Netflix"}, http.StatusOK, "", "/api/v1/subscriptions"},
+		{"by user and service missing", request{method: http.MethodGet, path: "/api/record/user_service?user_id=" + testUser + "&service_name=Okko"}, http.StatusNotFound, services.CodeRecordNotFound, "/api/v1/subscriptions"},
+		{"get", request{method: http.MethodGet, path: "/api/record/1"}, http.StatusOK, "", "/api/v1/subscriptions/1"},
+		{"delete missing", request{method: http.MethodDelete, path: "/api/delete/42"}, http.StatusNotFound, services.CodeRecordNotFound, "/api/v1/subscriptions/42"},
+		{"summary body", request{method: http.MethodGet, path: "/api/records/summary", body: `{"start_time":"01-01-2025","end_time":"01-03-2025"}`}, http.StatusOK, "", "/api/v1/subscriptions/summary"},
+		{"summary bad body", request{method: http.MethodGet, path: "/api/records/summary", body: `{"start_time":"01-01-2025"}`}, http.StatusBadRequest, services.CodeValidationFailed, "/api/v1/subscriptions/summary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newLegacyRouter(), tt.req)
+
+			if tt.code == "" {
+				if rec.Code != tt.status {
+					t.Fatalf("status: got %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+				}
+			} else {
+				assertProblem(t, rec, tt.req.path, tt.status, tt.code)
+			}
+
+			if rec.Header().Get("Deprecation") == "" {
+				t.Fatal("expected Deprecation header")
+			}
+			if want := `<` + tt.successor + `>; rel="successor-version"`; rec.Header().Get("Link") != want {
+				t.Fatalf("Link: got %q, want %q", rec.Header().Get("Link"), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/importer"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ImportRowResponse результат импорта одной строки файла
+type ImportRowResponse struct {
+	Line     int      `json:"line"`
+	Action   string   `json:"action"`
+	RecordID uint     `json:"record_id,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    *Problem `json:"error,omitempty"`
+}
+
+// ImportResponse отчет об импорте файла
+type ImportResponse struct {
+	DryRun    bool                `json:"dry_run"`
+	Total     int                 `json:"total"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Merged    int                 `json:"merged"`
+	Unchanged int                 `json:"unchanged"`
+	Failed    int                 `json:"failed"`
+	Rows      []ImportRowResponse `json:"rows"`
+}
+
+// ImportHandler обрабатывает загрузку файлов с подписками
+type ImportHandler struct {
+	Importer *importer.Importer
+}
+
+// NewImportHandler создает новый экземпляр ImportHandler
+func NewImportHandler(importer *importer.Importer) *ImportHandler {
+	return &ImportHandler{Importer: importer}
+}
+
+// ImportRecords импортирует подписки из CSV или XLSX
+// @Summary Импорт подписок из файла
+// @Description Импортирует подписки из CSV или XLSX. Строки проходят ту же валидацию, что и при создании записи.
+// @Description Повторный импорт идемпотентен: запись с теми же user_id, service_name и created_at обновляется.
+// @Description mapping - JSON вида {"service_name": "Сервис", "price": "Стоимость", ...}, по умолчанию колонки называются как поля API
+// @Tags Подписки
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV или XLSX файл"
+// @Param format formData string false "Формат файла, по умолчанию определяется по расширению" Enums(csv, xlsx)
+// @Param mapping formData string false "Соответствие полей колонкам файла (JSON)"
+// @Param sheet formData string false "Лист XLSX, по умолчанию первый"
+// @Param delimiter formData string false "Разделитель CSV" default(,)
+// @Param dry_run query bool false "Только проверить файл, ничего не сохраняя" default(false)
+// @Success 200 {object} ImportResponse "Отчет об импорте"
+// @Failure 400 {object} Problem "Неверный файл или параметры"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/subscriptions/import [post]
+// @DeprecatedRouter /records/import [post]
+func (h *ImportHandler) ImportRecords(ctx *gin.Context) {
+	var query struct {
+		DryRun bool `form:"dry_run"`
+	}
+
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var form struct {
+		Format    string `form:"format"`
+		Mapping   string `form:"mapping"`
+		Sheet     string `form:"sheet"`
+		Delimiter string `form:"delimiter"`
+	}
+
+	if err := ctx.ShouldBind(&form); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		respondFieldError(ctx, "file", "required", "is required")
+		return
+	}
+
+	formatName := form.Format
+	if formatName == "" {
+		formatName = strings.TrimPrefix(filepath.Ext(header.Filename), ".")
+	}
+
+	format, err := importer.ParseFormat(formatName)
+	if err != nil {
+		respondFieldError(ctx, "format", "oneof", "must be one of: csv xlsx")
+		return
+	}
+
+	mapping := importer.DefaultColumnMapping()
+	if form.Mapping != "" {
+		if err := json.Unmarshal([]byte(form.Mapping), &mapping); err != nil {
+			respondFieldError(ctx, "mapping", "json", "must be a JSON object of field to column name")
+			return
+		}
+	}
+
+	var delimiter rune
+	if form.Delimiter != "" {
+		if utf8.RuneCountInString(form.Delimiter) != 1 {
+			respondFieldError(ctx, "delimiter", "len", "must be a single character")
+			return
+		}
+		delimiter, _ = utf8.DecodeRuneInString(form.Delimiter)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer file.Close()
+
+	var reader importer.RowReader
+
+	switch format {
+	case importer.FormatXLSX:
+		reader, err = importer.NewXLSXReader(file, form.Sheet)
+		if err != nil {
+			respondFieldError(ctx, "file", "malformed", err.Error())
+			return
+		}
+	default:
+		reader = importer.NewCSVReader(file, delimiter)
+	}
+	defer reader.Close()
+
+	report, err := h.Importer.Import(ctx.Request.Context(), reader, mapping, query.DryRun)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := ImportResponse{
+		DryRun:    report.DryRun,
+		Total:     report.Total,
+		Created:   report.Created,
+		Updated:   report.Updated,
+		Merged:    report.Merged,
+		Unchanged: report.Unchanged,
+		Failed:    report.Failed,
+		Rows:      make([]ImportRowResponse, 0, len(report.Rows)),
+	}
+
+	for _, row := range report.Rows {
+		rowResp := ImportRowResponse{
+			Line:     row.Line,
+			Action:   string(row.Action),
+			RecordID: row.RecordID,
+		}
+
+		for _, w := range row.Warnings {
+			rowResp.Warnings = append(rowResp.Warnings, w.Message)
+		}
+
+		if row.Err != nil {
+			problem := problemFor(ctx, row.Err)
+			rowResp.Error = &problem
+		}
+
+		resp.Rows = append(resp.Rows, rowResp)
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// periodLayout - формат расчетного месяца (MM-YYYY)
+const periodLayout = "01-2006"
+
+// LedgerBackfillRequest для заполнения журнала за прошлые месяцы
+type LedgerBackfillRequest struct {
+	From string `json:"from" binding:"required,datetime=01-2006" example:"01-2024"`
+	To   string `json:"to" binding:"required,datetime=01-2006" example:"12-2024"`
+}
+
+// LedgerAdjustmentRequest для ручной корректировки начисления
+type LedgerAdjustmentRequest struct {
+	RecordID uint   `json:"record_id" binding:"required" example:"1"`
+	Period   string `json:"period" binding:"required,datetime=01-2006" example:"03-2024"`
+	// отрицательная сумма - возврат, положительная - доначисление
+	Amount int    `json:"amount" binding:"required" example:"-400"`
+	Reason string `json:"reason" binding:"required" example:"refund for service outage"`
+}
+
+// LedgerEntryResponse строка журнала начислений
+type LedgerEntryResponse struct {
+	ID          uint      `json:"id"`
+	RecordID    uint      `json:"record_id"`
+	Period      string    `json:"period" example:"03-2024"`
+	Kind        string    `json:"kind" enums:"charge,adjustment"`
+	UserID      string    `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	ChargeDate  string    `json:"charge_date" example:"15-03-2024"`
+	Amount      int       `json:"amount"`
+	Manual      bool      `json:"manual"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LedgerHandler - административный API журнала начислений
+type LedgerHandler struct {
+	LedgerService *services.LedgerService
+}
+
+// NewLedgerHandler создает новый экземпляр LedgerHandler
+func NewLedgerHandler(ledgerService *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{LedgerService: ledgerService}
+}
+
+// ListEntries возвращает строки журнала
+// @Summary Журнал начислений
+// @Tags Журнал начислений
+// @Produce json
+// @Security AdminToken
+// @Param user_id query string false "Фильтр по ID пользователя" example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param service_name query string false "Фильтр по названию сервиса" example(Netflix)
+// @Param from query string false "Дата списания от (DD-MM-YYYY)" example(01-01-2024)
+// @Param to query string false "Дата списания до (DD-MM-YYYY)" example(31-12-2024)
+// @Param limit query int false "Лимит записей (макс. 100)" minimum(1) maximum(100) default(20)
+// @Param offset query int false "Смещение" minimum(0) default(0)
+// @Success 200 {array} LedgerEntryResponse "Строки журнала, новые первыми"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/ledger [get]
+// @DeprecatedRouter /ledger [get]
+func (h *LedgerHandler) ListEntries(ctx *gin.Context) {
+	var req struct {
+		UserID      string `form:"user_id"`
+		ServiceName string `form:"service_name"`
+		From        string `form:"from" binding:"omitempty,datetime=02-01-2006"`
+		To          string `form:"to" binding:"omitempty,datetime=02-01-2006"`
+		Limit       int    `form:"limit"`
+		Offset      int    `form:"offset"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > 100 {
+		req.Limit = 100
+	}
+
+	// формат проверен при привязке, пустая строка дает нулевую дату - без границы
+	from, _ := time.Parse(dateLayout, req.From)
+	to, _ := time.Parse(dateLayout, req.To)
+
+	entries, err := h.LedgerService.ListEntries(ctx.Request.Context(), req.UserID, req.ServiceName, from, to, req.Limit, req.Offset)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]LedgerEntryResponse, len(entries))
+	for i := range entries {
+		resp[i] = ledgerEntryResponse(&entries[i])
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// Backfill заполняет журнал за прошлые месяцы
+// @Summary Заполнение журнала за период
+// @Description Записывает недостающие начисления за месяцы с from по to включительно и корректировки
+// @Description для записей, измененных после начисления. Повторный запуск ничего не дублирует, будущие списания не начисляются
+// @Tags Журнал начислений
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param input body LedgerBackfillRequest true "Период"
+// @Success 200 {object} services.MaterializeResult "Сколько строк добавлено"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/ledger/backfill [post]
+// @DeprecatedRouter /ledger/backfill [post]
+func (h *LedgerHandler) Backfill(ctx *gin.Context) {
+	var req LedgerBackfillRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	from, err := time.Parse(periodLayout, req.From)
+	if err != nil {
+		respondFieldError(ctx, "from", "datetime", "must be a month in MM-YYYY format")
+		return
+	}
+
+	to, err := time.Parse(periodLayout, req.To)
+	if err != nil {
+		respondFieldError(ctx, "to", "datetime", "must be a month in MM-YYYY format")
+		return
+	}
+
+	result, err := h.LedgerService.Backfill(ctx.Request.Context(), from, to)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// AddAdjustment записывает ручную корректировку
+// @Summary Корректировка начисления
+// @Description Добавляет в журнал корректировку начисления подписки за месяц. Существующие строки журнала не меняются
+// @Tags Журнал начислений
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param input body LedgerAdjustmentRequest true "Корректировка"
+// @Success 201 {object} LedgerEntryResponse "Корректировка записана"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Запись не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/ledger/adjustments [post]
+// @DeprecatedRouter /ledger/adjustments [post]
+func (h *LedgerHandler) AddAdjustment(ctx *gin.Context) {
+	var req LedgerAdjustmentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	period, err := time.Parse(periodLayout, req.Period)
+	if err != nil {
+		respondFieldError(ctx, "period", "datetime", "must be a month in MM-YYYY format")
+		return
+	}
+
+	entry, err := h.LedgerService.AddAdjustment(ctx.Request.Context(), services.LedgerAdjustment{
+		RecordID: req.RecordID,
+		Period:   period,
+		Amount:   req.Amount,
+		Reason:   req.Reason,
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ledgerEntryResponse(entry))
+}
+
+func ledgerEntryResponse(entry *entity.LedgerEntry) LedgerEntryResponse {
+	return LedgerEntryResponse{
+		ID:          entry.ID,
+		RecordID:    entry.RecordID,
+		Period:      entry.Period.Format(periodLayout),
+		Kind:        entry.Kind,
+		UserID:      entry.UserID,
+		ServiceName: entry.ServiceName,
+		ChargeDate:  entry.ChargeDate.Format(dateLayout),
+		Amount:      entry.Amount,
+		Manual:      entry.Manual,
+		Reason:      entry.Reason,
+		CreatedAt:   entry.CreatedAt,
+	}
+}
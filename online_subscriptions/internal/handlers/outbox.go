@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/outbox"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// OutboxHandler отдает состояние публикации событий
+type OutboxHandler struct {
+	Relay *outbox.Relay
+}
+
+// NewOutboxHandler создает новый экземпляр OutboxHandler
+func NewOutboxHandler(relay *outbox.Relay) *OutboxHandler {
+	return &OutboxHandler{Relay: relay}
+}
+
+// Stats возвращает отставание приемников событий
+// @Summary Отставание публикации событий
+// @Description Для каждого приемника: последнее опубликованное событие, число ожидающих и возраст самого старого из них
+// @Tags Вебхуки
+// @Produce json
+// @Security AdminToken
+// @Success 200 {object} outbox.Stats "Состояние outbox"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/outbox/stats [get]
+// @DeprecatedRouter /outbox/stats [get]
+func (h *OutboxHandler) Stats(ctx *gin.Context) {
+	stats, err := h.Relay.Stats(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:online-subscriptions:problem:"
+)
+
+// Problem описывает ответ об ошибке в формате RFC 7807
+type Problem struct {
+	Type     string                `json:"type"`
+	Title    string                `json:"title"`
+	Status   int                   `json:"status"`
+	Detail   string                `json:"detail,omitempty"`
+	Instance string                `json:"instance,omitempty"`
+	Code     string                `json:"code"`
+	Errors   []services.FieldError `json:"errors,omitempty"`
+	// Extensions сериализуются как члены верхнего уровня (RFC 7807, раздел 3.2)
+	Extensions map[string]any `json:"-" swaggerignore:"true"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+
+	body, err := json.Marshal(plain(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return body, err
+	}
+
+	members := make(map[string]any, len(p.Extensions))
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(members)
+}
+
+func init() {
+	// в ошибках валидации используем имена полей из тегов, а не имена полей структуры
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form", "uri"} {
+				name := strings.Split(field.Tag.Get(tag), ",")[0]
+				if name != "" && name != "-" {
+					return name
+				}
+			}
+
+			return field.Name
+		})
+	}
+}
+
+func statusForKind(kind services.Kind) int {
+	switch kind {
+	case services.KindValidation:
+		return http.StatusBadRequest
+	case services.KindNotFound:
+		return http.StatusNotFound
+	case services.KindConflict:
+		return http.StatusConflict
+	case services.KindForbidden:
+		return http.StatusForbidden
+	case services.KindUnauthorized:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeProblem(ctx *gin.Context, problem Problem) {
+	ctx.Header("Content-Type", problemContentType)
+	ctx.AbortWithStatusJSON(problem.Status, completeProblem(ctx, problem))
+}
+
+// completeProblem заполняет стандартные поля, которые можно вывести из статуса и запроса
+func completeProblem(ctx *gin.Context, problem Problem) Problem {
+	if problem.Type == "" {
+		problem.Type = problemTypePrefix + problem.Code
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" {
+		problem.Instance = ctx.Request.URL.Path
+	}
+
+	return problem
+}
+
+// respondError - единая точка преобразования ошибок сервиса в ответ problem+json.
+// Текст нетипизированных ошибок клиенту не отдается, чтобы не раскрывать детали БД
+func respondError(ctx *gin.Context, err error) {
+	_ = ctx.Error(err)
+
+	writeProblem(ctx, problemFor(ctx, err))
+}
+
+func problemFor(ctx *gin.Context, err error) Problem {
+	e, ok := services.AsError(err)
+	if !ok {
+		return completeProblem(ctx, Problem{
+			Status: http.StatusInternalServerError,
+			Code:   services.CodeInternal,
+			Detail: "internal server error",
+		})
+	}
+
+	return completeProblem(ctx, Problem{
+		Status:     statusForKind(e.Kind),
+		Code:       e.Code,
+		Detail:     e.Message,
+		Errors:     e.Fields,
+		Extensions: e.Meta,
+	})
+}
+
+// respondBindingError отдает ошибку разбора запроса с детализацией по полям
+func respondBindingError(ctx *gin.Context, err error) {
+	var (
+		validationErrs validator.ValidationErrors
+		typeErr        *json.UnmarshalTypeError
+		syntaxErr      *json.SyntaxError
+	)
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]services.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, services.FieldError{
+				Field:   fieldPath(fe),
+				Code:    fe.Tag(),
+				Message: validationMessage(fe),
+			})
+		}
+		respondError(ctx, services.NewValidationError("request validation failed", fields...))
+	case errors.As(err, &typeErr):
+		respondError(ctx, services.NewValidationError("request validation failed", services.FieldError{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}))
+	case errors.As(err, &syntaxErr):
+		writeProblem(ctx, Problem{
+			Status: http.StatusBadRequest,
+			Code:   services.CodeMalformedRequest,
+			Detail: "request body is not valid JSON",
+		})
+	default:
+		writeProblem(ctx, Problem{
+			Status: http.StatusBadRequest,
+			Code:   services.CodeMalformedRequest,
+			Detail: "request could not be parsed",
+		})
+	}
+}
+
+// respondFieldError отдает ошибку валидации одного поля
+func respondFieldError(ctx *gin.Context, field, code, message string) {
+	respondError(ctx, services.NewValidationError("request validation failed", services.FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	}))
+}
+
+// fieldPath возвращает путь к полю без имени корневой структуры, например items[2].expires_at
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+
+	return fe.Field()
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "datetime":
+		return fmt.Sprintf("must be a date in %s format", humanDateLayout(fe.Param()))
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed on '%s' validation", fe.Tag())
+	}
+}
+
+func humanDateLayout(layout string) string {
+	if layout == dateLayout {
+		return "DD-MM-YYYY"
+	}
+
+	return layout
+}
+
+// NotFound отдает problem+json для неизвестных маршрутов
+func NotFound(ctx *gin.Context) {
+	writeProblem(ctx, Problem{
+		Status: http.StatusNotFound,
+		Code:   services.CodeRouteNotFound,
+		Detail: "route not found",
+	})
+}
+
+// writeWarnings передает предупреждения сервиса в заголовках Warning (код 299 - постоянное предупреждение)
+func writeWarnings(ctx *gin.Context, warnings []services.Warning) {
+	for _, w := range warnings {
+		ctx.Writer.Header().Add("Warning", fmt.Sprintf("299 - %q", w.Message))
+	}
+}
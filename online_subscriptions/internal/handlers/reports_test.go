@@ -0,0 +1,361 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/handlers"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errStorage = errors.New("storage unavailable")
+
+// fakeForecast - ForecastRepository над срезами. err возвращается из всех методов
+type fakeForecast struct {
+	records []entity.Record
+	changes []entity.PriceChange
+	err     error
+}
+
+func (f *fakeForecast) GetRecordByID(ctx context.Context, id uint) (*entity.Record, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	for _, record := range f.records {
+		if record.ID == id {
+			return &record, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeForecast) FindActiveRecords(ctx context.Context, from time.Time, userID, serviceName string) ([]entity.Record, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var records []entity.Record
+	for _, record := range f.records {
+		if (userID == "" || record.UserID == userID) && (serviceName == "" || record.ServiceName == serviceName) {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func (f *fakeForecast) FindActivePriceChanges(ctx context.Context, from time.Time, userID, serviceName string) ([]entity.PriceChange, error) {
+	return f.changes, f.err
+}
+
+func (f *fakeForecast) CreatePriceChange(ctx context.Context, change *entity.PriceChange) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	for _, existing := range f.changes {
+		if existing.RecordID == change.RecordID && existing.EffectiveFrom.Equal(change.EffectiveFrom) {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	change.ID = uint(len(f.changes) + 1)
+	f.changes = append(f.changes, *change)
+
+	return nil
+}
+
+func (f *fakeForecast) ListRecordPriceChanges(ctx context.Context, recordID uint) ([]entity.PriceChange, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var changes []entity.PriceChange
+	for _, change := range f.changes {
+		if change.RecordID == recordID {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+func (f *fakeForecast) DeletePriceChange(ctx context.Context, recordID, id uint) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	for i, change := range f.changes {
+		if change.RecordID == recordID && change.ID == id {
+			f.changes = append(f.changes[:i], f.changes[i+1:]...)
+			return nil
+		}
+	}
+
+	return gorm.ErrRecordNotFound
+}
+
+// fakeAnalytics - AnalyticsRepository с заготовленными строками отчетов
+type fakeAnalytics struct {
+	err error
+}
+
+func (f *fakeAnalytics) MRR(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.MRRPoint, error) {
+	return []analytics.MRRPoint{{Month: period.From, MRR: 400, Subscriptions: 1}}, f.err
+}
+
+func (f *fakeAnalytics) Churn(ctx context.Context, period analytics.Range, filter analytics.Filter, today time.Time) ([]analytics.ChurnPoint, error) {
+	return []analytics.ChurnPoint{{Month: period.From, New: 1, Net: 1, CumulativeNet: 1}}, f.err
+}
+
+func (f *fakeAnalytics) TopServices(ctx context.Context, period analytics.Range, filter analytics.Filter, by analytics.RankBy, limit int) ([]analytics.ServiceRank, error) {
+	return []analytics.ServiceRank{{ServiceName: "Netflix", Spend: 2400, Subscribers: 1, SpendRank: 1, SubscribersRank: 1, Share: 100}}, f.err
+}
+
+func (f *fakeAnalytics) Lifetimes(ctx context.Context, period analytics.Range, filter analytics.Filter, today time.Time) ([]analytics.Lifetime, error) {
+	return []analytics.Lifetime{{Subscriptions: 1, AverageDays: 181, MedianDays: 181}, {ServiceName: "Netflix", Subscriptions: 1, AverageDays: 181, MedianDays: 181}}, f.err
+}
+
+func (f *fakeAnalytics) Cohorts(ctx context.Context, period analytics.Range, filter analytics.Filter, last time.Time) ([]analytics.CohortRow, error) {
+	return []analytics.CohortRow{{Cohort: period.From, Size: 1, Offset: 0, Retained: 1}}, f.err
+}
+
+// fakeFeeds - CalendarFeedRepository над картой лент
+type fakeFeeds struct {
+	feeds map[string]*entity.CalendarFeed
+	err   error
+}
+
+func (f *fakeFeeds) GetOrCreateCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	feed, ok := f.feeds[userID]
+	if !ok {
+		feed = &entity.CalendarFeed{UserID: userID, Version: 1}
+		f.feeds[userID] = feed
+	}
+
+	return feed, nil
+}
+
+func (f *fakeFeeds) GetCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	feed, ok := f.feeds[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return feed, nil
+}
+
+func (f *fakeFeeds) RevokeCalendarFeed(ctx context.Context, userID string) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	if feed, ok := f.feeds[userID]; ok {
+		feed.Version++
+	}
+
+	return nil
+}
+
+// reports - хранилища отчетов, над которыми newRouter собирает настоящие сервисы прогноза,
+// аналитики и календаря. Запись seedRecord (ID 1) есть и в прогнозе, и в календаре
+type reports struct {
+	forecast  *fakeForecast
+	analytics *fakeAnalytics
+	feeds     *fakeFeeds
+	calendar  *services.CalendarService
+}
+
+func newReports() *reports {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := clock.NewFake(testNow)
+
+	record := seedRecord()
+	record.ID = 1
+
+	records := memory.New(false, now)
+	if err := records.SaveRecord(context.Background(), &record); err != nil {
+		panic(err)
+	}
+
+	rep := &reports{
+		forecast:  &fakeForecast{records: []entity.Record{record}},
+		analytics: &fakeAnalytics{},
+		feeds:     &fakeFeeds{feeds: make(map[string]*entity.CalendarFeed)},
+	}
+
+	rep.calendar = services.NewCalendarService(log, records, rep.feeds, services.CalendarConfig{
+		Secret:        []byte("test-secret"),
+		HistoryMonths: 1,
+		HorizonMonths: 3,
+		Clock:         now,
+	})
+
+	return rep
+}
+
+func (rep *reports) handlers() (*handlers.CalendarHandler, *handlers.ForecastHandler, *handlers.AnalyticsHandler) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := clock.NewFake(testNow)
+
+	return handlers.NewCalendarHandler(rep.calendar),
+		handlers.NewForecastHandler(services.NewForecastService(log, rep.forecast, now)),
+		handlers.NewAnalyticsHandler(services.NewAnalyticsService(log, rep.analytics, nil, now), now)
+}
+
+// TestReportRoutes проходит по маршрутам прогноза, аналитики и календаря из routes.RegisterRoutes
+func TestReportRoutes(t *testing.T) {
+	scheduled := entity.PriceChange{ID: 1, RecordID: 1, Price: 500, EffectiveFrom: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)}
+
+	withChange := func(rep *reports) { rep.forecast.changes = []entity.PriceChange{scheduled} }
+	brokenForecast := func(rep *reports) { rep.forecast.err = errStorage }
+	brokenAnalytics := func(rep *reports) { rep.analytics.err = errStorage }
+
+	tests := []struct {
+		name  string
+		setup func(rep *reports)
+		// {token} в пути заменяется токеном ленты testUser
+		req    request
+		status int
+		// code - ожидаемый код problem+json, пустой - успешный ответ
+		code  string
+		field string
+	}{
+		{"forecast", nil, request{method: http.MethodGet, path: "/api/v1/subscriptions/forecast?months=6"}, http.StatusOK, "", ""},
+		{"forecast bad months", nil, request{method: http.MethodGet, path: "/api/v1/subscriptions/forecast?months=61"}, http.StatusBadRequest, services.CodeValidationFailed, "months"},
+		{"forecast storage failure", brokenForecast, request{method: http.MethodGet, path: "/api/v1/subscriptions/forecast"}, http.StatusInternalServerError, services.CodeForecastFailed, ""},
+
+		{"schedule price change", nil, request{method: http.MethodPost, path: "/api/v1/subscriptions/1/price-changes", body: `{"price":500,"effective_from":"01-05-2025"}`}, http.StatusCreated, "", ""},
+		{"schedule price change missing record", nil, request{method: http.MethodPost, path: "/api/v1/subscriptions/42/price-changes", body: `{"price":500,"effective_from":"01-05-2025"}`}, http.StatusNotFound, services.CodeRecordNotFound, ""},
+		{"schedule price change twice", withChange, request{method: http.MethodPost, path: "/api/v1/subscriptions/1/price-changes", body: `{"price":600,"effective_from":"01-05-2025"}`}, http.StatusConflict, services.CodePriceChangeExists, ""},
+		{"schedule price change before start", nil, request{method: http.MethodPost, path: "/api/v1/subscriptions/1/price-changes", body: `{"price":500,"effective_from":"01-01-2025"}`}, http.StatusBadRequest, services.CodeValidationFailed, "effective_from"},
+
+		{"list price changes", withChange, request{method: http.MethodGet, path: "/api/v1/subscriptions/1/price-changes"}, http.StatusOK, "", ""},
+		{"list price changes missing record", nil, request{method: http.MethodGet, path: "/api/v1/subscriptions/42/price-changes"}, http.StatusNotFound, services.CodeRecordNotFound, ""},
+
+		{"delete price change", withChange, request{method: http.MethodDelete, path: "/api/v1/subscriptions/1/price-changes/1"}, http.StatusNoContent, "", ""},
+		{"delete price change missing", withChange, request{method: http.MethodDelete, path: "/api/v1/subscriptions/1/price-changes/42"}, http.StatusNotFound, services.CodePriceChangeNotFound, ""},
+
+		{"mrr", nil, request{method: http.MethodGet, path: "/api/v1/analytics/mrr?from=01-2025&to=03-2025"}, http.StatusOK, "", ""},
+		{"mrr storage failure", brokenAnalytics, request{method: http.MethodGet, path: "/api/v1/analytics/mrr"}, http.StatusInternalServerError, services.CodeAnalyticsFailed, ""},
+
+		{"churn", nil, request{method: http.MethodGet, path: "/api/v1/analytics/churn"}, http.StatusOK, "", ""},
+		{"churn reversed period", nil, request{method: http.MethodGet, path: "/api/v1/analytics/churn?from=03-2025&to=01-2025"}, http.StatusBadRequest, services.CodeValidationFailed, "to"},
+
+		{"top services", nil, request{method: http.MethodGet, path: "/api/v1/analytics/top-services?by=subscribers&limit=5"}, http.StatusOK, "", ""},
+		{"top services bad order", nil, request{method: http.MethodGet, path: "/api/v1/analytics/top-services?by=cost"}, http.StatusBadRequest, services.CodeValidationFailed, "by"},
+
+		{"lifetime", nil, request{method: http.MethodGet, path: "/api/v1/analytics/lifetime"}, http.StatusOK, "", ""},
+		{"lifetime storage failure", brokenAnalytics, request{method: http.MethodGet, path: "/api/v1/analytics/lifetime"}, http.StatusInternalServerError, services.CodeAnalyticsFailed, ""},
+
+		{"cohorts", nil, request{method: http.MethodGet, path: "/api/v1/analytics/cohorts?from=01-2025"}, http.StatusOK, "", ""},
+		{"cohorts bad month", nil, request{method: http.MethodGet, path: "/api/v1/analytics/cohorts?from=2025-01"}, http.StatusBadRequest, services.CodeValidationFailed, "from"},
+
+		{"calendar feed", nil, request{method: http.MethodGet, path: "/api/v1/users/" + testUser + "/calendar.ics?token={token}"}, http.StatusOK, "", ""},
+		{"calendar feed bad token", nil, request{method: http.MethodGet, path: "/api/v1/users/" + testUser + "/calendar.ics?token=v1.forged"}, http.StatusForbidden, services.CodeInvalidFeedToken, ""},
+		{"calendar feed revoked token", func(rep *reports) { rep.feeds.feeds[testUser].Version++ }, request{method: http.MethodGet, path: "/api/v1/users/" + testUser + "/calendar.ics?token={token}"}, http.StatusForbidden, services.CodeInvalidFeedToken, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := newReports()
+
+			token, err := rep.calendar.IssueFeedToken(context.Background(), testUser)
+			if err != nil {
+				t.Fatalf("issue feed token: %v", err)
+			}
+			tt.req.path = strings.Replace(tt.req.path, "{token}", token, 1)
+
+			if tt.setup != nil {
+				tt.setup(rep)
+			}
+
+			rec := serve(newRouterWithReports(newFakeRecords(seedRecord()), nil, rep), tt.req)
+
+			if tt.code == "" {
+				if rec.Code != tt.status {
+					t.Fatalf("status: got %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+				}
+				return
+			}
+
+			problem := assertProblem(t, rec, tt.req.path, tt.status, tt.code)
+
+			if tt.field == "" {
+				return
+			}
+			for _, field := range problem.Errors {
+				if field.Field == tt.field {
+					return
+				}
+			}
+			t.Fatalf("expected error for field %q, got %+v", tt.field, problem.Errors)
+		})
+	}
+}
+
+func TestForecastRoute(t *testing.T) {
+	rep := newReports()
+	rep.forecast.changes = []entity.PriceChange{{ID: 1, RecordID: 1, Price: 500, EffectiveFrom: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)}}
+
+	rec := serve(newRouterWithReports(newFakeRecords(), nil, rep), request{method: http.MethodGet, path: "/api/v1/subscriptions/forecast?months=6"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body)
+	}
+
+	var got handlers.ForecastResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode forecast: %v", err)
+	}
+
+	// прогноз с текущего месяца часов: март и апрель по 400, май и июнь по новой цене, дальше подписка закончилась
+	if got.From != "03-2025" || got.Months != 6 || got.Total != 1800 {
+		t.Fatalf("got from %s, months %d, total %d, want 03-2025, 6, 1800", got.From, got.Months, got.Total)
+	}
+}
+
+func TestCalendarFeedRoute(t *testing.T) {
+	rep := newReports()
+
+	token, err := rep.calendar.IssueFeedToken(context.Background(), testUser)
+	if err != nil {
+		t.Fatalf("issue feed token: %v", err)
+	}
+
+	rec := serve(newRouterWithReports(newFakeRecords(), nil, rep), request{method: http.MethodGet, path: "/api/v1/users/" + testUser + "/calendar.ics?token=" + token})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, body %s", rec.Code, rec.Body)
+	}
+
+	if got := rec.Header().Get("Content-Type"); got != "text/calendar; charset=utf-8" {
+		t.Fatalf("content type: got %q", got)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{"BEGIN:VCALENDAR", "UID:record-1-expires@", "DTSTAMP:20250310T143005Z", "DTSTART;VALUE=DATE:20250401"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("feed has no %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// WebhookCreateRequest для создания вебхука
+type WebhookCreateRequest struct {
+	URL    string   `json:"url" binding:"required" example:"https://budget.example.com/hooks/subscriptions"`
+	Events []string `json:"events" binding:"required" example:"record.created,record.deleted"`
+	// если не передан, секрет генерируется и возвращается один раз в ответе
+	Secret string `json:"secret"`
+	Active *bool  `json:"active"`
+}
+
+// WebhookUpdateRequest для изменения вебхука, отсутствующие поля не меняются
+type WebhookUpdateRequest struct {
+	URL          *string  `json:"url"`
+	Events       []string `json:"events"`
+	Active       *bool    `json:"active"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// WebhookResponse вебхук. Secret возвращается только при создании и смене секрета
+type WebhookResponse struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeliveryResponse доставка события вебхуку
+type DeliveryResponse struct {
+	ID             uint              `json:"id"`
+	WebhookID      uint              `json:"webhook_id"`
+	EventID        string            `json:"event_id"`
+	Event          string            `json:"event"`
+	Status         string            `json:"status" enums:"pending,succeeded,failed"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  *time.Time        `json:"next_attempt_at,omitempty"`
+	LastStatusCode int               `json:"last_status_code,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	Payload        json.RawMessage   `json:"payload,omitempty" swaggertype:"object"`
+	AttemptLog     []AttemptResponse `json:"attempt_log,omitempty"`
+}
+
+// AttemptResponse попытка доставки
+type AttemptResponse struct {
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	At         time.Time `json:"at"`
+}
+
+// WebhookHandler - административный API вебхуков
+type WebhookHandler struct {
+	WebhookService *services.WebhookService
+}
+
+// NewWebhookHandler создает новый экземпляр WebhookHandler
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{WebhookService: webhookService}
+}
+
+type idURI struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+// CreateWebhook создает вебхук
+// @Summary Создание вебхука
+// @Description Подписывает URL на события записей: record.created, record.updated, record.deleted, record.expired.
+// @Description Тело запроса подписывается HMAC-SHA256: заголовок X-Webhook-Signature: t=<unix>,v1=<hex HMAC(secret, "<t>.<body>")>
+// @Tags Вебхуки
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param input body WebhookCreateRequest true "Вебхук"
+// @Success 201 {object} WebhookResponse "Вебхук создан, secret возвращается только сейчас"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhooks [post]
+// @DeprecatedRouter /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(ctx *gin.Context) {
+	var req WebhookCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	wh, err := h.WebhookService.CreateWebhook(ctx.Request.Context(), services.WebhookInput{
+		URL:    req.URL,
+		Events: eventTypes(req.Events),
+		Secret: req.Secret,
+		Active: req.Active == nil || *req.Active,
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := webhookResponse(wh)
+	resp.Secret = wh.Secret
+
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+// ListWebhooks возвращает вебхуки
+// @Summary Список вебхуков
+// @Tags Вебхуки
+// @Produce json
+// @Security AdminToken
+// @Success 200 {array} WebhookResponse "Вебхуки"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhooks [get]
+// @DeprecatedRouter /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(ctx *gin.Context) {
+	webhooks, err := h.WebhookService.ListWebhooks(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		resp[i] = webhookResponse(&webhooks[i])
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// GetWebhook возвращает вебхук
+// @Summary Вебхук по ID
+// @Tags Вебхуки
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "ID вебхука" example(1)
+// @Success 200 {object} WebhookResponse "Вебхук"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Вебхук не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhooks/{id} [get]
+// @DeprecatedRouter /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	wh, err := h.WebhookService.GetWebhook(ctx.Request.Context(), uri.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, webhookResponse(wh))
+}
+
+// UpdateWebhook изменяет вебхук
+// @Summary Изменение вебхука
+// @Description Меняет только переданные поля. rotate_secret=true выдает новый секрет, старый сразу перестает действовать
+// @Tags Вебхуки
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "ID вебхука" example(1)
+// @Param input body WebhookUpdateRequest true "Изменения"
+// @Success 200 {object} WebhookResponse "Вебхук изменен"
+// @Failure 400 {object} Problem "Неверные данные"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Вебхук не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhooks/{id} [patch]
+// @DeprecatedRouter /webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req WebhookUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	wh, err := h.WebhookService.UpdateWebhook(ctx.Request.Context(), services.WebhookPatch{
+		ID:           uri.ID,
+		URL:          req.URL,
+		Events:       eventTypes(req.Events),
+		Active:       req.Active,
+		RotateSecret: req.RotateSecret,
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := webhookResponse(wh)
+	if req.RotateSecret {
+		resp.Secret = wh.Secret
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// DeleteWebhook удаляет вебхук
+// @Summary Удаление вебхука
+// @Description Удаляет вебхук вместе с историей доставок
+// @Tags Вебхуки
+// @Security AdminToken
+// @Param id path int true "ID вебхука" example(1)
+// @Success 204 "Вебхук удален"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Вебхук не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhooks/{id} [delete]
+// @DeprecatedRouter /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if err := h.WebhookService.DeleteWebhook(ctx.Request.Context(), uri.ID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// ListDeliveries возвращает доставки вебхука
+// @Summary Доставки вебхука
+// @Tags Вебхуки
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "ID вебхука" example(1)
+// @Param status query string false "Фильтр по статусу" Enums(pending, succeeded, failed)
+// @Param limit query int false "Лимит записей (макс. 100)" minimum(1) maximum(100) default(20)
+// @Param offset query int false "Смещение" minimum(0) default(0)
+// @Success 200 {array} DeliveryResponse "Доставки, новые первыми"
+// @Failure 400 {object} Problem "Неверные параметры"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Вебхук не найден"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhooks/{id}/deliveries [get]
+// @DeprecatedRouter /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	var req struct {
+		Status string `form:"status" binding:"omitempty,oneof=pending succeeded failed"`
+		Limit  int    `form:"limit"`
+		Offset int    `form:"offset"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > 100 {
+		req.Limit = 100
+	}
+
+	deliveries, err := h.WebhookService.ListDeliveries(ctx.Request.Context(), uri.ID, req.Status, req.Limit, req.Offset)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := make([]DeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		resp[i] = deliveryResponse(&deliveries[i])
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// GetDelivery возвращает доставку с журналом попыток
+// @Summary Доставка по ID
+// @Tags Вебхуки
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "ID доставки" example(1)
+// @Success 200 {object} DeliveryResponse "Доставка, тело события и журнал попыток"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Доставка не найдена"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhook-deliveries/{id} [get]
+// @DeprecatedRouter /webhook-deliveries/{id} [get]
+func (h *WebhookHandler) GetDelivery(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	delivery, attempts, err := h.WebhookService.GetDelivery(ctx.Request.Context(), uri.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	resp := deliveryResponse(delivery)
+	resp.Payload = json.RawMessage(delivery.Payload)
+
+	for _, attempt := range attempts {
+		resp.AttemptLog = append(resp.AttemptLog, AttemptResponse{
+			Attempt:    attempt.Attempt,
+			StatusCode: attempt.StatusCode,
+			Error:      attempt.Error,
+			DurationMs: attempt.Duration.Milliseconds(),
+			At:         attempt.CreatedAt,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// RedeliverDelivery отправляет доставку заново
+// @Summary Повторная отправка
+// @Description Сразу отправляет событие заново, в том числе успешно доставленное. При неудаче доставка снова повторяется по расписанию
+// @Tags Вебхуки
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "ID доставки" example(1)
+// @Success 200 {object} DeliveryResponse "Результат отправки"
+// @Failure 401 {object} Problem "Нужен токен администратора"
+// @Failure 404 {object} Problem "Доставка или вебхук не найдены"
+// @Failure 500 {object} Problem "Внутренняя ошибка сервера"
+// @Router /v1/webhook-deliveries/{id}/redeliver [post]
+// @DeprecatedRouter /webhook-deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) RedeliverDelivery(ctx *gin.Context) {
+	var uri idURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	delivery, err := h.WebhookService.Redeliver(ctx.Request.Context(), uri.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deliveryResponse(delivery))
+}
+
+func eventTypes(names []string) []services.EventType {
+	if names == nil {
+		return nil
+	}
+
+	events := make([]services.EventType, len(names))
+	for i, name := range names {
+		events[i] = services.EventType(name)
+	}
+
+	return events
+}
+
+func webhookResponse(wh *entity.Webhook) WebhookResponse {
+	events := services.WebhookEvents(wh)
+
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = string(event)
+	}
+
+	return WebhookResponse{
+		ID:        wh.ID,
+		URL:       wh.URL,
+		Events:    names,
+		Active:    wh.Active,
+		CreatedAt: wh.CreatedAt,
+		UpdatedAt: wh.UpdatedAt,
+	}
+}
+
+func deliveryResponse(delivery *entity.WebhookDelivery) DeliveryResponse {
+	resp := DeliveryResponse{
+		ID:             delivery.ID,
+		WebhookID:      delivery.WebhookID,
+		EventID:        delivery.EventID,
+		Event:          delivery.Event,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+	}
+
+	if delivery.Status == services.DeliveryPending {
+		next := delivery.NextAttemptAt
+		resp.NextAttemptAt = &next
+	}
+
+	return resp
+}
@@ -0,0 +1,318 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// поддерживаемые форматы дат в ячейках: DD-MM-YYYY как в API и ISO-формат
+var dateLayouts = []string{"02-01-2006", time.DateOnly, "02.01.2006"}
+
+// ColumnMapping связывает поля записи с заголовками колонок файла
+type ColumnMapping struct {
+	ServiceName string `json:"service_name"`
+	Price       string `json:"price"`
+	UserID      string `json:"user_id"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at"`
+	Category    string `json:"category"`
+}
+
+// DefaultColumnMapping - колонки называются так же, как поля API
+func DefaultColumnMapping() ColumnMapping {
+	return ColumnMapping{
+		ServiceName: "service_name",
+		Price:       "price",
+		UserID:      "user_id",
+		CreatedAt:   "created_at",
+		ExpiresAt:   "expires_at",
+		Category:    "category",
+	}
+}
+
+// withDefaults подставляет имена по умолчанию для незаданных колонок
+func (m ColumnMapping) withDefaults() ColumnMapping {
+	def := DefaultColumnMapping()
+
+	for _, pair := range []struct {
+		dst *string
+		def string
+	}{
+		{&m.ServiceName, def.ServiceName},
+		{&m.Price, def.Price},
+		{&m.UserID, def.UserID},
+		{&m.CreatedAt, def.CreatedAt},
+		{&m.ExpiresAt, def.ExpiresAt},
+		{&m.Category, def.Category},
+	} {
+		if strings.TrimSpace(*pair.dst) == "" {
+			*pair.dst = pair.def
+		}
+	}
+
+	return m
+}
+
+// RecordImporter - часть RecordService, через которую идет импорт
+type RecordImporter interface {
+	ImportRecords(ctx context.Context, rows []services.ImportRow, dryRun bool) ([]services.ImportRowResult, error)
+}
+
+// Report - итог импорта файла
+type Report struct {
+	DryRun    bool
+	Total     int
+	Created   int
+	Updated   int
+	Merged    int
+	Unchanged int
+	Failed    int
+	Rows      []services.ImportRowResult
+}
+
+func (r *Report) add(result services.ImportRowResult) {
+	r.Total++
+
+	switch result.Action {
+	case services.ImportCreated:
+		r.Created++
+	case services.ImportUpdated:
+		r.Updated++
+	case services.ImportMerged:
+		r.Merged++
+	case services.ImportUnchanged:
+		r.Unchanged++
+	default:
+		r.Failed++
+	}
+
+	r.Rows = append(r.Rows, result)
+}
+
+type Importer struct {
+	log       *slog.Logger
+	records   RecordImporter
+	chunkSize int
+}
+
+func NewImporter(log *slog.Logger, records RecordImporter, chunkSize int) *Importer {
+	if chunkSize <= 0 {
+		chunkSize = 500
+	}
+
+	return &Importer{log: log, records: records, chunkSize: chunkSize}
+}
+
+// Import читает файл пачками по chunkSize строк, каждая пачка импортируется в отдельной транзакции
+func (im *Importer) Import(ctx context.Context, reader RowReader, mapping ColumnMapping, dryRun bool) (*Report, error) {
+	const op = "importer.Import"
+
+	log := im.log.With(slog.String("operation", op), slog.Bool("dry_run", dryRun))
+	log.Info("importing file...")
+
+	header, err := reader.Next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, services.NewValidationError("import file is empty")
+		}
+		return nil, malformedFile(err)
+	}
+
+	columns, err := resolveColumns(header, mapping.withDefaults())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: dryRun}
+	chunk := make([]services.ImportRow, 0, im.chunkSize)
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		results, err := im.records.ImportRecords(ctx, chunk, dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			report.add(result)
+		}
+
+		chunk = chunk[:0]
+
+		return nil
+	}
+
+	// строка 1 - заголовок
+	for line := 2; ; line++ {
+		cells, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, malformedFile(err)
+		}
+
+		if isBlank(cells) {
+			continue
+		}
+
+		chunk = append(chunk, columns.parse(line, cells))
+
+		if len(chunk) == im.chunkSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	log.Info("file imported",
+		slog.Int("total", report.Total),
+		slog.Int("created", report.Created),
+		slog.Int("updated", report.Updated),
+		slog.Int("failed", report.Failed))
+
+	return report, nil
+}
+
+// columnIndex - номера колонок для полей записи, -1 если колонки нет
+type columnIndex struct {
+	serviceName, price, userID, createdAt, expiresAt, category int
+}
+
+func resolveColumns(header []string, mapping ColumnMapping) (columnIndex, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[normalizeHeader(name)] = i
+	}
+
+	find := func(name string) int {
+		if i, ok := positions[normalizeHeader(name)]; ok {
+			return i
+		}
+		return -1
+	}
+
+	columns := columnIndex{
+		serviceName: find(mapping.ServiceName),
+		price:       find(mapping.Price),
+		userID:      find(mapping.UserID),
+		createdAt:   find(mapping.CreatedAt),
+		expiresAt:   find(mapping.ExpiresAt),
+		category:    find(mapping.Category),
+	}
+
+	var missing []services.FieldError
+	for _, required := range []struct {
+		field, column string
+		index         int
+	}{
+		{"service_name", mapping.ServiceName, columns.serviceName},
+		{"price", mapping.Price, columns.price},
+		{"user_id", mapping.UserID, columns.userID},
+		{"expires_at", mapping.ExpiresAt, columns.expiresAt},
+	} {
+		if required.index < 0 {
+			missing = append(missing, services.FieldError{
+				Field:   "mapping." + required.field,
+				Code:    "missing_column",
+				Message: fmt.Sprintf("column %q not found in file header", required.column),
+			})
+		}
+	}
+
+	if len(missing) > 0 {
+		return columns, services.NewValidationError("import file does not match column mapping", missing...)
+	}
+
+	return columns, nil
+}
+
+// parse превращает строку файла в запись; ошибки разбора ячеек собираются в ImportRow.Err
+func (c columnIndex) parse(line int, cells []string) services.ImportRow {
+	row := services.ImportRow{Line: line}
+
+	cell := func(index int) string {
+		if index < 0 || index >= len(cells) {
+			return ""
+		}
+		return strings.TrimSpace(cells[index])
+	}
+
+	var violations []services.FieldError
+
+	row.Record.ServiceName = cell(c.serviceName)
+	row.Record.UserID = cell(c.userID)
+	row.Record.Category = cell(c.category)
+
+	price, err := strconv.Atoi(strings.ReplaceAll(cell(c.price), " ", ""))
+	if err != nil {
+		violations = append(violations, services.FieldError{Field: "price", Code: "type", Message: "must be an integer"})
+	}
+	row.Record.Price = price
+
+	if value := cell(c.createdAt); value != "" {
+		if row.Record.CreatedAt, err = parseDate(value); err != nil {
+			violations = append(violations, dateViolation("created_at"))
+		}
+	}
+
+	if row.Record.ExpiresAt, err = parseDate(cell(c.expiresAt)); err != nil {
+		violations = append(violations, dateViolation("expires_at"))
+	}
+
+	if len(violations) > 0 {
+		row.Err = services.NewValidationError("invalid row", violations...)
+	}
+
+	return row
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unsupported date %q", value)
+}
+
+func dateViolation(field string) services.FieldError {
+	return services.FieldError{Field: field, Code: "datetime", Message: "must be a date in DD-MM-YYYY or YYYY-MM-DD format"}
+}
+
+func normalizeHeader(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
+
+func isBlank(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func malformedFile(err error) error {
+	return services.NewValidationError("import file could not be read", services.FieldError{
+		Field:   "file",
+		Code:    "malformed",
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format - формат файла импорта
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatCSV, FormatXLSX:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported import format %q", value)
+	}
+}
+
+// RowReader построчно читает табличный файл. Первая строка - заголовок.
+// Next возвращает io.EOF, когда строки закончились
+type RowReader interface {
+	Next() ([]string, error)
+	Close() error
+}
+
+type csvReader struct {
+	reader *csv.Reader
+}
+
+func NewCSVReader(r io.Reader, delimiter rune) RowReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+	if delimiter != 0 {
+		reader.Comma = delimiter
+	}
+
+	return &csvReader{reader: reader}
+}
+
+func (c *csvReader) Next() ([]string, error) {
+	row, err := c.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// ReuseRecord переиспользует срез, поэтому отдаем копию
+	return append([]string(nil), row...), nil
+}
+
+func (c *csvReader) Close() error {
+	return nil
+}
+
+type xlsxReader struct {
+	file *excelize.File
+	rows *excelize.Rows
+}
+
+// NewXLSXReader читает лист книги построчно (потоковый итератор excelize).
+// Если лист не указан, берется первый. Даты из ячеек с форматом даты отдаются как YYYY-MM-DD
+func NewXLSXReader(r io.Reader, sheet string) (RowReader, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not open xlsx: %w", err)
+	}
+
+	if sheet == "" {
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			_ = file.Close()
+			return nil, errors.New("xlsx has no sheets")
+		}
+		sheet = sheets[0]
+	}
+
+	rows, err := file.Rows(sheet)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("could not read sheet %q: %w", sheet, err)
+	}
+
+	return &xlsxReader{file: file, rows: rows}, nil
+}
+
+func (x *xlsxReader) Next() ([]string, error) {
+	if !x.rows.Next() {
+		if err := x.rows.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	formatted, err := x.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := x.rows.Columns(excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range formatted {
+		if i < len(raw) && formatted[i] != raw[i] {
+			// ячейка с числовым форматом даты хранит порядковый номер дня
+			if serial, err := strconv.ParseFloat(raw[i], 64); err == nil && looksLikeDate(formatted[i]) {
+				if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+					formatted[i] = t.Format(time.DateOnly)
+				}
+			}
+		}
+	}
+
+	return formatted, nil
+}
+
+func (x *xlsxReader) Close() error {
+	rowsErr := x.rows.Close()
+	if err := x.file.Close(); err != nil {
+		return err
+	}
+
+	return rowsErr
+}
+
+func looksLikeDate(value string) bool {
+	return strings.ContainsAny(value, "/-.") && strings.IndexFunc(value, func(r rune) bool {
+		return r >= '0' && r <= '9'
+	}) >= 0
+}
@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogNotifier пишет уведомления в лог, удобен для локального запуска
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) Name() string {
+	return "log"
+}
+
+func (n *LogNotifier) Notify(_ context.Context, message Message) error {
+	n.log.Info(message.Subject(),
+		slog.String("event", message.Event()),
+		slog.Any("details", message))
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Message - уведомление, которое доставляет Notifier. Event - стабильный тип для машинных получателей
+type Message interface {
+	Event() string
+	Subject() string
+	Text() string
+}
+
+// Reminder - напоминание о скором окончании подписки
+type Reminder struct {
+	RecordID    uint      `json:"record_id"`
+	UserID      string    `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	Price       int       `json:"price"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// DaysLeft - сколько дней осталось до окончания, WindowDays - окно, в которое попала подписка
+	DaysLeft   int `json:"days_left"`
+	WindowDays int `json:"window_days"`
+}
+
+func (r Reminder) Event() string {
+	return "subscription.expiring"
+}
+
+func (r Reminder) Subject() string {
+	return fmt.Sprintf("%s subscription expires in %s", r.ServiceName, days(r.DaysLeft))
+}
+
+func (r Reminder) Text() string {
+	return fmt.Sprintf("Subscription %d to %s (price %d) for user %s expires on %s, in %s.",
+		r.RecordID, r.ServiceName, r.Price, r.UserID, r.ExpiresAt.Format("02-01-2006"), days(r.DaysLeft))
+}
+
+// BudgetAlert - прогноз расходов пользователя за месяц превышает бюджет.
+// Category пустая для общего лимита
+type BudgetAlert struct {
+	UserID    string    `json:"user_id"`
+	Period    time.Time `json:"period"`
+	Category  string    `json:"category,omitempty"`
+	Limit     int       `json:"limit"`
+	Projected int       `json:"projected"`
+}
+
+func (a BudgetAlert) Event() string {
+	return "budget.exceeded"
+}
+
+func (a BudgetAlert) Subject() string {
+	if a.Category != "" {
+		return fmt.Sprintf("%s budget exceeded for %s", a.Category, a.Period.Format("01-2006"))
+	}
+
+	return fmt.Sprintf("Subscription budget exceeded for %s", a.Period.Format("01-2006"))
+}
+
+func (a BudgetAlert) Text() string {
+	scope := "total"
+	if a.Category != "" {
+		scope = fmt.Sprintf("category %s", a.Category)
+	}
+
+	return fmt.Sprintf("Projected subscription cost of user %s for %s (%s) is %d, limit is %d.",
+		a.UserID, a.Period.Format("01-2006"), scope, a.Projected, a.Limit)
+}
+
+// Notifier доставляет уведомление по одному каналу. Name используется как ключ
+// дедупликации, поэтому должен быть стабильным между перезапусками
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, message Message) error
+}
+
+func days(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+
+	return fmt.Sprintf("%d days", n)
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig - параметры почтового сервера. Адресов пользователей сервис не хранит,
+// поэтому уведомления уходят на заданный список получателей
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTPNotifier отправляет уведомление письмом
+type SMTPNotifier struct {
+	config SMTPConfig
+}
+
+func NewSMTPNotifier(config SMTPConfig) (*SMTPNotifier, error) {
+	if config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return nil, errors.New("smtp notifier requires host, from and to")
+	}
+
+	return &SMTPNotifier{config: config}, nil
+}
+
+func (n *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, message Message) error {
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+	}
+
+	addr := net.JoinHostPort(n.config.Host, n.config.Port)
+	msg := n.message(message)
+
+	// net/smtp не принимает контекст, поэтому отправка идет в горутине и прерывается по ctx
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, n.config.From, n.config.To, msg)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *SMTPNotifier) message(message Message) []byte {
+	var b bytes.Buffer
+
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+
+	header("From", n.config.From)
+	header("To", strings.Join(n.config.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", message.Subject()))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+	b.WriteString(message.Text())
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
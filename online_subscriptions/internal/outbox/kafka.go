@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSink пишет события в топик с ключом - ID записи, так что события одной записи
+// попадают в одну партицию и читаются по порядку
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaSink(brokers []string, topic string) *KafkaSink {
+	return &KafkaSink{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}
+}
+
+func (s *KafkaSink) Name() string {
+	return "kafka"
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, messages []Message) error {
+	batch := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		batch[i] = kafka.Message{
+			Key:   []byte(message.AggregateID),
+			Value: message.Payload,
+			Headers: []kafka.Header{
+				{Key: "event_id", Value: []byte(message.EventID)},
+				{Key: "event_type", Value: []byte(message.Type)},
+			},
+		}
+	}
+
+	return s.writer.WriteMessages(ctx, batch...)
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
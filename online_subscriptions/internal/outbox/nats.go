@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"github.com/nats-io/nats.go"
+)
+
+// NATSSink публикует событие в subject <prefix>.<тип события>, например subscriptions.record.created.
+// Заголовок Nats-Msg-Id позволяет JetStream отбросить повтор
+type NATSSink struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func NewNATSSink(url, prefix string) (*NATSSink, error) {
+	conn, err := nats.Connect(url, nats.Name("online-subscriptions-outbox"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSSink{conn: conn, prefix: prefix}, nil
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+func (s *NATSSink) Publish(ctx context.Context, messages []Message) error {
+	for _, message := range messages {
+		msg := nats.NewMsg(s.prefix + "." + message.Type)
+		msg.Data = message.Payload
+		msg.Header.Set(nats.MsgIdHdr, message.EventID)
+
+		if err := s.conn.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+
+	// пачка считается опубликованной, только когда сервер ее принял
+	return s.conn.FlushWithContext(ctx)
+}
+
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,295 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"log/slog"
+	"time"
+)
+
+// errCheckpointLocked - приемник сейчас обслуживает другой экземпляр сервиса
+var errCheckpointLocked = errors.New("checkpoint is locked")
+
+// Message - событие outbox в том виде, в каком его получает приемник
+type Message struct {
+	Sequence    uint
+	EventID     string
+	Type        string
+	AggregateID string
+	Payload     []byte
+	CreatedAt   time.Time
+}
+
+// Sink публикует события. Relay передает события строго по порядку и повторяет пачку целиком,
+// если Publish вернул ошибку, поэтому приемник должен быть готов к повторам (EventID для дедупликации)
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, messages []Message) error
+}
+
+type Store interface {
+	// Transaction выполняет fn в транзакции, вызовы Store с переданным контекстом идут в ней же
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// LockCheckpoint блокирует позицию приемника до конца транзакции; false - заблокирована другим
+	LockCheckpoint(ctx context.Context, sink string) (uint, bool, error)
+	SaveCheckpoint(ctx context.Context, sink string, lastEventID uint) error
+	FetchOutbox(ctx context.Context, afterID uint, limit int) ([]entity.OutboxEvent, error)
+	ListCheckpoints(ctx context.Context) ([]entity.RelayCheckpoint, error)
+	OutboxHead(ctx context.Context) (uint, error)
+	CountOutboxAfter(ctx context.Context, afterID uint) (int64, *time.Time, error)
+	DeleteOutboxBefore(ctx context.Context, maxID uint, createdBefore time.Time) (int64, error)
+}
+
+type Config struct {
+	BatchSize int
+	// пропуск в ID моложе GapTimeout считается незафиксированной транзакцией и ждет ее,
+	// старше - откаченной, и relay идет дальше
+	GapTimeout time.Duration
+	// опубликованные всеми приемниками события хранятся Retention, затем удаляются
+	Retention time.Duration
+	// Clock - часы для возраста пропусков и срока хранения, nil - системные
+	Clock clock.Clock
+}
+
+// Relay переносит события из outbox в приемники, у каждого приемника своя позиция
+type Relay struct {
+	log    *slog.Logger
+	store  Store
+	sinks  []Sink
+	config Config
+}
+
+func NewRelay(log *slog.Logger, store Store, sinks []Sink, config Config) *Relay {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	config.Clock = clock.OrSystem(config.Clock)
+
+	return &Relay{log: log, store: store, sinks: sinks, config: config}
+}
+
+// Run публикует все накопившиеся события во все приемники и удаляет старые опубликованные.
+// Запускается планировщиком
+func (r *Relay) Run(ctx context.Context) error {
+	var errs []error
+
+	for _, sink := range r.sinks {
+		if err := r.drain(ctx, sink); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name(), err))
+		}
+	}
+
+	if err := r.cleanup(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cleanup: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Relay) drain(ctx context.Context, sink Sink) error {
+	log := r.log.With(slog.String("sink", sink.Name()))
+
+	for {
+		published, more, err := r.relayBatch(ctx, sink)
+		if errors.Is(err, errCheckpointLocked) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if published > 0 {
+			log.Debug("events published", slog.Int("count", published))
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// relayBatch публикует одну пачку. Позиция сохраняется в той же транзакции, в которой она
+// заблокирована, поэтому приемник, пишущий в ту же БД, получает события ровно один раз
+func (r *Relay) relayBatch(ctx context.Context, sink Sink) (int, bool, error) {
+	var (
+		published int
+		more      bool
+	)
+
+	err := r.store.Transaction(ctx, func(ctx context.Context) error {
+		last, locked, err := r.store.LockCheckpoint(ctx, sink.Name())
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return errCheckpointLocked
+		}
+
+		events, err := r.store.FetchOutbox(ctx, last, r.config.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		ready := r.contiguous(last, events, r.config.Clock.Now())
+		if len(ready) == 0 {
+			return nil
+		}
+
+		messages := make([]Message, len(ready))
+		for i, event := range ready {
+			messages[i] = Message{
+				Sequence:    event.ID,
+				EventID:     event.EventID,
+				Type:        event.Type,
+				AggregateID: event.AggregateID,
+				Payload:     []byte(event.Payload),
+				CreatedAt:   event.CreatedAt,
+			}
+		}
+
+		if err := sink.Publish(ctx, messages); err != nil {
+			return err
+		}
+
+		published = len(ready)
+		more = len(ready) == len(events) && len(events) == r.config.BatchSize
+
+		return r.store.SaveCheckpoint(ctx, sink.Name(), ready[len(ready)-1].ID)
+	})
+
+	return published, more, err
+}
+
+// contiguous возвращает события до первого свежего пропуска в ID. ID выдаются при вставке,
+// а видны события после фиксации, поэтому транзакция с меньшим ID может зафиксироваться позже;
+// публикация в обход пропуска нарушила бы порядок и потеряла бы такое событие
+func (r *Relay) contiguous(last uint, events []entity.OutboxEvent, now time.Time) []entity.OutboxEvent {
+	expected := last + 1
+
+	for i, event := range events {
+		if event.ID != expected && now.Sub(event.CreatedAt) < r.config.GapTimeout {
+			return events[:i]
+		}
+
+		expected = event.ID + 1
+	}
+
+	return events
+}
+
+func (r *Relay) cleanup(ctx context.Context) error {
+	if r.config.Retention <= 0 || len(r.sinks) == 0 {
+		return nil
+	}
+
+	positions, err := r.positions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var minID uint
+	for i, sink := range r.sinks {
+		position := positions[sink.Name()]
+		if i == 0 || position < minID {
+			minID = position
+		}
+	}
+
+	if minID == 0 {
+		return nil
+	}
+
+	deleted, err := r.store.DeleteOutboxBefore(ctx, minID, r.config.Clock.Now().Add(-r.config.Retention))
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		r.log.Info("published outbox events removed", slog.Int64("count", deleted))
+	}
+
+	return nil
+}
+
+func (r *Relay) positions(ctx context.Context) (map[string]uint, error) {
+	checkpoints, err := r.store.ListCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]uint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		positions[checkpoint.Sink] = checkpoint.LastEventID
+	}
+
+	return positions, nil
+}
+
+// SinkStats - отставание приемника от outbox
+type SinkStats struct {
+	Sink        string     `json:"sink"`
+	LastEventID uint       `json:"last_event_id"`
+	Pending     int64      `json:"pending"`
+	LagSeconds  float64    `json:"lag_seconds"`
+	OldestAt    *time.Time `json:"oldest_pending_at,omitempty"`
+	UpdatedAt   *time.Time `json:"checkpoint_updated_at,omitempty"`
+}
+
+type Stats struct {
+	HeadEventID uint        `json:"head_event_id"`
+	Sinks       []SinkStats `json:"sinks"`
+}
+
+// Stats считает для каждого приемника число неопубликованных событий и возраст самого старого
+func (r *Relay) Stats(ctx context.Context) (*Stats, error) {
+	head, err := r.store.OutboxHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := r.store.ListCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]entity.RelayCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		byName[checkpoint.Sink] = checkpoint
+	}
+
+	stats := &Stats{HeadEventID: head, Sinks: make([]SinkStats, 0, len(r.sinks))}
+	now := r.config.Clock.Now()
+
+	for _, sink := range r.sinks {
+		checkpoint, ok := byName[sink.Name()]
+
+		pending, oldest, err := r.store.CountOutboxAfter(ctx, checkpoint.LastEventID)
+		if err != nil {
+			return nil, err
+		}
+
+		sinkStats := SinkStats{
+			Sink:        sink.Name(),
+			LastEventID: checkpoint.LastEventID,
+			Pending:     pending,
+			OldestAt:    oldest,
+		}
+
+		if oldest != nil {
+			sinkStats.LagSeconds = now.Sub(*oldest).Seconds()
+		}
+
+		if ok {
+			updated := checkpoint.UpdatedAt
+			sinkStats.UpdatedAt = &updated
+		}
+
+		stats.Sinks = append(stats.Sinks, sinkStats)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,396 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/outbox"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var relayNow = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+// fakeStore - Store в памяти. Ошибка в Transaction откатывает сохраненные позиции
+type fakeStore struct {
+	events      []entity.OutboxEvent
+	checkpoints map[string]uint
+	locked      map[string]bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{checkpoints: make(map[string]uint), locked: make(map[string]bool)}
+}
+
+// add добавляет событие с заданным ID, созданное age назад относительно relayNow.
+// События хранятся по возрастанию ID, как их отдает таблица outbox
+func (s *fakeStore) add(id uint, age time.Duration) {
+	s.events = append(s.events, entity.OutboxEvent{
+		ID:          id,
+		EventID:     "evt_" + strconv.FormatUint(uint64(id), 10),
+		Type:        "record.created",
+		AggregateID: strconv.FormatUint(uint64(id), 10),
+		Payload:     "{}",
+		CreatedAt:   relayNow.Add(-age),
+	})
+
+	sort.Slice(s.events, func(i, j int) bool { return s.events[i].ID < s.events[j].ID })
+}
+
+func (s *fakeStore) ids() []uint {
+	ids := make([]uint, len(s.events))
+	for i, event := range s.events {
+		ids[i] = event.ID
+	}
+
+	return ids
+}
+
+func (s *fakeStore) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	saved := make(map[string]uint, len(s.checkpoints))
+	for sink, id := range s.checkpoints {
+		saved[sink] = id
+	}
+
+	if err := fn(ctx); err != nil {
+		s.checkpoints = saved
+		return err
+	}
+
+	return nil
+}
+
+func (s *fakeStore) LockCheckpoint(ctx context.Context, sink string) (uint, bool, error) {
+	return s.checkpoints[sink], !s.locked[sink], nil
+}
+
+func (s *fakeStore) SaveCheckpoint(ctx context.Context, sink string, lastEventID uint) error {
+	s.checkpoints[sink] = lastEventID
+
+	return nil
+}
+
+func (s *fakeStore) FetchOutbox(ctx context.Context, afterID uint, limit int) ([]entity.OutboxEvent, error) {
+	var events []entity.OutboxEvent
+	for _, event := range s.events {
+		if event.ID > afterID && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (s *fakeStore) ListCheckpoints(ctx context.Context) ([]entity.RelayCheckpoint, error) {
+	checkpoints := make([]entity.RelayCheckpoint, 0, len(s.checkpoints))
+	for sink, id := range s.checkpoints {
+		checkpoints = append(checkpoints, entity.RelayCheckpoint{Sink: sink, LastEventID: id})
+	}
+
+	return checkpoints, nil
+}
+
+func (s *fakeStore) OutboxHead(ctx context.Context) (uint, error) {
+	if len(s.events) == 0 {
+		return 0, nil
+	}
+
+	return s.events[len(s.events)-1].ID, nil
+}
+
+func (s *fakeStore) CountOutboxAfter(ctx context.Context, afterID uint) (int64, *time.Time, error) {
+	var (
+		count  int64
+		oldest *time.Time
+	)
+
+	for _, event := range s.events {
+		if event.ID > afterID {
+			if oldest == nil {
+				createdAt := event.CreatedAt
+				oldest = &createdAt
+			}
+			count++
+		}
+	}
+
+	return count, oldest, nil
+}
+
+func (s *fakeStore) DeleteOutboxBefore(ctx context.Context, maxID uint, createdBefore time.Time) (int64, error) {
+	kept := s.events[:0]
+	for _, event := range s.events {
+		if event.ID <= maxID && event.CreatedAt.Before(createdBefore) {
+			continue
+		}
+		kept = append(kept, event)
+	}
+
+	deleted := int64(len(s.events) - len(kept))
+	s.events = kept
+
+	return deleted, nil
+}
+
+// recordingSink запоминает опубликованные Sequence, пока fail не пуст, вызовы возвращают ошибки из него
+type recordingSink struct {
+	name      string
+	fail      []error
+	published []uint
+	calls     int
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(ctx context.Context, messages []outbox.Message) error {
+	s.calls++
+
+	if len(s.fail) > 0 {
+		err := s.fail[0]
+		s.fail = s.fail[1:]
+		return err
+	}
+
+	for _, message := range messages {
+		s.published = append(s.published, message.Sequence)
+	}
+
+	return nil
+}
+
+func newRelay(store *fakeStore, config outbox.Config, sinks ...outbox.Sink) (*outbox.Relay, *clock.Fake) {
+	now := clock.NewFake(relayNow)
+	config.Clock = now
+
+	return outbox.NewRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), store, sinks, config), now
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	store := newFakeStore()
+	for id := uint(1); id <= 7; id++ {
+		store.add(id, time.Minute)
+	}
+
+	first := &recordingSink{name: "first"}
+	second := &recordingSink{name: "second"}
+	relay, _ := newRelay(store, outbox.Config{BatchSize: 3}, first, second)
+
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// пачки по 3 события идут подряд, у каждого приемника своя позиция
+	want := []uint{1, 2, 3, 4, 5, 6, 7}
+	for _, sink := range []*recordingSink{first, second} {
+		if !reflect.DeepEqual(sink.published, want) {
+			t.Fatalf("%s: got %v, want %v", sink.name, sink.published, want)
+		}
+		if sink.calls != 3 {
+			t.Fatalf("%s: got %d batches, want 3", sink.name, sink.calls)
+		}
+		if store.checkpoints[sink.name] != 7 {
+			t.Fatalf("%s: checkpoint %d, want 7", sink.name, store.checkpoints[sink.name])
+		}
+	}
+
+	// повторный запуск продолжает с позиции и не публикует ничего заново
+	store.add(8, 0)
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if want := append(want, 8); !reflect.DeepEqual(first.published, want) {
+		t.Fatalf("second run: got %v, want %v", first.published, want)
+	}
+}
+
+func TestRelayWaitsForFreshGap(t *testing.T) {
+	tests := []struct {
+		name string
+		// возраст события 4, идущего после пропуска на месте 3
+		age  time.Duration
+		want []uint
+	}{
+		{"fresh gap waits", 10 * time.Second, []uint{1, 2}},
+		{"gap at the timeout is skipped", time.Minute, []uint{1, 2, 4, 5}},
+		{"old gap is skipped", time.Hour, []uint{1, 2, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.add(1, time.Hour)
+			store.add(2, time.Hour)
+			store.add(4, tt.age)
+			store.add(5, 0)
+
+			sink := &recordingSink{name: "sink"}
+			relay, _ := newRelay(store, outbox.Config{GapTimeout: time.Minute}, sink)
+
+			if err := relay.Run(context.Background()); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if !reflect.DeepEqual(sink.published, tt.want) {
+				t.Fatalf("got %v, want %v", sink.published, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelayGapClosedOrTimedOut(t *testing.T) {
+	store := newFakeStore()
+	store.add(1, 0)
+	store.add(3, 0)
+
+	sink := &recordingSink{name: "sink"}
+	relay, now := newRelay(store, outbox.Config{GapTimeout: time.Minute}, sink)
+	ctx := context.Background()
+
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !reflect.DeepEqual(sink.published, []uint{1}) {
+		t.Fatalf("before commit: got %v, want [1]", sink.published)
+	}
+
+	// транзакция с ID 2 зафиксировалась - пропуск закрыт, порядок сохранен
+	store.add(2, 0)
+	store.add(5, 0)
+
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !reflect.DeepEqual(sink.published, []uint{1, 2, 3}) {
+		t.Fatalf("after commit: got %v, want [1 2 3]", sink.published)
+	}
+
+	// пропуск на месте 4 так и не закрылся: после GapTimeout relay идет дальше
+	now.Advance(time.Minute)
+
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !reflect.DeepEqual(sink.published, []uint{1, 2, 3, 5}) {
+		t.Fatalf("after timeout: got %v, want [1 2 3 5]", sink.published)
+	}
+}
+
+func TestRelayRetriesFailedBatch(t *testing.T) {
+	store := newFakeStore()
+	for id := uint(1); id <= 4; id++ {
+		store.add(id, time.Minute)
+	}
+
+	failing := &recordingSink{name: "failing", fail: []error{errors.New("broker unavailable")}}
+	healthy := &recordingSink{name: "healthy"}
+	relay, _ := newRelay(store, outbox.Config{BatchSize: 2}, failing, healthy)
+	ctx := context.Background()
+
+	// ошибка одного приемника не останавливает остальные и не сдвигает его позицию
+	err := relay.Run(ctx)
+	if err == nil {
+		t.Fatal("expected sink error")
+	}
+	if len(failing.published) != 0 || store.checkpoints["failing"] != 0 {
+		t.Fatalf("failing sink: published %v, checkpoint %d", failing.published, store.checkpoints["failing"])
+	}
+	if !reflect.DeepEqual(healthy.published, []uint{1, 2, 3, 4}) {
+		t.Fatalf("healthy sink: got %v", healthy.published)
+	}
+
+	// следующий запуск повторяет пачку целиком
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if !reflect.DeepEqual(failing.published, []uint{1, 2, 3, 4}) || store.checkpoints["failing"] != 4 {
+		t.Fatalf("after retry: published %v, checkpoint %d", failing.published, store.checkpoints["failing"])
+	}
+}
+
+func TestRelaySkipsLockedCheckpoint(t *testing.T) {
+	store := newFakeStore()
+	store.add(1, time.Minute)
+	store.locked["sink"] = true
+
+	sink := &recordingSink{name: "sink"}
+	relay, _ := newRelay(store, outbox.Config{}, sink)
+
+	// приемник обслуживает другой экземпляр - это не ошибка
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if sink.calls != 0 {
+		t.Fatalf("published %d batches to a locked sink", sink.calls)
+	}
+}
+
+func TestRelayRetention(t *testing.T) {
+	store := newFakeStore()
+	store.add(1, 3*time.Hour)
+	store.add(2, 3*time.Hour)
+	store.add(3, 3*time.Hour)
+	store.add(4, 30*time.Minute)
+
+	fast := &recordingSink{name: "fast"}
+	slow := &recordingSink{name: "slow"}
+	relay, now := newRelay(store, outbox.Config{Retention: time.Hour}, fast, slow)
+	ctx := context.Background()
+
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// все опубликовано, но событие 4 моложе срока хранения
+	if !reflect.DeepEqual(store.ids(), []uint{4}) {
+		t.Fatalf("after publish: got %v, want [4]", store.ids())
+	}
+
+	// отстающий приемник удерживает неопубликованные им события, сколько бы им ни было
+	store.add(5, 2*time.Hour)
+	store.add(6, 2*time.Hour)
+	slow.fail = []error{errors.New("broker unavailable")}
+	now.Advance(time.Hour)
+
+	if err := relay.Run(ctx); err == nil {
+		t.Fatal("expected sink error")
+	}
+	if !reflect.DeepEqual(store.ids(), []uint{5, 6}) {
+		t.Fatalf("with lagging sink: got %v, want [5 6]", store.ids())
+	}
+
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(store.ids()) != 0 {
+		t.Fatalf("after catch up: got %v, want none", store.ids())
+	}
+}
+
+func TestRelayStats(t *testing.T) {
+	store := newFakeStore()
+	store.add(1, 2*time.Minute)
+	store.add(2, time.Minute)
+	store.checkpoints["sink"] = 1
+
+	relay, _ := newRelay(store, outbox.Config{}, &recordingSink{name: "sink"}, &recordingSink{name: "new"})
+
+	stats, err := relay.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+
+	if stats.HeadEventID != 2 || len(stats.Sinks) != 2 {
+		t.Fatalf("stats: got %+v", stats)
+	}
+	if got := stats.Sinks[0]; got.LastEventID != 1 || got.Pending != 1 || got.LagSeconds != 60 {
+		t.Fatalf("sink: got %+v, want one pending event a minute old", got)
+	}
+	if got := stats.Sinks[1]; got.LastEventID != 0 || got.Pending != 2 || got.LagSeconds != 120 || got.UpdatedAt != nil {
+		t.Fatalf("new sink: got %+v, want everything pending", got)
+	}
+}
@@ -0,0 +1,40 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+)
+
+// WriterSink пишет события построчно в JSON (например, в stdout для сборщика логов)
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Name() string {
+	return "stdout"
+}
+
+func (s *WriterSink) Publish(_ context.Context, messages []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf := bufio.NewWriter(s.w)
+	for _, message := range messages {
+		// payload - уже сериализованное событие
+		if _, err := buf.Write(message.Payload); err != nil {
+			return err
+		}
+		if err := buf.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
@@ -0,0 +1,103 @@
+package pgtest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"testing"
+	"time"
+)
+
+// RecordSaver - хранилище, в которое вставляются фикстуры
+type RecordSaver interface {
+	SaveRecord(ctx context.Context, record *entity.Record) error
+}
+
+// RecordBuilder собирает entity.Record для тестов. По умолчанию - месячная подписка нового пользователя
+// на Netflix за 100 с начала текущего месяца
+type RecordBuilder struct {
+	record entity.Record
+}
+
+func NewRecord() *RecordBuilder {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return &RecordBuilder{record: entity.Record{
+		ServiceName: "Netflix",
+		Price:       100,
+		UserID:      NewUserID(),
+		CreatedAt:   start,
+		ExpiresAt:   start.AddDate(0, 1, 0),
+	}}
+}
+
+func (b *RecordBuilder) User(userID string) *RecordBuilder {
+	b.record.UserID = userID
+	return b
+}
+
+func (b *RecordBuilder) Service(serviceName string) *RecordBuilder {
+	b.record.ServiceName = serviceName
+	return b
+}
+
+func (b *RecordBuilder) Price(price int) *RecordBuilder {
+	b.record.Price = price
+	return b
+}
+
+func (b *RecordBuilder) Category(category string) *RecordBuilder {
+	b.record.Category = category
+	return b
+}
+
+// Period задает период подписки [from, to)
+func (b *RecordBuilder) Period(from, to time.Time) *RecordBuilder {
+	b.record.CreatedAt, b.record.ExpiresAt = from, to
+	return b
+}
+
+// Months задает период из months месяцев, начиная с from
+func (b *RecordBuilder) Months(from time.Time, months int) *RecordBuilder {
+	return b.Period(from, from.AddDate(0, months, 0))
+}
+
+func (b *RecordBuilder) Build() entity.Record {
+	return b.record
+}
+
+// Insert сохраняет запись и возвращает ее с присвоенным ID, ошибка сохранения завершает тест
+func (b *RecordBuilder) Insert(t testing.TB, repo RecordSaver) *entity.Record {
+	t.Helper()
+
+	record := b.Build()
+	if err := repo.SaveRecord(context.Background(), &record); err != nil {
+		t.Fatalf("insert fixture record: %v", err)
+	}
+
+	return &record
+}
+
+// NewUserID возвращает уникальный user_id - случайный UUID версии 4 (RFC 4122), чтобы тесты не видели
+// чужих записей и запросы с ним проходили проверку сервиса
+func NewUserID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Date разбирает дату в формате YYYY-MM-DD, неверная дата - ошибка в самом тесте
+func Date(value string) time.Time {
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return date
+}
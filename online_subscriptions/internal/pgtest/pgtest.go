@@ -0,0 +1,301 @@
+// Package pgtest - окружение интеграционных тестов с настоящим Postgres. Сервер запускается один раз
+// на процесс из локальных бинарников initdb и postgres во временном каталоге и слушает только unix-сокет,
+// сеть не нужна. Каждый тест получает собственную схему с примененными миграциями, поэтому тесты
+// репозитория и HTTP-тесты могут идти параллельно:
+//
+//	func TestMain(m *testing.M) { pgtest.Main(m) }
+//
+//	func TestSumPriceForPeriod(t *testing.T) {
+//		t.Parallel()
+//
+//		repo := pgtest.Repository(t)
+//		pgtest.NewRecord().Price(100).Insert(t, repo)
+//		...
+//	}
+//
+// Каталог бинарников задается PGTEST_BIN, иначе они ищутся в PATH и /usr/lib/postgresql/*/bin.
+// PGTEST_DSN подключает уже запущенный сервер вместо локального. Без бинарников и PGTEST_DSN тесты пропускаются.
+// Postgres не запускается от root, поэтому от root без PGTEST_DSN тесты тоже пропускаются
+package pgtest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/storage"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// сколько ждать готовности запущенного сервера
+const startTimeout = 30 * time.Second
+
+var (
+	errNoPostgres = errors.New("postgres binaries not found, set PGTEST_BIN or PGTEST_DSN")
+	errRoot       = errors.New("postgres does not run as root, run tests as a regular user or set PGTEST_DSN")
+)
+
+var (
+	startOnce sync.Once
+	current   *server
+	startErr  error
+	schemas   atomic.Int64
+)
+
+type server struct {
+	// dsn без search_path, схема добавляется для каждого теста
+	dsn   string
+	admin *gorm.DB
+	// cmd и dir заданы только для сервера, запущенного пакетом
+	cmd *exec.Cmd
+	dir string
+}
+
+// Main выполняет тесты пакета и останавливает запущенный сервер. Вызывается из TestMain
+func Main(m *testing.M) {
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// DB возвращает подключение к новой схеме с примененными миграциями (overlap.policy: reject).
+// Схема удаляется по окончании теста
+func DB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	srv := start(t)
+
+	schema := fmt.Sprintf("pgtest_%d_%d", os.Getpid(), schemas.Add(1))
+	if err := srv.admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := srv.admin.Exec("DROP SCHEMA " + schema + " CASCADE").Error; err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	// public остается в пути поиска ради btree_gist, установленного один раз на сервер
+	db, err := open(fmt.Sprintf("%s search_path=%s,public", srv.dsn, schema))
+	if err != nil {
+		t.Fatalf("connect to postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	driver, err := storage.NewDriver(config.DriverPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := driver.Migrate(db, true); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return db
+}
+
+// Repository возвращает репозиторий над новой схемой, см. DB
+func Repository(t testing.TB) *repository.Repository {
+	t.Helper()
+
+	return repository.NewRepository(DB(t))
+}
+
+func start(t testing.TB) *server {
+	t.Helper()
+
+	startOnce.Do(func() {
+		current, startErr = launch()
+	})
+
+	if errors.Is(startErr, errNoPostgres) || errors.Is(startErr, errRoot) {
+		t.Skip(startErr.Error())
+	}
+
+	if startErr != nil {
+		t.Fatalf("start postgres: %v", startErr)
+	}
+
+	return current
+}
+
+// launch подключается к PGTEST_DSN или инициализирует и запускает локальный сервер
+func launch() (*server, error) {
+	if dsn := os.Getenv("PGTEST_DSN"); dsn != "" {
+		return connect(&server{dsn: dsn})
+	}
+
+	bin, err := findBinaries()
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Geteuid() == 0 {
+		return nil, errRoot
+	}
+
+	dir, err := os.MkdirTemp("", "pgtest-")
+	if err != nil {
+		return nil, err
+	}
+
+	data := filepath.Join(dir, "data")
+
+	initdb := exec.Command(filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync")
+	if out, err := initdb.CombinedOutput(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %w: %s", err, out)
+	}
+
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	defer logFile.Close()
+
+	// без TCP и без гарантий сохранности: база живет только на время тестов
+	cmd := exec.Command(filepath.Join(bin, "postgres"),
+		"-D", data,
+		"-k", dir,
+		"-c", "listen_addresses=",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off",
+		"-c", "max_connections=200",
+	)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := cmd.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("start postgres: %w", err)
+	}
+
+	srv, err := connect(&server{
+		dsn: fmt.Sprintf("host=%s port=5432 user=postgres dbname=postgres sslmode=disable", dir),
+		cmd: cmd,
+		dir: dir,
+	})
+	if err != nil {
+		logs, _ := os.ReadFile(filepath.Join(dir, "postgres.log"))
+		shutdown(cmd, dir)
+		return nil, fmt.Errorf("%w: %s", err, logs)
+	}
+
+	return srv, nil
+}
+
+// connect ждет готовности сервера и устанавливает расширения, общие для всех схем
+func connect(srv *server) (*server, error) {
+	deadline := time.Now().Add(startTimeout)
+
+	for {
+		admin, err := open(srv.dsn)
+		if err == nil {
+			srv.admin = admin
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("postgres is not ready: %w", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := srv.admin.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist SCHEMA public").Error; err != nil {
+		return nil, fmt.Errorf("create btree_gist: %w", err)
+	}
+
+	return srv, nil
+}
+
+func open(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// findBinaries возвращает каталог с initdb и postgres
+func findBinaries() (string, error) {
+	candidates := []string{os.Getenv("PGTEST_BIN")}
+
+	if path, err := exec.LookPath("postgres"); err == nil {
+		candidates = append(candidates, filepath.Dir(path))
+	}
+
+	// самая новая установленная версия
+	installed, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	sort.Sort(sort.Reverse(sort.StringSlice(installed)))
+	candidates = append(candidates, installed...)
+	candidates = append(candidates, "/usr/local/pgsql/bin")
+
+	for _, dir := range candidates {
+		if dir == "" {
+			continue
+		}
+
+		if isFile(filepath.Join(dir, "initdb")) && isFile(filepath.Join(dir, "postgres")) {
+			return dir, nil
+		}
+	}
+
+	return "", errNoPostgres
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func stop() {
+	if current == nil {
+		return
+	}
+
+	if sqlDB, err := current.admin.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+
+	if current.cmd != nil {
+		shutdown(current.cmd, current.dir)
+	}
+}
+
+// shutdown останавливает сервер в режиме fast shutdown и удаляет его каталог
+func shutdown(cmd *exec.Cmd, dir string) {
+	_ = cmd.Process.Signal(os.Interrupt)
+	_ = cmd.Wait()
+	_ = os.RemoveAll(dir)
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm/clause"
+	"sort"
+	"time"
+)
+
+// ключ advisory-блокировки агрегатов ("aggr" в ASCII). Пересчет ключей берет ее разделяемой,
+// полное построение - исключительной
+const aggregatesLockKey int64 = 0x61676772
+
+// aggregateSource пересчитывает агрегаты по записям r, подходящим под where:
+// каждая подписка раскладывается на месяцы от начала до окончания
+func aggregateSource(where string) string {
+	return `
+SELECT month, user_id, service_name, new_count, new_price, active_count, active_price
+FROM (
+	SELECT m.month::date AS month, r.user_id, r.service_name,
+		COUNT(*) FILTER (WHERE r.created_at >= m.month AND r.created_at < m.month + interval '1 month') AS new_count,
+		COALESCE(SUM(r.price) FILTER (WHERE r.created_at >= m.month AND r.created_at < m.month + interval '1 month'), 0) AS new_price,
+		COUNT(*) FILTER (WHERE r.expires_at > (m.month + interval '1 month' - interval '1 day')::date) AS active_count,
+		COALESCE(SUM(r.price) FILTER (WHERE r.expires_at > (m.month + interval '1 month' - interval '1 day')::date), 0) AS active_price
+	FROM records r
+	CROSS JOIN LATERAL generate_series(date_trunc('month', r.created_at), date_trunc('month', r.expires_at), interval '1 month') AS m(month)
+	WHERE ` + where + `
+	GROUP BY 1, 2, 3
+) s
+WHERE new_count > 0 OR active_count > 0`
+}
+
+const aggregateColumns = "month, user_id, service_name, new_count, new_price, active_count, active_price"
+
+// RefreshAggregates пересчитывает агрегаты ключей. Вызывается в транзакции изменения записей,
+// поэтому агрегаты фиксируются вместе с ними
+func (r *Repository) RefreshAggregates(ctx context.Context, keys []entity.AggregateKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return r.Transaction(ctx, func(ctx context.Context) error {
+		db := r.conn(ctx)
+
+		if err := db.Exec("SELECT pg_advisory_xact_lock_shared(?)", aggregatesLockKey).Error; err != nil {
+			return err
+		}
+
+		// блокировки ключей берутся в одном порядке, чтобы параллельные транзакции не взаимоблокировались
+		names := make([]string, len(keys))
+		pairs := make([][]any, len(keys))
+		for i, key := range keys {
+			names[i] = key.UserID + "/" + key.ServiceName
+			pairs[i] = []any{key.UserID, key.ServiceName}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if err := db.Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", int32(aggregatesLockKey), name).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := db.Where("(user_id, service_name) IN ?", pairs).Delete(&entity.MonthlyAggregate{}).Error; err != nil {
+			return err
+		}
+
+		return db.Exec("INSERT INTO monthly_aggregates ("+aggregateColumns+") "+
+			aggregateSource("(r.user_id, r.service_name) IN ?"), pairs).Error
+	})
+}
+
+// RebuildAggregates строит агрегаты заново по всем записям и отмечает их построенными
+func (r *Repository) RebuildAggregates(ctx context.Context) error {
+	return r.Transaction(ctx, func(ctx context.Context) error {
+		db := r.conn(ctx)
+
+		if err := db.Exec("SELECT pg_advisory_xact_lock(?)", aggregatesLockKey).Error; err != nil {
+			return err
+		}
+
+		if err := db.Exec("DELETE FROM monthly_aggregates").Error; err != nil {
+			return err
+		}
+
+		if err := db.Exec("INSERT INTO monthly_aggregates (" + aggregateColumns + ") " + aggregateSource("TRUE")).Error; err != nil {
+			return err
+		}
+
+		return db.Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&entity.AggregateState{ID: 1, BuiltAt: db.NowFunc()}).Error
+	})
+}
+
+// AggregatesBuilt сообщает, построены ли агрегаты полностью
+func (r *Repository) AggregatesBuilt(ctx context.Context) (bool, error) {
+	var count int64
+
+	if err := r.conn(ctx).Model(&entity.AggregateState{}).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// CheckAggregates сравнивает сохраненные агрегаты с пересчитанными по записям
+// и возвращает до limit расхождений
+func (r *Repository) CheckAggregates(ctx context.Context, limit int) ([]entity.AggregateMismatch, error) {
+	var mismatches []entity.AggregateMismatch
+
+	query := `
+WITH expected AS (` + aggregateSource("TRUE") + `)
+SELECT COALESCE(e.month, a.month) AS month,
+	COALESCE(e.user_id, a.user_id) AS user_id,
+	COALESCE(e.service_name, a.service_name) AS service_name,
+	COALESCE(e.new_count, 0) AS expected_new_count, COALESCE(a.new_count, 0) AS actual_new_count,
+	COALESCE(e.new_price, 0) AS expected_new_price, COALESCE(a.new_price, 0) AS actual_new_price,
+	COALESCE(e.active_count, 0) AS expected_active_count, COALESCE(a.active_count, 0) AS actual_active_count,
+	COALESCE(e.active_price, 0) AS expected_active_price, COALESCE(a.active_price, 0) AS actual_active_price
+FROM expected e
+FULL OUTER JOIN monthly_aggregates a
+	ON a.month = e.month AND a.user_id = e.user_id AND a.service_name = e.service_name
+WHERE (e.new_count, e.new_price, e.active_count, e.active_price)
+	IS DISTINCT FROM (a.new_count, a.new_price, a.active_count, a.active_price)
+ORDER BY 1, 2, 3
+LIMIT ?`
+
+	if err := r.conn(ctx).Raw(query, limit).Scan(&mismatches).Error; err != nil {
+		return nil, err
+	}
+
+	return mismatches, nil
+}
+
+// SumAggregatedPrice - сумма цен подписок, начавшихся в месяцах [from, to], по агрегатам
+func (r *Repository) SumAggregatedPrice(ctx context.Context, from, to time.Time, userID, serviceName string) (int, error) {
+	var total int
+
+	query := r.reader(ctx).Model(&entity.MonthlyAggregate{}).Where("month BETWEEN ? AND ?", from, to)
+
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if serviceName != "" {
+		query = query.Where("service_name = ?", serviceName)
+	}
+
+	if err := query.Select("COALESCE(SUM(new_price), 0)").Scan(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// AggregatedMRR - то же, что MRR, по агрегатам
+func (r *Repository) AggregatedMRR(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.MRRPoint, error) {
+	var points []analytics.MRRPoint
+
+	args := analyticsArgs(period, filter)
+	args["prev"] = period.From.AddDate(0, -1, 0)
+
+	query := `
+WITH months AS (
+	SELECT generate_series(@prev::date, @to::date, interval '1 month')::date AS month
+),
+snapshots AS (
+	SELECT m.month, COALESCE(SUM(r.active_price), 0) AS mrr, COALESCE(SUM(r.active_count), 0) AS subscriptions
+	FROM months m
+	LEFT JOIN monthly_aggregates r
+		ON r.month = m.month
+		AND ` + analyticsFilter + `
+	GROUP BY m.month
+)
+SELECT month, mrr, change, subscriptions
+FROM (
+	SELECT month, mrr, subscriptions, mrr - LAG(mrr) OVER (ORDER BY month) AS change
+	FROM snapshots
+) s
+WHERE month >= @from
+ORDER BY month`
+
+	if err := r.conn(ctx).Raw(query, args).Scan(&points).Error; err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// AggregatedTopServices - то же, что TopServices, по агрегатам
+func (r *Repository) AggregatedTopServices(ctx context.Context, period analytics.Range, filter analytics.Filter, by analytics.RankBy, limit int) ([]analytics.ServiceRank, error) {
+	var services []analytics.ServiceRank
+
+	args := analyticsArgs(period, filter)
+	args["limit"] = limit
+
+	query := `
+WITH totals AS (
+	SELECT r.service_name, SUM(r.active_price) AS spend, COUNT(DISTINCT r.user_id) AS subscribers
+	FROM monthly_aggregates r
+	WHERE r.month BETWEEN @from::date AND @to::date
+		AND r.active_count > 0
+		AND ` + analyticsFilter + `
+	GROUP BY r.service_name
+),` + rankedServices(by)
+
+	if err := r.conn(ctx).Raw(query, args).Scan(&services).Error; err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
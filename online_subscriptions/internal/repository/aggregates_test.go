@@ -0,0 +1,166 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/pgtest"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// seedAggregates вставляет подписки пользователя user, начинающиеся на границах и внутри месяцев
+func seedAggregates(t *testing.T, repo *repository.Repository, user string) {
+	t.Helper()
+
+	pgtest.NewRecord().User(user).Service("Netflix").Price(100).Months(pgtest.Date("2025-01-01"), 2).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("Netflix").Price(150).Period(pgtest.Date("2025-03-31"), pgtest.Date("2025-06-15")).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("Spotify").Price(200).Period(pgtest.Date("2025-01-31"), pgtest.Date("2025-02-28")).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("Okko").Price(400).Period(pgtest.Date("2025-02-15"), pgtest.Date("2025-04-01")).Insert(t, repo)
+	pgtest.NewRecord().Service("Netflix").Price(10000).Months(pgtest.Date("2025-02-01"), 1).Insert(t, repo)
+}
+
+func TestAggregatedSumMatchesRecords(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+	seedAggregates(t, repo, user)
+
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	aggregates := services.NewAggregateService(log, repo, services.AggregateConfig{Read: true})
+	if err := aggregates.Rebuild(ctx); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+
+	svc := services.NewRecordService(log, repo, services.NewRecordValidator(services.ValidationRules{}), services.RecordServiceConfig{
+		Clock:      clock.NewFake(pgtest.Date("2025-06-01")),
+		Aggregates: aggregates,
+	})
+
+	tests := []struct {
+		name        string
+		start, end  time.Time
+		userID      string
+		serviceName string
+	}{
+		// целые месяцы считаются по агрегатам
+		{"quarter", pgtest.Date("2025-01-01"), pgtest.Date("2025-03-31"), user, ""},
+		{"february", pgtest.Date("2025-02-01"), pgtest.Date("2025-02-28"), user, ""},
+		{"all users", pgtest.Date("2025-01-01"), pgtest.Date("2025-12-31"), "", ""},
+		{"service", pgtest.Date("2025-01-01"), pgtest.Date("2025-03-31"), user, "Netflix"},
+		{"no records", pgtest.Date("2024-01-01"), pgtest.Date("2024-12-31"), user, ""},
+		// остальные - по записям
+		{"mid-month start", pgtest.Date("2025-01-15"), pgtest.Date("2025-03-31"), user, ""},
+		{"mid-month end", pgtest.Date("2025-01-01"), pgtest.Date("2025-03-30"), user, ""},
+		{"single day", pgtest.Date("2025-01-31"), pgtest.Date("2025-01-31"), user, ""},
+	}
+
+	for _, tt := range tests {
+		want, err := repo.SumPriceForPeriod(ctx, tt.start, tt.end, tt.userID, tt.serviceName)
+		if err != nil {
+			t.Fatalf("%s: SumPriceForPeriod: %v", tt.name, err)
+		}
+
+		got, err := svc.SummaryPriceOfSelectedRecords(ctx, tt.start, tt.end, tt.userID, tt.serviceName)
+		if err != nil {
+			t.Fatalf("%s: summary: %v", tt.name, err)
+		}
+
+		if got != want {
+			t.Fatalf("%s: summary %d, records %d", tt.name, got, want)
+		}
+	}
+
+	// сверка: суммы по агрегатам за каждый месяц совпадают с записями
+	for month := pgtest.Date("2025-01-01"); month.Before(pgtest.Date("2025-07-01")); month = month.AddDate(0, 1, 0) {
+		want, err := repo.SumPriceForPeriod(ctx, month, month.AddDate(0, 1, -1), user, "")
+		if err != nil {
+			t.Fatalf("SumPriceForPeriod: %v", err)
+		}
+
+		got, err := repo.SumAggregatedPrice(ctx, month, month, user, "")
+		if err != nil {
+			t.Fatalf("SumAggregatedPrice: %v", err)
+		}
+
+		if got != want {
+			t.Fatalf("%s: aggregates %d, records %d", month.Format("2006-01"), got, want)
+		}
+	}
+}
+
+func TestAggregatesMaintainRepairsDrift(t *testing.T) {
+	t.Parallel()
+
+	db := pgtest.DB(t)
+	repo := repository.NewRepository(db)
+	user := pgtest.NewUserID()
+	seedAggregates(t, repo, user)
+
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// первый запуск строит агрегаты
+	svc := services.NewAggregateService(log, repo, services.AggregateConfig{Repair: true, CheckLimit: 100})
+	if err := svc.Maintain(ctx); err != nil {
+		t.Fatalf("first maintain: %v", err)
+	}
+
+	if mismatches := checkAggregates(t, svc); len(mismatches) != 0 {
+		t.Fatalf("diverged right after build: %+v", mismatches)
+	}
+
+	// расхождения: испорченная строка агрегатов и запись, добавленная в обход пересчета
+	if err := db.Exec("UPDATE monthly_aggregates SET new_price = new_price + 1 WHERE user_id = ? AND service_name = ?", user, "Okko").Error; err != nil {
+		t.Fatalf("corrupt aggregates: %v", err)
+	}
+	pgtest.NewRecord().User(user).Service("Kion").Price(300).Months(pgtest.Date("2025-05-01"), 1).Insert(t, repo)
+
+	// без починки сверка только сообщает о расхождениях
+	reporting := services.NewAggregateService(log, repo, services.AggregateConfig{CheckLimit: 100})
+	if err := reporting.Maintain(ctx); err != nil {
+		t.Fatalf("maintain without repair: %v", err)
+	}
+
+	mismatches := checkAggregates(t, svc)
+	keys := make(map[string]bool)
+	for _, mismatch := range mismatches {
+		if mismatch.UserID != user {
+			t.Fatalf("mismatch for another user: %+v", mismatch)
+		}
+		keys[mismatch.ServiceName] = true
+	}
+	if len(keys) != 2 || !keys["Okko"] || !keys["Kion"] {
+		t.Fatalf("mismatched services: got %v, want Okko and Kion", keys)
+	}
+
+	if err := svc.Maintain(ctx); err != nil {
+		t.Fatalf("maintain with repair: %v", err)
+	}
+
+	if mismatches := checkAggregates(t, svc); len(mismatches) != 0 {
+		t.Fatalf("still diverged after repair: %+v", mismatches)
+	}
+}
+
+// checkAggregates сверяет агрегаты без починки и возвращает расхождения
+func checkAggregates(t *testing.T, svc *services.AggregateService) []entity.AggregateMismatch {
+	t.Helper()
+
+	check, err := svc.Check(context.Background(), false)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	if !check.Built {
+		t.Fatal("aggregates are not marked as built")
+	}
+	return check.Mismatches
+}
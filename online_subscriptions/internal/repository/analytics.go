@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"time"
+)
+
+// analyticsFilter - условия фильтров отчетов для таблицы records с псевдонимом r
+const analyticsFilter = `(@user_id = '' OR r.user_id = @user_id) AND (@service_name = '' OR r.service_name = @service_name)`
+
+// monthEnd - последний день месяца m.month, на который снимается состояние подписок
+const monthEnd = `(m.month + interval '1 month' - interval '1 day')::date`
+
+func analyticsArgs(period analytics.Range, filter analytics.Filter) map[string]any {
+	return map[string]any{
+		"from":         period.From,
+		"to":           period.To,
+		"end":          period.End(),
+		"user_id":      filter.UserID,
+		"service_name": filter.ServiceName,
+	}
+}
+
+// MRR возвращает выручку на конец каждого месяца периода. Изменение к предыдущему месяцу
+// считается через LAG, поэтому ряд начинается на месяц раньше периода
+func (r *Repository) MRR(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.MRRPoint, error) {
+	var points []analytics.MRRPoint
+
+	args := analyticsArgs(period, filter)
+	args["prev"] = period.From.AddDate(0, -1, 0)
+
+	query := `
+WITH months AS (
+	SELECT generate_series(@prev::date, @to::date, interval '1 month')::date AS month
+),
+snapshots AS (
+	SELECT m.month, COALESCE(SUM(r.price), 0) AS mrr, COUNT(r.id) AS subscriptions
+	FROM months m
+	LEFT JOIN records r
+		ON r.created_at <= ` + monthEnd + `
+		AND r.expires_at > ` + monthEnd + `
+		AND ` + analyticsFilter + `
+	GROUP BY m.month
+)
+SELECT month, mrr, change, subscriptions
+FROM (
+	SELECT month, mrr, subscriptions, mrr - LAG(mrr) OVER (ORDER BY month) AS change
+	FROM snapshots
+) s
+WHERE month >= @from
+ORDER BY month`
+
+	if err := r.conn(ctx).Raw(query, args).Scan(&points).Error; err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// Churn возвращает новые и завершившиеся к today подписки по месяцам периода
+func (r *Repository) Churn(ctx context.Context, period analytics.Range, filter analytics.Filter, today time.Time) ([]analytics.ChurnPoint, error) {
+	var points []analytics.ChurnPoint
+
+	args := analyticsArgs(period, filter)
+	args["today"] = today
+
+	query := `
+WITH months AS (
+	SELECT generate_series(@from::date, @to::date, interval '1 month')::date AS month
+),
+filtered AS (
+	SELECT r.created_at, r.expires_at FROM records r WHERE ` + analyticsFilter + `
+),
+stats AS (
+	SELECT m.month,
+		COUNT(*) FILTER (WHERE f.created_at >= m.month AND f.created_at < m.month + interval '1 month') AS "new",
+		COUNT(*) FILTER (WHERE f.expires_at >= m.month AND f.expires_at < m.month + interval '1 month' AND f.expires_at <= @today::date) AS churned,
+		COUNT(*) FILTER (WHERE f.created_at < m.month AND f.expires_at >= m.month) AS active
+	FROM months m
+	LEFT JOIN filtered f ON f.created_at < m.month + interval '1 month' AND f.expires_at >= m.month
+	GROUP BY m.month
+)
+SELECT month, "new", churned, "new" - churned AS net, active,
+	COALESCE(ROUND(100.0 * churned / NULLIF(active, 0), 2), 0)::float8 AS churn_rate,
+	SUM("new" - churned) OVER (ORDER BY month) AS cumulative_net
+FROM stats
+ORDER BY month`
+
+	if err := r.conn(ctx).Raw(query, args).Scan(&points).Error; err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// TopServices возвращает limit сервисов с наибольшими расходами или числом подписчиков за период.
+// Расходы сервиса - сумма его MRR по месяцам периода
+func (r *Repository) TopServices(ctx context.Context, period analytics.Range, filter analytics.Filter, by analytics.RankBy, limit int) ([]analytics.ServiceRank, error) {
+	var services []analytics.ServiceRank
+
+	args := analyticsArgs(period, filter)
+	args["limit"] = limit
+
+	query := `
+WITH months AS (
+	SELECT generate_series(@from::date, @to::date, interval '1 month')::date AS month
+),
+totals AS (
+	SELECT r.service_name, SUM(r.price) AS spend, COUNT(DISTINCT r.user_id) AS subscribers
+	FROM months m
+	JOIN records r
+		ON r.created_at <= ` + monthEnd + `
+		AND r.expires_at > ` + monthEnd + `
+		AND ` + analyticsFilter + `
+	GROUP BY r.service_name
+),` + rankedServices(by)
+
+	if err := r.conn(ctx).Raw(query, args).Scan(&services).Error; err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+// rankedServices ранжирует сервисы из CTE totals(service_name, spend, subscribers)
+func rankedServices(by analytics.RankBy) string {
+	// порядок выбирается из фиксированных значений, пользовательский ввод в запрос не попадает
+	order := "spend_rank"
+	if by == analytics.RankBySubscribers {
+		order = "subscribers_rank"
+	}
+
+	return fmt.Sprintf(`
+ranked AS (
+	SELECT service_name, spend, subscribers,
+		RANK() OVER (ORDER BY spend DESC) AS spend_rank,
+		RANK() OVER (ORDER BY subscribers DESC) AS subscribers_rank,
+		COALESCE(ROUND(100.0 * spend / NULLIF(SUM(spend) OVER (), 0), 2), 0)::float8 AS share
+	FROM totals
+)
+SELECT service_name, spend, subscribers, spend_rank, subscribers_rank, share
+FROM ranked
+ORDER BY %s, service_name
+LIMIT @limit`, order)
+}
+
+// Lifetimes возвращает срок жизни подписок, начавшихся в периоде: первой строкой итог, затем по сервисам
+func (r *Repository) Lifetimes(ctx context.Context, period analytics.Range, filter analytics.Filter, today time.Time) ([]analytics.Lifetime, error) {
+	var lifetimes []analytics.Lifetime
+
+	args := analyticsArgs(period, filter)
+	args["today"] = today
+
+	query := `
+WITH started AS (
+	SELECT r.service_name,
+		LEAST(r.expires_at, @today::date) - r.created_at AS days,
+		r.expires_at > @today::date AS active
+	FROM records r
+	WHERE r.created_at >= @from::date AND r.created_at < @end::date AND r.created_at <= @today::date
+		AND ` + analyticsFilter + `
+)
+SELECT COALESCE(service_name, '') AS service_name,
+	COUNT(*) AS subscriptions,
+	COUNT(*) FILTER (WHERE active) AS active,
+	COALESCE(ROUND(AVG(days), 2), 0)::float8 AS average_days,
+	COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY days), 0)::float8 AS median_days
+FROM started
+GROUP BY GROUPING SETS ((), (service_name))
+ORDER BY GROUPING(service_name) DESC, service_name`
+
+	if err := r.conn(ctx).Raw(query, args).Scan(&lifetimes).Error; err != nil {
+		return nil, err
+	}
+
+	return lifetimes, nil
+}
+
+// Cohorts возвращает удержание когорт подписок по месяцу начала. Смещения ограничены месяцем last:
+// подписка удержана через k месяцев, если действует на конец k-го месяца после начала когорты
+func (r *Repository) Cohorts(ctx context.Context, period analytics.Range, filter analytics.Filter, last time.Time) ([]analytics.CohortRow, error) {
+	var rows []analytics.CohortRow
+
+	args := analyticsArgs(period, filter)
+	args["last"] = last
+	args["months"] = period.Months()
+
+	query := `
+WITH cohorts AS (
+	SELECT date_trunc('month', r.created_at)::date AS cohort,
+		r.expires_at,
+		COUNT(*) OVER (PARTITION BY date_trunc('month', r.created_at)) AS size
+	FROM records r
+	WHERE r.created_at >= @from::date AND r.created_at < @end::date
+		AND ` + analyticsFilter + `
+),
+offsets AS (
+	SELECT DISTINCT c.cohort, c.size, k.n AS month_offset
+	FROM cohorts c
+	CROSS JOIN generate_series(0, @months::int - 1) AS k(n)
+	WHERE c.cohort + make_interval(months => k.n) <= @last::date
+)
+SELECT o.cohort, o.size, o.month_offset AS "offset", COUNT(c.cohort) AS retained
+FROM offsets o
+LEFT JOIN cohorts c
+	ON c.cohort = o.cohort
+	AND c.expires_at > (o.cohort + make_interval(months => o.month_offset + 1) - interval '1 day')::date
+GROUP BY o.cohort, o.size, o.month_offset
+ORDER BY o.cohort, o.month_offset`
+
+	if err := r.conn(ctx).Raw(query, args).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,333 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/pgtest"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository"
+	"reflect"
+	"testing"
+)
+
+// seedAnalytics вставляет подписки пользователя user на январь-апрель 2025:
+//
+//	Netflix 100: 15.01 - 15.04
+//	Spotify 200: 01.02 - 01.03
+//	Okko    400: 31.03 - 30.04 - начинается и заканчивается ровно в последний день месяца
+//
+// и одну подписку другого пользователя, которую фильтр по user_id должен отсечь
+func seedAnalytics(t *testing.T, repo *repository.Repository, user string) {
+	t.Helper()
+
+	pgtest.NewRecord().User(user).Service("Netflix").Price(100).Period(pgtest.Date("2025-01-15"), pgtest.Date("2025-04-15")).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("Spotify").Price(200).Period(pgtest.Date("2025-02-01"), pgtest.Date("2025-03-01")).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("Okko").Price(400).Period(pgtest.Date("2025-03-31"), pgtest.Date("2025-04-30")).Insert(t, repo)
+
+	pgtest.NewRecord().Service("Netflix").Price(10000).Period(pgtest.Date("2024-12-01"), pgtest.Date("2025-12-01")).Insert(t, repo)
+}
+
+// janToApr - период январь-апрель 2025
+var janToApr = analytics.NewRange(pgtest.Date("2025-01-01"), pgtest.Date("2025-04-01"))
+
+func TestAnalyticsMRR(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+	seedAnalytics(t, repo, user)
+
+	tests := []struct {
+		name   string
+		filter analytics.Filter
+		want   []analytics.MRRPoint
+	}{
+		{
+			// снимок на конец месяца: Spotify, закончившаяся 01.03, в марте уже не считается,
+			// Okko с 31.03 считается в марте, а в апреле заканчивается в последний день и выпадает
+			name:   "user",
+			filter: analytics.Filter{UserID: user},
+			want: []analytics.MRRPoint{
+				{Month: pgtest.Date("2025-01-01"), MRR: 100, Change: 100, Subscriptions: 1},
+				{Month: pgtest.Date("2025-02-01"), MRR: 300, Change: 200, Subscriptions: 2},
+				{Month: pgtest.Date("2025-03-01"), MRR: 500, Change: 200, Subscriptions: 2},
+				{Month: pgtest.Date("2025-04-01"), MRR: 0, Change: -500, Subscriptions: 0},
+			},
+		},
+		{
+			name:   "user and service",
+			filter: analytics.Filter{UserID: user, ServiceName: "Netflix"},
+			want: []analytics.MRRPoint{
+				{Month: pgtest.Date("2025-01-01"), MRR: 100, Change: 100, Subscriptions: 1},
+				{Month: pgtest.Date("2025-02-01"), MRR: 100, Change: 0, Subscriptions: 1},
+				{Month: pgtest.Date("2025-03-01"), MRR: 100, Change: 0, Subscriptions: 1},
+				{Month: pgtest.Date("2025-04-01"), MRR: 0, Change: -100, Subscriptions: 0},
+			},
+		},
+		{
+			// месяцы без подписок все равно попадают в ряд
+			name:   "empty",
+			filter: analytics.Filter{UserID: pgtest.NewUserID()},
+			want: []analytics.MRRPoint{
+				{Month: pgtest.Date("2025-01-01")},
+				{Month: pgtest.Date("2025-02-01")},
+				{Month: pgtest.Date("2025-03-01")},
+				{Month: pgtest.Date("2025-04-01")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		points, err := repo.MRR(context.Background(), janToApr, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: MRR: %v", tt.name, err)
+		}
+
+		if len(points) != len(tt.want) {
+			t.Fatalf("%s: got %d points, want %d: %+v", tt.name, len(points), len(tt.want), points)
+		}
+		for i, want := range tt.want {
+			got := points[i]
+			if !got.Month.Equal(want.Month) || got.MRR != want.MRR || got.Change != want.Change || got.Subscriptions != want.Subscriptions {
+				t.Fatalf("%s: point %d: got %+v, want %+v", tt.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestAnalyticsMRRPeriodStartsAfterRecords(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+	seedAnalytics(t, repo, user)
+
+	// изменение первого месяца считается от месяца перед периодом
+	points, err := repo.MRR(context.Background(), analytics.NewRange(pgtest.Date("2025-03-01"), pgtest.Date("2025-03-01")), analytics.Filter{UserID: user})
+	if err != nil {
+		t.Fatalf("MRR: %v", err)
+	}
+
+	if len(points) != 1 || points[0].MRR != 500 || points[0].Change != 200 {
+		t.Fatalf("got %+v, want one point with MRR 500 and change 200", points)
+	}
+}
+
+func TestAnalyticsChurn(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+	seedAnalytics(t, repo, user)
+
+	// Okko заканчивается 30.04, после today, и еще не считается завершившейся
+	today := pgtest.Date("2025-04-20")
+
+	points, err := repo.Churn(context.Background(), janToApr, analytics.Filter{UserID: user}, today)
+	if err != nil {
+		t.Fatalf("Churn: %v", err)
+	}
+
+	want := []analytics.ChurnPoint{
+		{Month: pgtest.Date("2025-01-01"), New: 1, Churned: 0, Net: 1, Active: 0, ChurnRate: 0, CumulativeNet: 1},
+		{Month: pgtest.Date("2025-02-01"), New: 1, Churned: 0, Net: 1, Active: 1, ChurnRate: 0, CumulativeNet: 2},
+		{Month: pgtest.Date("2025-03-01"), New: 1, Churned: 1, Net: 0, Active: 2, ChurnRate: 50, CumulativeNet: 2},
+		{Month: pgtest.Date("2025-04-01"), New: 0, Churned: 1, Net: -1, Active: 2, ChurnRate: 50, CumulativeNet: 1},
+	}
+
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d: %+v", len(points), len(want), points)
+	}
+	for i, w := range want {
+		got := points[i]
+		if !got.Month.Equal(w.Month) || got.New != w.New || got.Churned != w.Churned || got.Net != w.Net ||
+			got.Active != w.Active || got.ChurnRate != w.ChurnRate || got.CumulativeNet != w.CumulativeNet {
+			t.Fatalf("point %d: got %+v, want %+v", i, got, w)
+		}
+	}
+
+	// другой сервис того же пользователя
+	points, err = repo.Churn(context.Background(), janToApr, analytics.Filter{UserID: user, ServiceName: "Spotify"}, today)
+	if err != nil {
+		t.Fatalf("Churn for service: %v", err)
+	}
+
+	var news, churned int
+	for _, point := range points {
+		news += point.New
+		churned += point.Churned
+	}
+	if len(points) != 4 || news != 1 || churned != 1 || points[2].Churned != 1 {
+		t.Fatalf("Spotify churn: got %+v, want one new in February and one churned in March", points)
+	}
+
+	// пустой фильтр: месяцы есть, подписок нет
+	points, err = repo.Churn(context.Background(), janToApr, analytics.Filter{UserID: pgtest.NewUserID()}, today)
+	if err != nil {
+		t.Fatalf("Churn for empty user: %v", err)
+	}
+	for _, point := range points {
+		if point.New != 0 || point.Churned != 0 || point.Active != 0 || point.ChurnRate != 0 {
+			t.Fatalf("empty user: got %+v", point)
+		}
+	}
+	if len(points) != 4 {
+		t.Fatalf("empty user: got %d points, want 4", len(points))
+	}
+}
+
+func TestAnalyticsTopServices(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+	seedAnalytics(t, repo, user)
+
+	ctx := context.Background()
+	filter := analytics.Filter{UserID: user}
+
+	// расходы - сумма MRR по месяцам: Netflix 3 x 100, Spotify 200, Okko 400
+	services, err := repo.TopServices(ctx, janToApr, filter, analytics.RankBySpend, 10)
+	if err != nil {
+		t.Fatalf("TopServices: %v", err)
+	}
+
+	want := []analytics.ServiceRank{
+		{ServiceName: "Okko", Spend: 400, Subscribers: 1, SpendRank: 1, SubscribersRank: 1, Share: 44.44},
+		{ServiceName: "Netflix", Spend: 300, Subscribers: 1, SpendRank: 2, SubscribersRank: 1, Share: 33.33},
+		{ServiceName: "Spotify", Spend: 200, Subscribers: 1, SpendRank: 3, SubscribersRank: 1, Share: 22.22},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Fatalf("by spend: got %+v, want %+v", services, want)
+	}
+
+	// при равном ранге - по названию
+	services, err = repo.TopServices(ctx, janToApr, filter, analytics.RankBySubscribers, 2)
+	if err != nil {
+		t.Fatalf("TopServices by subscribers: %v", err)
+	}
+	if len(services) != 2 || services[0].ServiceName != "Netflix" || services[1].ServiceName != "Okko" {
+		t.Fatalf("by subscribers: got %+v, want Netflix, Okko", services)
+	}
+
+	// апрель: на 30.04 ничего не действует
+	services, err = repo.TopServices(ctx, analytics.NewRange(pgtest.Date("2025-04-01"), pgtest.Date("2025-04-01")), filter, analytics.RankBySpend, 10)
+	if err != nil {
+		t.Fatalf("TopServices for April: %v", err)
+	}
+	if len(services) != 0 {
+		t.Fatalf("April: got %+v, want no services", services)
+	}
+
+	services, err = repo.TopServices(ctx, janToApr, analytics.Filter{UserID: user, ServiceName: "Spotify"}, analytics.RankBySpend, 10)
+	if err != nil {
+		t.Fatalf("TopServices for service: %v", err)
+	}
+	if len(services) != 1 || services[0].ServiceName != "Spotify" || services[0].Share != 100 {
+		t.Fatalf("Spotify: got %+v", services)
+	}
+}
+
+func TestAnalyticsLifetimes(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+	seedAnalytics(t, repo, user)
+
+	ctx := context.Background()
+	today := pgtest.Date("2025-04-20")
+
+	// Netflix 90 дней, Spotify 28, Okko действует и живет 20 дней по today
+	lifetimes, err := repo.Lifetimes(ctx, janToApr, analytics.Filter{UserID: user}, today)
+	if err != nil {
+		t.Fatalf("Lifetimes: %v", err)
+	}
+
+	want := []analytics.Lifetime{
+		{Subscriptions: 3, Active: 1, AverageDays: 46, MedianDays: 28},
+		{ServiceName: "Netflix", Subscriptions: 1, AverageDays: 90, MedianDays: 90},
+		{ServiceName: "Okko", Subscriptions: 1, Active: 1, AverageDays: 20, MedianDays: 20},
+		{ServiceName: "Spotify", Subscriptions: 1, AverageDays: 28, MedianDays: 28},
+	}
+	if !reflect.DeepEqual(lifetimes, want) {
+		t.Fatalf("got %+v, want %+v", lifetimes, want)
+	}
+
+	// период считается по дате начала: Netflix начался в январе и в февраль-март не входит
+	lifetimes, err = repo.Lifetimes(ctx, analytics.NewRange(pgtest.Date("2025-02-01"), pgtest.Date("2025-03-01")), analytics.Filter{UserID: user}, today)
+	if err != nil {
+		t.Fatalf("Lifetimes for February-March: %v", err)
+	}
+	if len(lifetimes) != 3 || lifetimes[0].Subscriptions != 2 {
+		t.Fatalf("February-March: got %+v, want total of 2 and two services", lifetimes)
+	}
+
+	// подписки, начинающиеся после today, не учитываются
+	lifetimes, err = repo.Lifetimes(ctx, janToApr, analytics.Filter{UserID: user}, pgtest.Date("2025-03-30"))
+	if err != nil {
+		t.Fatalf("Lifetimes before Okko: %v", err)
+	}
+	if lifetimes[0].Subscriptions != 2 {
+		t.Fatalf("before Okko: got %+v, want total of 2", lifetimes)
+	}
+
+	// без подписок остается только итоговая строка с нулями
+	lifetimes, err = repo.Lifetimes(ctx, janToApr, analytics.Filter{UserID: pgtest.NewUserID()}, today)
+	if err != nil {
+		t.Fatalf("Lifetimes for empty user: %v", err)
+	}
+	if !reflect.DeepEqual(lifetimes, []analytics.Lifetime{{}}) {
+		t.Fatalf("empty user: got %+v, want one zero total", lifetimes)
+	}
+}
+
+func TestAnalyticsCohorts(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+	seedAnalytics(t, repo, user)
+
+	ctx := context.Background()
+
+	// смещения не дальше апреля; подписка удержана, если действует на конец месяца смещения
+	rows, err := repo.Cohorts(ctx, janToApr, analytics.Filter{UserID: user}, pgtest.Date("2025-04-01"))
+	if err != nil {
+		t.Fatalf("Cohorts: %v", err)
+	}
+
+	type row struct {
+		cohort                 string
+		size, offset, retained int
+	}
+
+	want := []row{
+		{"2025-01", 1, 0, 1}, {"2025-01", 1, 1, 1}, {"2025-01", 1, 2, 1}, {"2025-01", 1, 3, 0},
+		{"2025-02", 1, 0, 1}, {"2025-02", 1, 1, 0}, {"2025-02", 1, 2, 0},
+		{"2025-03", 1, 0, 1}, {"2025-03", 1, 1, 0},
+	}
+
+	got := make([]row, len(rows))
+	for i, r := range rows {
+		got[i] = row{r.Cohort.Format("2006-01"), r.Size, r.Offset, r.Retained}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	rows, err = repo.Cohorts(ctx, janToApr, analytics.Filter{UserID: user, ServiceName: "Okko"}, pgtest.Date("2025-04-01"))
+	if err != nil {
+		t.Fatalf("Cohorts for service: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Cohort.Format("2006-01") != "2025-03" {
+		t.Fatalf("Okko: got %+v, want the March cohort only", rows)
+	}
+
+	rows, err = repo.Cohorts(ctx, janToApr, analytics.Filter{UserID: pgtest.NewUserID()}, pgtest.Date("2025-04-01"))
+	if err != nil {
+		t.Fatalf("Cohorts for empty user: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("empty user: got %+v, want no rows", rows)
+	}
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *Repository) CreateBudget(ctx context.Context, budget *entity.Budget) error {
+	return r.conn(ctx).Create(budget).Error
+}
+
+func (r *Repository) GetBudgetByUserID(ctx context.Context, userID string) (*entity.Budget, error) {
+	var budget entity.Budget
+
+	err := r.conn(ctx).
+		Preload("CategoryLimits", func(db *gorm.DB) *gorm.DB { return db.Order("category") }).
+		Where("user_id = ?", userID).
+		First(&budget).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &budget, nil
+}
+
+func (r *Repository) ListBudgets(ctx context.Context, limit, offset int) ([]entity.Budget, error) {
+	var budgets []entity.Budget
+
+	query := r.conn(ctx).
+		Preload("CategoryLimits", func(db *gorm.DB) *gorm.DB { return db.Order("category") }).
+		Order("id")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	if err := query.Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+// ReplaceBudget сохраняет лимиты бюджета, категорийные лимиты заменяются целиком
+func (r *Repository) ReplaceBudget(ctx context.Context, budget *entity.Budget) error {
+	return r.Transaction(ctx, func(ctx context.Context) error {
+		result := r.conn(ctx).Model(&entity.Budget{}).
+			Where("id = ?", budget.ID).
+			Updates(map[string]any{"monthly_limit": budget.MonthlyLimit, "enforce": budget.Enforce})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := r.conn(ctx).Where("budget_id = ?", budget.ID).Delete(&entity.BudgetCategoryLimit{}).Error; err != nil {
+			return err
+		}
+
+		if len(budget.CategoryLimits) == 0 {
+			return nil
+		}
+
+		for i := range budget.CategoryLimits {
+			budget.CategoryLimits[i].ID = 0
+			budget.CategoryLimits[i].BudgetID = budget.ID
+		}
+
+		return r.conn(ctx).Create(&budget.CategoryLimits).Error
+	})
+}
+
+func (r *Repository) DeleteBudget(ctx context.Context, userID string) error {
+	result := r.conn(ctx).Where("user_id = ?", userID).Delete(&entity.Budget{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ClaimBudgetAlert сохраняет уведомление о превышении, если такое еще не отправлялось. false - уже отправлено
+func (r *Repository) ClaimBudgetAlert(ctx context.Context, alert *entity.BudgetAlert) (bool, error) {
+	result := r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(alert)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *Repository) ReleaseBudgetAlert(ctx context.Context, alert *entity.BudgetAlert) error {
+	return r.conn(ctx).Delete(&entity.BudgetAlert{}, alert.ID).Error
+}
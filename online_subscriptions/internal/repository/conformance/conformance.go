@@ -0,0 +1,630 @@
+// Package conformance - общий набор проверок реализаций services.Repository.
+// Реализация подключается из своего теста:
+//
+//	func TestRepository(t *testing.T) {
+//		conformance.Run(t, func(t *testing.T) services.Repository { return memory.New(false, nil) })
+//	}
+//
+// Проверки используют собственные user_id и не создают пересекающихся подписок,
+// поэтому подходят и для общей тестовой базы Postgres с любой политикой пересечений
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/pgtest"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"gorm.io/gorm"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory создает репозиторий для одной проверки
+type Factory func(t *testing.T) services.Repository
+
+type testCase struct {
+	name string
+	run  func(t *testing.T, repo services.Repository)
+}
+
+var cases = []testCase{
+	{"SaveAndGet", testSaveAndGet},
+	{"GetMissing", testGetMissing},
+	{"Delete", testDelete},
+	{"UpdateReplacesAllFields", testUpdateReplacesAllFields},
+	{"UpdateKeepsCreatedAt", testUpdateKeepsCreatedAt},
+	{"UpdateMissing", testUpdateMissing},
+	{"ListFilterOrderPaging", testList},
+	{"ListPagingSameCreatedAt", testListSameCreatedAt},
+	{"GetByUserID", testGetByUserID},
+	{"LatestByUserAndService", testLatestByUserAndService},
+	{"NaturalKey", testNaturalKey},
+	{"FindOverlapping", testFindOverlapping},
+	{"SumPriceForPeriod", testSum},
+	{"StreamRecords", testStream},
+	{"SaveRecordsAtomic", testSaveRecordsAtomic},
+	{"TransactionRollback", testTransactionRollback},
+	{"NestedTransactionRollback", testNestedTransactionRollback},
+	{"ConcurrentSaves", testConcurrentSaves},
+}
+
+// Run прогоняет все проверки, для каждой создавая репозиторий через newRepo
+func Run(t *testing.T, newRepo Factory) {
+	t.Helper()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, newRepo(t))
+		})
+	}
+}
+
+func date(value string) time.Time {
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+func record(userID, serviceName string, price int, createdAt, expiresAt string) entity.Record {
+	return entity.Record{
+		UserID:      userID,
+		ServiceName: serviceName,
+		Price:       price,
+		CreatedAt:   date(createdAt),
+		ExpiresAt:   date(expiresAt),
+	}
+}
+
+func save(t *testing.T, repo services.Repository, records ...entity.Record) []entity.Record {
+	t.Helper()
+
+	for i := range records {
+		if err := repo.SaveRecord(context.Background(), &records[i]); err != nil {
+			t.Fatalf("SaveRecord: %v", err)
+		}
+	}
+
+	return records
+}
+
+func ids(records []entity.Record) []uint {
+	result := make([]uint, len(records))
+	for i, record := range records {
+		result[i] = record.ID
+	}
+
+	return result
+}
+
+func expectIDs(t *testing.T, what string, got []entity.Record, want ...uint) {
+	t.Helper()
+
+	if fmt.Sprint(ids(got)) != fmt.Sprint(want) {
+		t.Fatalf("%s: got ids %v, want %v", what, ids(got), want)
+	}
+}
+
+func expectNotFound(t *testing.T, what string, err error) {
+	t.Helper()
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("%s: got error %v, want gorm.ErrRecordNotFound", what, err)
+	}
+}
+
+func testSaveAndGet(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	saved := save(t, repo, entity.Record{
+		UserID:      user,
+		ServiceName: "Netflix",
+		Price:       400,
+		Category:    "video",
+		CreatedAt:   time.Date(2024, 3, 15, 13, 45, 0, 0, time.UTC),
+		ExpiresAt:   date("2024-09-15"),
+	})[0]
+
+	if saved.ID == 0 {
+		t.Fatal("SaveRecord did not assign an ID")
+	}
+
+	got, err := repo.GetRecordByID(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("GetRecordByID: %v", err)
+	}
+
+	// даты хранятся без времени
+	want := entity.Record{
+		ID:          saved.ID,
+		ServiceName: "Netflix",
+		Price:       400,
+		UserID:      user,
+		Category:    "video",
+		CreatedAt:   date("2024-03-15"),
+		ExpiresAt:   date("2024-09-15"),
+	}
+
+	if !got.CreatedAt.Equal(want.CreatedAt) || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Fatalf("dates: got %v - %v, want %v - %v", got.CreatedAt, got.ExpiresAt, want.CreatedAt, want.ExpiresAt)
+	}
+
+	got.CreatedAt, got.ExpiresAt = want.CreatedAt, want.ExpiresAt
+	if *got != want {
+		t.Fatalf("GetRecordByID: got %+v, want %+v", *got, want)
+	}
+}
+
+func testGetMissing(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	_, err := repo.GetRecordByID(ctx, 1<<31-1)
+	expectNotFound(t, "GetRecordByID", err)
+
+	_, err = repo.GetRecordByUserIDAndServiceName(ctx, user, "Netflix")
+	expectNotFound(t, "GetRecordByUserIDAndServiceName", err)
+
+	_, err = repo.GetRecordByNaturalKey(ctx, user, "Netflix", date("2024-01-01"))
+	expectNotFound(t, "GetRecordByNaturalKey", err)
+
+	records, err := repo.GetRecordsByUserID(ctx, user)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("GetRecordsByUserID: got %v, %v, want no records", records, err)
+	}
+}
+
+func testDelete(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	saved := save(t, repo, record(pgtest.NewUserID(), "Netflix", 400, "2024-01-01", "2024-06-01"))[0]
+
+	if err := repo.DeleteRecordByID(ctx, saved.ID); err != nil {
+		t.Fatalf("DeleteRecordByID: %v", err)
+	}
+
+	_, err := repo.GetRecordByID(ctx, saved.ID)
+	expectNotFound(t, "GetRecordByID after delete", err)
+
+	expectNotFound(t, "second DeleteRecordByID", repo.DeleteRecordByID(ctx, saved.ID))
+}
+
+func testUpdateReplacesAllFields(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	saved := save(t, repo, entity.Record{
+		UserID:      user,
+		ServiceName: "Netflix",
+		Price:       400,
+		Category:    "video",
+		CreatedAt:   date("2024-01-01"),
+		ExpiresAt:   date("2024-06-01"),
+	})[0]
+
+	// нулевые значения тоже записываются: бесплатная подписка без категории
+	update := entity.Record{
+		ID:          saved.ID,
+		UserID:      user,
+		ServiceName: "Netflix",
+		Price:       0,
+		Category:    "",
+		CreatedAt:   date("2024-02-01"),
+		ExpiresAt:   date("2024-12-01"),
+	}
+	if err := repo.UpdateRecord(ctx, &update); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+
+	got, err := repo.GetRecordByID(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("GetRecordByID: %v", err)
+	}
+
+	if got.ID != saved.ID || got.UserID != user || got.ServiceName != "Netflix" {
+		t.Fatalf("identity fields changed: got %+v", *got)
+	}
+
+	if got.Price != 0 || got.Category != "" || !got.CreatedAt.Equal(date("2024-02-01")) || !got.ExpiresAt.Equal(date("2024-12-01")) {
+		t.Fatalf("all fields must be replaced: got %+v", *got)
+	}
+}
+
+func testUpdateKeepsCreatedAt(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	saved := save(t, repo, record(user, "Netflix", 400, "2024-01-01", "2024-06-01"))[0]
+
+	// PUT без created_at: дата начала не задана и не должна сдвинуться
+	update := record(user, "Netflix", 500, "2024-01-01", "2024-09-01")
+	update.ID = saved.ID
+	update.CreatedAt = time.Time{}
+
+	if err := repo.UpdateRecord(ctx, &update); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+
+	got, err := repo.GetRecordByID(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("GetRecordByID: %v", err)
+	}
+
+	if !got.CreatedAt.Equal(date("2024-01-01")) {
+		t.Fatalf("created_at must be kept: got %s", got.CreatedAt)
+	}
+	if got.Price != 500 || !got.ExpiresAt.Equal(date("2024-09-01")) {
+		t.Fatalf("other fields must be replaced: got %+v", *got)
+	}
+}
+
+func testUpdateMissing(t *testing.T, repo services.Repository) {
+	err := repo.UpdateRecord(context.Background(), &entity.Record{ID: 1<<31 - 1, Price: 100})
+	expectNotFound(t, "UpdateRecord", err)
+}
+
+func testList(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user, other := pgtest.NewUserID(), pgtest.NewUserID()
+
+	records := save(t, repo,
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Spotify", 200, "2024-03-01", "2024-04-01"),
+		record(user, "Netflix", 300, "2024-02-01", "2024-03-01"),
+		record(other, "Netflix", 400, "2024-05-01", "2024-06-01"),
+	)
+
+	got, err := repo.ListRecords(ctx, 0, 0, user, "")
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	expectIDs(t, "ListRecords by user, newest first", got, records[1].ID, records[2].ID, records[0].ID)
+
+	got, err = repo.ListRecords(ctx, 0, 0, user, "Netflix")
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	expectIDs(t, "ListRecords by user and service", got, records[2].ID, records[0].ID)
+
+	got, err = repo.ListRecords(ctx, 1, 1, user, "")
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	expectIDs(t, "ListRecords limit 1 offset 1", got, records[2].ID)
+
+	got, err = repo.ListRecords(ctx, 10, 5, user, "")
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	expectIDs(t, "ListRecords offset past the end", got)
+}
+
+// testListSameCreatedAt проверяет, что записи с одной датой начала упорядочены по id
+// и страницы не теряют и не повторяют записи
+func testListSameCreatedAt(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	records := save(t, repo,
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Spotify", 200, "2024-01-01", "2024-02-01"),
+		record(user, "YouTube", 300, "2024-01-01", "2024-02-01"),
+		record(user, "Kinopoisk", 400, "2024-01-01", "2024-02-01"),
+		record(user, "Okko", 500, "2024-01-01", "2024-02-01"),
+	)
+
+	var paged []entity.Record
+	for offset := 0; offset < len(records)+2; offset += 2 {
+		page, err := repo.ListRecords(ctx, 2, offset, user, "")
+		if err != nil {
+			t.Fatalf("ListRecords: %v", err)
+		}
+		paged = append(paged, page...)
+	}
+
+	expectIDs(t, "ListRecords pages with equal created_at", paged,
+		records[4].ID, records[3].ID, records[2].ID, records[1].ID, records[0].ID)
+}
+
+func testGetByUserID(t *testing.T, repo services.Repository) {
+	user := pgtest.NewUserID()
+
+	records := save(t, repo,
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Spotify", 200, "2024-03-01", "2024-04-01"),
+		record(pgtest.NewUserID(), "Netflix", 400, "2024-05-01", "2024-06-01"),
+	)
+
+	got, err := repo.GetRecordsByUserID(context.Background(), user)
+	if err != nil {
+		t.Fatalf("GetRecordsByUserID: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("GetRecordsByUserID: got %d records, want 2", len(got))
+	}
+
+	for _, r := range got {
+		if r.ID != records[0].ID && r.ID != records[1].ID {
+			t.Fatalf("GetRecordsByUserID: unexpected record %d", r.ID)
+		}
+	}
+}
+
+func testLatestByUserAndService(t *testing.T, repo services.Repository) {
+	user := pgtest.NewUserID()
+
+	records := save(t, repo,
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Netflix", 200, "2024-03-01", "2024-04-01"),
+		record(user, "Netflix", 300, "2024-02-01", "2024-03-01"),
+	)
+
+	got, err := repo.GetRecordByUserIDAndServiceName(context.Background(), user, "Netflix")
+	if err != nil {
+		t.Fatalf("GetRecordByUserIDAndServiceName: %v", err)
+	}
+
+	if got.ID != records[1].ID {
+		t.Fatalf("GetRecordByUserIDAndServiceName: got %d, want latest %d", got.ID, records[1].ID)
+	}
+}
+
+func testNaturalKey(t *testing.T, repo services.Repository) {
+	user := pgtest.NewUserID()
+
+	records := save(t, repo,
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Netflix", 200, "2024-03-01", "2024-04-01"),
+	)
+
+	got, err := repo.GetRecordByNaturalKey(context.Background(), user, "Netflix", date("2024-03-01"))
+	if err != nil {
+		t.Fatalf("GetRecordByNaturalKey: %v", err)
+	}
+
+	if got.ID != records[1].ID {
+		t.Fatalf("GetRecordByNaturalKey: got %d, want %d", got.ID, records[1].ID)
+	}
+}
+
+func testFindOverlapping(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	records := save(t, repo,
+		record(user, "Netflix", 100, "2024-03-01", "2024-05-01"),
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Netflix", 100, "2024-06-01", "2024-07-01"),
+		record(user, "Spotify", 100, "2024-01-01", "2024-12-01"),
+	)
+
+	// период полуоткрытый: запись, заканчивающаяся в день начала, не пересекается
+	got, err := repo.FindOverlappingRecords(ctx, user, "Netflix", date("2024-01-15"), date("2024-06-01"), 0)
+	if err != nil {
+		t.Fatalf("FindOverlappingRecords: %v", err)
+	}
+	expectIDs(t, "FindOverlappingRecords", got, records[1].ID, records[0].ID)
+
+	got, err = repo.FindOverlappingRecords(ctx, user, "Netflix", date("2024-01-15"), date("2024-06-01"), records[1].ID)
+	if err != nil {
+		t.Fatalf("FindOverlappingRecords: %v", err)
+	}
+	expectIDs(t, "FindOverlappingRecords excluding", got, records[0].ID)
+}
+
+func testSum(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	save(t, repo,
+		record(user, "Netflix", 100, "2024-01-01", "2024-01-15"),
+		record(user, "Netflix", 200, "2024-01-31", "2024-02-01"),
+		record(user, "Spotify", 300, "2024-01-15", "2024-03-01"),
+		record(user, "Netflix", 400, "2024-02-01", "2024-03-01"),
+		record(pgtest.NewUserID(), "Netflix", 500, "2024-01-10", "2024-03-01"),
+	)
+
+	checks := []struct {
+		serviceName string
+		from, to    string
+		want        int
+	}{
+		// границы включаются
+		{"", "2024-01-01", "2024-01-31", 600},
+		{"Netflix", "2024-01-01", "2024-01-31", 300},
+		{"Netflix", "2024-01-31", "2024-02-01", 600},
+		{"", "2025-01-01", "2025-12-31", 0},
+	}
+
+	for _, check := range checks {
+		got, err := repo.SumPriceForPeriod(ctx, date(check.from), date(check.to), user, check.serviceName)
+		if err != nil {
+			t.Fatalf("SumPriceForPeriod: %v", err)
+		}
+
+		if got != check.want {
+			t.Fatalf("SumPriceForPeriod(%s..%s, %q): got %d, want %d", check.from, check.to, check.serviceName, got, check.want)
+		}
+	}
+}
+
+func testStream(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	records := save(t, repo,
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Spotify", 200, "2024-03-01", "2024-04-01"),
+		record(user, "Netflix", 300, "2024-03-01", "2024-04-01"),
+	)
+
+	var got []entity.Record
+	err := repo.StreamRecords(ctx, user, "", func(record *entity.Record) error {
+		got = append(got, *record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamRecords: %v", err)
+	}
+	expectIDs(t, "StreamRecords, newest first", got, records[2].ID, records[1].ID, records[0].ID)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.StreamRecords(ctx, user, "", func(record *entity.Record) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("StreamRecords must stop on callback error: got %v after %d calls", err, calls)
+	}
+}
+
+func testSaveRecordsAtomic(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	records := []entity.Record{
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Spotify", -1, "2024-01-01", "2024-02-01"),
+	}
+
+	if err := repo.SaveRecords(ctx, records, 1); err == nil {
+		t.Fatal("SaveRecords must fail on a negative price")
+	}
+
+	got, err := repo.GetRecordsByUserID(ctx, user)
+	if err != nil {
+		t.Fatalf("GetRecordsByUserID: %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Fatalf("SaveRecords must save nothing on failure, saved %d", len(got))
+	}
+
+	records = []entity.Record{
+		record(user, "Netflix", 100, "2024-01-01", "2024-02-01"),
+		record(user, "Spotify", 200, "2024-01-01", "2024-02-01"),
+	}
+
+	if err := repo.SaveRecords(ctx, records, 1); err != nil {
+		t.Fatalf("SaveRecords: %v", err)
+	}
+
+	if records[0].ID == 0 || records[1].ID == 0 || records[0].ID == records[1].ID {
+		t.Fatalf("SaveRecords must assign distinct IDs: %v", ids(records))
+	}
+}
+
+func testTransactionRollback(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+	failure := errors.New("rollback")
+
+	err := repo.Transaction(ctx, func(ctx context.Context) error {
+		saved := record(user, "Spotify", 200, "2024-01-01", "2024-02-01")
+		if err := repo.SaveRecord(ctx, &saved); err != nil {
+			t.Fatalf("SaveRecord in transaction: %v", err)
+		}
+
+		// внутри транзакции изменение видно
+		if _, err := repo.GetRecordByID(ctx, saved.ID); err != nil {
+			t.Fatalf("GetRecordByID in transaction: %v", err)
+		}
+
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Transaction must return fn error, got %v", err)
+	}
+
+	got, err := repo.ListRecords(ctx, 0, 0, user, "")
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	expectIDs(t, "records after rollback", got)
+}
+
+func testNestedTransactionRollback(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+	failure := errors.New("rollback")
+
+	outer := record(user, "Netflix", 100, "2024-01-01", "2024-02-01")
+
+	err := repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := repo.SaveRecord(ctx, &outer); err != nil {
+			return err
+		}
+
+		err := repo.Transaction(ctx, func(ctx context.Context) error {
+			inner := record(user, "Spotify", 200, "2024-01-01", "2024-02-01")
+			if err := repo.SaveRecord(ctx, &inner); err != nil {
+				return err
+			}
+
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			return fmt.Errorf("nested transaction returned %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction: %v", err)
+	}
+
+	got, err := repo.ListRecords(ctx, 0, 0, user, "")
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	expectIDs(t, "only the outer write is committed", got, outer.ID)
+}
+
+func testConcurrentSaves(t *testing.T, repo services.Repository) {
+	ctx := context.Background()
+	user := pgtest.NewUserID()
+
+	const writers = 8
+	const perWriter = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < perWriter; i++ {
+				saved := record(user, fmt.Sprintf("service-%d-%d", w, i), 100, "2024-01-01", "2024-02-01")
+				if err := repo.SaveRecord(ctx, &saved); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("concurrent SaveRecord: %v", err)
+	}
+
+	total, err := repo.SumPriceForPeriod(ctx, date("2024-01-01"), date("2024-01-01"), user, "")
+	if err != nil {
+		t.Fatalf("SumPriceForPeriod: %v", err)
+	}
+
+	if total != writers*perWriter*100 {
+		t.Fatalf("concurrent saves: got total %d, want %d", total, writers*perWriter*100)
+	}
+}
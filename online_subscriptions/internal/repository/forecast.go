@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+// FindActiveRecords возвращает записи, действующие после from, с фильтрами как у ListRecords
+func (r *Repository) FindActiveRecords(ctx context.Context, from time.Time, userID, serviceName string) ([]entity.Record, error) {
+	var records []entity.Record
+
+	query := r.conn(ctx).Model(&entity.Record{}).Where("expires_at > ?", from)
+
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if serviceName != "" {
+		query = query.Where("service_name = ?", serviceName)
+	}
+
+	if err := query.Order("id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (r *Repository) CreatePriceChange(ctx context.Context, change *entity.PriceChange) error {
+	return r.conn(ctx).Omit("Record").Create(change).Error
+}
+
+// FindActivePriceChanges возвращает изменения цен записей, которые вернул бы FindActiveRecords
+func (r *Repository) FindActivePriceChanges(ctx context.Context, from time.Time, userID, serviceName string) ([]entity.PriceChange, error) {
+	var changes []entity.PriceChange
+
+	records := r.conn(ctx).Model(&entity.Record{}).Select("id").Where("expires_at > ?", from)
+
+	if userID != "" {
+		records = records.Where("user_id = ?", userID)
+	}
+
+	if serviceName != "" {
+		records = records.Where("service_name = ?", serviceName)
+	}
+
+	err := r.conn(ctx).
+		Where("record_id IN (?)", records).
+		Order("record_id").
+		Order("effective_from").
+		Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (r *Repository) ListRecordPriceChanges(ctx context.Context, recordID uint) ([]entity.PriceChange, error) {
+	var changes []entity.PriceChange
+
+	if err := r.conn(ctx).Where("record_id = ?", recordID).Order("effective_from").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (r *Repository) DeletePriceChange(ctx context.Context, recordID, id uint) error {
+	result := r.conn(ctx).Where("record_id = ?", recordID).Delete(&entity.PriceChange{}, id)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
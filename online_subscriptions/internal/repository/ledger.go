@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ключ advisory-блокировки журнала начислений ("ledger" в ASCII)
+const ledgerLockKey int64 = 0x6c6564676572
+
+// LockLedger блокирует журнал до конца транзакции, чтобы два экземпляра не записали одну корректировку дважды
+func (r *Repository) LockLedger(ctx context.Context) error {
+	return r.conn(ctx).Exec("SELECT pg_advisory_xact_lock(?)", ledgerLockKey).Error
+}
+
+// FindLedgerCandidates возвращает записи, по которым в окне [from, to) могут быть начисления:
+// активные в окне и уже имеющие строки журнала в окне (например, после сокращения подписки)
+func (r *Repository) FindLedgerCandidates(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]entity.Record, error) {
+	var records []entity.Record
+
+	charged := r.conn(ctx).Model(&entity.LedgerEntry{}).
+		Select("record_id").
+		Where("period >= ? AND period < ?", from, to)
+
+	err := r.conn(ctx).
+		Where("id > ?", afterID).
+		Where(r.conn(ctx).
+			Where("created_at < ? AND expires_at > ?", to, from).
+			Or("id IN (?)", charged)).
+		Order("id").
+		Limit(limit).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// LedgerBalances суммирует автоматические строки журнала по подпискам и расчетным месяцам [from, to)
+func (r *Repository) LedgerBalances(ctx context.Context, recordIDs []uint, from, to time.Time) ([]entity.LedgerBalance, error) {
+	var balances []entity.LedgerBalance
+
+	if len(recordIDs) == 0 {
+		return balances, nil
+	}
+
+	err := r.conn(ctx).Model(&entity.LedgerEntry{}).
+		Select("record_id, period, SUM(amount) AS amount, BOOL_OR(kind = ?) AS charged, MIN(charge_date) AS charge_date", entity.LedgerCharge).
+		Where("record_id IN ? AND period >= ? AND period < ? AND manual = false", recordIDs, from, to).
+		Group("record_id, period").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+// AppendLedgerEntries добавляет строки журнала, повторное начисление за тот же месяц пропускается
+func (r *Repository) AppendLedgerEntries(ctx context.Context, entries []entity.LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error
+}
+
+// ListLedgerEntries возвращает строки журнала с датой списания в [from, to], нулевая дата - без границы
+func (r *Repository) ListLedgerEntries(ctx context.Context, userID, serviceName string, from, to time.Time, limit, offset int) ([]entity.LedgerEntry, error) {
+	var entries []entity.LedgerEntry
+
+	query := ledgerFilter(r.conn(ctx).Model(&entity.LedgerEntry{}), userID, serviceName)
+
+	if !from.IsZero() {
+		query = query.Where("charge_date >= ?", from)
+	}
+
+	if !to.IsZero() {
+		query = query.Where("charge_date <= ?", to)
+	}
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	if err := query.Order("charge_date DESC").Order("id DESC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// SumLedgerForPeriod суммирует начисления и корректировки с датой списания в [startTime, endTime]
+func (r *Repository) SumLedgerForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
+	var total int
+
+	query := ledgerFilter(r.reader(ctx).Model(&entity.LedgerEntry{}), userID, serviceName).
+		Where("charge_date BETWEEN ? AND ?", startTime, endTime)
+
+	if err := query.Select("COALESCE(SUM(amount), 0)").Scan(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func ledgerFilter(query *gorm.DB, userID, serviceName string) *gorm.DB {
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if serviceName != "" {
+		query = query.Where("service_name = ?", serviceName)
+	}
+
+	return query
+}
@@ -0,0 +1,421 @@
+// Package memory - хранилище записей в памяти процесса с той же семантикой, что у repository.Repository:
+// те же ошибки gorm, фильтры, порядок выдачи и транзакции с откатом вложенных вызовов.
+// Подходит для тестов и демонстрационного режима без Postgres
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+	"time"
+)
+
+// имя ограничения в ошибке пересечения, как у Postgres
+const overlapConstraint = "records_no_overlap"
+
+// Repository хранит записи в памяти. Транзакция верхнего уровня держит хранилище эксклюзивно до конца,
+// поэтому ее изменения не видны другим вызовам до фиксации
+type Repository struct {
+	mu      sync.Mutex
+	records map[uint]entity.Record
+	nextID  uint
+	// rejectOverlaps повторяет exclusion-ограничение records_no_overlap
+	rejectOverlaps bool
+	// clock заполняет нулевую дату начала при вставке
+	clock clock.Clock
+}
+
+// New создает пустое хранилище. rejectOverlaps - запрещать пересечение периодов подписок
+// одного пользователя на один сервис, как при overlap.policy: reject. clk заменяет NOW() базы
+func New(rejectOverlaps bool, clk clock.Clock) *Repository {
+	return &Repository{records: make(map[uint]entity.Record), nextID: 1, rejectOverlaps: rejectOverlaps, clock: clock.OrSystem(clk)}
+}
+
+type txKey struct{}
+
+// inTransaction сообщает, выполняется ли вызов внутри транзакции этого хранилища
+func (r *Repository) inTransaction(ctx context.Context) bool {
+	owner, _ := ctx.Value(txKey{}).(*Repository)
+	return owner == r
+}
+
+// lock захватывает хранилище на время вызова вне транзакции. Внутри транзакции оно уже захвачено
+func (r *Repository) lock(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if r.inTransaction(ctx) {
+		return func() {}, nil
+	}
+
+	r.mu.Lock()
+
+	return r.mu.Unlock, nil
+}
+
+// Transaction выполняет fn атомарно: ошибка fn восстанавливает состояние на момент вызова.
+// Вложенный вызов откатывает только свои изменения
+func (r *Repository) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	records := make(map[uint]entity.Record, len(r.records))
+	for id, record := range r.records {
+		records[id] = record
+	}
+	nextID := r.nextID
+
+	if err := fn(context.WithValue(ctx, txKey{}, r)); err != nil {
+		r.records, r.nextID = records, nextID
+		return err
+	}
+
+	return nil
+}
+
+func (r *Repository) SaveRecord(ctx context.Context, record *entity.Record) error {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return r.insert(record)
+}
+
+// SaveRecords вставляет записи все или ни одной. batchSize в памяти не нужен
+func (r *Repository) SaveRecords(ctx context.Context, records []entity.Record, batchSize int) error {
+	return r.Transaction(ctx, func(ctx context.Context) error {
+		for i := range records {
+			if err := r.insert(&records[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) insert(record *entity.Record) error {
+	// как gorm: нулевое время создания заполняется текущим
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = r.clock.Now()
+	}
+
+	stored := normalize(*record)
+
+	if err := r.check(stored, 0); err != nil {
+		return err
+	}
+
+	if record.ID == 0 {
+		record.ID = r.nextID
+	} else if _, exists := r.records[record.ID]; exists {
+		return fmt.Errorf("%w: records_pkey", gorm.ErrDuplicatedKey)
+	}
+
+	if record.ID >= r.nextID {
+		r.nextID = record.ID + 1
+	}
+
+	stored.ID = record.ID
+	r.records[stored.ID] = stored
+
+	return nil
+}
+
+// check повторяет ограничения таблицы records
+func (r *Repository) check(record entity.Record, excludeID uint) error {
+	if record.Price < 0 {
+		return gorm.ErrCheckConstraintViolated
+	}
+
+	if !r.rejectOverlaps {
+		return nil
+	}
+
+	for id, existing := range r.records {
+		if id != excludeID && overlaps(existing, record) {
+			return fmt.Errorf("%w: %s", gorm.ErrDuplicatedKey, overlapConstraint)
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteRecordByID(ctx context.Context, id uint) error {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.records[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	delete(r.records, id)
+
+	return nil
+}
+
+func (r *Repository) GetRecordByID(ctx context.Context, id uint) (*entity.Record, error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	record, ok := r.records[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &record, nil
+}
+
+func (r *Repository) GetRecordsByUserID(ctx context.Context, userID string) ([]entity.Record, error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	records := r.filter(func(record entity.Record) bool { return record.UserID == userID })
+	sortByID(records)
+
+	return records, nil
+}
+
+// GetRecordByUserIDAndServiceName возвращает самую позднюю подписку пользователя на сервис
+func (r *Repository) GetRecordByUserIDAndServiceName(ctx context.Context, userID, serviceName string) (*entity.Record, error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	records := r.filter(func(record entity.Record) bool {
+		return record.UserID == userID && record.ServiceName == serviceName
+	})
+	sortNewestFirst(records)
+
+	if len(records) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &records[0], nil
+}
+
+func (r *Repository) GetRecordByNaturalKey(ctx context.Context, userID, serviceName string, createdAt time.Time) (*entity.Record, error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	createdAt = truncateToDate(createdAt)
+
+	records := r.filter(func(record entity.Record) bool {
+		return record.UserID == userID && record.ServiceName == serviceName && record.CreatedAt.Equal(createdAt)
+	})
+	sortByID(records)
+
+	if len(records) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &records[0], nil
+}
+
+// UpdateRecord заменяет все поля записи record.ID, в том числе нулевые
+func (r *Repository) UpdateRecord(ctx context.Context, record *entity.Record) error {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, ok := r.records[record.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	// нулевая дата начала - дата не задана, остается сохраненная
+	updated := *record
+	if updated.CreatedAt.IsZero() {
+		updated.CreatedAt = current.CreatedAt
+	}
+
+	stored := normalize(updated)
+
+	if err := r.check(stored, stored.ID); err != nil {
+		return err
+	}
+
+	r.records[stored.ID] = stored
+
+	return nil
+}
+
+// FindOverlappingRecords ищет подписки пользователя на тот же сервис, период которых [created_at, expires_at)
+// пересекается с [start, end). Запись excludeID не учитывается
+func (r *Repository) FindOverlappingRecords(ctx context.Context, userID, serviceName string, start, end time.Time, excludeID uint) ([]entity.Record, error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	records := r.filter(func(record entity.Record) bool {
+		return record.UserID == userID &&
+			record.ServiceName == serviceName &&
+			record.CreatedAt.Before(end) &&
+			record.ExpiresAt.After(start) &&
+			(excludeID == 0 || record.ID != excludeID)
+	})
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.Before(records[j].CreatedAt)
+		}
+		return records[i].ID < records[j].ID
+	})
+
+	return records, nil
+}
+
+func (r *Repository) ListRecords(ctx context.Context, limit, offset int, userID, serviceName string) ([]entity.Record, error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	records := r.filter(matches(userID, serviceName))
+	sortNewestFirst(records)
+
+	if offset > 0 {
+		if offset >= len(records) {
+			return []entity.Record{}, nil
+		}
+		records = records[offset:]
+	}
+
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+
+	return records, nil
+}
+
+// StreamRecords вызывает fn для снимка отфильтрованных записей. fn выполняется без блокировки
+// хранилища и может обращаться к нему
+func (r *Repository) StreamRecords(ctx context.Context, userID, serviceName string, fn func(record *entity.Record) error) error {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+
+	records := r.filter(matches(userID, serviceName))
+	unlock()
+
+	sortNewestFirst(records)
+
+	for i := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(&records[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SumPriceForPeriod суммирует цены записей, начавшихся в [startTime, endTime] включительно
+func (r *Repository) SumPriceForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	total := 0
+
+	for _, record := range r.filter(matches(userID, serviceName)) {
+		if !record.CreatedAt.Before(startTime) && !record.CreatedAt.After(endTime) {
+			total += record.Price
+		}
+	}
+
+	return total, nil
+}
+
+func (r *Repository) filter(keep func(record entity.Record) bool) []entity.Record {
+	records := make([]entity.Record, 0)
+
+	for _, record := range r.records {
+		if keep(record) {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func matches(userID, serviceName string) func(record entity.Record) bool {
+	return func(record entity.Record) bool {
+		return (userID == "" || record.UserID == userID) && (serviceName == "" || record.ServiceName == serviceName)
+	}
+}
+
+// overlaps повторяет daterange(created_at, expires_at) && daterange(...): пустой период ни с чем не пересекается
+func overlaps(a, b entity.Record) bool {
+	if a.UserID != b.UserID || a.ServiceName != b.ServiceName {
+		return false
+	}
+
+	if !a.CreatedAt.Before(a.ExpiresAt) || !b.CreatedAt.Before(b.ExpiresAt) {
+		return false
+	}
+
+	return a.CreatedAt.Before(b.ExpiresAt) && b.CreatedAt.Before(a.ExpiresAt)
+}
+
+// normalize приводит даты к тому виду, в котором их возвращает Postgres (столбцы типа date)
+func normalize(record entity.Record) entity.Record {
+	record.CreatedAt = truncateToDate(record.CreatedAt)
+	record.ExpiresAt = truncateToDate(record.ExpiresAt)
+
+	return record
+}
+
+func truncateToDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func sortByID(records []entity.Record) {
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+}
+
+// sortNewestFirst - порядок created_at DESC, id DESC
+func sortNewestFirst(records []entity.Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.After(records[j].CreatedAt)
+		}
+		return records[i].ID > records[j].ID
+	})
+}
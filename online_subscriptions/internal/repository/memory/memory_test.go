@@ -0,0 +1,16 @@
+package memory_test
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/conformance"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) services.Repository { return memory.New(false, nil) })
+}
+
+func TestRepositoryRejectOverlaps(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) services.Repository { return memory.New(true, nil) })
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// AppendOutbox добавляет события в outbox. Уже записанные события (тот же EventID) пропускаются
+// заранее, а не через конфликт вставки: конфликт тратит значение последовательности и оставляет
+// пропуск в ID, который relay какое-то время принимает за незафиксированную транзакцию
+func (r *Repository) AppendOutbox(ctx context.Context, events []entity.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.EventID
+	}
+
+	var existing []string
+	if err := r.conn(ctx).Model(&entity.OutboxEvent{}).Where("event_id IN ?", ids).Pluck("event_id", &existing).Error; err != nil {
+		return err
+	}
+
+	skip := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		skip[id] = true
+	}
+
+	fresh := make([]entity.OutboxEvent, 0, len(events))
+	for _, event := range events {
+		if !skip[event.EventID] {
+			skip[event.EventID] = true
+			fresh = append(fresh, event)
+		}
+	}
+
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	return r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&fresh).Error
+}
+
+func (r *Repository) LockCheckpoint(ctx context.Context, sink string) (uint, bool, error) {
+	err := r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entity.RelayCheckpoint{Sink: sink}).Error
+	if err != nil {
+		return 0, false, err
+	}
+
+	var checkpoints []entity.RelayCheckpoint
+
+	err = r.conn(ctx).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("sink = ?", sink).
+		Find(&checkpoints).Error
+	if err != nil || len(checkpoints) == 0 {
+		return 0, false, err
+	}
+
+	return checkpoints[0].LastEventID, true, nil
+}
+
+func (r *Repository) SaveCheckpoint(ctx context.Context, sink string, lastEventID uint) error {
+	db := r.conn(ctx)
+
+	return db.Model(&entity.RelayCheckpoint{}).
+		Where("sink = ?", sink).
+		Updates(map[string]any{"last_event_id": lastEventID, "updated_at": db.NowFunc()}).Error
+}
+
+func (r *Repository) FetchOutbox(ctx context.Context, afterID uint, limit int) ([]entity.OutboxEvent, error) {
+	var events []entity.OutboxEvent
+
+	if err := r.conn(ctx).Where("id > ?", afterID).Order("id").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *Repository) ListCheckpoints(ctx context.Context) ([]entity.RelayCheckpoint, error) {
+	var checkpoints []entity.RelayCheckpoint
+
+	if err := r.conn(ctx).Order("sink").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+func (r *Repository) OutboxHead(ctx context.Context) (uint, error) {
+	var head uint
+
+	err := r.conn(ctx).Model(&entity.OutboxEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&head).Error
+
+	return head, err
+}
+
+// CountOutboxAfter возвращает число событий после afterID и время самого старого из них
+func (r *Repository) CountOutboxAfter(ctx context.Context, afterID uint) (int64, *time.Time, error) {
+	var result struct {
+		Pending int64
+		Oldest  *time.Time
+	}
+
+	err := r.conn(ctx).Model(&entity.OutboxEvent{}).
+		Select("COUNT(*) AS pending, MIN(created_at) AS oldest").
+		Where("id > ?", afterID).
+		Scan(&result).Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return result.Pending, result.Oldest, nil
+}
+
+func (r *Repository) DeleteOutboxBefore(ctx context.Context, maxID uint, createdBefore time.Time) (int64, error) {
+	result := r.conn(ctx).Where("id <= ? AND created_at < ?", maxID, createdBefore).Delete(&entity.OutboxEvent{})
+
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,95 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/pgtest"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestSumPriceForPeriodInclusiveBounds(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+	user := pgtest.NewUserID()
+
+	// записи на обеих границах периода учитываются, за его пределами - нет
+	pgtest.NewRecord().User(user).Service("Netflix").Price(100).Months(pgtest.Date("2025-01-01"), 1).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("Spotify").Price(200).Months(pgtest.Date("2025-03-01"), 1).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("YouTube").Price(400).Months(pgtest.Date("2024-12-31"), 1).Insert(t, repo)
+	pgtest.NewRecord().User(user).Service("Okko").Price(800).Months(pgtest.Date("2025-03-02"), 1).Insert(t, repo)
+
+	total, err := repo.SumPriceForPeriod(context.Background(), pgtest.Date("2025-01-01"), pgtest.Date("2025-03-01"), user, "")
+	if err != nil {
+		t.Fatalf("SumPriceForPeriod: %v", err)
+	}
+	if total != 300 {
+		t.Fatalf("SumPriceForPeriod: got %d, want 300", total)
+	}
+
+	// период из одного дня
+	total, err = repo.SumPriceForPeriod(context.Background(), pgtest.Date("2025-03-01"), pgtest.Date("2025-03-01"), user, "Spotify")
+	if err != nil {
+		t.Fatalf("SumPriceForPeriod: %v", err)
+	}
+	if total != 200 {
+		t.Fatalf("SumPriceForPeriod for one day: got %d, want 200", total)
+	}
+}
+
+func TestPriceCheckConstraint(t *testing.T) {
+	t.Parallel()
+
+	repo := pgtest.Repository(t)
+
+	record := pgtest.NewRecord().Price(-1).Build()
+
+	err := repo.SaveRecord(context.Background(), &record)
+	if !errors.Is(err, gorm.ErrCheckConstraintViolated) {
+		t.Fatalf("SaveRecord with negative price: got %v, want gorm.ErrCheckConstraintViolated", err)
+	}
+
+	// нулевая цена допустима
+	pgtest.NewRecord().Price(0).Insert(t, repo)
+}
+
+func TestOverlapExclusionConstraint(t *testing.T) {
+	t.Parallel()
+
+	db := pgtest.DB(t)
+	user := pgtest.NewUserID()
+
+	first := pgtest.NewRecord().User(user).Months(pgtest.Date("2025-01-01"), 3).Build()
+	if err := db.Create(&first).Error; err != nil {
+		t.Fatalf("insert record: %v", err)
+	}
+
+	// смежный период не пересекается: daterange исключает правую границу
+	adjacent := pgtest.NewRecord().User(user).Months(pgtest.Date("2025-04-01"), 1).Build()
+	if err := db.Create(&adjacent).Error; err != nil {
+		t.Fatalf("insert adjacent record: %v", err)
+	}
+
+	overlapping := pgtest.NewRecord().User(user).Months(pgtest.Date("2025-02-01"), 1).Build()
+
+	err := db.Create(&overlapping).Error
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23P01" || pgErr.ConstraintName != "records_no_overlap" {
+		t.Fatalf("insert overlapping record: got %v, want exclusion_violation on records_no_overlap", err)
+	}
+
+	// репозиторий переводит нарушение в gorm.ErrDuplicatedKey, по нему сервис отвечает конфликтом
+	repo := pgtest.Repository(t)
+	pgtest.NewRecord().User(user).Months(pgtest.Date("2025-01-01"), 3).Insert(t, repo)
+
+	overlapping = pgtest.NewRecord().User(user).Months(pgtest.Date("2025-02-01"), 1).Build()
+
+	err = repo.SaveRecord(context.Background(), &overlapping)
+	if !errors.Is(err, gorm.ErrDuplicatedKey) || !strings.Contains(err.Error(), "records_no_overlap") {
+		t.Fatalf("SaveRecord overlapping: got %v, want gorm.ErrDuplicatedKey for records_no_overlap", err)
+	}
+}
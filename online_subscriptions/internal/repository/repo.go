@@ -2,25 +2,84 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
+// код ошибки Postgres exclusion_violation
+const exclusionViolationCode = "23P01"
+
 type Repository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	replica *gorm.DB
 }
 
 func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// WithReplica направляет списки и суммы на реплику. Реплика может отставать,
+// поэтому остальные чтения и все записи идут в основную базу; nil отключает реплику
+func (r *Repository) WithReplica(replica *gorm.DB) *Repository {
+	r.replica = replica
+	return r
+}
+
+type txKey struct{}
+
+// conn возвращает транзакцию из контекста, если она открыта, иначе общее подключение
+func (r *Repository) conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	return r.db.WithContext(ctx)
+}
+
+// reader - подключение для чтений, допускающих отставание: реплика, если она задана
+// и транзакция не открыта, иначе то же, что conn
+func (r *Repository) reader(ctx context.Context) *gorm.DB {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok || r.replica == nil {
+		return r.conn(ctx)
+	}
+
+	return r.replica.WithContext(ctx)
+}
+
+func (r *Repository) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// вложенный вызов работает внутри открытой транзакции через SAVEPOINT:
+	// ошибка откатывает только его изменения
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// translateError приводит нарушение exclusion-ограничения к gorm.ErrDuplicatedKey,
+// остальные ошибки ограничений переводит сам gorm (TranslateError)
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == exclusionViolationCode {
+		return fmt.Errorf("%w: %s", gorm.ErrDuplicatedKey, pgErr.ConstraintName)
+	}
+
+	return err
+}
+
 func (r *Repository) SaveRecord(ctx context.Context, record *entity.Record) error {
-	return r.db.WithContext(ctx).Create(record).Error
+	return translateError(r.conn(ctx).Create(record).Error)
+}
+
+func (r *Repository) SaveRecords(ctx context.Context, records []entity.Record, batchSize int) error {
+	return translateError(r.conn(ctx).CreateInBatches(records, batchSize).Error)
 }
 
 func (r *Repository) DeleteRecordByID(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).Delete(&entity.Record{}, id)
+	result := r.conn(ctx).Delete(&entity.Record{}, id)
 
 	if result.Error != nil {
 		return result.Error
@@ -36,7 +95,7 @@ func (r *Repository) DeleteRecordByID(ctx context.Context, id uint) error {
 func (r *Repository) GetRecordByID(ctx context.Context, id uint) (*entity.Record, error) {
 	var record entity.Record
 
-	if err := r.db.WithContext(ctx).First(&record, id).Error; err != nil {
+	if err := r.conn(ctx).First(&record, id).Error; err != nil {
 		return nil, err
 	}
 
@@ -46,7 +105,7 @@ func (r *Repository) GetRecordByID(ctx context.Context, id uint) (*entity.Record
 func (r *Repository) GetRecordsByUserID(ctx context.Context, userID string) ([]entity.Record, error) {
 	var records []entity.Record
 
-	query := r.db.WithContext(ctx).Model(&entity.Record{}).Where("user_id = ?", userID)
+	query := r.conn(ctx).Model(&entity.Record{}).Where("user_id = ?", userID)
 	if err := query.Find(&records).Error; err != nil {
 		return nil, err
 	}
@@ -57,21 +116,51 @@ func (r *Repository) GetRecordsByUserID(ctx context.Context, userID string) ([]e
 func (r *Repository) GetRecordByUserIDAndServiceName(ctx context.Context, userID, serviceName string) (*entity.Record, error) {
 	var record entity.Record
 
-	if err := r.db.WithContext(ctx).Where("user_id = ? AND service_name = ?", userID, serviceName).First(&record).Error; err != nil {
+	// у пользователя может быть несколько подписок на сервис, отдаем самую позднюю
+	query := r.conn(ctx).
+		Where("user_id = ? AND service_name = ?", userID, serviceName).
+		Order("created_at DESC").
+		Order("id DESC")
+
+	if err := query.First(&record).Error; err != nil {
 		return nil, err
 	}
 
 	return &record, nil
 }
 
+// GetRecordByNaturalKey ищет запись по естественному ключу: пользователь, сервис и дата начала
+func (r *Repository) GetRecordByNaturalKey(ctx context.Context, userID, serviceName string, createdAt time.Time) (*entity.Record, error) {
+	var record entity.Record
+
+	query := r.conn(ctx).
+		Where("user_id = ? AND service_name = ? AND created_at = ?", userID, serviceName, createdAt)
+
+	if err := query.First(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// UpdateRecord заменяет все поля записи record.ID, в том числе нулевые: Updates со структурой
+// без Select пропустил бы price 0 и пустую category
 func (r *Repository) UpdateRecord(ctx context.Context, record *entity.Record) error {
-	result := r.db.WithContext(ctx).
+	// нулевая дата начала - дата не задана, остается сохраненная
+	omit := []string{"id"}
+	if record.CreatedAt.IsZero() {
+		omit = append(omit, "created_at")
+	}
+
+	result := r.conn(ctx).
 		Model(&entity.Record{}).
 		Where("id = ?", record.ID).
+		Select("*").
+		Omit(omit...).
 		Updates(record)
 
 	if result.Error != nil {
-		return result.Error
+		return translateError(result.Error)
 	}
 
 	if result.RowsAffected == 0 {
@@ -81,10 +170,30 @@ func (r *Repository) UpdateRecord(ctx context.Context, record *entity.Record) er
 	return nil
 }
 
+// FindOverlappingRecords ищет подписки пользователя на тот же сервис, период которых [created_at, expires_at)
+// пересекается с [start, end). Запись excludeID (обновляемая) не учитывается
+func (r *Repository) FindOverlappingRecords(ctx context.Context, userID, serviceName string, start, end time.Time, excludeID uint) ([]entity.Record, error) {
+	var records []entity.Record
+
+	query := r.conn(ctx).Model(&entity.Record{}).
+		Where("user_id = ? AND service_name = ?", userID, serviceName).
+		Where("created_at < ? AND expires_at > ?", end, start)
+
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+
+	if err := query.Order("created_at").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
 func (r *Repository) ListRecords(ctx context.Context, limit, offset int, userID, serviceName string) ([]entity.Record, error) {
 	var records []entity.Record
 
-	query := r.db.WithContext(ctx).Model(&entity.Record{})
+	query := r.reader(ctx).Model(&entity.Record{})
 
 	// фильтрация
 	if userID != "" {
@@ -104,7 +213,7 @@ func (r *Repository) ListRecords(ctx context.Context, limit, offset int, userID,
 		query = query.Offset(offset)
 	}
 
-	query = query.Order("created_at DESC")
+	query = query.Order("created_at DESC").Order("id DESC")
 
 	if err := query.Find(&records).Error; err != nil {
 		return nil, err
@@ -113,10 +222,42 @@ func (r *Repository) ListRecords(ctx context.Context, limit, offset int, userID,
 	return records, nil
 }
 
+// StreamRecords проходит по отфильтрованным записям курсором, не загружая их в память целиком
+func (r *Repository) StreamRecords(ctx context.Context, userID, serviceName string, fn func(record *entity.Record) error) error {
+	query := r.conn(ctx).Model(&entity.Record{})
+
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if serviceName != "" {
+		query = query.Where("service_name = ?", serviceName)
+	}
+
+	rows, err := query.Order("created_at DESC").Order("id DESC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record entity.Record
+		if err := query.ScanRows(rows, &record); err != nil {
+			return err
+		}
+
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func (r *Repository) SumPriceForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
 	var total int
 
-	query := r.db.WithContext(ctx).Model(&entity.Record{}).
+	query := r.reader(ctx).Model(&entity.Record{}).
 		Where("created_at BETWEEN ? AND ?", startTime, endTime)
 
 	if userID != "" {
@@ -133,3 +274,63 @@ func (r *Repository) SumPriceForPeriod(ctx context.Context, startTime, endTime t
 
 	return total, nil
 }
+
+// GetOrCreateCalendarFeed возвращает ленту пользователя, создавая ее при первом обращении
+func (r *Repository) GetOrCreateCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error) {
+	feed := entity.CalendarFeed{UserID: userID, Version: 1}
+
+	err := r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&feed).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetCalendarFeed(ctx, userID)
+}
+
+func (r *Repository) GetCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error) {
+	var feed entity.CalendarFeed
+
+	if err := r.conn(ctx).Where("user_id = ?", userID).First(&feed).Error; err != nil {
+		return nil, err
+	}
+
+	return &feed, nil
+}
+
+// RevokeCalendarFeed увеличивает версию ленты, если она была создана
+func (r *Repository) RevokeCalendarFeed(ctx context.Context, userID string) error {
+	return r.conn(ctx).Model(&entity.CalendarFeed{}).
+		Where("user_id = ?", userID).
+		Update("version", gorm.Expr("version + 1")).Error
+}
+
+// FindRecordsExpiringBetween возвращает записи, заканчивающиеся в [from, to]
+func (r *Repository) FindRecordsExpiringBetween(ctx context.Context, from, to time.Time) ([]entity.Record, error) {
+	var records []entity.Record
+
+	err := r.conn(ctx).
+		Where("expires_at >= ? AND expires_at <= ?", from, to).
+		Order("expires_at").
+		Order("id").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ClaimNotification сохраняет напоминание, если такое еще не отправлялось. false - уже отправлено
+func (r *Repository) ClaimNotification(ctx context.Context, notification *entity.SentNotification) (bool, error) {
+	result := r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseNotification снимает отметку об отправке, чтобы напоминание ушло при следующем запуске
+func (r *Repository) ReleaseNotification(ctx context.Context, notification *entity.SentNotification) error {
+	return r.conn(ctx).Delete(&entity.SentNotification{}, notification.ID).Error
+}
@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/pgtest"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/conformance"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"testing"
+)
+
+func TestMain(m *testing.M) { pgtest.Main(m) }
+
+func TestRepository(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) services.Repository { return pgtest.Repository(t) })
+}
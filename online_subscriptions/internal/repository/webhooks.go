@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+func (r *Repository) CreateWebhook(ctx context.Context, webhook *entity.Webhook) error {
+	return r.conn(ctx).Create(webhook).Error
+}
+
+func (r *Repository) ListWebhooks(ctx context.Context) ([]entity.Webhook, error) {
+	var webhooks []entity.Webhook
+
+	if err := r.conn(ctx).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *Repository) GetWebhookByID(ctx context.Context, id uint) (*entity.Webhook, error) {
+	var webhook entity.Webhook
+
+	if err := r.conn(ctx).First(&webhook, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (r *Repository) UpdateWebhook(ctx context.Context, webhook *entity.Webhook) error {
+	result := r.conn(ctx).Model(webhook).Select("*").Omit("created_at").Updates(webhook)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteWebhook удаляет вебхук вместе с доставками и журналом попыток
+func (r *Repository) DeleteWebhook(ctx context.Context, id uint) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		deliveries := tx.Model(&entity.WebhookDelivery{}).Select("id").Where("webhook_id = ?", id)
+
+		if err := tx.Where("delivery_id IN (?)", deliveries).Delete(&entity.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("webhook_id = ?", id).Delete(&entity.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&entity.Webhook{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// CreateDeliveries ставит доставки в очередь, уже существующие (тот же вебхук и событие) пропускаются
+func (r *Repository) CreateDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// ClaimDueDeliveries забирает доставки, время которых пришло, и откладывает их на lease, чтобы
+// другие экземпляры сервиса не взяли их же. Если обработчик упадет, доставка вернется после lease
+func (r *Repository) ClaimDueDeliveries(ctx context.Context, status string, now time.Time, lease time.Duration, limit int) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", status, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+
+		return tx.Model(&entity.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// SaveDeliveryAttempt сохраняет состояние доставки и запись о попытке
+func (r *Repository) SaveDeliveryAttempt(ctx context.Context, delivery *entity.WebhookDelivery, attempt *entity.WebhookAttempt) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(delivery).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(attempt).Error
+	})
+}
+
+func (r *Repository) UpdateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	return r.conn(ctx).Save(delivery).Error
+}
+
+func (r *Repository) ListDeliveries(ctx context.Context, webhookID uint, status string, limit, offset int) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+
+	query := r.conn(ctx).Where("webhook_id = ?", webhookID)
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *Repository) GetDeliveryByID(ctx context.Context, id uint) (*entity.WebhookDelivery, error) {
+	var delivery entity.WebhookDelivery
+
+	if err := r.conn(ctx).First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (r *Repository) ListDeliveryAttempts(ctx context.Context, deliveryID uint) ([]entity.WebhookAttempt, error) {
+	var attempts []entity.WebhookAttempt
+
+	if err := r.conn(ctx).Where("delivery_id = ?", deliveryID).Order("id").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
@@ -8,26 +8,159 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func RegisterRoutes(router *gin.RouterGroup, handler *handlers.RecordHandler) {
-	router.POST("/create", handler.CreateRecord)
-	router.DELETE("/delete/:id", handler.DeleteRecord)
-	router.PUT("/update/:id", handler.UpdateRecord)
+// RegisterRoutes регистрирует API версии v1: подписки как ресурс /subscriptions и связанные с ними отчеты
+func RegisterRoutes(router *gin.RouterGroup, handler *handlers.RecordHandler, importHandler *handlers.ImportHandler, calendarHandler *handlers.CalendarHandler, forecastHandler *handlers.ForecastHandler, analyticsHandler *handlers.AnalyticsHandler) {
+	RegisterRecordRoutes(router, handler, importHandler)
 
-	// получить по id
-	router.GET("/record/:id", handler.GetRecordByID)
+	// прогноз расходов по месяцам
+	router.GET("/subscriptions/forecast", forecastHandler.Forecast)
 
-	// получить по user_id
-	router.GET("/records/user", handler.GetRecordsByUserID)
+	// запланированные изменения цены
+	router.POST("/subscriptions/:id/price-changes", forecastHandler.SchedulePriceChange)
+	router.GET("/subscriptions/:id/price-changes", forecastHandler.ListPriceChanges)
+	router.DELETE("/subscriptions/:id/price-changes/:change_id", forecastHandler.DeletePriceChange)
 
-	// получить по user_id + service_name
-	router.GET("/record/user_service", handler.GetRecordByUserIDAndServiceName)
+	// отчеты
+	router.GET("/analytics/mrr", analyticsHandler.MRR)
+	router.GET("/analytics/churn", analyticsHandler.Churn)
+	router.GET("/analytics/top-services", analyticsHandler.TopServices)
+	router.GET("/analytics/lifetime", analyticsHandler.Lifetime)
+	router.GET("/analytics/cohorts", analyticsHandler.Cohorts)
 
-	// получить список с фильтрацией
-	router.GET("/records", handler.ListRecords)
+	// лента календаря: доступ по токену ленты, токены выдает администратор
+	router.GET("/users/:id/calendar.ics", calendarHandler.CalendarFeed)
+}
+
+// RegisterRecordRoutes регистрирует операции с подписками API v1. Только они доступны
+// при хранении в памяти (storage.driver: memory)
+func RegisterRecordRoutes(router *gin.RouterGroup, handler *handlers.RecordHandler, importHandler *handlers.ImportHandler) {
+	router.POST("/subscriptions", handler.CreateRecord)
+	router.GET("/subscriptions", handler.ListRecords)
+	router.GET("/subscriptions/:id", handler.GetRecordByID)
+	router.PUT("/subscriptions/:id", handler.UpdateRecord)
+	router.PATCH("/subscriptions/:id", handler.PatchRecord)
+	router.DELETE("/subscriptions/:id", handler.DeleteRecord)
 
 	// сумма за период
-	router.GET("/records/summary", handler.SumPriceForPeriod)
+	router.GET("/subscriptions/summary", handler.Summary)
+
+	// выгрузка в CSV/JSONL/XLSX
+	router.GET("/subscriptions/export", handler.ExportRecords)
+
+	// импорт из CSV/XLSX
+	router.POST("/subscriptions/import", importHandler.ImportRecords)
 
-	// swagger
+	// пакетные операции: /subscriptions:batch
+	router.POST("/subscriptions:action", customMethod("batch", handler.BatchCreateRecords))
+	router.PATCH("/subscriptions:action", customMethod("batch", handler.BatchPatchRecords))
+	router.DELETE("/subscriptions:action", customMethod("batch", handler.BatchDeleteRecords))
+}
+
+// RegisterSwagger регистрирует документацию API
+func RegisterSwagger(router *gin.RouterGroup) {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
+
+// RegisterLegacyRoutes регистрирует маршруты без версии как устаревшие псевдонимы API v1.
+// successor - префикс API v1 (например, /api/v1), на него указывают ссылки в ответах
+func RegisterLegacyRoutes(router *gin.RouterGroup, deprecation handlers.Deprecation, successor string, handler *handlers.RecordHandler, importHandler *handlers.ImportHandler, calendarHandler *handlers.CalendarHandler, forecastHandler *handlers.ForecastHandler, analyticsHandler *handlers.AnalyticsHandler) {
+	RegisterLegacyRecordRoutes(router, deprecation, successor, handler, importHandler)
+
+	deprecated := func(path string) gin.HandlerFunc {
+		return handlers.Deprecated(deprecation, successor+path)
+	}
+
+	router.GET("/records/forecast", deprecated("/subscriptions/forecast"), forecastHandler.Forecast)
+
+	router.POST("/record/:id/price-changes", deprecated("/subscriptions/:id/price-changes"), forecastHandler.SchedulePriceChange)
+	router.GET("/record/:id/price-changes", deprecated("/subscriptions/:id/price-changes"), forecastHandler.ListPriceChanges)
+	router.DELETE("/record/:id/price-changes/:change_id", deprecated("/subscriptions/:id/price-changes/:change_id"), forecastHandler.DeletePriceChange)
+
+	router.GET("/analytics/mrr", deprecated("/analytics/mrr"), analyticsHandler.MRR)
+	router.GET("/analytics/churn", deprecated("/analytics/churn"), analyticsHandler.Churn)
+	router.GET("/analytics/top-services", deprecated("/analytics/top-services"), analyticsHandler.TopServices)
+	router.GET("/analytics/lifetime", deprecated("/analytics/lifetime"), analyticsHandler.Lifetime)
+	router.GET("/analytics/cohorts", deprecated("/analytics/cohorts"), analyticsHandler.Cohorts)
+
+	router.GET("/users/:id/calendar.ics", deprecated("/users/:id/calendar.ics"), calendarHandler.CalendarFeed)
+}
+
+// RegisterLegacyRecordRoutes регистрирует устаревшие операции с записями, см. RegisterLegacyRoutes
+func RegisterLegacyRecordRoutes(router *gin.RouterGroup, deprecation handlers.Deprecation, successor string, handler *handlers.RecordHandler, importHandler *handlers.ImportHandler) {
+	deprecated := func(path string) gin.HandlerFunc {
+		return handlers.Deprecated(deprecation, successor+path)
+	}
+
+	router.POST("/create", deprecated("/subscriptions"), handler.CreateRecord)
+	router.DELETE("/delete/:id", deprecated("/subscriptions/:id"), handler.DeleteRecord)
+	router.PUT("/update/:id", deprecated("/subscriptions/:id"), handler.UpdateRecord)
+	router.GET("/record/:id", deprecated("/subscriptions/:id"), handler.GetRecordByID)
+
+	// поиск по пользователю и сервису заменен фильтрами списка
+	router.GET("/records/user", deprecated("/subscriptions"), handler.GetRecordsByUserID)
+	router.GET("/record/user_service", deprecated("/subscriptions"), handler.GetRecordByUserIDAndServiceName)
+
+	router.GET("/records", deprecated("/subscriptions"), handler.ListRecords)
+
+	// сумма с параметрами в теле GET-запроса, в v1 - в строке запроса
+	router.GET("/records/summary", deprecated("/subscriptions/summary"), handler.SumPriceForPeriod)
+
+	router.GET("/records/export", deprecated("/subscriptions/export"), handler.ExportRecords)
+	router.POST("/records/import", deprecated("/subscriptions/import"), importHandler.ImportRecords)
+
+	router.POST("/records:action", deprecated("/subscriptions:batch"), customMethod("batch", handler.BatchCreateRecords))
+	router.PATCH("/records:action", deprecated("/subscriptions:batch"), customMethod("batch", handler.BatchPatchRecords))
+	router.DELETE("/records:action", deprecated("/subscriptions:batch"), customMethod("batch", handler.BatchDeleteRecords))
+}
+
+// RegisterAdminRoutes регистрирует маршруты, доступные только с токеном администратора
+func RegisterAdminRoutes(router *gin.RouterGroup, adminAuth gin.HandlerFunc, calendarHandler *handlers.CalendarHandler, webhookHandler *handlers.WebhookHandler, outboxHandler *handlers.OutboxHandler, ledgerHandler *handlers.LedgerHandler, budgetHandler *handlers.BudgetHandler, aggregateHandler *handlers.AggregateHandler) {
+	admin := router.Group("", adminAuth)
+
+	// токены ленты календаря: по токену лента отдает подписки пользователя
+	admin.POST("/users/:id/calendar/token", calendarHandler.IssueFeedToken)
+	admin.DELETE("/users/:id/calendar/token", calendarHandler.RevokeFeedToken)
+
+	// вебхуки
+	admin.POST("/webhooks", webhookHandler.CreateWebhook)
+	admin.GET("/webhooks", webhookHandler.ListWebhooks)
+	admin.GET("/webhooks/:id", webhookHandler.GetWebhook)
+	admin.PATCH("/webhooks/:id", webhookHandler.UpdateWebhook)
+	admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+	admin.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+	admin.GET("/webhook-deliveries/:id", webhookHandler.GetDelivery)
+	admin.POST("/webhook-deliveries/:id/redeliver", webhookHandler.RedeliverDelivery)
+
+	// публикация событий
+	admin.GET("/outbox/stats", outboxHandler.Stats)
+
+	// журнал начислений
+	admin.GET("/ledger", ledgerHandler.ListEntries)
+	admin.POST("/ledger/backfill", ledgerHandler.Backfill)
+	admin.POST("/ledger/adjustments", ledgerHandler.AddAdjustment)
+
+	// бюджеты
+	admin.POST("/budgets", budgetHandler.CreateBudget)
+	admin.GET("/budgets", budgetHandler.ListBudgets)
+	admin.GET("/budgets/:user_id", budgetHandler.GetBudget)
+	admin.PUT("/budgets/:user_id", budgetHandler.UpdateBudget)
+	admin.DELETE("/budgets/:user_id", budgetHandler.DeleteBudget)
+	admin.GET("/budgets/:user_id/status", budgetHandler.BudgetStatus)
+
+	// месячные агрегаты
+	admin.POST("/aggregates/check", aggregateHandler.Check)
+	admin.POST("/aggregates/rebuild", aggregateHandler.Rebuild)
+}
+
+// customMethod обслуживает пути вида /subscriptions:batch. Gin считает двоеточие началом параметра,
+// поэтому маршрут регистрируется как /subscriptions:action, а имя действия проверяется здесь
+func customMethod(action string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Param("action") != ":"+action {
+			handlers.NotFound(ctx)
+			return
+		}
+
+		handler(ctx)
+	}
+}
@@ -0,0 +1,200 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/handlers"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/importer"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/pgtest"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/routes"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	pgtest.Main(m)
+}
+
+// backends - хранилища, над которыми идут HTTP-тесты, оба отклоняют пересечения (overlap.policy: reject).
+// Postgres - отдельная схема на тест, без сервера подтесты пропускаются
+var backends = []struct {
+	name string
+	repo func(t *testing.T) services.Repository
+}{
+	{"memory", func(t *testing.T) services.Repository { return memory.New(true, nil) }},
+	{"postgres", func(t *testing.T) services.Repository { return pgtest.Repository(t) }},
+}
+
+// forEachBackend запускает test параллельными подтестами для каждого хранилища
+func forEachBackend(t *testing.T, test func(t *testing.T, srv *httptest.Server)) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			test(t, newServer(t, backend.repo(t)))
+		})
+	}
+}
+
+// newServer поднимает операции с подписками API v1 над хранилищем repo
+func newServer(t *testing.T, repo services.Repository) *httptest.Server {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc := services.NewRecordService(log, repo, services.NewRecordValidator(services.ValidationRules{AllowBackdated: true}), services.RecordServiceConfig{
+		OverlapPolicy: services.OverlapReject,
+	})
+
+	r := gin.New()
+	r.NoRoute(handlers.NotFound)
+	routes.RegisterRecordRoutes(r.Group("/api/v1"), handlers.NewRecordHandler(svc, nil, nil), handlers.NewImportHandler(importer.NewImporter(log, svc, 0)))
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func do(t *testing.T, method, url, body string) (int, []byte) {
+	t.Helper()
+
+	status, data, err := send(method, url, body)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+
+	return status, data
+}
+
+// send выполняет запрос без t, поэтому его можно вызывать из горутин
+func send(method, url, body string) (int, []byte, error) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, data, nil
+}
+
+func recordJSON(user, service string, price int, createdAt, expiresAt string) string {
+	return fmt.Sprintf(`{"service_name":%q,"price":%d,"user_id":%q,"created_at":%q,"expires_at":%q}`, service, price, user, createdAt, expiresAt)
+}
+
+func TestSummaryInclusiveBounds(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *httptest.Server) {
+		user := pgtest.NewUserID()
+
+		for _, record := range []string{
+			recordJSON(user, "Netflix", 100, "01-01-2025", "01-02-2025"),
+			recordJSON(user, "Spotify", 200, "01-03-2025", "01-04-2025"),
+			recordJSON(user, "YouTube", 400, "31-12-2024", "31-01-2025"),
+			recordJSON(user, "Okko", 800, "02-03-2025", "02-04-2025"),
+		} {
+			if status, body := do(t, http.MethodPost, srv.URL+"/api/v1/subscriptions", record); status != http.StatusCreated {
+				t.Fatalf("create: status %d, body %s", status, body)
+			}
+		}
+
+		status, body := do(t, http.MethodGet, srv.URL+"/api/v1/subscriptions/summary?start_time=01-01-2025&end_time=01-03-2025&user_id="+user, "")
+		if status != http.StatusOK {
+			t.Fatalf("summary: status %d, body %s", status, body)
+		}
+
+		var resp handlers.SumPeriodResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("decode summary: %v", err)
+		}
+		if resp.TotalPrice != 300 {
+			t.Fatalf("summary: got %d, want 300", resp.TotalPrice)
+		}
+	})
+}
+
+func TestCreateOverlappingConflict(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *httptest.Server) {
+		user := pgtest.NewUserID()
+
+		// одновременные запросы проходят проверку сервиса вместе, лишние отсекает хранилище:
+		// exclusion-ограничение Postgres или проверка под блокировкой в памяти
+		const requests = 8
+
+		statuses := make([]int, requests)
+		bodies := make([][]byte, requests)
+		errs := make([]error, requests)
+
+		var wg sync.WaitGroup
+		for i := range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses[i], bodies[i], errs[i] = send(http.MethodPost, srv.URL+"/api/v1/subscriptions", recordJSON(user, "Netflix", 100+i, "01-01-2025", "01-04-2025"))
+			}()
+		}
+		wg.Wait()
+
+		created := 0
+		for i, status := range statuses {
+			if errs[i] != nil {
+				t.Fatalf("create: %v", errs[i])
+			}
+
+			switch status {
+			case http.StatusCreated:
+				created++
+			case http.StatusConflict:
+				var problem handlers.Problem
+				if err := json.Unmarshal(bodies[i], &problem); err != nil {
+					t.Fatalf("decode problem: %v", err)
+				}
+				if problem.Code != services.CodeOverlappingSubscription {
+					t.Fatalf("conflict code: got %q, body %s", problem.Code, bodies[i])
+				}
+			default:
+				t.Fatalf("create: unexpected status %d, body %s", status, bodies[i])
+			}
+		}
+
+		if created != 1 {
+			t.Fatalf("created %d overlapping subscriptions, want 1", created)
+		}
+	})
+}
+
+func TestCreateNegativePrice(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *httptest.Server) {
+		status, body := do(t, http.MethodPost, srv.URL+"/api/v1/subscriptions", recordJSON(pgtest.NewUserID(), "Netflix", -1, "01-01-2025", "01-02-2025"))
+		if status != http.StatusBadRequest {
+			t.Fatalf("create: status %d, body %s", status, body)
+		}
+
+		var problem handlers.Problem
+		if err := json.Unmarshal(body, &problem); err != nil {
+			t.Fatalf("decode problem: %v", err)
+		}
+		if problem.Code != services.CodeValidationFailed || len(problem.Errors) != 1 || problem.Errors[0].Field != "price" {
+			t.Fatalf("unexpected problem %s", body)
+		}
+	})
+}
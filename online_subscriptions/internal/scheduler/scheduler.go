@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job - периодическая задача. Ошибка логируется, следующий запуск идет по расписанию
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler запускает задачи сразу при старте и затем каждые Interval, пока не отменен контекст
+type Scheduler struct {
+	log  *slog.Logger
+	jobs []Job
+}
+
+func New(log *slog.Logger) *Scheduler {
+	return &Scheduler{log: log}
+}
+
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Run блокируется до отмены ctx и ждет завершения выполняющихся задач
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	log := s.log.With(slog.String("job", job.Name))
+	log.Info("job scheduled", slog.Duration("interval", job.Interval))
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx, log, job)
+
+		select {
+		case <-ctx.Done():
+			log.Info("job stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, log *slog.Logger, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("job panicked", slog.Any("panic", r))
+		}
+	}()
+
+	started := time.Now()
+
+	if err := job.Run(ctx); err != nil {
+		log.Error("job failed", slog.Any("error", err), slog.Duration("duration", time.Since(started)))
+		return
+	}
+
+	log.Debug("job finished", slog.Duration("duration", time.Since(started)))
+}
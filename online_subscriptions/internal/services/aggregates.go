@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var ErrAggregatesFailed = errors.New("could not process aggregates")
+
+const CodeAggregatesFailed = "aggregates_failed"
+
+type AggregateWriter interface {
+	RefreshAggregates(ctx context.Context, keys []entity.AggregateKey) error
+}
+
+// aggregateRepository пересчитывает месячные агрегаты затронутых пар пользователь/сервис
+// в той же транзакции, что и изменение записей
+type aggregateRepository struct {
+	Repository
+	aggregates AggregateWriter
+}
+
+// WithAggregates оборачивает репозиторий так, что любое изменение записей обновляет месячные агрегаты
+func WithAggregates(repo Repository, aggregates AggregateWriter) Repository {
+	return &aggregateRepository{Repository: repo, aggregates: aggregates}
+}
+
+func (r *aggregateRepository) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.Repository.Transaction(ctx, func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, inTransactionKey{}, true))
+	})
+}
+
+// write выполняет изменение и пересчет агрегатов атомарно: в открытой транзакции или в новой
+func (r *aggregateRepository) write(ctx context.Context, change func(ctx context.Context) ([]entity.AggregateKey, error)) error {
+	apply := func(ctx context.Context) error {
+		keys, err := change(ctx)
+		if err != nil {
+			return err
+		}
+
+		return r.aggregates.RefreshAggregates(ctx, uniqueKeys(keys))
+	}
+
+	if inTransaction, _ := ctx.Value(inTransactionKey{}).(bool); inTransaction {
+		return apply(ctx)
+	}
+
+	return r.Transaction(ctx, apply)
+}
+
+func (r *aggregateRepository) SaveRecord(ctx context.Context, record *entity.Record) error {
+	return r.write(ctx, func(ctx context.Context) ([]entity.AggregateKey, error) {
+		if err := r.Repository.SaveRecord(ctx, record); err != nil {
+			return nil, err
+		}
+
+		return []entity.AggregateKey{aggregateKey(record)}, nil
+	})
+}
+
+func (r *aggregateRepository) SaveRecords(ctx context.Context, records []entity.Record, batchSize int) error {
+	return r.write(ctx, func(ctx context.Context) ([]entity.AggregateKey, error) {
+		if err := r.Repository.SaveRecords(ctx, records, batchSize); err != nil {
+			return nil, err
+		}
+
+		keys := make([]entity.AggregateKey, len(records))
+		for i := range records {
+			keys[i] = aggregateKey(&records[i])
+		}
+
+		return keys, nil
+	})
+}
+
+func (r *aggregateRepository) UpdateRecord(ctx context.Context, record *entity.Record) error {
+	return r.write(ctx, func(ctx context.Context) ([]entity.AggregateKey, error) {
+		// запись могла сменить пользователя или сервис - пересчитываются оба ключа
+		previous, err := r.Repository.GetRecordByID(ctx, record.ID)
+		if err != nil {
+			return nil, r.Repository.UpdateRecord(ctx, record)
+		}
+
+		if err := r.Repository.UpdateRecord(ctx, record); err != nil {
+			return nil, err
+		}
+
+		return []entity.AggregateKey{aggregateKey(previous), aggregateKey(record)}, nil
+	})
+}
+
+func (r *aggregateRepository) DeleteRecordByID(ctx context.Context, id uint) error {
+	return r.write(ctx, func(ctx context.Context) ([]entity.AggregateKey, error) {
+		record, err := r.Repository.GetRecordByID(ctx, id)
+		if err != nil {
+			return nil, r.Repository.DeleteRecordByID(ctx, id)
+		}
+
+		if err := r.Repository.DeleteRecordByID(ctx, id); err != nil {
+			return nil, err
+		}
+
+		return []entity.AggregateKey{aggregateKey(record)}, nil
+	})
+}
+
+func aggregateKey(record *entity.Record) entity.AggregateKey {
+	return entity.AggregateKey{UserID: record.UserID, ServiceName: record.ServiceName}
+}
+
+func uniqueKeys(keys []entity.AggregateKey) []entity.AggregateKey {
+	seen := make(map[entity.AggregateKey]struct{}, len(keys))
+	unique := keys[:0]
+
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		unique = append(unique, key)
+	}
+
+	return unique
+}
+
+type AggregateRepository interface {
+	AggregateWriter
+	RebuildAggregates(ctx context.Context) error
+	AggregatesBuilt(ctx context.Context) (bool, error)
+	CheckAggregates(ctx context.Context, limit int) ([]entity.AggregateMismatch, error)
+	SumAggregatedPrice(ctx context.Context, from, to time.Time, userID, serviceName string) (int, error)
+	AggregatedMRR(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.MRRPoint, error)
+	AggregatedTopServices(ctx context.Context, period analytics.Range, filter analytics.Filter, by analytics.RankBy, limit int) ([]analytics.ServiceRank, error)
+}
+
+// AggregateService обслуживает месячные агрегаты: построение, сверку с записями и чтение отчетов.
+// Отчеты читают агрегаты, только когда они построены полностью и чтение включено
+type AggregateService struct {
+	log    *slog.Logger
+	repo   AggregateRepository
+	config AggregateConfig
+	built  atomic.Bool
+}
+
+type AggregateConfig struct {
+	// Read - отчеты помесячной точности читают агрегаты вместо записей
+	Read bool
+	// Repair - плановая сверка сразу пересчитывает расходящиеся агрегаты
+	Repair bool
+	// CheckLimit - сколько расхождений возвращает сверка
+	CheckLimit int
+}
+
+func NewAggregateService(log *slog.Logger, repo AggregateRepository, config AggregateConfig) *AggregateService {
+	return &AggregateService{log: log, repo: repo, config: config}
+}
+
+// AggregateCheck - результат сверки агрегатов с записями
+type AggregateCheck struct {
+	Built      bool
+	Mismatches []entity.AggregateMismatch
+	Repaired   int
+}
+
+// ready сообщает, можно ли читать агрегаты. Построенность кешируется: агрегаты не разрушаются
+func (s *AggregateService) ready(ctx context.Context) bool {
+	if s == nil || !s.config.Read {
+		return false
+	}
+
+	if s.built.Load() {
+		return true
+	}
+
+	built, err := s.repo.AggregatesBuilt(ctx)
+	if err != nil {
+		s.log.Warn("could not check aggregates state", slog.Any("error", err))
+		return false
+	}
+
+	if built {
+		s.built.Store(true)
+	}
+
+	return built
+}
+
+// Rebuild строит агрегаты заново по всем записям
+func (s *AggregateService) Rebuild(ctx context.Context) error {
+	const op = "aggregateService.Rebuild"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("rebuilding aggregates...")
+
+	if err := s.repo.RebuildAggregates(ctx); err != nil {
+		log.Error("failed to rebuild aggregates", slog.Any("error", err))
+		return NewInternalError(CodeAggregatesFailed, ErrAggregatesFailed, err)
+	}
+
+	s.built.Store(true)
+
+	log.Info("aggregates rebuilt")
+
+	return nil
+}
+
+// Check сверяет агрегаты с записями и, если включено, пересчитывает расходящиеся ключи
+func (s *AggregateService) Check(ctx context.Context, repair bool) (*AggregateCheck, error) {
+	const op = "aggregateService.Check"
+
+	log := s.log.With(slog.String("operation", op))
+
+	built, err := s.repo.AggregatesBuilt(ctx)
+	if err != nil {
+		log.Error("failed to get aggregates state", slog.Any("error", err))
+		return nil, NewInternalError(CodeAggregatesFailed, ErrAggregatesFailed, err)
+	}
+
+	mismatches, err := s.repo.CheckAggregates(ctx, s.config.CheckLimit)
+	if err != nil {
+		log.Error("failed to check aggregates", slog.Any("error", err))
+		return nil, NewInternalError(CodeAggregatesFailed, ErrAggregatesFailed, err)
+	}
+
+	check := &AggregateCheck{Built: built, Mismatches: mismatches}
+
+	if len(mismatches) > 0 {
+		log.Warn("aggregates diverged from records", slog.Int("mismatches", len(mismatches)))
+	}
+
+	if !repair || len(mismatches) == 0 {
+		return check, nil
+	}
+
+	keys := make([]entity.AggregateKey, len(mismatches))
+	for i, mismatch := range mismatches {
+		keys[i] = entity.AggregateKey{UserID: mismatch.UserID, ServiceName: mismatch.ServiceName}
+	}
+	keys = uniqueKeys(keys)
+
+	if err := s.repo.RefreshAggregates(ctx, keys); err != nil {
+		log.Error("failed to repair aggregates", slog.Any("error", err))
+		return nil, NewInternalError(CodeAggregatesFailed, ErrAggregatesFailed, err)
+	}
+
+	check.Repaired = len(keys)
+
+	log.Info("aggregates repaired", slog.Int("keys", len(keys)))
+
+	return check, nil
+}
+
+// Maintain - плановая задача: строит агрегаты при первом запуске, затем сверяет их с записями
+func (s *AggregateService) Maintain(ctx context.Context) error {
+	built, err := s.repo.AggregatesBuilt(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !built {
+		return s.Rebuild(ctx)
+	}
+
+	_, err = s.Check(ctx, s.config.Repair)
+
+	return err
+}
+
+// sumPrice считает сумму за период по агрегатам. ok=false - период не из целых месяцев
+// или агрегаты нельзя читать, сумму нужно считать по записям
+func (s *AggregateService) sumPrice(ctx context.Context, start, end time.Time, userID, serviceName string) (total int, ok bool, err error) {
+	if !monthAligned(start, end) || !s.ready(ctx) {
+		return 0, false, nil
+	}
+
+	total, err = s.repo.SumAggregatedPrice(ctx, monthStart(start), monthStart(end), userID, serviceName)
+
+	return total, true, err
+}
+
+// monthAligned сообщает, покрывает ли период [start, end] целые месяцы
+func monthAligned(start, end time.Time) bool {
+	start, end = truncateToDate(start), truncateToDate(end)
+
+	return start.Day() == 1 && end.AddDate(0, 0, 1).Day() == 1 && !end.Before(start)
+}
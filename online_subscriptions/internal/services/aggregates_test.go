@@ -0,0 +1,200 @@
+package services_test
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeAggregates - AggregateRepository, который запоминает вызовы. Сумма по агрегатам всегда total
+type fakeAggregates struct {
+	built      bool
+	total      int
+	mismatches []entity.AggregateMismatch
+
+	sums      [][2]time.Time
+	refreshed []entity.AggregateKey
+	rebuilt   int
+}
+
+func (f *fakeAggregates) RefreshAggregates(ctx context.Context, keys []entity.AggregateKey) error {
+	f.refreshed = append(f.refreshed, keys...)
+	f.mismatches = nil
+
+	return nil
+}
+
+func (f *fakeAggregates) RebuildAggregates(ctx context.Context) error {
+	f.rebuilt++
+	f.built = true
+
+	return nil
+}
+
+func (f *fakeAggregates) AggregatesBuilt(ctx context.Context) (bool, error) {
+	return f.built, nil
+}
+
+func (f *fakeAggregates) CheckAggregates(ctx context.Context, limit int) ([]entity.AggregateMismatch, error) {
+	return f.mismatches, nil
+}
+
+func (f *fakeAggregates) SumAggregatedPrice(ctx context.Context, from, to time.Time, userID, serviceName string) (int, error) {
+	f.sums = append(f.sums, [2]time.Time{from, to})
+
+	return f.total, nil
+}
+
+func (f *fakeAggregates) AggregatedMRR(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.MRRPoint, error) {
+	return nil, nil
+}
+
+func (f *fakeAggregates) AggregatedTopServices(ctx context.Context, period analytics.Range, filter analytics.Filter, by analytics.RankBy, limit int) ([]analytics.ServiceRank, error) {
+	return nil, nil
+}
+
+func TestSummaryReadsAggregatesForWholeMonths(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	repo := memory.New(false, nil)
+	for _, start := range []time.Time{day(2025, time.January, 1), day(2025, time.January, 20), day(2025, time.March, 31)} {
+		record := entity.Record{ServiceName: "Netflix", Price: 100, UserID: recordUser, CreatedAt: start, ExpiresAt: start.AddDate(0, 1, 0)}
+		if err := repo.SaveRecord(ctx, &record); err != nil {
+			t.Fatalf("save record: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		read       bool
+		built      bool
+		// aggregated - сумма взята из агрегатов, иначе из записей
+		aggregated bool
+		want       int
+	}{
+		{"whole months", day(2025, time.January, 1), day(2025, time.March, 31), true, true, true, 999},
+		{"one month", day(2025, time.February, 1), day(2025, time.February, 28), true, true, true, 999},
+		{"leap february", day(2024, time.February, 1), day(2024, time.February, 29), true, true, true, 999},
+		{"start mid-month", day(2025, time.January, 2), day(2025, time.March, 31), true, true, false, 200},
+		{"end mid-month", day(2025, time.January, 1), day(2025, time.March, 30), true, true, false, 200},
+		{"end before start", day(2025, time.March, 1), day(2025, time.January, 31), true, true, false, 0},
+		{"reading disabled", day(2025, time.January, 1), day(2025, time.March, 31), false, true, false, 300},
+		{"not built", day(2025, time.January, 1), day(2025, time.March, 31), true, false, false, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregates := &fakeAggregates{built: tt.built, total: 999}
+
+			svc := services.NewRecordService(log, repo, services.NewRecordValidator(services.ValidationRules{}), services.RecordServiceConfig{
+				Clock:      clock.NewFake(day(2025, time.June, 1)),
+				Aggregates: services.NewAggregateService(log, aggregates, services.AggregateConfig{Read: tt.read}),
+			})
+
+			total, err := svc.SummaryPriceOfSelectedRecords(ctx, tt.start, tt.end, recordUser, "")
+			if err != nil {
+				t.Fatalf("summary: %v", err)
+			}
+			if total != tt.want {
+				t.Fatalf("total: got %d, want %d", total, tt.want)
+			}
+
+			if !tt.aggregated {
+				if len(aggregates.sums) != 0 {
+					t.Fatalf("aggregates read for %s - %s", tt.start, tt.end)
+				}
+				return
+			}
+
+			// агрегаты читаются по первым числам месяцев периода
+			want := [][2]time.Time{{day(tt.start.Year(), tt.start.Month(), 1), day(tt.end.Year(), tt.end.Month(), 1)}}
+			if !reflect.DeepEqual(aggregates.sums, want) {
+				t.Fatalf("aggregate months: got %v, want %v", aggregates.sums, want)
+			}
+		})
+	}
+}
+
+func TestAggregatesMaintain(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	drift := []entity.AggregateMismatch{
+		{Month: day(2025, time.January, 1), UserID: recordUser, ServiceName: "Netflix", ExpectedNewPrice: 100, ActualNewPrice: 101},
+		{Month: day(2025, time.February, 1), UserID: recordUser, ServiceName: "Netflix", ExpectedActivePrice: 100},
+		{Month: day(2025, time.January, 1), UserID: recordUser, ServiceName: "Spotify", ExpectedNewCount: 1},
+	}
+
+	t.Run("first run builds", func(t *testing.T) {
+		aggregates := &fakeAggregates{}
+		svc := services.NewAggregateService(log, aggregates, services.AggregateConfig{Repair: true})
+
+		if err := svc.Maintain(ctx); err != nil {
+			t.Fatalf("maintain: %v", err)
+		}
+		if aggregates.rebuilt != 1 || len(aggregates.refreshed) != 0 {
+			t.Fatalf("got %d rebuilds and %d refreshed keys, want one rebuild", aggregates.rebuilt, len(aggregates.refreshed))
+		}
+
+		// следующий запуск уже только сверяет
+		if err := svc.Maintain(ctx); err != nil {
+			t.Fatalf("maintain: %v", err)
+		}
+		if aggregates.rebuilt != 1 {
+			t.Fatalf("rebuilt again on the second run")
+		}
+	})
+
+	t.Run("drift repaired", func(t *testing.T) {
+		aggregates := &fakeAggregates{built: true, mismatches: drift}
+		svc := services.NewAggregateService(log, aggregates, services.AggregateConfig{Repair: true, CheckLimit: 10})
+
+		if err := svc.Maintain(ctx); err != nil {
+			t.Fatalf("maintain: %v", err)
+		}
+
+		// ключи пересчитываются по одному разу, даже если расходятся несколько месяцев
+		want := []entity.AggregateKey{{UserID: recordUser, ServiceName: "Netflix"}, {UserID: recordUser, ServiceName: "Spotify"}}
+		if !reflect.DeepEqual(aggregates.refreshed, want) {
+			t.Fatalf("refreshed: got %+v, want %+v", aggregates.refreshed, want)
+		}
+
+		check, err := svc.Check(ctx, false)
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		if len(check.Mismatches) != 0 {
+			t.Fatalf("still diverged after repair: %+v", check.Mismatches)
+		}
+	})
+
+	t.Run("drift reported without repair", func(t *testing.T) {
+		aggregates := &fakeAggregates{built: true, mismatches: drift}
+		svc := services.NewAggregateService(log, aggregates, services.AggregateConfig{CheckLimit: 10})
+
+		if err := svc.Maintain(ctx); err != nil {
+			t.Fatalf("maintain: %v", err)
+		}
+		if len(aggregates.refreshed) != 0 {
+			t.Fatalf("repaired with repair disabled: %+v", aggregates.refreshed)
+		}
+
+		check, err := svc.Check(ctx, false)
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		if !check.Built || len(check.Mismatches) != len(drift) || check.Repaired != 0 {
+			t.Fatalf("check: got %+v, want %d mismatches and nothing repaired", check, len(drift))
+		}
+	})
+}
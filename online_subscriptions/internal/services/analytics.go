@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/analytics"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"log/slog"
+	"time"
+)
+
+var ErrAnalyticsFailed = errors.New("could not build report")
+
+const (
+	CodeAnalyticsFailed = "analytics_failed"
+
+	// MaxAnalyticsMonths ограничивает длину отчетного периода
+	MaxAnalyticsMonths = 120
+	// MaxTopServices ограничивает размер топа сервисов
+	MaxTopServices = 100
+)
+
+type AnalyticsRepository interface {
+	MRR(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.MRRPoint, error)
+	Churn(ctx context.Context, period analytics.Range, filter analytics.Filter, today time.Time) ([]analytics.ChurnPoint, error)
+	TopServices(ctx context.Context, period analytics.Range, filter analytics.Filter, by analytics.RankBy, limit int) ([]analytics.ServiceRank, error)
+	Lifetimes(ctx context.Context, period analytics.Range, filter analytics.Filter, today time.Time) ([]analytics.Lifetime, error)
+	Cohorts(ctx context.Context, period analytics.Range, filter analytics.Filter, last time.Time) ([]analytics.CohortRow, error)
+}
+
+// AnalyticsService строит отчеты по подпискам за период из целых месяцев.
+// MRR и топ сервисов читаются из месячных агрегатов, когда они доступны
+type AnalyticsService struct {
+	log        *slog.Logger
+	repo       AnalyticsRepository
+	aggregates *AggregateService
+	clock      clock.Clock
+}
+
+// NewAnalyticsService создает сервис отчетов, aggregates может быть nil - тогда отчеты всегда читают записи.
+// clk задает текущую дату для оттока, сроков жизни и когорт, nil - системные часы
+func NewAnalyticsService(log *slog.Logger, repo AnalyticsRepository, aggregates *AggregateService, clk clock.Clock) *AnalyticsService {
+	return &AnalyticsService{log: log, repo: repo, aggregates: aggregates, clock: clock.OrSystem(clk)}
+}
+
+// LifetimeReport - срок жизни подписок: итог и разбивка по сервисам
+type LifetimeReport struct {
+	Total    analytics.Lifetime
+	Services []analytics.Lifetime
+}
+
+func (s *AnalyticsService) MRR(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.MRRPoint, error) {
+	const op = "analyticsService.MRR"
+
+	log := s.log.With(slog.String("operation", op))
+
+	if err := validateRange(period); err != nil {
+		return nil, err
+	}
+
+	var points []analytics.MRRPoint
+	var err error
+
+	if s.aggregates.ready(ctx) {
+		points, err = s.aggregates.repo.AggregatedMRR(ctx, period, filter)
+	} else {
+		points, err = s.repo.MRR(ctx, period, filter)
+	}
+	if err != nil {
+		log.Error("failed to build report", slog.Any("error", err))
+		return nil, NewInternalError(CodeAnalyticsFailed, ErrAnalyticsFailed, err)
+	}
+
+	return points, nil
+}
+
+func (s *AnalyticsService) Churn(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.ChurnPoint, error) {
+	const op = "analyticsService.Churn"
+
+	log := s.log.With(slog.String("operation", op))
+
+	if err := validateRange(period); err != nil {
+		return nil, err
+	}
+
+	points, err := s.repo.Churn(ctx, period, filter, truncateToDate(s.clock.Now()))
+	if err != nil {
+		log.Error("failed to build report", slog.Any("error", err))
+		return nil, NewInternalError(CodeAnalyticsFailed, ErrAnalyticsFailed, err)
+	}
+
+	return points, nil
+}
+
+func (s *AnalyticsService) TopServices(ctx context.Context, period analytics.Range, filter analytics.Filter, by analytics.RankBy, limit int) ([]analytics.ServiceRank, error) {
+	const op = "analyticsService.TopServices"
+
+	log := s.log.With(slog.String("operation", op))
+
+	violations := rangeViolations(period)
+
+	if by != analytics.RankBySpend && by != analytics.RankBySubscribers {
+		violations = append(violations, FieldError{Field: "by", Code: "oneof", Message: "must be one of: spend, subscribers"})
+	}
+
+	if limit < 1 || limit > MaxTopServices {
+		violations = append(violations, FieldError{Field: "limit", Code: "range", Message: "must be between 1 and 100"})
+	}
+
+	if len(violations) > 0 {
+		return nil, NewValidationError("invalid report parameters", violations...)
+	}
+
+	var services []analytics.ServiceRank
+	var err error
+
+	if s.aggregates.ready(ctx) {
+		services, err = s.aggregates.repo.AggregatedTopServices(ctx, period, filter, by, limit)
+	} else {
+		services, err = s.repo.TopServices(ctx, period, filter, by, limit)
+	}
+	if err != nil {
+		log.Error("failed to build report", slog.Any("error", err))
+		return nil, NewInternalError(CodeAnalyticsFailed, ErrAnalyticsFailed, err)
+	}
+
+	return services, nil
+}
+
+func (s *AnalyticsService) Lifetime(ctx context.Context, period analytics.Range, filter analytics.Filter) (*LifetimeReport, error) {
+	const op = "analyticsService.Lifetime"
+
+	log := s.log.With(slog.String("operation", op))
+
+	if err := validateRange(period); err != nil {
+		return nil, err
+	}
+
+	lifetimes, err := s.repo.Lifetimes(ctx, period, filter, truncateToDate(s.clock.Now()))
+	if err != nil {
+		log.Error("failed to build report", slog.Any("error", err))
+		return nil, NewInternalError(CodeAnalyticsFailed, ErrAnalyticsFailed, err)
+	}
+
+	report := &LifetimeReport{Services: make([]analytics.Lifetime, 0, len(lifetimes))}
+
+	// итог по всем сервисам приходит первой строкой
+	for i, lifetime := range lifetimes {
+		if i == 0 {
+			report.Total = lifetime
+			continue
+		}
+		report.Services = append(report.Services, lifetime)
+	}
+
+	return report, nil
+}
+
+// Cohorts возвращает удержание когорт, начавшихся в периоде. Удержание считается не дальше текущего месяца
+func (s *AnalyticsService) Cohorts(ctx context.Context, period analytics.Range, filter analytics.Filter) ([]analytics.Cohort, error) {
+	const op = "analyticsService.Cohorts"
+
+	log := s.log.With(slog.String("operation", op))
+
+	if err := validateRange(period); err != nil {
+		return nil, err
+	}
+
+	last := period.To
+	if current := monthStart(s.clock.Now()); current.Before(last) {
+		last = current
+	}
+
+	rows, err := s.repo.Cohorts(ctx, period, filter, last)
+	if err != nil {
+		log.Error("failed to build report", slog.Any("error", err))
+		return nil, NewInternalError(CodeAnalyticsFailed, ErrAnalyticsFailed, err)
+	}
+
+	return analytics.BuildCohorts(rows), nil
+}
+
+func validateRange(period analytics.Range) error {
+	if violations := rangeViolations(period); len(violations) > 0 {
+		return NewValidationError("invalid report period", violations...)
+	}
+
+	return nil
+}
+
+func rangeViolations(period analytics.Range) []FieldError {
+	if period.To.Before(period.From) {
+		return []FieldError{{Field: "to", Code: "invalid_range", Message: "must not be before from"}}
+	}
+
+	if period.Months() > MaxAnalyticsMonths {
+		return []FieldError{{Field: "to", Code: "range", Message: "period must not exceed 120 months"}}
+	}
+
+	return nil
+}
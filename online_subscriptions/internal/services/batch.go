@@ -0,0 +1,417 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+const (
+	CodeBatchAborted  = "batch_aborted"
+	CodeBatchTooLarge = "batch_too_large"
+)
+
+// errBatchAborted - item не применен, потому что в атомарном пакете ошибся другой item
+var errBatchAborted = errors.New("batch aborted")
+
+// BatchLimits ограничивает размер пакетных операций
+type BatchLimits struct {
+	// MaxItems - максимальное число элементов в одном запросе
+	MaxItems int
+	// InsertChunkSize - размер пачки для CreateInBatches
+	InsertChunkSize int
+}
+
+// RecordPatch - частичное изменение записи, nil-поля остаются без изменений
+type RecordPatch struct {
+	ID          uint
+	ServiceName *string
+	Price       *int
+	UserID      *string
+	Category    *string
+	CreatedAt   *time.Time
+	ExpiresAt   *time.Time
+}
+
+func (p RecordPatch) Apply(record *entity.Record) {
+	if p.ServiceName != nil {
+		record.ServiceName = *p.ServiceName
+	}
+	if p.Price != nil {
+		record.Price = *p.Price
+	}
+	if p.UserID != nil {
+		record.UserID = *p.UserID
+	}
+	if p.Category != nil {
+		record.Category = *p.Category
+	}
+	if p.CreatedAt != nil {
+		record.CreatedAt = *p.CreatedAt
+	}
+	if p.ExpiresAt != nil {
+		record.ExpiresAt = *p.ExpiresAt
+	}
+}
+
+// BatchItemResult - итог обработки одного элемента пакета
+type BatchItemResult struct {
+	Index    int
+	Record   *entity.Record
+	Merged   bool
+	Warnings []Warning
+	Err      error
+}
+
+func (item *BatchItemResult) setWritten(written *WriteResult, err error) {
+	if err != nil {
+		item.Err = err
+		return
+	}
+
+	item.Record, item.Merged, item.Warnings = written.Record, written.Merged, written.Warnings
+}
+
+// BatchResult - итог пакетной операции. В атомарном режиме Committed=false означает,
+// что не применен ни один элемент
+type BatchResult struct {
+	Atomic    bool
+	Committed bool
+	Items     []BatchItemResult
+}
+
+func newBatchResult(atomic bool, size int) *BatchResult {
+	result := &BatchResult{Atomic: atomic, Items: make([]BatchItemResult, size)}
+	for i := range result.Items {
+		result.Items[i].Index = i
+	}
+
+	return result
+}
+
+func (r *BatchResult) Failed() int {
+	failed := 0
+	for _, item := range r.Items {
+		if item.Err != nil {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+func (s *RecordService) checkBatchSize(size int) error {
+	if s.config.Batch.MaxItems > 0 && size > s.config.Batch.MaxItems {
+		return NewValidationError("batch is too large", FieldError{
+			Field:   "items",
+			Code:    CodeBatchTooLarge,
+			Message: fmt.Sprintf("must contain at most %d items", s.config.Batch.MaxItems),
+		})
+	}
+
+	if size == 0 {
+		return NewValidationError("batch is empty", FieldError{
+			Field:   "items",
+			Code:    "required",
+			Message: "must contain at least one item",
+		})
+	}
+
+	return nil
+}
+
+// abortBatch помечает все успешные элементы атомарного пакета как неприменённые
+func abortBatch(result *BatchResult) {
+	result.Committed = false
+
+	for i := range result.Items {
+		if result.Items[i].Err == nil {
+			result.Items[i].Err = NewConflictError(CodeBatchAborted, "not applied: another item of the batch failed", errBatchAborted)
+			result.Items[i].Warnings = nil
+		}
+	}
+}
+
+// CreateRecords создает записи пакетом. В атомарном режиме все записи пишутся в одной транзакции,
+// записи без пересечений вставляются через CreateInBatches; в режиме best effort каждая запись создается отдельно
+func (s *RecordService) CreateRecords(ctx context.Context, records []entity.Record, atomic bool) (*BatchResult, error) {
+	const op = "recordService.CreateRecords"
+
+	log := s.log.With(slog.String("operation", op), slog.Int("items", len(records)), slog.Bool("atomic", atomic))
+	log.Info("creating records batch...")
+
+	if err := s.checkBatchSize(len(records)); err != nil {
+		return nil, err
+	}
+
+	result := newBatchResult(atomic, len(records))
+
+	if !atomic {
+		for i := range records {
+			written, err := s.CreateRecord(ctx, &records[i])
+			result.Items[i].setWritten(written, err)
+		}
+
+		result.Committed = result.Failed() == 0
+		log.Info("records batch processed", slog.Int("failed", result.Failed()))
+
+		return result, nil
+	}
+
+	today := s.config.Clock.Now()
+
+	for i := range records {
+		records[i].ID = 0
+		result.Items[i].Record = &records[i]
+		result.Items[i].Err = s.validator.Validate(&records[i], today)
+	}
+
+	if result.Failed() > 0 {
+		abortBatch(result)
+		log.Warn("records batch rejected by validation", slog.Int("failed", result.Failed()))
+
+		return result, nil
+	}
+
+	sequential := hasInternalOverlaps(records)
+
+	// записанные версии элементов: при объединении это существующая запись, а не records[i]
+	written := make([]*WriteResult, len(records))
+	var checks []*BudgetCheck
+
+	err := s.recordRepository.Transaction(ctx, func(ctx context.Context) error {
+		var pending []entity.Record
+		var pendingIdx []int
+
+		for i := range records {
+			record := &records[i]
+
+			// если записи пакета пересекаются между собой, пишем их по одной,
+			// чтобы каждая следующая видела предыдущие при проверке пересечений
+			if !sequential {
+				overlaps, err := s.recordRepository.FindOverlappingRecords(ctx, record.UserID, record.ServiceName, record.CreatedAt, record.ExpiresAt, 0)
+				if err != nil {
+					return err
+				}
+
+				if len(overlaps) == 0 {
+					pending = append(pending, *record)
+					pendingIdx = append(pendingIdx, i)
+					continue
+				}
+			}
+
+			item, err := s.writeWithOverlapPolicy(ctx, record, s.recordRepository.SaveRecord)
+			if err != nil {
+				result.Items[i].Err = s.classifyWriteError(ctx, record, err, CodeCreateFailed, ErrCreateFailed)
+				return errBatchAborted
+			}
+
+			written[i] = item
+		}
+
+		if len(pending) > 0 {
+			if err := s.recordRepository.SaveRecords(ctx, pending, s.config.Batch.InsertChunkSize); err != nil {
+				return err
+			}
+
+			for j, i := range pendingIdx {
+				records[i] = pending[j]
+				written[i] = &WriteResult{Record: &records[i]}
+			}
+		}
+
+		// бюджет проверяется, когда записан весь пакет: прогноз учитывает все его записи
+		for i, item := range written {
+			check, err := s.checkBudget(ctx, item, true)
+			if err != nil {
+				result.Items[i].Err = err
+				return errBatchAborted
+			}
+			checks = append(checks, check)
+
+			result.Items[i].Merged, result.Items[i].Warnings = item.Merged, item.Warnings
+		}
+
+		return nil
+	})
+
+	result, err = s.finishAtomicBatch(log, result, err, CodeCreateFailed, ErrCreateFailed)
+	if err == nil && result.Committed {
+		s.alertBudgets(ctx, checks)
+	}
+
+	return result, err
+}
+
+// PatchRecords частично обновляет записи пакетом
+func (s *RecordService) PatchRecords(ctx context.Context, patches []RecordPatch, atomic bool) (*BatchResult, error) {
+	const op = "recordService.PatchRecords"
+
+	log := s.log.With(slog.String("operation", op), slog.Int("items", len(patches)), slog.Bool("atomic", atomic))
+	log.Info("patching records batch...")
+
+	if err := s.checkBatchSize(len(patches)); err != nil {
+		return nil, err
+	}
+
+	result := newBatchResult(atomic, len(patches))
+
+	if !atomic {
+		for i, patch := range patches {
+			written, err := s.PatchRecord(ctx, patch)
+			result.Items[i].setWritten(written, err)
+		}
+
+		result.Committed = result.Failed() == 0
+		log.Info("records batch processed", slog.Int("failed", result.Failed()))
+
+		return result, nil
+	}
+
+	var checks []*BudgetCheck
+
+	err := s.recordRepository.Transaction(ctx, func(ctx context.Context) error {
+		for i, patch := range patches {
+			record, err := s.recordRepository.GetRecordByID(ctx, patch.ID)
+			if err != nil {
+				result.Items[i].Err = s.classifyWriteError(ctx, nil, err, CodeUpdateFailed, ErrUpdateFailed)
+				return errBatchAborted
+			}
+
+			patch.Apply(record)
+			result.Items[i].Record = record
+
+			if err := s.validator.Validate(record, s.config.Clock.Now()); err != nil {
+				result.Items[i].Err = err
+				return errBatchAborted
+			}
+
+			written, err := s.writeWithOverlapPolicy(ctx, record, s.recordRepository.UpdateRecord)
+			if err != nil {
+				result.Items[i].Err = s.classifyWriteError(ctx, record, err, CodeUpdateFailed, ErrUpdateFailed)
+				return errBatchAborted
+			}
+
+			check, err := s.checkBudget(ctx, written, false)
+			if err != nil {
+				result.Items[i].Err = err
+				return errBatchAborted
+			}
+			checks = append(checks, check)
+
+			result.Items[i].Merged, result.Items[i].Warnings = written.Merged, written.Warnings
+		}
+
+		return nil
+	})
+
+	result, err = s.finishAtomicBatch(log, result, err, CodeUpdateFailed, ErrUpdateFailed)
+	if err == nil && result.Committed {
+		s.alertBudgets(ctx, checks)
+	}
+
+	return result, err
+}
+
+// DeleteRecords удаляет записи пакетом
+func (s *RecordService) DeleteRecords(ctx context.Context, ids []uint, atomic bool) (*BatchResult, error) {
+	const op = "recordService.DeleteRecords"
+
+	log := s.log.With(slog.String("operation", op), slog.Int("items", len(ids)), slog.Bool("atomic", atomic))
+	log.Info("deleting records batch...")
+
+	if err := s.checkBatchSize(len(ids)); err != nil {
+		return nil, err
+	}
+
+	result := newBatchResult(atomic, len(ids))
+
+	if !atomic {
+		for i, id := range ids {
+			result.Items[i].Err = s.DeleteRecordByID(ctx, id)
+		}
+
+		result.Committed = result.Failed() == 0
+		log.Info("records batch processed", slog.Int("failed", result.Failed()))
+
+		return result, nil
+	}
+
+	err := s.recordRepository.Transaction(ctx, func(ctx context.Context) error {
+		for i, id := range ids {
+			if err := s.recordRepository.DeleteRecordByID(ctx, id); err != nil {
+				result.Items[i].Err = s.classifyWriteError(ctx, nil, err, CodeDeleteFailed, ErrDeleteFailed)
+				return errBatchAborted
+			}
+		}
+
+		return nil
+	})
+
+	return s.finishAtomicBatch(log, result, err, CodeDeleteFailed, ErrDeleteFailed)
+}
+
+// finishAtomicBatch разбирает итог транзакции атомарного пакета
+func (s *RecordService) finishAtomicBatch(log *slog.Logger, result *BatchResult, err error, code string, sentinel error) (*BatchResult, error) {
+	switch {
+	case err == nil:
+		result.Committed = true
+		log.Info("records batch committed")
+
+		return result, nil
+	case errors.Is(err, errBatchAborted):
+		abortBatch(result)
+		log.Warn("records batch rolled back", slog.Int("failed", result.Failed()))
+
+		return result, nil
+	default:
+		log.Error("records batch failed", slog.Any("error", err))
+
+		return nil, NewInternalError(code, sentinel, err)
+	}
+}
+
+// hasInternalOverlaps проверяет, пересекаются ли записи пакета между собой
+func hasInternalOverlaps(records []entity.Record) bool {
+	type key struct{ userID, serviceName string }
+
+	periods := make(map[key][]entity.Record, len(records))
+
+	for _, record := range records {
+		k := key{record.UserID, record.ServiceName}
+
+		for _, other := range periods[k] {
+			if record.CreatedAt.Before(other.ExpiresAt) && other.CreatedAt.Before(record.ExpiresAt) {
+				return true
+			}
+		}
+
+		periods[k] = append(periods[k], record)
+	}
+
+	return false
+}
+
+// classifyWriteError приводит ошибку записи к типизированной ошибке сервиса
+func (s *RecordService) classifyWriteError(ctx context.Context, record *entity.Record, err error, code string, sentinel error) error {
+	if _, ok := AsError(err); ok {
+		return err
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errRecordNotFound(err)
+	}
+
+	if record != nil {
+		if conflict := s.overlapConflict(ctx, record, err); conflict != nil {
+			return conflict
+		}
+	}
+
+	return NewInternalError(code, sentinel, err)
+}
@@ -0,0 +1,532 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/billing"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/notify"
+	"gorm.io/gorm"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrBudgetFailed = errors.New("could not process budget")
+
+const (
+	CodeBudgetNotFound = "budget_not_found"
+	CodeBudgetExists   = "budget_exists"
+	CodeBudgetExceeded = "budget_exceeded"
+	CodeBudgetFailed   = "budget_failed"
+)
+
+type BudgetRepository interface {
+	CreateBudget(ctx context.Context, budget *entity.Budget) error
+	GetBudgetByUserID(ctx context.Context, userID string) (*entity.Budget, error)
+	ListBudgets(ctx context.Context, limit, offset int) ([]entity.Budget, error)
+	ReplaceBudget(ctx context.Context, budget *entity.Budget) error
+	DeleteBudget(ctx context.Context, userID string) error
+	ClaimBudgetAlert(ctx context.Context, alert *entity.BudgetAlert) (bool, error)
+	ReleaseBudgetAlert(ctx context.Context, alert *entity.BudgetAlert) error
+	GetRecordsByUserID(ctx context.Context, userID string) ([]entity.Record, error)
+}
+
+// BudgetConfig - настройки проверки бюджетов
+type BudgetConfig struct {
+	// при записи подписки проверяются месяцы ее списаний от текущего на HorizonMonths вперед
+	HorizonMonths int
+	// Clock задает текущий месяц проверки, nil - системные часы
+	Clock clock.Clock
+}
+
+// BudgetService ведет бюджеты пользователей и сравнивает с ними прогноз расходов на подписки.
+// Прогноз за месяц - сумма цен подписок, списание по которым приходится на этот месяц
+type BudgetService struct {
+	log       *slog.Logger
+	repo      BudgetRepository
+	notifiers []notify.Notifier
+	config    BudgetConfig
+}
+
+func NewBudgetService(log *slog.Logger, repo BudgetRepository, notifiers []notify.Notifier, config BudgetConfig) *BudgetService {
+	if config.HorizonMonths <= 0 {
+		config.HorizonMonths = 12
+	}
+
+	config.Clock = clock.OrSystem(config.Clock)
+
+	return &BudgetService{
+		log:       log,
+		repo:      repo,
+		notifiers: notifiers,
+		config:    config,
+	}
+}
+
+// BudgetInput - лимиты бюджета, CategoryLimits - лимиты по категориям
+type BudgetInput struct {
+	UserID         string
+	MonthlyLimit   int
+	Enforce        bool
+	CategoryLimits map[string]int
+}
+
+// BudgetUsage - прогноз расходов против лимита. Category пустая для общего лимита
+type BudgetUsage struct {
+	Category  string `json:"category,omitempty"`
+	Limit     int    `json:"limit"`
+	Projected int    `json:"projected"`
+	Exceeded  bool   `json:"exceeded"`
+}
+
+// BudgetStatus - состояние бюджета пользователя за месяц
+type BudgetStatus struct {
+	UserID     string
+	Period     time.Time
+	Total      BudgetUsage
+	Categories []BudgetUsage
+}
+
+// exceeded возвращает превышенные лимиты; onlyCategory ограничивает категорийные лимиты одной категорией
+func (st *BudgetStatus) exceeded(onlyCategory *string) []BudgetExceeded {
+	var result []BudgetExceeded
+
+	add := func(usage BudgetUsage) {
+		result = append(result, BudgetExceeded{
+			Period:    st.Period,
+			Category:  usage.Category,
+			Limit:     usage.Limit,
+			Projected: usage.Projected,
+		})
+	}
+
+	if st.Total.Exceeded {
+		add(st.Total)
+	}
+
+	for _, usage := range st.Categories {
+		if usage.Exceeded && (onlyCategory == nil || *onlyCategory == usage.Category) {
+			add(usage)
+		}
+	}
+
+	return result
+}
+
+// BudgetExceeded - превышенный лимит за месяц
+type BudgetExceeded struct {
+	Period    time.Time
+	Category  string
+	Limit     int
+	Projected int
+}
+
+// BudgetCheck - итог проверки записанной подписки
+type BudgetCheck struct {
+	UserID   string
+	Enforce  bool
+	Exceeded []BudgetExceeded
+}
+
+func (s *BudgetService) CreateBudget(ctx context.Context, input BudgetInput) (*entity.Budget, error) {
+	const op = "budgetService.CreateBudget"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("creating budget...")
+
+	budget, err := newBudget(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateBudget(ctx, budget); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, NewConflictError(CodeBudgetExists, "budget for this user already exists", err)
+		}
+
+		log.Error("failed to create budget", slog.Any("error", err))
+		return nil, NewInternalError(CodeBudgetFailed, ErrBudgetFailed, err)
+	}
+
+	log.Info("budget created", slog.Uint64("budget_id", uint64(budget.ID)))
+
+	return budget, nil
+}
+
+func (s *BudgetService) GetBudget(ctx context.Context, userID string) (*entity.Budget, error) {
+	const op = "budgetService.GetBudget"
+
+	budget, err := s.repo.GetBudgetByUserID(ctx, normalizeUserID(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errBudgetNotFound(err)
+		}
+
+		s.log.Error("failed to get budget", slog.String("operation", op), slog.Any("error", err))
+		return nil, NewInternalError(CodeBudgetFailed, ErrBudgetFailed, err)
+	}
+
+	return budget, nil
+}
+
+func (s *BudgetService) ListBudgets(ctx context.Context, limit, offset int) ([]entity.Budget, error) {
+	budgets, err := s.repo.ListBudgets(ctx, limit, offset)
+	if err != nil {
+		s.log.Error("failed to list budgets", slog.String("operation", "budgetService.ListBudgets"), slog.Any("error", err))
+		return nil, NewInternalError(CodeBudgetFailed, ErrBudgetFailed, err)
+	}
+
+	return budgets, nil
+}
+
+// UpdateBudget заменяет лимиты бюджета пользователя
+func (s *BudgetService) UpdateBudget(ctx context.Context, input BudgetInput) (*entity.Budget, error) {
+	const op = "budgetService.UpdateBudget"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("updating budget...")
+
+	budget, err := newBudget(input)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.GetBudget(ctx, budget.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	budget.ID = existing.ID
+
+	if err := s.repo.ReplaceBudget(ctx, budget); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errBudgetNotFound(err)
+		}
+
+		log.Error("failed to update budget", slog.Any("error", err))
+		return nil, NewInternalError(CodeBudgetFailed, ErrBudgetFailed, err)
+	}
+
+	log.Info("budget updated", slog.Uint64("budget_id", uint64(budget.ID)))
+
+	return s.GetBudget(ctx, budget.UserID)
+}
+
+func (s *BudgetService) DeleteBudget(ctx context.Context, userID string) error {
+	const op = "budgetService.DeleteBudget"
+
+	log := s.log.With(slog.String("operation", op))
+
+	if err := s.repo.DeleteBudget(ctx, normalizeUserID(userID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errBudgetNotFound(err)
+		}
+
+		log.Error("failed to delete budget", slog.Any("error", err))
+		return NewInternalError(CodeBudgetFailed, ErrBudgetFailed, err)
+	}
+
+	log.Info("budget deleted")
+
+	return nil
+}
+
+// Status считает прогноз расходов пользователя за месяц period против его бюджета, нулевой period - текущий месяц
+func (s *BudgetService) Status(ctx context.Context, userID string, period time.Time) (*BudgetStatus, error) {
+	const op = "budgetService.Status"
+
+	if period.IsZero() {
+		period = s.config.Clock.Now()
+	}
+
+	budget, err := s.GetBudget(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.repo.GetRecordsByUserID(ctx, budget.UserID)
+	if err != nil {
+		s.log.Error("failed to get records", slog.String("operation", op), slog.Any("error", err))
+		return nil, NewInternalError(CodeBudgetFailed, ErrBudgetFailed, err)
+	}
+
+	return evaluateBudget(budget, records, monthStart(period)), nil
+}
+
+// CheckRecord сравнивает с бюджетом прогноз расходов в месяцы списаний записанной подписки.
+// Вызывается в транзакции записи, поэтому видит запись уже сохраненной. Без бюджета возвращает nil
+func (s *BudgetService) CheckRecord(ctx context.Context, record *entity.Record) (*BudgetCheck, error) {
+	budget, err := s.repo.GetBudgetByUserID(ctx, record.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.repo.GetRecordsByUserID(ctx, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := monthStart(s.config.Clock.Now())
+	to := from.AddDate(0, s.config.HorizonMonths, 0)
+
+	check := &BudgetCheck{UserID: budget.UserID, Enforce: budget.Enforce}
+
+	for _, date := range billing.ChargeDates(truncateToDate(record.CreatedAt), truncateToDate(record.ExpiresAt), from, to) {
+		status := evaluateBudget(budget, records, monthStart(date))
+		check.Exceeded = append(check.Exceeded, status.exceeded(&record.Category)...)
+	}
+
+	return check, nil
+}
+
+// CheckAll проверяет бюджеты всех пользователей за текущий месяц и уведомляет о превышениях.
+// Запускается планировщиком
+func (s *BudgetService) CheckAll(ctx context.Context) error {
+	const (
+		op       = "budgetService.CheckAll"
+		pageSize = 100
+	)
+
+	log := s.log.With(slog.String("operation", op))
+
+	period := monthStart(s.config.Clock.Now())
+	exceeded := 0
+
+	for offset := 0; ; offset += pageSize {
+		budgets, err := s.repo.ListBudgets(ctx, pageSize, offset)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for i := range budgets {
+			records, err := s.repo.GetRecordsByUserID(ctx, budgets[i].UserID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			status := evaluateBudget(&budgets[i], records, period)
+			if items := status.exceeded(nil); len(items) > 0 {
+				exceeded++
+				s.Alert(ctx, &BudgetCheck{UserID: budgets[i].UserID, Exceeded: items})
+			}
+		}
+
+		if len(budgets) < pageSize {
+			break
+		}
+	}
+
+	if exceeded > 0 {
+		log.Info("budgets exceeded", slog.Int("users", exceeded))
+	}
+
+	return nil
+}
+
+// Alert уведомляет о превышенных лимитах по всем каналам, не чаще раза в месяц на лимит и канал.
+// Отметка ставится до отправки и снимается при ошибке канала
+func (s *BudgetService) Alert(ctx context.Context, check *BudgetCheck) {
+	log := s.log.With(slog.String("operation", "budgetService.Alert"), slog.String("user_id", check.UserID))
+
+	for _, item := range check.Exceeded {
+		message := notify.BudgetAlert{
+			UserID:    check.UserID,
+			Period:    item.Period,
+			Category:  item.Category,
+			Limit:     item.Limit,
+			Projected: item.Projected,
+		}
+
+		for _, notifier := range s.notifiers {
+			alert := &entity.BudgetAlert{
+				UserID:    check.UserID,
+				Period:    item.Period,
+				Category:  item.Category,
+				Channel:   notifier.Name(),
+				Limit:     item.Limit,
+				Projected: item.Projected,
+				SentAt:    s.config.Clock.Now(),
+			}
+
+			claimed, err := s.repo.ClaimBudgetAlert(ctx, alert)
+			if err != nil {
+				log.Error("failed to claim budget alert", slog.Any("error", err))
+				continue
+			}
+			if !claimed {
+				continue
+			}
+
+			if err := notifier.Notify(ctx, message); err != nil {
+				log.Error("failed to send budget alert", slog.String("channel", notifier.Name()), slog.Any("error", err))
+
+				if err := s.repo.ReleaseBudgetAlert(ctx, alert); err != nil {
+					log.Error("failed to release budget alert", slog.Any("error", err))
+				}
+			}
+		}
+	}
+}
+
+// checkBudget проверяет записанную подписку против бюджета. Создание сверх бюджета с Enforce
+// отклоняется, в остальных случаях превышение возвращается предупреждением.
+// Вызывается в транзакции записи, уведомления отправляются после ее фиксации
+func (s *RecordService) checkBudget(ctx context.Context, result *WriteResult, create bool) (*BudgetCheck, error) {
+	if s.config.Budgets == nil {
+		return nil, nil
+	}
+
+	check, err := s.config.Budgets.CheckRecord(ctx, result.Record)
+	if err != nil {
+		return nil, NewInternalError(CodeBudgetFailed, ErrBudgetFailed, err)
+	}
+	if check == nil || len(check.Exceeded) == 0 {
+		return nil, nil
+	}
+
+	first := check.Exceeded[0]
+
+	if create && check.Enforce {
+		return nil, NewConflictError(CodeBudgetExceeded, "subscription exceeds user budget", nil).
+			WithMeta("period", first.Period.Format("01-2006")).
+			WithMeta("category", first.Category).
+			WithMeta("limit", first.Limit).
+			WithMeta("projected", first.Projected)
+	}
+
+	scope := "projected cost"
+	if first.Category != "" {
+		scope = fmt.Sprintf("projected %s cost", first.Category)
+	}
+
+	result.Warnings = append(result.Warnings, Warning{
+		Code:     CodeBudgetExceeded,
+		Message:  fmt.Sprintf("%s %d for %s exceeds budget %d", scope, first.Projected, first.Period.Format("01-2006"), first.Limit),
+		RecordID: result.Record.ID,
+	})
+
+	return check, nil
+}
+
+// alertBudgets отправляет уведомления по проверкам, собранным в зафиксированной транзакции
+func (s *RecordService) alertBudgets(ctx context.Context, checks []*BudgetCheck) {
+	for _, check := range checks {
+		if check != nil {
+			s.config.Budgets.Alert(ctx, check)
+		}
+	}
+}
+
+func newBudget(input BudgetInput) (*entity.Budget, error) {
+	var violations []FieldError
+
+	add := func(field, code, message string) {
+		violations = append(violations, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	userID := normalizeUserID(input.UserID)
+	if !uuidPattern.MatchString(userID) {
+		add("user_id", "uuid", "must be a valid UUID")
+	}
+
+	if input.MonthlyLimit < 0 {
+		add("monthly_limit", "min", "must not be negative")
+	}
+
+	budget := &entity.Budget{
+		UserID:       userID,
+		MonthlyLimit: input.MonthlyLimit,
+		Enforce:      input.Enforce,
+	}
+
+	categories := make([]string, 0, len(input.CategoryLimits))
+	for category := range input.CategoryLimits {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	seen := make(map[string]bool, len(categories))
+
+	for _, raw := range categories {
+		category := normalizeCategory(raw)
+		field := "category_limits." + raw
+
+		switch {
+		case category == "":
+			add(field, "required", "category must not be blank")
+			continue
+		case seen[category]:
+			add(field, "duplicate", fmt.Sprintf("category %q is listed more than once", category))
+			continue
+		case input.CategoryLimits[raw] < 0:
+			add(field, "min", "must not be negative")
+			continue
+		}
+
+		seen[category] = true
+		budget.CategoryLimits = append(budget.CategoryLimits, entity.BudgetCategoryLimit{
+			Category:     category,
+			MonthlyLimit: input.CategoryLimits[raw],
+		})
+	}
+
+	if len(violations) > 0 {
+		return nil, NewValidationError("invalid budget", violations...)
+	}
+
+	return budget, nil
+}
+
+// evaluateBudget считает прогноз за месяц, начинающийся с period
+func evaluateBudget(budget *entity.Budget, records []entity.Record, period time.Time) *BudgetStatus {
+	next := period.AddDate(0, 1, 0)
+
+	total := 0
+	byCategory := make(map[string]int)
+
+	for _, record := range records {
+		if len(billing.ChargeDates(truncateToDate(record.CreatedAt), truncateToDate(record.ExpiresAt), period, next)) == 0 {
+			continue
+		}
+
+		total += record.Price
+		byCategory[record.Category] += record.Price
+	}
+
+	status := &BudgetStatus{
+		UserID: budget.UserID,
+		Period: period,
+		Total: BudgetUsage{
+			Limit:     budget.MonthlyLimit,
+			Projected: total,
+			Exceeded:  total > budget.MonthlyLimit,
+		},
+	}
+
+	for _, limit := range budget.CategoryLimits {
+		projected := byCategory[limit.Category]
+		status.Categories = append(status.Categories, BudgetUsage{
+			Category:  limit.Category,
+			Limit:     limit.MonthlyLimit,
+			Projected: projected,
+			Exceeded:  projected > limit.MonthlyLimit,
+		})
+	}
+
+	return status
+}
+
+func normalizeUserID(userID string) string {
+	return strings.ToLower(strings.TrimSpace(userID))
+}
+
+func errBudgetNotFound(err error) *Error {
+	return NewNotFoundError(CodeBudgetNotFound, "budget not found", err)
+}
@@ -0,0 +1,268 @@
+package services_test
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/notify"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+const budgetUser = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+// budgetRepository - хранилище записей в памяти с бюджетами для BudgetService
+type budgetRepository struct {
+	*memory.Repository
+
+	mu      sync.Mutex
+	budgets map[string]entity.Budget
+}
+
+func (r *budgetRepository) CreateBudget(ctx context.Context, budget *entity.Budget) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.budgets[budget.UserID] = *budget
+	return nil
+}
+
+func (r *budgetRepository) GetBudgetByUserID(ctx context.Context, userID string) (*entity.Budget, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	budget, ok := r.budgets[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &budget, nil
+}
+
+func (r *budgetRepository) ListBudgets(ctx context.Context, limit, offset int) ([]entity.Budget, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var budgets []entity.Budget
+	for _, budget := range r.budgets {
+		budgets = append(budgets, budget)
+	}
+
+	return budgets, nil
+}
+
+func (r *budgetRepository) ReplaceBudget(ctx context.Context, budget *entity.Budget) error {
+	return r.CreateBudget(ctx, budget)
+}
+
+func (r *budgetRepository) DeleteBudget(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.budgets, userID)
+	return nil
+}
+
+func (r *budgetRepository) ClaimBudgetAlert(ctx context.Context, alert *entity.BudgetAlert) (bool, error) {
+	return true, nil
+}
+
+func (r *budgetRepository) ReleaseBudgetAlert(ctx context.Context, alert *entity.BudgetAlert) error {
+	return nil
+}
+
+// recordingNotifier запоминает отправленные уведомления. Пока err не nil, уведомления не доставляются
+type recordingNotifier struct {
+	mu       sync.Mutex
+	name     string
+	err      error
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Name() string {
+	if n.name == "" {
+		return "test"
+	}
+
+	return n.name
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, message notify.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.err != nil {
+		return n.err
+	}
+
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func (n *recordingNotifier) sent() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.messages)
+}
+
+// newBudgetedService создает сервис записей с бюджетом 1000 в месяц для budgetUser.
+// Часы стоят на 10.03.2026, подписки из budgetRecord списываются в марте, апреле и мае
+func newBudgetedService(t *testing.T, enforce bool) (*services.RecordService, *budgetRepository, *recordingNotifier) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := clock.NewFake(time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC))
+
+	repo := &budgetRepository{Repository: memory.New(false, nil), budgets: make(map[string]entity.Budget)}
+	if err := repo.CreateBudget(context.Background(), &entity.Budget{UserID: budgetUser, MonthlyLimit: 1000, Enforce: enforce}); err != nil {
+		t.Fatalf("create budget: %v", err)
+	}
+
+	notifier := &recordingNotifier{}
+	budgets := services.NewBudgetService(log, repo, []notify.Notifier{notifier}, services.BudgetConfig{Clock: now})
+
+	svc := services.NewRecordService(log, repo, services.NewRecordValidator(services.ValidationRules{}), services.RecordServiceConfig{
+		Budgets: budgets,
+		Clock:   now,
+	})
+
+	return svc, repo, notifier
+}
+
+func budgetRecord(serviceName string, price int) entity.Record {
+	return entity.Record{
+		ServiceName: serviceName,
+		Price:       price,
+		UserID:      budgetUser,
+		CreatedAt:   time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC),
+		ExpiresAt:   time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func assertBudgetExceeded(t *testing.T, err error) {
+	t.Helper()
+
+	assertCode(t, err, services.CodeBudgetExceeded)
+}
+
+func TestCreateRecordsAtomicRejectsOverBudget(t *testing.T) {
+	svc, repo, notifier := newBudgetedService(t, true)
+	ctx := context.Background()
+
+	result, err := svc.CreateRecords(ctx, []entity.Record{budgetRecord("Netflix", 600), budgetRecord("Spotify", 600)}, true)
+	if err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+	if result.Committed {
+		t.Fatalf("expected rolled back batch, got %+v", result)
+	}
+	// прогноз считается по всему пакету, поэтому бюджет превышает уже первый элемент
+	assertBudgetExceeded(t, result.Items[0].Err)
+
+	records, err := repo.GetRecordsByUserID(ctx, budgetUser)
+	if err != nil {
+		t.Fatalf("get records: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected no records after rollback, got %d", len(records))
+	}
+	if notifier.sent() != 0 {
+		t.Fatalf("expected no alerts for rolled back batch, got %d", notifier.sent())
+	}
+}
+
+func TestPatchRecordsAtomicWarnsOverBudget(t *testing.T) {
+	svc, _, notifier := newBudgetedService(t, true)
+	ctx := context.Background()
+
+	record := budgetRecord("Netflix", 600)
+	written, err := svc.CreateRecord(ctx, &record)
+	if err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+
+	price := 1500
+	result, err := svc.PatchRecords(ctx, []services.RecordPatch{{ID: written.Record.ID, Price: &price}}, true)
+	if err != nil {
+		t.Fatalf("patch records: %v", err)
+	}
+	if !result.Committed {
+		t.Fatalf("expected committed batch, got %+v", result)
+	}
+
+	item := result.Items[0]
+	if item.Err != nil {
+		t.Fatalf("unexpected item error: %v", item.Err)
+	}
+	if len(item.Warnings) != 1 || item.Warnings[0].Code != services.CodeBudgetExceeded {
+		t.Fatalf("expected budget warning, got %+v", item.Warnings)
+	}
+	if notifier.sent() == 0 {
+		t.Fatal("expected budget alert after commit")
+	}
+}
+
+func TestImportRecordsRejectsRowOverBudget(t *testing.T) {
+	svc, repo, notifier := newBudgetedService(t, true)
+	ctx := context.Background()
+
+	rows := []services.ImportRow{
+		{Line: 2, Record: budgetRecord("Netflix", 600)},
+		{Line: 3, Record: budgetRecord("Spotify", 600)},
+	}
+
+	results, err := svc.ImportRecords(ctx, rows, false)
+	if err != nil {
+		t.Fatalf("import records: %v", err)
+	}
+
+	if results[0].Action != services.ImportCreated {
+		t.Fatalf("expected first row created, got %s (%v)", results[0].Action, results[0].Err)
+	}
+	if results[1].Action != services.ImportFailed {
+		t.Fatalf("expected second row failed, got %s", results[1].Action)
+	}
+	assertBudgetExceeded(t, results[1].Err)
+
+	records, err := repo.GetRecordsByUserID(ctx, budgetUser)
+	if err != nil {
+		t.Fatalf("get records: %v", err)
+	}
+	if len(records) != 1 || records[0].ServiceName != "Netflix" {
+		t.Fatalf("expected only the first row imported, got %+v", records)
+	}
+	if notifier.sent() != 0 {
+		t.Fatalf("expected no alerts, got %d", notifier.sent())
+	}
+}
+
+func TestImportRecordsWarnsOverBudget(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		svc, _, notifier := newBudgetedService(t, false)
+
+		rows := []services.ImportRow{{Line: 2, Record: budgetRecord("Netflix", 1500)}}
+
+		results, err := svc.ImportRecords(context.Background(), rows, dryRun)
+		if err != nil {
+			t.Fatalf("dry_run=%t: import records: %v", dryRun, err)
+		}
+
+		if results[0].Action != services.ImportCreated {
+			t.Fatalf("dry_run=%t: expected row created, got %s (%v)", dryRun, results[0].Action, results[0].Err)
+		}
+		if len(results[0].Warnings) != 1 || results[0].Warnings[0].Code != services.CodeBudgetExceeded {
+			t.Fatalf("dry_run=%t: expected budget warning, got %+v", dryRun, results[0].Warnings)
+		}
+
+		if alerted := notifier.sent() > 0; alerted == dryRun {
+			t.Fatalf("dry_run=%t: unexpected alerts sent: %d", dryRun, notifier.sent())
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/billing"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/calendar"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFeedFailed = errors.New("could not build calendar feed")
+	ErrFeedToken  = errors.New("could not issue feed token")
+)
+
+const (
+	CodeInvalidFeedToken = "invalid_feed_token"
+	CodeFeedFailed       = "feed_failed"
+
+	// домен в UID событий, вместе с ID записи дает глобально уникальный и стабильный UID
+	eventUIDDomain = "online-subscriptions"
+)
+
+type CalendarFeedRepository interface {
+	GetOrCreateCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error)
+	GetCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error)
+	RevokeCalendarFeed(ctx context.Context, userID string) error
+}
+
+// CalendarConfig - настройки ленты календаря
+type CalendarConfig struct {
+	// Secret подписывает токены лент, его смена отзывает все токены
+	Secret []byte
+	// даты списаний попадают в ленту за HistoryMonths месяцев назад и HorizonMonths вперед
+	HistoryMonths   int
+	HorizonMonths   int
+	RefreshInterval time.Duration
+	// Clock задает дату, от которой отсчитываются месяцы ленты, nil - системные часы
+	Clock clock.Clock
+}
+
+// CalendarService выдает подписанные токены лент и строит ленту продлений и окончаний подписок
+type CalendarService struct {
+	log     *slog.Logger
+	records Repository
+	feeds   CalendarFeedRepository
+	config  CalendarConfig
+}
+
+func NewCalendarService(log *slog.Logger, records Repository, feeds CalendarFeedRepository, config CalendarConfig) *CalendarService {
+	config.Clock = clock.OrSystem(config.Clock)
+
+	return &CalendarService{
+		log:     log,
+		records: records,
+		feeds:   feeds,
+		config:  config,
+	}
+}
+
+// IssueFeedToken возвращает токен текущей версии ленты пользователя
+func (s *CalendarService) IssueFeedToken(ctx context.Context, userID string) (string, error) {
+	const op = "calendarService.IssueFeedToken"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("issuing feed token...")
+
+	if err := validateUserID(userID); err != nil {
+		return "", err
+	}
+
+	feed, err := s.feeds.GetOrCreateCalendarFeed(ctx, userID)
+	if err != nil {
+		log.Error("failed to get calendar feed", slog.Any("error", err))
+		return "", NewInternalError(CodeFeedFailed, ErrFeedToken, err)
+	}
+
+	log.Info("feed token issued", slog.Int("version", feed.Version))
+
+	return s.sign(userID, feed.Version), nil
+}
+
+// RevokeFeedToken отзывает все выданные токены ленты. Следующий IssueFeedToken выдаст новый токен
+func (s *CalendarService) RevokeFeedToken(ctx context.Context, userID string) error {
+	const op = "calendarService.RevokeFeedToken"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("revoking feed token...")
+
+	if err := validateUserID(userID); err != nil {
+		return err
+	}
+
+	if err := s.feeds.RevokeCalendarFeed(ctx, userID); err != nil {
+		log.Error("failed to revoke calendar feed", slog.Any("error", err))
+		return NewInternalError(CodeFeedFailed, ErrFeedToken, err)
+	}
+
+	log.Info("feed token revoked")
+
+	return nil
+}
+
+// Feed проверяет токен и строит календарь: окончание каждой подписки и даты ежемесячных списаний
+func (s *CalendarService) Feed(ctx context.Context, userID, token string) (*calendar.Calendar, error) {
+	const op = "calendarService.Feed"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("building calendar feed...")
+
+	if err := s.verify(ctx, userID, token); err != nil {
+		log.Warn("feed token rejected", slog.Any("error", err))
+		return nil, err
+	}
+
+	records, err := s.records.GetRecordsByUserID(ctx, userID)
+	if err != nil {
+		log.Error("failed to get records", slog.Any("error", err))
+		return nil, NewInternalError(CodeFeedFailed, ErrFeedFailed, err)
+	}
+
+	now := s.config.Clock.Now()
+	today := truncateToDate(now)
+	from := today.AddDate(0, -s.config.HistoryMonths, 0)
+	to := today.AddDate(0, s.config.HorizonMonths, 0)
+
+	cal := &calendar.Calendar{
+		ProdID:          "-//" + eventUIDDomain + "//calendar feed//EN",
+		Name:            "Subscriptions",
+		RefreshInterval: s.config.RefreshInterval,
+		Stamp:           now,
+	}
+
+	for _, record := range records {
+		cal.Events = append(cal.Events, calendar.Event{
+			UID:         fmt.Sprintf("record-%d-expires@%s", record.ID, eventUIDDomain),
+			Date:        record.ExpiresAt,
+			Summary:     fmt.Sprintf("%s subscription expires", record.ServiceName),
+			Description: fmt.Sprintf("Subscription %d to %s ends.", record.ID, record.ServiceName),
+		})
+
+		for _, date := range billing.ChargeDates(record.CreatedAt, record.ExpiresAt, from, to) {
+			cal.Events = append(cal.Events, calendar.Event{
+				UID:         fmt.Sprintf("record-%d-charge-%s@%s", record.ID, date.Format("20060102"), eventUIDDomain),
+				Date:        date,
+				Summary:     fmt.Sprintf("%s payment: %d", record.ServiceName, record.Price),
+				Description: fmt.Sprintf("Monthly charge for subscription %d to %s.", record.ID, record.ServiceName),
+			})
+		}
+	}
+
+	log.Info("calendar feed built", slog.Int("events", len(cal.Events)))
+
+	return cal, nil
+}
+
+// токен: v<версия ленты>.<HMAC-SHA256(user_id, версия)>
+func (s *CalendarService) sign(userID string, version int) string {
+	mac := hmac.New(sha256.New, s.config.Secret)
+	mac.Write([]byte("calendar-feed:" + userID + ":" + strconv.Itoa(version)))
+
+	return "v" + strconv.Itoa(version) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *CalendarService) verify(ctx context.Context, userID, token string) error {
+	invalid := NewForbiddenError(CodeInvalidFeedToken, "invalid or revoked feed token", nil)
+
+	versionPart, _, ok := strings.Cut(token, ".")
+	if !ok || !strings.HasPrefix(versionPart, "v") {
+		return invalid
+	}
+
+	version, err := strconv.Atoi(versionPart[1:])
+	if err != nil || !hmac.Equal([]byte(token), []byte(s.sign(userID, version))) {
+		return invalid
+	}
+
+	feed, err := s.feeds.GetCalendarFeed(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return invalid
+	}
+	if err != nil {
+		return NewInternalError(CodeFeedFailed, ErrFeedFailed, err)
+	}
+
+	if feed.Version != version {
+		return invalid
+	}
+
+	return nil
+}
+
+func validateUserID(userID string) error {
+	if !uuidPattern.MatchString(userID) {
+		return NewValidationError("request validation failed", FieldError{
+			Field:   "id",
+			Code:    "uuid",
+			Message: "must be a valid UUID",
+		})
+	}
+
+	return nil
+}
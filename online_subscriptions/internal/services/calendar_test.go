@@ -0,0 +1,228 @@
+package services_test
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/calendar"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const otherUser = "5c2a3e1f-7d4b-4f6a-8e9c-1b2d3f4a5b6c"
+
+// calendarFeeds - версии лент в памяти, отзыв увеличивает версию
+type calendarFeeds map[string]*entity.CalendarFeed
+
+func (f calendarFeeds) GetOrCreateCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error) {
+	if _, ok := f[userID]; !ok {
+		f[userID] = &entity.CalendarFeed{UserID: userID, Version: 1}
+	}
+
+	return f[userID], nil
+}
+
+func (f calendarFeeds) GetCalendarFeed(ctx context.Context, userID string) (*entity.CalendarFeed, error) {
+	feed, ok := f[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return feed, nil
+}
+
+func (f calendarFeeds) RevokeCalendarFeed(ctx context.Context, userID string) error {
+	if feed, ok := f[userID]; ok {
+		feed.Version++
+	}
+
+	return nil
+}
+
+// newCalendarService создает ленту на месяц назад и два вперед с часами на 10.03.2025.
+// У recordUser две подписки: действующая с 31 января и закончившаяся 5 февраля
+func newCalendarService(t *testing.T) (*services.CalendarService, *clock.Fake) {
+	t.Helper()
+
+	repo := memory.New(false, nil)
+	for _, record := range []entity.Record{
+		{ServiceName: "Netflix", Price: 100, UserID: recordUser, CreatedAt: day(2025, time.January, 31), ExpiresAt: day(2025, time.June, 30)},
+		{ServiceName: "Spotify", Price: 200, UserID: recordUser, CreatedAt: day(2024, time.October, 5), ExpiresAt: day(2025, time.February, 5)},
+		{ServiceName: "Okko", Price: 300, UserID: otherUser, CreatedAt: day(2025, time.March, 1), ExpiresAt: day(2025, time.April, 1)},
+	} {
+		if err := repo.SaveRecord(context.Background(), &record); err != nil {
+			t.Fatalf("save record: %v", err)
+		}
+	}
+
+	now := clock.NewFake(time.Date(2025, time.March, 10, 14, 30, 0, 0, time.UTC))
+
+	svc := services.NewCalendarService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, calendarFeeds{}, services.CalendarConfig{
+		Secret:        []byte("calendar-secret"),
+		HistoryMonths: 1,
+		HorizonMonths: 2,
+		Clock:         now,
+	})
+
+	return svc, now
+}
+
+func feed(t *testing.T, svc *services.CalendarService) *calendar.Calendar {
+	t.Helper()
+
+	ctx := context.Background()
+
+	token, err := svc.IssueFeedToken(ctx, recordUser)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+
+	cal, err := svc.Feed(ctx, recordUser, token)
+	if err != nil {
+		t.Fatalf("feed: %v", err)
+	}
+
+	return cal
+}
+
+// feedEvent - UID и дата события
+type feedEvent struct {
+	UID  string
+	Date string
+}
+
+func feedEvents(cal *calendar.Calendar) []feedEvent {
+	events := make([]feedEvent, len(cal.Events))
+	for i, event := range cal.Events {
+		events[i] = feedEvent{event.UID, event.Date.Format("2006-01-02")}
+	}
+
+	return events
+}
+
+func TestCalendarFeedEvents(t *testing.T) {
+	svc, now := newCalendarService(t)
+
+	cal := feed(t, svc)
+
+	if !cal.Stamp.Equal(now.Now()) {
+		t.Fatalf("stamp: got %s, want %s", cal.Stamp, now.Now())
+	}
+
+	// списания попадают в окно [10.02, 10.05), окончание подписки - всегда; 31-е число
+	// переносится на последний день месяца, подписки другого пользователя в ленте нет
+	want := []feedEvent{
+		{"record-1-expires@online-subscriptions", "2025-06-30"},
+		{"record-1-charge-20250228@online-subscriptions", "2025-02-28"},
+		{"record-1-charge-20250331@online-subscriptions", "2025-03-31"},
+		{"record-1-charge-20250430@online-subscriptions", "2025-04-30"},
+		{"record-2-expires@online-subscriptions", "2025-02-05"},
+	}
+	if got := feedEvents(cal); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	if summary := cal.Events[1].Summary; summary != "Netflix payment: 100" {
+		t.Fatalf("charge summary: got %q", summary)
+	}
+}
+
+func TestCalendarFeedUIDsStable(t *testing.T) {
+	svc, now := newCalendarService(t)
+
+	before := make(map[string]string)
+	for _, event := range feedEvents(feed(t, svc)) {
+		before[event.UID] = event.Date
+	}
+
+	// через месяц окно сдвигается: февральское списание уходит, майское появляется,
+	// остальные события сохраняют UID и календарь обновляет их на месте
+	now.Advance(31 * 24 * time.Hour)
+
+	after := feedEvents(feed(t, svc))
+
+	want := []feedEvent{
+		{"record-1-expires@online-subscriptions", "2025-06-30"},
+		{"record-1-charge-20250331@online-subscriptions", "2025-03-31"},
+		{"record-1-charge-20250430@online-subscriptions", "2025-04-30"},
+		{"record-1-charge-20250531@online-subscriptions", "2025-05-31"},
+		{"record-2-expires@online-subscriptions", "2025-02-05"},
+	}
+	if !reflect.DeepEqual(after, want) {
+		t.Fatalf("events:\ngot  %+v\nwant %+v", after, want)
+	}
+
+	for _, event := range after {
+		if date, ok := before[event.UID]; ok && date != event.Date {
+			t.Fatalf("%s moved from %s to %s", event.UID, date, event.Date)
+		}
+	}
+}
+
+func TestCalendarFeedToken(t *testing.T) {
+	svc, _ := newCalendarService(t)
+	ctx := context.Background()
+
+	token, err := svc.IssueFeedToken(ctx, recordUser)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+
+	// повторная выдача без отзыва возвращает тот же токен
+	if again, _ := svc.IssueFeedToken(ctx, recordUser); again != token {
+		t.Fatalf("token changed without revoke: %s, %s", token, again)
+	}
+
+	otherToken, err := svc.IssueFeedToken(ctx, otherUser)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+
+	rejected := []struct {
+		name   string
+		userID string
+		token  string
+	}{
+		{"empty", recordUser, ""},
+		{"garbage", recordUser, "not-a-token"},
+		{"tampered", recordUser, token + "A"},
+		{"another version", recordUser, "v2" + token[2:]},
+		{"another user", recordUser, otherToken},
+	}
+
+	for _, tt := range rejected {
+		if _, err := svc.Feed(ctx, tt.userID, tt.token); err == nil {
+			t.Fatalf("%s: token accepted", tt.name)
+		} else {
+			assertCode(t, err, services.CodeInvalidFeedToken)
+		}
+	}
+
+	// отзыв делает старый токен недействительным, новый токен работает
+	if err := svc.RevokeFeedToken(ctx, recordUser); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	_, err = svc.Feed(ctx, recordUser, token)
+	assertCode(t, err, services.CodeInvalidFeedToken)
+
+	renewed, err := svc.IssueFeedToken(ctx, recordUser)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	if renewed == token {
+		t.Fatal("token not changed after revoke")
+	}
+	if _, err := svc.Feed(ctx, recordUser, renewed); err != nil {
+		t.Fatalf("feed with renewed token: %v", err)
+	}
+
+	_, err = svc.IssueFeedToken(ctx, "not-a-uuid")
+	assertFieldError(t, err, "id", "uuid")
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind классифицирует ошибку сервиса, по нему транспортный слой выбирает статус ответа
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindForbidden
+	KindUnauthorized
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindValidation:
+		return "validation"
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindForbidden:
+		return "forbidden"
+	case KindUnauthorized:
+		return "unauthorized"
+	default:
+		return "internal"
+	}
+}
+
+// стабильные коды ошибок, на них могут опираться клиенты API
+const (
+	CodeValidationFailed = "validation_failed"
+	CodeMalformedRequest = "malformed_request"
+	CodeRecordNotFound   = "record_not_found"
+	CodeRouteNotFound    = "route_not_found"
+	CodeConflict         = "conflict"
+	CodeForbidden        = "forbidden"
+	CodeUnauthorized     = "unauthorized"
+	CodeInternal         = "internal_error"
+	CodeGetFailed        = "get_failed"
+	CodeCreateFailed     = "create_failed"
+	CodeUpdateFailed     = "update_failed"
+	CodeDeleteFailed     = "delete_failed"
+	CodeSumFailed        = "sum_failed"
+	CodeExportFailed     = "export_failed"
+)
+
+// FieldError описывает нарушение, относящееся к конкретному полю запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error - типизированная ошибка сервиса
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Meta содержит дополнительные данные, которые безопасно отдавать клиенту
+	Meta map[string]any
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+
+	b.WriteString(e.Message)
+
+	for _, f := range e.Fields {
+		b.WriteString(fmt.Sprintf("; %s: %s", f.Field, f.Message))
+	}
+
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithMeta добавляет к ошибке публичные данные
+func (e *Error) WithMeta(key string, value any) *Error {
+	if e.Meta == nil {
+		e.Meta = make(map[string]any)
+	}
+	e.Meta[key] = value
+
+	return e
+}
+
+func NewValidationError(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: CodeValidationFailed, Message: message, Fields: fields}
+}
+
+func NewNotFoundError(code, message string, err error) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message, Err: err}
+}
+
+func NewConflictError(code, message string, err error) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message, Err: err}
+}
+
+func NewForbiddenError(code, message string, err error) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message, Err: err}
+}
+
+func NewUnauthorizedError(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+// NewInternalError оборачивает причину в sentinel-ошибку операции, сообщение клиенту берется из sentinel
+func NewInternalError(code string, sentinel, err error) *Error {
+	return &Error{Kind: KindInternal, Code: code, Message: sentinel.Error(), Err: fmt.Errorf("%w: %v", sentinel, err)}
+}
+
+// AsError извлекает типизированную ошибку из цепочки
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+
+	return nil, false
+}
+
+// KindOf возвращает вид ошибки, для нетипизированных ошибок - KindInternal
+func KindOf(err error) Kind {
+	if e, ok := AsError(err); ok {
+		return e.Kind
+	}
+
+	return KindInternal
+}
+
+func errRecordNotFound(err error) *Error {
+	return NewNotFoundError(CodeRecordNotFound, "record not found", err)
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// EventType - тип события жизненного цикла записи
+type EventType string
+
+const (
+	EventRecordCreated EventType = "record.created"
+	EventRecordUpdated EventType = "record.updated"
+	EventRecordDeleted EventType = "record.deleted"
+	EventRecordExpired EventType = "record.expired"
+)
+
+var EventTypes = []EventType{EventRecordCreated, EventRecordUpdated, EventRecordDeleted, EventRecordExpired}
+
+func (t EventType) Valid() bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Event - событие, которое получают подписчики. ID уникален и одинаков для всех получателей
+type Event struct {
+	ID         string        `json:"id"`
+	Type       EventType     `json:"type"`
+	OccurredAt time.Time     `json:"occurred_at"`
+	Record     entity.Record `json:"record"`
+}
+
+func newEvent(eventType EventType, record entity.Record, occurredAt time.Time) Event {
+	return Event{
+		ID:         newEventID(),
+		Type:       eventType,
+		OccurredAt: occurredAt.UTC(),
+		Record:     record,
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func (e Event) outbox() (entity.OutboxEvent, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return entity.OutboxEvent{}, err
+	}
+
+	return entity.OutboxEvent{
+		EventID:     e.ID,
+		Type:        string(e.Type),
+		AggregateID: strconv.FormatUint(uint64(e.Record.ID), 10),
+		Payload:     string(payload),
+	}, nil
+}
+
+type OutboxWriter interface {
+	AppendOutbox(ctx context.Context, events []entity.OutboxEvent) error
+}
+
+// eventRepository пишет событие в outbox в той же транзакции, что и изменение записи:
+// откаченное изменение не порождает событие, а зафиксированное не теряет его при падении
+type eventRepository struct {
+	Repository
+	outbox OutboxWriter
+	clock  clock.Clock
+}
+
+// WithEvents оборачивает репозиторий так, что все изменения записей, включая пакетные операции,
+// импорт и слияние пересечений, попадают в outbox. clk задает время событий, nil - системные часы
+func WithEvents(repo Repository, outbox OutboxWriter, clk clock.Clock) Repository {
+	return &eventRepository{Repository: repo, outbox: outbox, clock: clock.OrSystem(clk)}
+}
+
+type inTransactionKey struct{}
+
+func (r *eventRepository) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.Repository.Transaction(ctx, func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, inTransactionKey{}, true))
+	})
+}
+
+// write выполняет изменение и запись событий атомарно: в открытой транзакции или в новой
+func (r *eventRepository) write(ctx context.Context, change func(ctx context.Context) ([]Event, error)) error {
+	apply := func(ctx context.Context) error {
+		events, err := change(ctx)
+		if err != nil {
+			return err
+		}
+
+		rows := make([]entity.OutboxEvent, len(events))
+		for i, event := range events {
+			if rows[i], err = event.outbox(); err != nil {
+				return fmt.Errorf("could not encode event: %w", err)
+			}
+		}
+
+		return r.outbox.AppendOutbox(ctx, rows)
+	}
+
+	if inTransaction, _ := ctx.Value(inTransactionKey{}).(bool); inTransaction {
+		return apply(ctx)
+	}
+
+	return r.Transaction(ctx, apply)
+}
+
+func (r *eventRepository) SaveRecord(ctx context.Context, record *entity.Record) error {
+	return r.write(ctx, func(ctx context.Context) ([]Event, error) {
+		if err := r.Repository.SaveRecord(ctx, record); err != nil {
+			return nil, err
+		}
+
+		return []Event{newEvent(EventRecordCreated, *record, r.clock.Now())}, nil
+	})
+}
+
+func (r *eventRepository) SaveRecords(ctx context.Context, records []entity.Record, batchSize int) error {
+	return r.write(ctx, func(ctx context.Context) ([]Event, error) {
+		if err := r.Repository.SaveRecords(ctx, records, batchSize); err != nil {
+			return nil, err
+		}
+
+		now := r.clock.Now()
+
+		events := make([]Event, len(records))
+		for i, record := range records {
+			events[i] = newEvent(EventRecordCreated, record, now)
+		}
+
+		return events, nil
+	})
+}
+
+func (r *eventRepository) UpdateRecord(ctx context.Context, record *entity.Record) error {
+	return r.write(ctx, func(ctx context.Context) ([]Event, error) {
+		if err := r.Repository.UpdateRecord(ctx, record); err != nil {
+			return nil, err
+		}
+
+		return []Event{newEvent(EventRecordUpdated, *record, r.clock.Now())}, nil
+	})
+}
+
+func (r *eventRepository) DeleteRecordByID(ctx context.Context, id uint) error {
+	return r.write(ctx, func(ctx context.Context) ([]Event, error) {
+		// удаленную запись отдаем подписчикам целиком, поэтому читаем ее до удаления
+		record, err := r.Repository.GetRecordByID(ctx, id)
+		if err != nil {
+			return nil, r.Repository.DeleteRecordByID(ctx, id)
+		}
+
+		if err := r.Repository.DeleteRecordByID(ctx, id); err != nil {
+			return nil, err
+		}
+
+		return []Event{newEvent(EventRecordDeleted, *record, r.clock.Now())}, nil
+	})
+}
+
+type ExpiredEventsRepository interface {
+	OutboxWriter
+	FindRecordsExpiringBetween(ctx context.Context, from, to time.Time) ([]entity.Record, error)
+}
+
+// ExpiredEvents пишет в outbox record.expired для подписок, закончившихся за последние lookbackDays дней.
+// ID события детерминирован, поэтому повторные запуски не создают повторных событий
+type ExpiredEvents struct {
+	log          *slog.Logger
+	repo         ExpiredEventsRepository
+	lookbackDays int
+	clock        clock.Clock
+}
+
+// NewExpiredEvents создает задачу событий об окончании подписок, clk == nil - системные часы
+func NewExpiredEvents(log *slog.Logger, repo ExpiredEventsRepository, lookbackDays int, clk clock.Clock) *ExpiredEvents {
+	return &ExpiredEvents{log: log, repo: repo, lookbackDays: lookbackDays, clock: clock.OrSystem(clk)}
+}
+
+func (e *ExpiredEvents) Publish(ctx context.Context) error {
+	const op = "expiredEvents.Publish"
+
+	today := truncateToDate(e.clock.Now())
+
+	records, err := e.repo.FindRecordsExpiringBetween(ctx, today.AddDate(0, 0, -e.lookbackDays), today)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows := make([]entity.OutboxEvent, len(records))
+	for i, record := range records {
+		event := Event{
+			ID:         fmt.Sprintf("expired-%d-%s", record.ID, record.ExpiresAt.Format("20060102")),
+			Type:       EventRecordExpired,
+			OccurredAt: record.ExpiresAt,
+			Record:     record,
+		}
+
+		if rows[i], err = event.outbox(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := e.repo.AppendOutbox(ctx, rows); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	e.log.Debug("expired events checked", slog.String("operation", op), slog.Int("records", len(records)))
+
+	return nil
+}
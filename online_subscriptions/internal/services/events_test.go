@@ -0,0 +1,151 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var errOutbox = errors.New("outbox is unavailable")
+
+// fakeOutbox запоминает записанные события. Вызов номер failOn (с единицы) возвращает errOutbox
+type fakeOutbox struct {
+	failOn int
+	calls  int
+	events []entity.OutboxEvent
+}
+
+func (f *fakeOutbox) AppendOutbox(ctx context.Context, events []entity.OutboxEvent) error {
+	f.calls++
+	if f.calls == f.failOn {
+		return errOutbox
+	}
+
+	f.events = append(f.events, events...)
+
+	return nil
+}
+
+func (f *fakeOutbox) types() []string {
+	types := make([]string, len(f.events))
+	for i, event := range f.events {
+		types[i] = event.Type
+	}
+
+	return types
+}
+
+// newEventRepository возвращает хранилище с одной записью и обертку над ним, пишущую в outbox
+func newEventRepository(t *testing.T, outbox *fakeOutbox) (services.Repository, *memory.Repository, entity.Record) {
+	t.Helper()
+
+	repo := memory.New(false, nil)
+
+	record := entity.Record{ServiceName: "Netflix", Price: 100, UserID: recordUser, CreatedAt: day(2025, time.January, 1)}
+	if err := repo.SaveRecord(context.Background(), &record); err != nil {
+		t.Fatalf("save record: %v", err)
+	}
+
+	return services.WithEvents(repo, outbox, clock.NewFake(day(2025, time.March, 10))), repo, record
+}
+
+func TestEventsWrittenWithChanges(t *testing.T) {
+	outbox := &fakeOutbox{}
+	events, _, record := newEventRepository(t, outbox)
+	ctx := context.Background()
+
+	created := entity.Record{ServiceName: "Spotify", Price: 200, UserID: recordUser, CreatedAt: day(2025, time.February, 1)}
+	if err := events.SaveRecord(ctx, &created); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	record.Price = 150
+	if err := events.UpdateRecord(ctx, &record); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if err := events.DeleteRecordByID(ctx, record.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	want := []string{string(services.EventRecordCreated), string(services.EventRecordUpdated), string(services.EventRecordDeleted)}
+	if !reflect.DeepEqual(outbox.types(), want) {
+		t.Fatalf("events: got %v, want %v", outbox.types(), want)
+	}
+
+	// удаленная запись попадает в событие целиком, время события берется из часов
+	var deleted services.Event
+	if err := json.Unmarshal([]byte(outbox.events[2].Payload), &deleted); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if deleted.Record.ServiceName != "Netflix" || deleted.Record.Price != 150 || !deleted.OccurredAt.Equal(day(2025, time.March, 10)) {
+		t.Fatalf("deleted event: got %+v", deleted)
+	}
+	if outbox.events[2].EventID != deleted.ID || outbox.events[2].AggregateID != "1" {
+		t.Fatalf("outbox row: got %+v", outbox.events[2])
+	}
+}
+
+func TestFailedOutboxRollsBackChange(t *testing.T) {
+	tests := []struct {
+		name string
+		// failOn - номер записи в outbox, которая завершится ошибкой
+		failOn int
+		change func(ctx context.Context, repo services.Repository, record entity.Record) error
+	}{
+		{"create", 1, func(ctx context.Context, repo services.Repository, record entity.Record) error {
+			return repo.SaveRecord(ctx, &entity.Record{ServiceName: "Spotify", Price: 200, UserID: recordUser, CreatedAt: day(2025, time.February, 1)})
+		}},
+		{"create batch", 1, func(ctx context.Context, repo services.Repository, record entity.Record) error {
+			return repo.SaveRecords(ctx, []entity.Record{
+				{ServiceName: "Spotify", Price: 200, UserID: recordUser, CreatedAt: day(2025, time.February, 1)},
+				{ServiceName: "Okko", Price: 300, UserID: recordUser, CreatedAt: day(2025, time.February, 1)},
+			}, 100)
+		}},
+		{"update", 1, func(ctx context.Context, repo services.Repository, record entity.Record) error {
+			record.Price = 150
+			return repo.UpdateRecord(ctx, &record)
+		}},
+		{"delete", 1, func(ctx context.Context, repo services.Repository, record entity.Record) error {
+			return repo.DeleteRecordByID(ctx, record.ID)
+		}},
+		{"second change in transaction", 2, func(ctx context.Context, repo services.Repository, record entity.Record) error {
+			// первое изменение и его событие уже записаны, но откатываются вместе со вторым
+			return repo.Transaction(ctx, func(ctx context.Context) error {
+				if err := repo.SaveRecord(ctx, &entity.Record{ServiceName: "Spotify", Price: 200, UserID: recordUser, CreatedAt: day(2025, time.February, 1)}); err != nil {
+					return err
+				}
+
+				record.Price = 150
+				return repo.UpdateRecord(ctx, &record)
+			})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := &fakeOutbox{failOn: tt.failOn}
+			events, repo, record := newEventRepository(t, outbox)
+			ctx := context.Background()
+
+			if err := tt.change(ctx, events, record); !errors.Is(err, errOutbox) {
+				t.Fatalf("got %v, want outbox error", err)
+			}
+
+			records, err := repo.GetRecordsByUserID(ctx, recordUser)
+			if err != nil {
+				t.Fatalf("get records: %v", err)
+			}
+			if len(records) != 1 || !reflect.DeepEqual(records[0], record) {
+				t.Fatalf("records after rollback: got %+v, want only %+v", records, record)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/billing"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+var (
+	ErrForecastFailed    = errors.New("could not build forecast")
+	ErrPriceChangeFailed = errors.New("could not process price change")
+)
+
+const (
+	CodeForecastFailed      = "forecast_failed"
+	CodePriceChangeFailed   = "price_change_failed"
+	CodePriceChangeNotFound = "price_change_not_found"
+	CodePriceChangeExists   = "price_change_exists"
+
+	// MaxForecastMonths ограничивает горизонт прогноза
+	MaxForecastMonths = 60
+)
+
+type ForecastRepository interface {
+	GetRecordByID(ctx context.Context, id uint) (*entity.Record, error)
+	FindActiveRecords(ctx context.Context, from time.Time, userID, serviceName string) ([]entity.Record, error)
+	FindActivePriceChanges(ctx context.Context, from time.Time, userID, serviceName string) ([]entity.PriceChange, error)
+	CreatePriceChange(ctx context.Context, change *entity.PriceChange) error
+	ListRecordPriceChanges(ctx context.Context, recordID uint) ([]entity.PriceChange, error)
+	DeletePriceChange(ctx context.Context, recordID, id uint) error
+}
+
+// ForecastService прогнозирует расходы на подписки по месяцам и ведет запланированные изменения цен
+type ForecastService struct {
+	log   *slog.Logger
+	repo  ForecastRepository
+	clock clock.Clock
+}
+
+// NewForecastService создает сервис прогноза, clk задает текущий месяц прогноза по умолчанию, nil - системные часы
+func NewForecastService(log *slog.Logger, repo ForecastRepository, clk clock.Clock) *ForecastService {
+	return &ForecastService{log: log, repo: repo, clock: clock.OrSystem(clk)}
+}
+
+// ForecastQuery - параметры прогноза. From - первый месяц, нулевой - текущий, фильтры как у суммы за период.
+// AutoRenew считает, что подписки продлеваются после ExpiresAt по последней известной цене
+type ForecastQuery struct {
+	From        time.Time
+	Months      int
+	UserID      string
+	ServiceName string
+	AutoRenew   bool
+}
+
+// ServiceContribution - вклад сервиса в расходы месяца
+type ServiceContribution struct {
+	ServiceName   string `json:"service_name"`
+	Amount        int    `json:"amount"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+// ForecastMonth - прогноз расходов за месяц, сервисы по убыванию вклада
+type ForecastMonth struct {
+	Month    time.Time
+	Total    int
+	Services []ServiceContribution
+}
+
+type Forecast struct {
+	From      time.Time
+	AutoRenew bool
+	Total     int
+	Months    []ForecastMonth
+}
+
+func (s *ForecastService) Forecast(ctx context.Context, query ForecastQuery) (*Forecast, error) {
+	const op = "forecastService.Forecast"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("building forecast...")
+
+	if query.Months < 1 || query.Months > MaxForecastMonths {
+		return nil, NewValidationError("invalid forecast horizon", FieldError{
+			Field:   "months",
+			Code:    "range",
+			Message: "must be between 1 and 60",
+		})
+	}
+
+	if query.From.IsZero() {
+		query.From = s.clock.Now()
+	}
+
+	from := monthStart(query.From)
+	to := from.AddDate(0, query.Months, 0)
+
+	records, err := s.repo.FindActiveRecords(ctx, from, query.UserID, query.ServiceName)
+	if err != nil {
+		log.Error("failed to get records", slog.Any("error", err))
+		return nil, NewInternalError(CodeForecastFailed, ErrForecastFailed, err)
+	}
+
+	changes, err := s.repo.FindActivePriceChanges(ctx, from, query.UserID, query.ServiceName)
+	if err != nil {
+		log.Error("failed to get price changes", slog.Any("error", err))
+		return nil, NewInternalError(CodeForecastFailed, ErrForecastFailed, err)
+	}
+
+	byRecord := make(map[uint][]entity.PriceChange)
+	for _, change := range changes {
+		byRecord[change.RecordID] = append(byRecord[change.RecordID], change)
+	}
+
+	type cell struct {
+		amount, subscriptions int
+	}
+
+	months := make([]map[string]*cell, query.Months)
+	for i := range months {
+		months[i] = make(map[string]*cell)
+	}
+
+	for _, record := range records {
+		end := truncateToDate(record.ExpiresAt)
+		if query.AutoRenew {
+			end = to
+		}
+
+		for _, date := range billing.ChargeDates(truncateToDate(record.CreatedAt), end, from, to) {
+			month := months[billing.MonthsBetween(from, date)]
+
+			c, ok := month[record.ServiceName]
+			if !ok {
+				c = &cell{}
+				month[record.ServiceName] = c
+			}
+
+			c.amount += priceAt(record, byRecord[record.ID], date)
+			c.subscriptions++
+		}
+	}
+
+	forecast := &Forecast{From: from, AutoRenew: query.AutoRenew, Months: make([]ForecastMonth, query.Months)}
+
+	for i, contributions := range months {
+		month := ForecastMonth{Month: from.AddDate(0, i, 0), Services: make([]ServiceContribution, 0, len(contributions))}
+
+		for name, c := range contributions {
+			month.Total += c.amount
+			month.Services = append(month.Services, ServiceContribution{ServiceName: name, Amount: c.amount, Subscriptions: c.subscriptions})
+		}
+
+		sort.Slice(month.Services, func(a, b int) bool {
+			if month.Services[a].Amount != month.Services[b].Amount {
+				return month.Services[a].Amount > month.Services[b].Amount
+			}
+			return month.Services[a].ServiceName < month.Services[b].ServiceName
+		})
+
+		forecast.Total += month.Total
+		forecast.Months[i] = month
+	}
+
+	log.Info("forecast built", slog.Int("records", len(records)), slog.Int("total", forecast.Total))
+
+	return forecast, nil
+}
+
+// priceAt возвращает цену подписки на дату: последнее вступившее в силу изменение или исходную цену.
+// changes отсортированы по дате
+func priceAt(record entity.Record, changes []entity.PriceChange, date time.Time) int {
+	price := record.Price
+
+	for _, change := range changes {
+		if truncateToDate(change.EffectiveFrom).After(date) {
+			break
+		}
+		price = change.Price
+	}
+
+	return price
+}
+
+// SchedulePriceChange планирует новую цену подписки с даты effectiveFrom
+func (s *ForecastService) SchedulePriceChange(ctx context.Context, recordID uint, price int, effectiveFrom time.Time) (*entity.PriceChange, error) {
+	const op = "forecastService.SchedulePriceChange"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("scheduling price change...")
+
+	record, err := s.repo.GetRecordByID(ctx, recordID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errRecordNotFound(err)
+		}
+
+		log.Error("failed to get record", slog.Any("error", err))
+		return nil, NewInternalError(CodePriceChangeFailed, ErrPriceChangeFailed, err)
+	}
+
+	effectiveFrom = truncateToDate(effectiveFrom)
+
+	var violations []FieldError
+
+	if price < 0 {
+		violations = append(violations, FieldError{Field: "price", Code: "min", Message: "must not be negative"})
+	}
+
+	if !effectiveFrom.After(truncateToDate(record.CreatedAt)) {
+		violations = append(violations, FieldError{Field: "effective_from", Code: "invalid_range", Message: "must be after subscription start date"})
+	}
+
+	if len(violations) > 0 {
+		return nil, NewValidationError("invalid price change", violations...)
+	}
+
+	change := &entity.PriceChange{RecordID: record.ID, Price: price, EffectiveFrom: effectiveFrom}
+
+	if err := s.repo.CreatePriceChange(ctx, change); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, NewConflictError(CodePriceChangeExists, "price change for this date already exists", err)
+		}
+
+		log.Error("failed to save price change", slog.Any("error", err))
+		return nil, NewInternalError(CodePriceChangeFailed, ErrPriceChangeFailed, err)
+	}
+
+	log.Info("price change scheduled", slog.Uint64("record_id", uint64(record.ID)))
+
+	return change, nil
+}
+
+func (s *ForecastService) ListPriceChanges(ctx context.Context, recordID uint) ([]entity.PriceChange, error) {
+	const op = "forecastService.ListPriceChanges"
+
+	log := s.log.With(slog.String("operation", op))
+
+	if _, err := s.repo.GetRecordByID(ctx, recordID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errRecordNotFound(err)
+		}
+
+		log.Error("failed to get record", slog.Any("error", err))
+		return nil, NewInternalError(CodePriceChangeFailed, ErrPriceChangeFailed, err)
+	}
+
+	changes, err := s.repo.ListRecordPriceChanges(ctx, recordID)
+	if err != nil {
+		log.Error("failed to list price changes", slog.Any("error", err))
+		return nil, NewInternalError(CodePriceChangeFailed, ErrPriceChangeFailed, err)
+	}
+
+	return changes, nil
+}
+
+func (s *ForecastService) DeletePriceChange(ctx context.Context, recordID, id uint) error {
+	const op = "forecastService.DeletePriceChange"
+
+	log := s.log.With(slog.String("operation", op))
+
+	if err := s.repo.DeletePriceChange(ctx, recordID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return NewNotFoundError(CodePriceChangeNotFound, "price change not found", err)
+		}
+
+		log.Error("failed to delete price change", slog.Any("error", err))
+		return NewInternalError(CodePriceChangeFailed, ErrPriceChangeFailed, err)
+	}
+
+	log.Info("price change deleted")
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+var (
+	ErrImportFailed = errors.New("could not import records")
+
+	// errDryRun откатывает транзакцию пробного импорта
+	errDryRun = errors.New("dry run")
+)
+
+const CodeImportFailed = "import_failed"
+
+// ImportAction - что импорт сделал (или сделал бы в пробном режиме) со строкой
+type ImportAction string
+
+const (
+	ImportCreated   ImportAction = "created"
+	ImportUpdated   ImportAction = "updated"
+	ImportMerged    ImportAction = "merged"
+	ImportUnchanged ImportAction = "unchanged"
+	ImportFailed    ImportAction = "failed"
+)
+
+// ImportRow - строка файла импорта. Err заполняется, если строку не удалось разобрать
+type ImportRow struct {
+	Line   int
+	Record entity.Record
+	Err    error
+}
+
+type ImportRowResult struct {
+	Line     int
+	Action   ImportAction
+	RecordID uint
+	Warnings []Warning
+	Err      error
+	// budget - превышение бюджета строкой, уведомление отправляется после фиксации импорта
+	budget *BudgetCheck
+}
+
+// ImportRecords импортирует пачку строк в одной транзакции. Строки проходят ту же валидацию
+// и проверку пересечений, что и CreateRecord. Повторный импорт идемпотентен: запись с тем же
+// естественным ключом (user_id, service_name, created_at) обновляется, а не создается заново.
+// В пробном режиме все изменения откатываются, но отчет отражает то, что было бы сделано
+func (s *RecordService) ImportRecords(ctx context.Context, rows []ImportRow, dryRun bool) ([]ImportRowResult, error) {
+	const op = "recordService.ImportRecords"
+
+	log := s.log.With(slog.String("operation", op), slog.Int("rows", len(rows)), slog.Bool("dry_run", dryRun))
+	log.Info("importing records...")
+
+	results := make([]ImportRowResult, len(rows))
+
+	err := s.recordRepository.Transaction(ctx, func(ctx context.Context) error {
+		for i := range rows {
+			results[i] = s.importRow(ctx, &rows[i])
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Error("failed to import records", slog.Any("error", err))
+		return nil, NewInternalError(CodeImportFailed, ErrImportFailed, err)
+	}
+
+	if !dryRun {
+		checks := make([]*BudgetCheck, len(results))
+		for i := range results {
+			checks[i] = results[i].budget
+		}
+		s.alertBudgets(ctx, checks)
+	}
+
+	log.Info("records imported")
+
+	return results, nil
+}
+
+// importRow обрабатывает строку во вложенной транзакции, чтобы ошибка строки не откатывала всю пачку
+func (s *RecordService) importRow(ctx context.Context, row *ImportRow) ImportRowResult {
+	result := ImportRowResult{Line: row.Line, Action: ImportFailed}
+
+	if row.Err != nil {
+		result.Err = row.Err
+		return result
+	}
+
+	record := &row.Record
+	record.ID = 0
+
+	if err := s.validator.Validate(record, s.config.Clock.Now()); err != nil {
+		result.Err = err
+		return result
+	}
+
+	err := s.recordRepository.Transaction(ctx, func(ctx context.Context) error {
+		existing, err := s.recordRepository.GetRecordByNaturalKey(ctx, record.UserID, record.ServiceName, record.CreatedAt)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			result.Action = ImportCreated
+		case err != nil:
+			return err
+		case existing.Price == record.Price && existing.Category == record.Category && existing.ExpiresAt.Equal(record.ExpiresAt):
+			result.Action = ImportUnchanged
+			result.RecordID = existing.ID
+			return nil
+		default:
+			result.Action = ImportUpdated
+			record.ID = existing.ID
+		}
+
+		save := s.recordRepository.SaveRecord
+		if record.ID != 0 {
+			save = s.recordRepository.UpdateRecord
+		}
+
+		written, err := s.writeWithOverlapPolicy(ctx, record, save)
+		if err != nil {
+			return err
+		}
+
+		// создание сверх бюджета с enforce отклоняет строку, как и CreateRecord
+		check, err := s.checkBudget(ctx, written, result.Action == ImportCreated)
+		if err != nil {
+			return err
+		}
+		result.budget = check
+
+		if written.Merged {
+			result.Action = ImportMerged
+		}
+		result.RecordID = written.Record.ID
+		result.Warnings = written.Warnings
+
+		return nil
+	})
+	if err != nil {
+		result.Action = ImportFailed
+		result.budget = nil
+		result.Err = s.classifyWriteError(ctx, record, err, CodeImportFailed, ErrImportFailed)
+	}
+
+	return result
+}
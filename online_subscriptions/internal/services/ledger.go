@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/billing"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var ErrLedgerFailed = errors.New("could not process ledger")
+
+const CodeLedgerFailed = "ledger_failed"
+
+// причина автоматической корректировки
+const reconcileReason = "record changed"
+
+type LedgerRepository interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	GetRecordByID(ctx context.Context, id uint) (*entity.Record, error)
+	LockLedger(ctx context.Context) error
+	FindLedgerCandidates(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]entity.Record, error)
+	LedgerBalances(ctx context.Context, recordIDs []uint, from, to time.Time) ([]entity.LedgerBalance, error)
+	AppendLedgerEntries(ctx context.Context, entries []entity.LedgerEntry) error
+	ListLedgerEntries(ctx context.Context, userID, serviceName string, from, to time.Time, limit, offset int) ([]entity.LedgerEntry, error)
+	SumLedgerForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error)
+}
+
+// LedgerConfig - настройки журнала начислений
+type LedgerConfig struct {
+	// плановый запуск сверяет текущий месяц и LookbackMonths предыдущих
+	LookbackMonths int
+	BatchSize      int
+	// Clock определяет, какие списания уже наступили, nil - системные часы
+	Clock clock.Clock
+}
+
+// LedgerService ведет журнал начислений: по одной строке на подписку за каждый расчетный месяц.
+// Изменение записи после начисления не переписывает журнал, а добавляет корректировку на разницу
+type LedgerService struct {
+	log    *slog.Logger
+	repo   LedgerRepository
+	config LedgerConfig
+}
+
+func NewLedgerService(log *slog.Logger, repo LedgerRepository, config LedgerConfig) *LedgerService {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+
+	config.Clock = clock.OrSystem(config.Clock)
+
+	return &LedgerService{log: log, repo: repo, config: config}
+}
+
+// MaterializeResult - итог заполнения журнала
+type MaterializeResult struct {
+	Charges     int `json:"charges"`
+	Adjustments int `json:"adjustments"`
+}
+
+// LedgerAdjustment - ручная корректировка начисления подписки за месяц
+type LedgerAdjustment struct {
+	RecordID uint
+	Period   time.Time
+	Amount   int
+	Reason   string
+}
+
+// ChargeDue записывает начисления, дата списания которых уже наступила. Запускается планировщиком
+func (s *LedgerService) ChargeDue(ctx context.Context) error {
+	today := truncateToDate(s.config.Clock.Now())
+	from := monthStart(today).AddDate(0, -s.config.LookbackMonths, 0)
+
+	_, err := s.materialize(ctx, from, today.AddDate(0, 0, 1))
+
+	return err
+}
+
+// Backfill заполняет журнал за месяцы с from по to включительно. Будущие списания не начисляются
+func (s *LedgerService) Backfill(ctx context.Context, from, to time.Time) (*MaterializeResult, error) {
+	const op = "ledgerService.Backfill"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("backfilling ledger...")
+
+	if to.Before(from) {
+		return nil, NewValidationError("invalid backfill range",
+			FieldError{Field: "to", Code: "invalid_range", Message: "to must not be before from"})
+	}
+
+	end := monthStart(to).AddDate(0, 1, 0)
+	if tomorrow := truncateToDate(s.config.Clock.Now()).AddDate(0, 0, 1); end.After(tomorrow) {
+		end = tomorrow
+	}
+
+	result, err := s.materialize(ctx, monthStart(from), end)
+	if err != nil {
+		log.Error("failed to backfill ledger", slog.Any("error", err))
+		return nil, NewInternalError(CodeLedgerFailed, ErrLedgerFailed, err)
+	}
+
+	log.Info("ledger backfilled", slog.Int("charges", result.Charges), slog.Int("adjustments", result.Adjustments))
+
+	return result, nil
+}
+
+// materialize сверяет журнал с записями в окне [from, to): from - начало месяца,
+// to - начало месяца или завтрашний день для текущего месяца
+func (s *LedgerService) materialize(ctx context.Context, from, to time.Time) (*MaterializeResult, error) {
+	result := &MaterializeResult{}
+
+	var afterID uint
+
+	for {
+		var count int
+
+		err := s.repo.Transaction(ctx, func(ctx context.Context) error {
+			if err := s.repo.LockLedger(ctx); err != nil {
+				return err
+			}
+
+			records, err := s.repo.FindLedgerCandidates(ctx, from, to, afterID, s.config.BatchSize)
+			if err != nil {
+				return err
+			}
+
+			count = len(records)
+			if count == 0 {
+				return nil
+			}
+
+			afterID = records[count-1].ID
+
+			entries, err := s.reconcile(ctx, records, from, to)
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				if entry.Kind == entity.LedgerCharge {
+					result.Charges++
+				} else {
+					result.Adjustments++
+				}
+			}
+
+			return s.repo.AppendLedgerEntries(ctx, entries)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if count < s.config.BatchSize {
+			return result, nil
+		}
+	}
+}
+
+type ledgerKey struct {
+	recordID uint
+	period   time.Time
+}
+
+// reconcile возвращает строки журнала, приводящие итог каждой подписки за каждый месяц окна
+// к цене подписки, если списание в этом месяце было, и к нулю, если нет
+func (s *LedgerService) reconcile(ctx context.Context, records []entity.Record, from, to time.Time) ([]entity.LedgerEntry, error) {
+	ids := make([]uint, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+
+	balances, err := s.repo.LedgerBalances(ctx, ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[ledgerKey]entity.LedgerBalance, len(balances))
+	for _, balance := range balances {
+		byKey[ledgerKey{balance.RecordID, balance.Period.UTC()}] = balance
+	}
+
+	var entries []entity.LedgerEntry
+
+	for _, record := range records {
+		entry := func(kind string, date time.Time, amount int) entity.LedgerEntry {
+			e := entity.LedgerEntry{
+				RecordID:    record.ID,
+				Period:      monthStart(date),
+				Kind:        kind,
+				UserID:      record.UserID,
+				ServiceName: record.ServiceName,
+				ChargeDate:  date,
+				Amount:      amount,
+			}
+			if kind == entity.LedgerAdjustment {
+				e.Reason = reconcileReason
+			}
+
+			return e
+		}
+
+		start, end := truncateToDate(record.CreatedAt), truncateToDate(record.ExpiresAt)
+
+		for _, date := range billing.ChargeDates(start, end, from, to) {
+			key := ledgerKey{record.ID, monthStart(date)}
+			balance := byKey[key]
+			delete(byKey, key)
+
+			if !balance.Charged {
+				entries = append(entries, entry(entity.LedgerCharge, date, record.Price))
+				balance.Amount += record.Price
+			}
+
+			if diff := record.Price - balance.Amount; diff != 0 {
+				entries = append(entries, entry(entity.LedgerAdjustment, date, diff))
+			}
+		}
+	}
+
+	// оставшиеся итоги относятся к месяцам, в которых списания по записи больше нет
+	byID := make(map[uint]entity.Record, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+	}
+
+	for key, balance := range byKey {
+		if balance.Amount == 0 {
+			continue
+		}
+
+		record := byID[key.recordID]
+		entries = append(entries, entity.LedgerEntry{
+			RecordID:    record.ID,
+			Period:      key.period,
+			Kind:        entity.LedgerAdjustment,
+			UserID:      record.UserID,
+			ServiceName: record.ServiceName,
+			ChargeDate:  truncateToDate(balance.ChargeDate),
+			Amount:      -balance.Amount,
+			Reason:      reconcileReason,
+		})
+	}
+
+	return entries, nil
+}
+
+// AddAdjustment записывает ручную корректировку начисления
+func (s *LedgerService) AddAdjustment(ctx context.Context, adjustment LedgerAdjustment) (*entity.LedgerEntry, error) {
+	const op = "ledgerService.AddAdjustment"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("adding ledger adjustment...")
+
+	var fields []FieldError
+
+	if adjustment.Amount == 0 {
+		fields = append(fields, FieldError{Field: "amount", Code: "required", Message: "must not be zero"})
+	}
+
+	reason := strings.TrimSpace(adjustment.Reason)
+	if reason == "" {
+		fields = append(fields, FieldError{Field: "reason", Code: "required", Message: "is required"})
+	}
+
+	if len(fields) > 0 {
+		return nil, NewValidationError("invalid adjustment", fields...)
+	}
+
+	record, err := s.repo.GetRecordByID(ctx, adjustment.RecordID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errRecordNotFound(err)
+		}
+
+		log.Error("failed to get record", slog.Any("error", err))
+		return nil, NewInternalError(CodeLedgerFailed, ErrLedgerFailed, err)
+	}
+
+	start := truncateToDate(record.CreatedAt)
+	period := monthStart(adjustment.Period)
+
+	entry := entity.LedgerEntry{
+		RecordID:    record.ID,
+		Period:      period,
+		Kind:        entity.LedgerAdjustment,
+		UserID:      record.UserID,
+		ServiceName: record.ServiceName,
+		ChargeDate:  billing.ChargeDate(start, billing.MonthsBetween(start, period)),
+		Amount:      adjustment.Amount,
+		Manual:      true,
+		Reason:      reason,
+	}
+
+	entries := []entity.LedgerEntry{entry}
+	if err := s.repo.AppendLedgerEntries(ctx, entries); err != nil {
+		log.Error("failed to save adjustment", slog.Any("error", err))
+		return nil, NewInternalError(CodeLedgerFailed, ErrLedgerFailed, err)
+	}
+
+	log.Info("ledger adjustment added", slog.Uint64("record_id", uint64(record.ID)))
+
+	return &entries[0], nil
+}
+
+func (s *LedgerService) ListEntries(ctx context.Context, userID, serviceName string, from, to time.Time, limit, offset int) ([]entity.LedgerEntry, error) {
+	entries, err := s.repo.ListLedgerEntries(ctx, userID, serviceName, from, to, limit, offset)
+	if err != nil {
+		s.log.Error("failed to list ledger entries", slog.String("operation", "ledgerService.ListEntries"), slog.Any("error", err))
+		return nil, NewInternalError(CodeLedgerFailed, ErrLedgerFailed, err)
+	}
+
+	return entries, nil
+}
+
+// SumForPeriod суммирует журнал за период - то, что фактически начислено, с учетом корректировок
+func (s *LedgerService) SumForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
+	const op = "ledgerService.SumForPeriod"
+
+	log := s.log.With(slog.String("operation", op))
+
+	total, err := s.repo.SumLedgerForPeriod(ctx, startTime, endTime, userID, serviceName)
+	if err != nil {
+		log.Error("failed to sum ledger", slog.Any("error", err))
+		return 0, NewInternalError(CodeSumFailed, ErrSumFailed, err)
+	}
+
+	return total, nil
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,343 @@
+package services_test
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeLedger - LedgerRepository в памяти с той же выборкой кандидатов и итогов, что и в SQL.
+// Повторное начисление за месяц пропускается, как при конфликте с уникальным индексом, и считается в duplicates
+type fakeLedger struct {
+	records    []entity.Record
+	entries    []entity.LedgerEntry
+	duplicates int
+}
+
+func (f *fakeLedger) add(record entity.Record) entity.Record {
+	record.ID = uint(len(f.records) + 1)
+	if record.UserID == "" {
+		record.UserID = recordUser
+	}
+	f.records = append(f.records, record)
+
+	return record
+}
+
+func (f *fakeLedger) update(record entity.Record) {
+	f.records[record.ID-1] = record
+}
+
+func (f *fakeLedger) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	entries := f.entries
+	if err := fn(ctx); err != nil {
+		f.entries = entries
+		return err
+	}
+
+	return nil
+}
+
+func (f *fakeLedger) GetRecordByID(ctx context.Context, id uint) (*entity.Record, error) {
+	if id == 0 || int(id) > len(f.records) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	record := f.records[id-1]
+
+	return &record, nil
+}
+
+func (f *fakeLedger) LockLedger(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeLedger) FindLedgerCandidates(ctx context.Context, from, to time.Time, afterID uint, limit int) ([]entity.Record, error) {
+	charged := make(map[uint]bool)
+	for _, entry := range f.entries {
+		if !entry.Period.Before(from) && entry.Period.Before(to) {
+			charged[entry.RecordID] = true
+		}
+	}
+
+	var records []entity.Record
+	for _, record := range f.records {
+		active := record.CreatedAt.Before(to) && record.ExpiresAt.After(from)
+		if record.ID > afterID && (active || charged[record.ID]) && len(records) < limit {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func (f *fakeLedger) LedgerBalances(ctx context.Context, recordIDs []uint, from, to time.Time) ([]entity.LedgerBalance, error) {
+	type key struct {
+		recordID uint
+		period   time.Time
+	}
+
+	wanted := make(map[uint]bool, len(recordIDs))
+	for _, id := range recordIDs {
+		wanted[id] = true
+	}
+
+	var (
+		keys     []key
+		balances = make(map[key]*entity.LedgerBalance)
+	)
+
+	for _, entry := range f.entries {
+		if !wanted[entry.RecordID] || entry.Manual || entry.Period.Before(from) || !entry.Period.Before(to) {
+			continue
+		}
+
+		k := key{entry.RecordID, entry.Period}
+		balance, ok := balances[k]
+		if !ok {
+			balance = &entity.LedgerBalance{RecordID: entry.RecordID, Period: entry.Period, ChargeDate: entry.ChargeDate}
+			balances[k] = balance
+			keys = append(keys, k)
+		}
+
+		balance.Amount += entry.Amount
+		balance.Charged = balance.Charged || entry.Kind == entity.LedgerCharge
+		if entry.ChargeDate.Before(balance.ChargeDate) {
+			balance.ChargeDate = entry.ChargeDate
+		}
+	}
+
+	result := make([]entity.LedgerBalance, len(keys))
+	for i, k := range keys {
+		result[i] = *balances[k]
+	}
+
+	return result, nil
+}
+
+func (f *fakeLedger) AppendLedgerEntries(ctx context.Context, entries []entity.LedgerEntry) error {
+	for _, entry := range entries {
+		if entry.Kind == entity.LedgerCharge && f.charged(entry.RecordID, entry.Period) {
+			f.duplicates++
+			continue
+		}
+
+		entry.ID = uint(len(f.entries) + 1)
+		f.entries = append(f.entries, entry)
+	}
+
+	return nil
+}
+
+func (f *fakeLedger) charged(recordID uint, period time.Time) bool {
+	for _, entry := range f.entries {
+		if entry.RecordID == recordID && entry.Period.Equal(period) && entry.Kind == entity.LedgerCharge {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *fakeLedger) ListLedgerEntries(ctx context.Context, userID, serviceName string, from, to time.Time, limit, offset int) ([]entity.LedgerEntry, error) {
+	return f.entries, nil
+}
+
+func (f *fakeLedger) SumLedgerForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
+	var total int
+	for _, entry := range f.entries {
+		if !entry.ChargeDate.Before(startTime) && !entry.ChargeDate.After(endTime) {
+			total += entry.Amount
+		}
+	}
+
+	return total, nil
+}
+
+// ledgerLine - строка журнала без служебных полей, для сравнения
+type ledgerLine struct {
+	RecordID uint
+	Kind     string
+	Date     string
+	Amount   int
+}
+
+func (f *fakeLedger) lines() []ledgerLine {
+	lines := make([]ledgerLine, len(f.entries))
+	for i, entry := range f.entries {
+		lines[i] = ledgerLine{entry.RecordID, entry.Kind, entry.ChargeDate.Format("2006-01-02"), entry.Amount}
+	}
+
+	return lines
+}
+
+func newLedgerService(repo *fakeLedger, now time.Time, lookbackMonths, batchSize int) (*services.LedgerService, *clock.Fake) {
+	clk := clock.NewFake(now)
+
+	return services.NewLedgerService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, services.LedgerConfig{
+		LookbackMonths: lookbackMonths,
+		BatchSize:      batchSize,
+		Clock:          clk,
+	}), clk
+}
+
+func TestChargeDueBoundaries(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Time
+		today      time.Time
+		// want - дата начисления, пустая - начислений нет
+		want string
+	}{
+		{"charge day", day(2025, time.January, 15), day(2025, time.June, 15), day(2025, time.March, 15), "2025-03-15"},
+		{"day before charge", day(2025, time.January, 15), day(2025, time.June, 15), day(2025, time.March, 14), ""},
+		{"first day of subscription", day(2025, time.March, 10), day(2025, time.June, 10), day(2025, time.March, 10), "2025-03-10"},
+		{"not started yet", day(2025, time.March, 11), day(2025, time.June, 11), day(2025, time.March, 10), ""},
+		{"31st in february", day(2025, time.January, 31), day(2025, time.June, 30), day(2025, time.February, 28), "2025-02-28"},
+		{"31st before end of february", day(2025, time.January, 31), day(2025, time.June, 30), day(2025, time.February, 27), ""},
+		{"31st in leap february", day(2024, time.January, 31), day(2024, time.June, 30), day(2024, time.February, 29), "2024-02-29"},
+		{"31st back in march", day(2025, time.January, 31), day(2025, time.June, 30), day(2025, time.March, 31), "2025-03-31"},
+		{"expires on charge day", day(2025, time.January, 15), day(2025, time.March, 15), day(2025, time.March, 20), ""},
+		{"expires after charge day", day(2025, time.January, 15), day(2025, time.March, 16), day(2025, time.March, 20), "2025-03-15"},
+		{"last day of year", day(2024, time.October, 31), day(2025, time.March, 1), day(2024, time.December, 31), "2024-12-31"},
+		{"first day of year", day(2024, time.October, 1), day(2025, time.March, 1), day(2025, time.January, 1), "2025-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeLedger{}
+			record := repo.add(entity.Record{ServiceName: "Netflix", Price: 100, CreatedAt: tt.start, ExpiresAt: tt.end})
+
+			svc, _ := newLedgerService(repo, tt.today, 0, 0)
+			if err := svc.ChargeDue(context.Background()); err != nil {
+				t.Fatalf("charge due: %v", err)
+			}
+
+			// без окна просмотра назад начисляется только текущий месяц
+			want := []ledgerLine{}
+			if tt.want != "" {
+				want = []ledgerLine{{record.ID, entity.LedgerCharge, tt.want, 100}}
+			}
+			if got := repo.lines(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("entries: got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestChargeDueIdempotent(t *testing.T) {
+	repo := &fakeLedger{}
+	repo.add(entity.Record{ServiceName: "Netflix", Price: 100, CreatedAt: day(2025, time.January, 31), ExpiresAt: day(2025, time.December, 31)})
+	repo.add(entity.Record{ServiceName: "Spotify", Price: 200, CreatedAt: day(2025, time.February, 1), ExpiresAt: day(2025, time.April, 1)})
+	repo.add(entity.Record{ServiceName: "Okko", Price: 300, CreatedAt: day(2025, time.March, 10), ExpiresAt: day(2025, time.April, 10)})
+	repo.add(entity.Record{ServiceName: "Kion", Price: 400, CreatedAt: day(2024, time.June, 1), ExpiresAt: day(2024, time.December, 1)})
+
+	// пачки по одной записи проверяют продолжение после последнего ID
+	svc, now := newLedgerService(repo, day(2025, time.January, 1), 1, 1)
+	ctx := context.Background()
+
+	// планировщик запускает задачу несколько раз в день, каждый день до конца апреля
+	for now.Now().Before(day(2025, time.May, 1)) {
+		for run := 0; run < 3; run++ {
+			if err := svc.ChargeDue(ctx); err != nil {
+				t.Fatalf("charge due on %s: %v", now.Now().Format("2006-01-02"), err)
+			}
+		}
+		now.Advance(24 * time.Hour)
+	}
+
+	want := []ledgerLine{
+		{1, entity.LedgerCharge, "2025-01-31", 100},
+		{2, entity.LedgerCharge, "2025-02-01", 200},
+		{1, entity.LedgerCharge, "2025-02-28", 100},
+		{2, entity.LedgerCharge, "2025-03-01", 200},
+		{3, entity.LedgerCharge, "2025-03-10", 300},
+		{1, entity.LedgerCharge, "2025-03-31", 100},
+		{1, entity.LedgerCharge, "2025-04-30", 100},
+	}
+	if got := repo.lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	// повторные запуски не пытаются начислить уже начисленное
+	if repo.duplicates != 0 {
+		t.Fatalf("%d duplicate charges attempted", repo.duplicates)
+	}
+}
+
+func TestChargeDueCatchesUpWithinLookback(t *testing.T) {
+	repo := &fakeLedger{}
+	repo.add(entity.Record{ServiceName: "Netflix", Price: 100, CreatedAt: day(2024, time.December, 20), ExpiresAt: day(2025, time.June, 20)})
+
+	// планировщик не работал с декабря: начисления восстанавливаются только за окно
+	svc, _ := newLedgerService(repo, day(2025, time.March, 25), 1, 0)
+	if err := svc.ChargeDue(context.Background()); err != nil {
+		t.Fatalf("charge due: %v", err)
+	}
+
+	want := []ledgerLine{
+		{1, entity.LedgerCharge, "2025-02-20", 100},
+		{1, entity.LedgerCharge, "2025-03-20", 100},
+	}
+	if got := repo.lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries: got %+v, want %+v", got, want)
+	}
+}
+
+func TestChargeDueAdjustsChangedRecords(t *testing.T) {
+	repo := &fakeLedger{}
+	record := repo.add(entity.Record{ServiceName: "Netflix", Price: 100, CreatedAt: day(2025, time.February, 10), ExpiresAt: day(2025, time.June, 10)})
+
+	svc, _ := newLedgerService(repo, day(2025, time.March, 20), 1, 0)
+	ctx := context.Background()
+
+	if err := svc.ChargeDue(ctx); err != nil {
+		t.Fatalf("charge due: %v", err)
+	}
+
+	// цена изменилась после начисления: корректировки на разницу, повторный запуск их не дублирует
+	record.Price = 150
+	repo.update(record)
+
+	for run := 0; run < 2; run++ {
+		if err := svc.ChargeDue(ctx); err != nil {
+			t.Fatalf("charge due: %v", err)
+		}
+	}
+
+	// подписка отменена задним числом: мартовское списание сторнируется
+	record.ExpiresAt = day(2025, time.March, 10)
+	repo.update(record)
+
+	for run := 0; run < 2; run++ {
+		if err := svc.ChargeDue(ctx); err != nil {
+			t.Fatalf("charge due: %v", err)
+		}
+	}
+
+	want := []ledgerLine{
+		{1, entity.LedgerCharge, "2025-02-10", 100},
+		{1, entity.LedgerCharge, "2025-03-10", 100},
+		{1, entity.LedgerAdjustment, "2025-02-10", 50},
+		{1, entity.LedgerAdjustment, "2025-03-10", 50},
+		{1, entity.LedgerAdjustment, "2025-03-10", -150},
+	}
+	if got := repo.lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	total, err := svc.SumForPeriod(ctx, day(2025, time.February, 1), day(2025, time.March, 31), "", "")
+	if err != nil {
+		t.Fatalf("sum: %v", err)
+	}
+	if total != 150 {
+		t.Fatalf("total: got %d, want 150 for february only", total)
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"gorm.io/gorm"
+)
+
+// OverlapPolicy определяет, что делать с пересекающимися подписками одного пользователя на один сервис
+type OverlapPolicy string
+
+const (
+	// OverlapReject отклоняет запись с ошибкой conflict
+	OverlapReject OverlapPolicy = "reject"
+	// OverlapMerge объединяет пересекающиеся записи в одну, покрывающую весь период
+	OverlapMerge OverlapPolicy = "merge"
+	// OverlapWarn сохраняет запись как есть и возвращает предупреждение
+	OverlapWarn OverlapPolicy = "warn"
+)
+
+const (
+	CodeOverlappingSubscription = "overlapping_subscription"
+)
+
+func ParseOverlapPolicy(value string) (OverlapPolicy, error) {
+	switch policy := OverlapPolicy(value); policy {
+	case OverlapReject, OverlapMerge, OverlapWarn:
+		return policy, nil
+	case "":
+		return OverlapReject, nil
+	default:
+		return "", fmt.Errorf("unknown overlap policy %q", value)
+	}
+}
+
+// Warning - некритичное замечание к успешно выполненной записи
+type Warning struct {
+	Code     string
+	Message  string
+	RecordID uint
+}
+
+// WriteResult - итог создания или обновления записи
+type WriteResult struct {
+	Record *entity.Record
+	// Merged - запись была объединена с уже существующей
+	Merged   bool
+	Warnings []Warning
+}
+
+// writeWithOverlapPolicy сохраняет запись с учетом пересечений по правилам config.OverlapPolicy.
+// Должна вызываться внутри транзакции репозитория
+func (s *RecordService) writeWithOverlapPolicy(ctx context.Context, record *entity.Record, save func(context.Context, *entity.Record) error) (*WriteResult, error) {
+	overlaps, err := s.recordRepository.FindOverlappingRecords(ctx, record.UserID, record.ServiceName, record.CreatedAt, record.ExpiresAt, record.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WriteResult{Record: record}
+
+	if len(overlaps) == 0 {
+		return result, save(ctx, record)
+	}
+
+	switch s.config.OverlapPolicy {
+	case OverlapWarn:
+		for _, other := range overlaps {
+			result.Warnings = append(result.Warnings, Warning{
+				Code:     CodeOverlappingSubscription,
+				Message:  fmt.Sprintf("subscription overlaps record %d", other.ID),
+				RecordID: other.ID,
+			})
+		}
+
+		return result, save(ctx, record)
+	case OverlapMerge:
+		return s.mergeOverlapping(ctx, record, overlaps)
+	default:
+		return nil, errOverlap(overlaps)
+	}
+}
+
+// mergeOverlapping объединяет запись с пересекающимися: период расширяется до общего, цена берется из новой записи.
+// При создании сохраняется первая из существующих записей, при обновлении - обновляемая, остальные удаляются
+func (s *RecordService) mergeOverlapping(ctx context.Context, record *entity.Record, overlaps []entity.Record) (*WriteResult, error) {
+	target := *record
+	rest := overlaps
+
+	if record.ID == 0 {
+		target = overlaps[0]
+		target.Price = record.Price
+		if record.Category != "" {
+			target.Category = record.Category
+		}
+		rest = overlaps[1:]
+	}
+
+	for _, other := range append([]entity.Record{*record}, overlaps...) {
+		if other.CreatedAt.Before(target.CreatedAt) {
+			target.CreatedAt = other.CreatedAt
+		}
+		if other.ExpiresAt.After(target.ExpiresAt) {
+			target.ExpiresAt = other.ExpiresAt
+		}
+	}
+
+	for _, other := range rest {
+		if err := s.recordRepository.DeleteRecordByID(ctx, other.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.recordRepository.UpdateRecord(ctx, &target); err != nil {
+		return nil, err
+	}
+
+	*record = target
+
+	return &WriteResult{Record: record, Merged: true}, nil
+}
+
+// overlapConflict переводит нарушение ограничения на пересечения, пойманное БД, в ошибку conflict с указанием записи
+func (s *RecordService) overlapConflict(ctx context.Context, record *entity.Record, err error) error {
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil
+	}
+
+	overlaps, findErr := s.recordRepository.FindOverlappingRecords(ctx, record.UserID, record.ServiceName, record.CreatedAt, record.ExpiresAt, record.ID)
+	if findErr != nil || len(overlaps) == 0 {
+		return NewConflictError(CodeOverlappingSubscription, "subscription overlaps an existing one", err)
+	}
+
+	return errOverlap(overlaps)
+}
+
+func errOverlap(overlaps []entity.Record) *Error {
+	ids := make([]uint, 0, len(overlaps))
+	for _, other := range overlaps {
+		ids = append(ids, other.ID)
+	}
+
+	return NewConflictError(
+		CodeOverlappingSubscription,
+		fmt.Sprintf("subscription overlaps existing record %d", overlaps[0].ID),
+		nil,
+	).
+		WithMeta("conflicting_record_id", overlaps[0].ID).
+		WithMeta("conflicting_record_ids", ids)
+}
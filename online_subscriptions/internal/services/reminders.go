@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/notify"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+type ReminderRepository interface {
+	FindRecordsExpiringBetween(ctx context.Context, from, to time.Time) ([]entity.Record, error)
+	ClaimNotification(ctx context.Context, notification *entity.SentNotification) (bool, error)
+	ReleaseNotification(ctx context.Context, notification *entity.SentNotification) error
+}
+
+// ReminderService напоминает о подписках, которые скоро закончатся
+type ReminderService struct {
+	log       *slog.Logger
+	repo      ReminderRepository
+	notifiers []notify.Notifier
+	// окна в днях по возрастанию, например 1 и 7
+	windows []int
+	clock   clock.Clock
+}
+
+// NewReminderService создает сервис напоминаний, clk == nil - системные часы
+func NewReminderService(log *slog.Logger, repo ReminderRepository, notifiers []notify.Notifier, windows []int, clk clock.Clock) (*ReminderService, error) {
+	if len(windows) == 0 {
+		return nil, errors.New("at least one reminder window is required")
+	}
+
+	sorted := append([]int(nil), windows...)
+	sort.Ints(sorted)
+
+	if sorted[0] < 0 {
+		return nil, fmt.Errorf("reminder window must not be negative, got %d", sorted[0])
+	}
+
+	return &ReminderService{
+		log:       log,
+		repo:      repo,
+		notifiers: notifiers,
+		windows:   sorted,
+		clock:     clock.OrSystem(clk),
+	}, nil
+}
+
+// SendDueReminders отправляет напоминания по подпискам, попавшим в окна. Подписка получает напоминание
+// только для самого узкого подходящего окна: если сервис был остановлен и увидел подписку за день
+// до окончания, недельное напоминание уже не отправляется.
+// Отметка об отправке ставится до отправки, поэтому параллельные экземпляры не дублируют напоминания;
+// при ошибке канала отметка снимается и напоминание уходит на следующем запуске
+func (s *ReminderService) SendDueReminders(ctx context.Context) error {
+	const op = "reminderService.SendDueReminders"
+
+	log := s.log.With(slog.String("operation", op))
+
+	today := truncateToDate(s.clock.Now())
+	until := today.AddDate(0, 0, s.windows[len(s.windows)-1])
+
+	records, err := s.repo.FindRecordsExpiringBetween(ctx, today, until)
+	if err != nil {
+		log.Error("failed to find expiring records", slog.Any("error", err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	sent, failed := 0, 0
+
+	for _, record := range records {
+		daysLeft := int(record.ExpiresAt.Sub(today).Hours() / 24)
+
+		reminder := notify.Reminder{
+			RecordID:    record.ID,
+			UserID:      record.UserID,
+			ServiceName: record.ServiceName,
+			Price:       record.Price,
+			ExpiresAt:   record.ExpiresAt,
+			DaysLeft:    daysLeft,
+			WindowDays:  s.window(daysLeft),
+		}
+
+		for _, notifier := range s.notifiers {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			ok, err := s.send(ctx, notifier, reminder)
+			if err != nil {
+				failed++
+				log.Error("failed to send reminder",
+					slog.String("channel", notifier.Name()),
+					slog.Uint64("record_id", uint64(record.ID)),
+					slog.Any("error", err))
+				continue
+			}
+
+			if ok {
+				sent++
+			}
+		}
+	}
+
+	log.Info("reminders processed", slog.Int("records", len(records)), slog.Int("sent", sent), slog.Int("failed", failed))
+
+	return nil
+}
+
+// send возвращает false, если напоминание по этому каналу уже отправлялось
+func (s *ReminderService) send(ctx context.Context, notifier notify.Notifier, reminder notify.Reminder) (bool, error) {
+	notification := &entity.SentNotification{
+		RecordID:   reminder.RecordID,
+		Channel:    notifier.Name(),
+		WindowDays: reminder.WindowDays,
+		ExpiresAt:  reminder.ExpiresAt,
+		SentAt:     s.clock.Now(),
+	}
+
+	claimed, err := s.repo.ClaimNotification(ctx, notification)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	if err := notifier.Notify(ctx, reminder); err != nil {
+		// отметку снимаем без контекста запуска: он мог быть отменен при остановке
+		if releaseErr := s.repo.ReleaseNotification(context.WithoutCancel(ctx), notification); releaseErr != nil {
+			return false, errors.Join(err, releaseErr)
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// window возвращает самое узкое окно, в которое попадает подписка
+func (s *ReminderService) window(daysLeft int) int {
+	for _, w := range s.windows {
+		if daysLeft <= w {
+			return w
+		}
+	}
+
+	return s.windows[len(s.windows)-1]
+}
@@ -0,0 +1,280 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/notify"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// reminderRepository - записи и отметки об отправке в памяти. Ключ отметки тот же, что у уникального индекса
+type reminderRepository struct {
+	records []entity.Record
+	sent    map[reminderKey]uint
+	nextID  uint
+}
+
+type reminderKey struct {
+	recordID   uint
+	channel    string
+	windowDays int
+	expiresAt  time.Time
+}
+
+func newReminderRepository(records ...entity.Record) *reminderRepository {
+	for i := range records {
+		records[i].ID = uint(i + 1)
+		records[i].UserID = recordUser
+	}
+
+	return &reminderRepository{records: records, sent: make(map[reminderKey]uint)}
+}
+
+func (r *reminderRepository) FindRecordsExpiringBetween(ctx context.Context, from, to time.Time) ([]entity.Record, error) {
+	var records []entity.Record
+	for _, record := range r.records {
+		if !record.ExpiresAt.Before(from) && !record.ExpiresAt.After(to) {
+			records = append(records, record)
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].ExpiresAt.Before(records[j].ExpiresAt) })
+
+	return records, nil
+}
+
+func (r *reminderRepository) ClaimNotification(ctx context.Context, notification *entity.SentNotification) (bool, error) {
+	key := reminderKey{notification.RecordID, notification.Channel, notification.WindowDays, notification.ExpiresAt}
+	if _, ok := r.sent[key]; ok {
+		return false, nil
+	}
+
+	r.nextID++
+	notification.ID = r.nextID
+	r.sent[key] = notification.ID
+
+	return true, nil
+}
+
+func (r *reminderRepository) ReleaseNotification(ctx context.Context, notification *entity.SentNotification) error {
+	for key, id := range r.sent {
+		if id == notification.ID {
+			delete(r.sent, key)
+		}
+	}
+
+	return nil
+}
+
+// sentReminder - то, что получил канал: подписка, дней до окончания и окно
+type sentReminder struct {
+	Service    string
+	DaysLeft   int
+	WindowDays int
+}
+
+func reminders(t *testing.T, notifier *recordingNotifier) []sentReminder {
+	t.Helper()
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	var got []sentReminder
+	for _, message := range notifier.messages {
+		reminder, ok := message.(notify.Reminder)
+		if !ok {
+			t.Fatalf("unexpected message %T", message)
+		}
+		got = append(got, sentReminder{reminder.ServiceName, reminder.DaysLeft, reminder.WindowDays})
+	}
+
+	notifier.messages = nil
+
+	return got
+}
+
+func newReminderService(t *testing.T, repo *reminderRepository, now *clock.Fake, notifiers ...*recordingNotifier) *services.ReminderService {
+	t.Helper()
+
+	channels := make([]notify.Notifier, len(notifiers))
+	for i, notifier := range notifiers {
+		channels[i] = notifier
+	}
+
+	svc, err := services.NewReminderService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, channels, []int{7, 1}, now)
+	if err != nil {
+		t.Fatalf("new reminder service: %v", err)
+	}
+
+	return svc
+}
+
+func expiring(serviceName string, expiresAt time.Time) entity.Record {
+	return entity.Record{ServiceName: serviceName, Price: 100, CreatedAt: expiresAt.AddDate(0, -1, 0), ExpiresAt: expiresAt}
+}
+
+func TestReminderWindows(t *testing.T) {
+	today := day(2025, time.March, 10)
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		// want - nil, если напоминания нет
+		want []sentReminder
+	}{
+		{"expired yesterday", today.AddDate(0, 0, -1), nil},
+		{"expires today", today, []sentReminder{{"Netflix", 0, 1}}},
+		{"expires tomorrow", today.AddDate(0, 0, 1), []sentReminder{{"Netflix", 1, 1}}},
+		{"two days left", today.AddDate(0, 0, 2), []sentReminder{{"Netflix", 2, 7}}},
+		{"last day of week window", today.AddDate(0, 0, 7), []sentReminder{{"Netflix", 7, 7}}},
+		{"outside windows", today.AddDate(0, 0, 8), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// время суток не влияет на число оставшихся дней
+			now := clock.NewFake(today.Add(23 * time.Hour))
+			notifier := &recordingNotifier{}
+			svc := newReminderService(t, newReminderRepository(expiring("Netflix", tt.expiresAt)), now, notifier)
+
+			if err := svc.SendDueReminders(context.Background()); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+
+			if got := reminders(t, notifier); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemindersNotRepeated(t *testing.T) {
+	today := day(2025, time.March, 10)
+
+	repo := newReminderRepository(
+		expiring("Netflix", today.AddDate(0, 0, 2)),
+		expiring("Spotify", today.AddDate(0, 0, 6)),
+	)
+	now := clock.NewFake(today)
+	email := &recordingNotifier{name: "email"}
+	hook := &recordingNotifier{name: "webhook"}
+	svc := newReminderService(t, repo, now, email, hook)
+	ctx := context.Background()
+
+	steps := []struct {
+		name string
+		// advance - сдвиг часов перед запуском
+		advance time.Duration
+		// restart - запуск новым экземпляром сервиса над теми же отметками
+		restart bool
+		want    []sentReminder
+	}{
+		{"first run", 0, false, []sentReminder{{"Netflix", 2, 7}, {"Spotify", 6, 7}}},
+		{"same day", time.Hour, false, nil},
+		{"after restart", time.Hour, true, nil},
+		// Netflix переходит в однодневное окно, Spotify остается в недельном
+		{"next day", 24 * time.Hour, false, []sentReminder{{"Netflix", 1, 1}}},
+		{"expiry day", 24 * time.Hour, false, nil},
+		{"after expiry", 24 * time.Hour, false, nil},
+	}
+
+	for _, step := range steps {
+		now.Advance(step.advance)
+		if step.restart {
+			svc = newReminderService(t, repo, now, email, hook)
+		}
+
+		if err := svc.SendDueReminders(ctx); err != nil {
+			t.Fatalf("%s: send: %v", step.name, err)
+		}
+
+		// каждое напоминание уходит в каждый канал ровно один раз
+		for _, notifier := range []*recordingNotifier{email, hook} {
+			if got := reminders(t, notifier); !reflect.DeepEqual(got, step.want) {
+				t.Fatalf("%s: %s got %+v, want %+v", step.name, notifier.name, got, step.want)
+			}
+		}
+	}
+}
+
+func TestReminderAfterRenewal(t *testing.T) {
+	today := day(2025, time.March, 10)
+
+	repo := newReminderRepository(expiring("Netflix", today.AddDate(0, 0, 1)))
+	now := clock.NewFake(today)
+	notifier := &recordingNotifier{}
+	svc := newReminderService(t, repo, now, notifier)
+	ctx := context.Background()
+
+	if err := svc.SendDueReminders(ctx); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// подписку продлили на месяц, через месяц напоминания приходят снова
+	repo.records[0].ExpiresAt = repo.records[0].ExpiresAt.AddDate(0, 1, 0)
+	now.Set(repo.records[0].ExpiresAt.AddDate(0, 0, -1))
+
+	if err := svc.SendDueReminders(ctx); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	want := []sentReminder{{"Netflix", 1, 1}, {"Netflix", 1, 1}}
+	if got := reminders(t, notifier); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReminderRetriedAfterChannelFailure(t *testing.T) {
+	today := day(2025, time.March, 10)
+
+	repo := newReminderRepository(expiring("Netflix", today.AddDate(0, 0, 5)))
+	now := clock.NewFake(today)
+	email := &recordingNotifier{name: "email", err: errors.New("smtp is unavailable")}
+	hook := &recordingNotifier{name: "webhook"}
+	svc := newReminderService(t, repo, now, email, hook)
+	ctx := context.Background()
+
+	// ошибка канала не прерывает запуск и не мешает другим каналам
+	if err := svc.SendDueReminders(ctx); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := reminders(t, hook); len(got) != 1 {
+		t.Fatalf("webhook: got %+v, want one reminder", got)
+	}
+	if len(repo.sent) != 1 {
+		t.Fatalf("got %d sent marks, want only the webhook one", len(repo.sent))
+	}
+
+	// отметка снята, поэтому после восстановления канала напоминание уходит
+	email.err = nil
+	now.Advance(time.Hour)
+
+	if err := svc.SendDueReminders(ctx); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if got := reminders(t, email); !reflect.DeepEqual(got, []sentReminder{{"Netflix", 5, 7}}) {
+		t.Fatalf("email: got %+v, want the retried reminder", got)
+	}
+	if got := reminders(t, hook); len(got) != 0 {
+		t.Fatalf("webhook: got %+v, want no repeats", got)
+	}
+}
+
+func TestReminderWindowsValidated(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, windows := range [][]int{nil, {7, -1}} {
+		if _, err := services.NewReminderService(log, newReminderRepository(), nil, windows, nil); err == nil {
+			t.Fatalf("windows %v: expected error", windows)
+		}
+	}
+}
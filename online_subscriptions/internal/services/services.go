@@ -3,7 +3,7 @@ package services
 import (
 	"context"
 	"errors"
-	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
 	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
 	"gorm.io/gorm"
 	"log/slog"
@@ -16,6 +16,7 @@ var (
 	ErrUpdateFailed = errors.New("could not update record")
 	ErrDeleteFailed = errors.New("could not delete record")
 	ErrSumFailed    = errors.New("could not sum records")
+	ErrExportFailed = errors.New("could not export records")
 )
 
 type Repository interface {
@@ -24,44 +25,90 @@ type Repository interface {
 	GetRecordByID(ctx context.Context, id uint) (*entity.Record, error)
 	GetRecordsByUserID(ctx context.Context, userID string) ([]entity.Record, error)
 	GetRecordByUserIDAndServiceName(ctx context.Context, userID string, serviceName string) (*entity.Record, error)
+	GetRecordByNaturalKey(ctx context.Context, userID, serviceName string, createdAt time.Time) (*entity.Record, error)
 	UpdateRecord(ctx context.Context, record *entity.Record) error
+	// SaveRecords вставляет записи пачками по batchSize
+	SaveRecords(ctx context.Context, records []entity.Record, batchSize int) error
+	FindOverlappingRecords(ctx context.Context, userID, serviceName string, start, end time.Time, excludeID uint) ([]entity.Record, error)
 	ListRecords(ctx context.Context, limit, offset int, userID, serviceName string) ([]entity.Record, error)
+	// StreamRecords вызывает fn для каждой записи по курсору БД, ошибка fn прерывает обход
+	StreamRecords(ctx context.Context, userID, serviceName string, fn func(record *entity.Record) error) error
 	SumPriceForPeriod(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error)
+	// Transaction выполняет fn в транзакции, вызовы репозитория с переданным контекстом идут в ней же.
+	// Вложенный вызов откатывает только свои изменения
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type RecordService struct {
 	log              *slog.Logger
 	recordRepository Repository
+	validator        *RecordValidator
+	config           RecordServiceConfig
 }
 
-func NewRecordService(log *slog.Logger, recordRepository Repository) *RecordService {
-	return &RecordService{log: log, recordRepository: recordRepository}
+// RecordServiceConfig - настраиваемые правила RecordService
+type RecordServiceConfig struct {
+	OverlapPolicy OverlapPolicy
+	Batch         BatchLimits
+	// Budgets проверяет создаваемые и изменяемые подписки против бюджета пользователя, nil - без проверки
+	Budgets *BudgetService
+	// Aggregates отвечает на сумму за целые месяцы по месячным агрегатам, nil - всегда по записям
+	Aggregates *AggregateService
+	// Clock - источник текущей даты для проверки записей, nil - системные часы
+	Clock clock.Clock
 }
 
-func (s *RecordService) CreateRecord(ctx context.Context, record *entity.Record) error {
+func NewRecordService(log *slog.Logger, recordRepository Repository, validator *RecordValidator, config RecordServiceConfig) *RecordService {
+	config.Clock = clock.OrSystem(config.Clock)
+
+	return &RecordService{
+		log:              log,
+		recordRepository: recordRepository,
+		validator:        validator,
+		config:           config,
+	}
+}
+
+func (s *RecordService) CreateRecord(ctx context.Context, record *entity.Record) (*WriteResult, error) {
 	const op = "recordService.CreateRecord"
 
 	log := s.log.With(slog.String("operation", op))
 	log.Info("creating new record...")
 
-	now := time.Now()
-	created := record.CreatedAt
-	if created.IsZero() {
-		created = now
-	}
+	record.ID = 0
 
-	if record.ExpiresAt.Before(record.CreatedAt) || record.ExpiresAt.Before(time.Now()) {
-		return fmt.Errorf("%w: expires date must be after created date", ErrCreateFailed)
+	if err := s.validator.Validate(record, s.config.Clock.Now()); err != nil {
+		log.Warn("record validation failed", slog.Any("error", err))
+		return nil, err
 	}
 
-	if err := s.recordRepository.SaveRecord(ctx, record); err != nil {
+	var (
+		result *WriteResult
+		check  *BudgetCheck
+	)
+
+	err := s.recordRepository.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = s.writeWithOverlapPolicy(ctx, record, s.recordRepository.SaveRecord)
+		if err != nil {
+			return err
+		}
+
+		check, err = s.checkBudget(ctx, result, true)
+		return err
+	})
+	if err != nil {
 		log.Error("failed to save record", slog.Any("error", err))
-		return fmt.Errorf("%w: %v", ErrCreateFailed, err)
+		return nil, s.classifyWriteError(ctx, record, err, CodeCreateFailed, ErrCreateFailed)
+	}
+
+	if check != nil {
+		s.config.Budgets.Alert(ctx, check)
 	}
 
-	log.Info("record successfully created")
+	log.Info("record successfully created", slog.Bool("merged", result.Merged), slog.Int("warnings", len(result.Warnings)))
 
-	return nil
+	return result, nil
 }
 
 func (s *RecordService) DeleteRecordByID(ctx context.Context, id uint) error {
@@ -75,10 +122,10 @@ func (s *RecordService) DeleteRecordByID(ctx context.Context, id uint) error {
 		log.Error("failed to delete record", slog.Any("error", err))
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+			return errRecordNotFound(err)
 		}
 
-		return fmt.Errorf("%w: %v", ErrDeleteFailed, err)
+		return NewInternalError(CodeDeleteFailed, ErrDeleteFailed, err)
 	}
 
 	log.Info("record successfully deleted")
@@ -86,35 +133,66 @@ func (s *RecordService) DeleteRecordByID(ctx context.Context, id uint) error {
 	return nil
 }
 
-func (s *RecordService) UpdateRecord(ctx context.Context, record *entity.Record) error {
+func (s *RecordService) UpdateRecord(ctx context.Context, record *entity.Record) (*WriteResult, error) {
 	const op = "recordService.UpdateRecord"
 
 	log := s.log.With(slog.String("operation", op))
 	log.Info("updating record...")
 
-	now := time.Now()
-	created := record.CreatedAt
-	if created.IsZero() {
-		created = now
+	// без даты начала подписка сохраняет прежнюю: сегодняшняя дата по умолчанию - только для новых записей
+	if record.CreatedAt.IsZero() {
+		stored, err := s.GetRecordByID(ctx, record.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		record.CreatedAt = stored.CreatedAt
 	}
 
-	if record.ExpiresAt.Before(record.CreatedAt) || record.ExpiresAt.Before(time.Now()) {
-		return fmt.Errorf("%w: expires date must be after created date", ErrCreateFailed)
+	if err := s.validator.Validate(record, s.config.Clock.Now()); err != nil {
+		log.Warn("record validation failed", slog.Any("error", err))
+		return nil, err
 	}
 
-	if err := s.recordRepository.UpdateRecord(ctx, record); err != nil {
-		log.Error("failed to update record", slog.Any("error", err))
+	var (
+		result *WriteResult
+		check  *BudgetCheck
+	)
 
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	err := s.recordRepository.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = s.writeWithOverlapPolicy(ctx, record, s.recordRepository.UpdateRecord)
+		if err != nil {
 			return err
 		}
 
-		return fmt.Errorf("%w: %v", ErrUpdateFailed, err)
+		check, err = s.checkBudget(ctx, result, false)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to update record", slog.Any("error", err))
+		return nil, s.classifyWriteError(ctx, record, err, CodeUpdateFailed, ErrUpdateFailed)
+	}
+
+	if check != nil {
+		s.config.Budgets.Alert(ctx, check)
 	}
 
-	log.Info("record successfully updated")
+	log.Info("record successfully updated", slog.Bool("merged", result.Merged), slog.Int("warnings", len(result.Warnings)))
 
-	return nil
+	return result, nil
+}
+
+// PatchRecord применяет частичное изменение к существующей записи
+func (s *RecordService) PatchRecord(ctx context.Context, patch RecordPatch) (*WriteResult, error) {
+	record, err := s.GetRecordByID(ctx, patch.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	patch.Apply(record)
+
+	return s.UpdateRecord(ctx, record)
 }
 
 func (s *RecordService) GetRecordByID(ctx context.Context, id uint) (*entity.Record, error) {
@@ -129,10 +207,10 @@ func (s *RecordService) GetRecordByID(ctx context.Context, id uint) (*entity.Rec
 		log.Error("failed to get record", slog.Any("error", err))
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
+			return nil, errRecordNotFound(err)
 		}
 
-		return nil, fmt.Errorf("%w: %v", ErrGetFailed, err)
+		return nil, NewInternalError(CodeGetFailed, ErrGetFailed, err)
 	}
 
 	log.Info("record successfully retrieved")
@@ -149,7 +227,7 @@ func (s *RecordService) GetRecordsByUserID(ctx context.Context, userID string) (
 	records, err := s.recordRepository.GetRecordsByUserID(ctx, userID)
 	if err != nil {
 		log.Error("failed to get records", slog.Any("error", err))
-		return nil, fmt.Errorf("%w: %v", ErrGetFailed, err)
+		return nil, NewInternalError(CodeGetFailed, ErrGetFailed, err)
 	}
 
 	log.Info("records successfully retrieved")
@@ -168,10 +246,10 @@ func (s *RecordService) GetRecordByUserIDAndServiceName(ctx context.Context, use
 		log.Error("failed to get record", slog.Any("error", err))
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
+			return nil, errRecordNotFound(err)
 		}
 
-		return nil, fmt.Errorf("%w: %v", ErrGetFailed, err)
+		return nil, NewInternalError(CodeGetFailed, ErrGetFailed, err)
 	}
 
 	log.Info("record successfully retrieved")
@@ -188,7 +266,7 @@ func (s *RecordService) ListRecords(ctx context.Context, limit, offset int, user
 	records, err := s.recordRepository.ListRecords(ctx, limit, offset, userID, serviceName)
 	if err != nil {
 		log.Error("failed to get records", slog.Any("error", err))
-		return nil, fmt.Errorf("%w: %v", ErrGetFailed, err)
+		return nil, NewInternalError(CodeGetFailed, ErrGetFailed, err)
 	}
 
 	log.Info("records successfully retrieved")
@@ -196,16 +274,42 @@ func (s *RecordService) ListRecords(ctx context.Context, limit, offset int, user
 	return records, nil
 }
 
+// ExportRecords передает в fn все записи, подходящие под фильтры /records, без пагинации
+func (s *RecordService) ExportRecords(ctx context.Context, userID, serviceName string, fn func(record *entity.Record) error) error {
+	const op = "recordService.ExportRecords"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("exporting records...")
+
+	exported := 0
+
+	err := s.recordRepository.StreamRecords(ctx, userID, serviceName, func(record *entity.Record) error {
+		exported++
+		return fn(record)
+	})
+	if err != nil {
+		log.Error("failed to export records", slog.Int("exported", exported), slog.Any("error", err))
+		return NewInternalError(CodeExportFailed, ErrExportFailed, err)
+	}
+
+	log.Info("records successfully exported", slog.Int("exported", exported))
+
+	return nil
+}
+
 func (s *RecordService) SummaryPriceOfSelectedRecords(ctx context.Context, startTime, endTime time.Time, userID, serviceName string) (int, error) {
 	const op = "recordService.SummaryPriceOfSelectedRecords"
 
 	log := s.log.With(slog.String("operation", op))
 	log.Info("summary records...")
 
-	total, err := s.recordRepository.SumPriceForPeriod(ctx, startTime, endTime, userID, serviceName)
+	total, aggregated, err := s.config.Aggregates.sumPrice(ctx, startTime, endTime, userID, serviceName)
+	if err == nil && !aggregated {
+		total, err = s.recordRepository.SumPriceForPeriod(ctx, startTime, endTime, userID, serviceName)
+	}
 	if err != nil {
 		log.Error("failed to get records", slog.Any("error", err))
-		return 0, fmt.Errorf("%w: %v", ErrSumFailed, err)
+		return 0, NewInternalError(CodeSumFailed, ErrSumFailed, err)
 	}
 
 	log.Info("records successfully summary")
@@ -0,0 +1,231 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const recordUser = "0b7f5f8e-52f4-4c36-9d57-3b1d7c6a9e21"
+
+// newRecordService создает сервис записей над хранилищем в памяти с часами, стоящими на now
+func newRecordService(t *testing.T, rules services.ValidationRules, now time.Time) (*services.RecordService, *memory.Repository) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := memory.New(false, nil)
+
+	svc := services.NewRecordService(log, repo, services.NewRecordValidator(rules), services.RecordServiceConfig{
+		Clock: clock.NewFake(now),
+	})
+
+	return svc, repo
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestUpdateRecordKeepsCreatedAt(t *testing.T) {
+	svc, repo := newRecordService(t, services.ValidationRules{}, day(2026, time.March, 10))
+	ctx := context.Background()
+
+	created, err := svc.CreateRecord(ctx, &entity.Record{
+		ServiceName: "Netflix",
+		Price:       400,
+		UserID:      recordUser,
+		CreatedAt:   day(2026, time.January, 1),
+		ExpiresAt:   day(2026, time.July, 1),
+	})
+	if err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+
+	// PUT без created_at
+	if _, err := svc.UpdateRecord(ctx, &entity.Record{
+		ID:          created.Record.ID,
+		ServiceName: "Netflix",
+		Price:       500,
+		UserID:      recordUser,
+		ExpiresAt:   day(2026, time.September, 1),
+	}); err != nil {
+		t.Fatalf("update record: %v", err)
+	}
+
+	got, err := repo.GetRecordByID(ctx, created.Record.ID)
+	if err != nil {
+		t.Fatalf("get record: %v", err)
+	}
+	if !got.CreatedAt.Equal(day(2026, time.January, 1)) {
+		t.Fatalf("created_at moved to %s", got.CreatedAt)
+	}
+	if got.Price != 500 || !got.ExpiresAt.Equal(day(2026, time.September, 1)) {
+		t.Fatalf("update not applied: %+v", *got)
+	}
+}
+
+func TestUpdateRecordWithoutCreatedAtMissing(t *testing.T) {
+	svc, _ := newRecordService(t, services.ValidationRules{}, day(2026, time.March, 10))
+
+	_, err := svc.UpdateRecord(context.Background(), &entity.Record{
+		ID:          42,
+		ServiceName: "Netflix",
+		Price:       500,
+		UserID:      recordUser,
+		ExpiresAt:   day(2026, time.September, 1),
+	})
+
+	assertCode(t, err, services.CodeRecordNotFound)
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var svcErr *services.Error
+	if !errors.As(err, &svcErr) || svcErr.Code != code {
+		t.Fatalf("expected %s error, got %v", code, err)
+	}
+}
+
+func TestCreateRecordExpiredByClock(t *testing.T) {
+	today := day(2026, time.March, 10)
+
+	tests := []struct {
+		name      string
+		rules     services.ValidationRules
+		expiresAt time.Time
+		wantErr   bool
+	}{
+		{"expired yesterday", services.ValidationRules{}, today.AddDate(0, 0, -1), true},
+		{"expires today", services.ValidationRules{}, today, false},
+		{"expires tomorrow", services.ValidationRules{}, today.AddDate(0, 0, 1), false},
+		{"backdated allowed", services.ValidationRules{AllowBackdated: true}, today.AddDate(0, 0, -1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newRecordService(t, tt.rules, today.Add(15*time.Hour))
+
+			_, err := svc.CreateRecord(context.Background(), &entity.Record{
+				ServiceName: "Netflix",
+				Price:       400,
+				UserID:      recordUser,
+				CreatedAt:   day(2025, time.September, 1),
+				ExpiresAt:   tt.expiresAt,
+			})
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("create record: %v", err)
+				}
+				return
+			}
+
+			assertFieldError(t, err, "expires_at", "expired")
+		})
+	}
+}
+
+func TestCreateRecordFollowsClock(t *testing.T) {
+	now := clock.NewFake(day(2026, time.March, 10))
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := services.NewRecordService(log, memory.New(false, now), services.NewRecordValidator(services.ValidationRules{}), services.RecordServiceConfig{
+		Clock: now,
+	})
+
+	record := func(createdAt time.Time) *entity.Record {
+		return &entity.Record{ServiceName: "Netflix", Price: 400, UserID: recordUser, CreatedAt: createdAt, ExpiresAt: day(2026, time.April, 1)}
+	}
+
+	// без created_at подписка начинается в день часов
+	written, err := svc.CreateRecord(context.Background(), record(time.Time{}))
+	if err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+	if !written.Record.CreatedAt.Equal(day(2026, time.March, 10)) {
+		t.Fatalf("created_at: got %s, want 2026-03-10", written.Record.CreatedAt)
+	}
+
+	// после перевода часов та же дата окончания уже в прошлом
+	now.Set(day(2026, time.April, 2))
+
+	_, err = svc.CreateRecord(context.Background(), record(day(2026, time.March, 1)))
+	assertFieldError(t, err, "expires_at", "expired")
+}
+
+func TestUpdateRecordExpiredByClock(t *testing.T) {
+	svc, _ := newRecordService(t, services.ValidationRules{}, day(2026, time.March, 10))
+	ctx := context.Background()
+
+	created, err := svc.CreateRecord(ctx, &entity.Record{
+		ServiceName: "Netflix",
+		Price:       400,
+		UserID:      recordUser,
+		CreatedAt:   day(2026, time.January, 1),
+		ExpiresAt:   day(2026, time.July, 1),
+	})
+	if err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+
+	_, err = svc.UpdateRecord(ctx, &entity.Record{
+		ID:          created.Record.ID,
+		ServiceName: "Netflix",
+		Price:       400,
+		UserID:      recordUser,
+		CreatedAt:   day(2026, time.January, 1),
+		ExpiresAt:   day(2026, time.February, 1),
+	})
+	assertFieldError(t, err, "expires_at", "expired")
+}
+
+func TestImportRecordsBackdated(t *testing.T) {
+	rows := []services.ImportRow{{Line: 2, Record: entity.Record{
+		ServiceName: "Netflix",
+		Price:       400,
+		UserID:      recordUser,
+		CreatedAt:   day(2024, time.January, 1),
+		ExpiresAt:   day(2024, time.June, 1),
+	}}}
+
+	for _, allow := range []bool{false, true} {
+		svc, _ := newRecordService(t, services.ValidationRules{AllowBackdated: allow}, day(2026, time.March, 10))
+
+		results, err := svc.ImportRecords(context.Background(), rows, false)
+		if err != nil {
+			t.Fatalf("allow_backdated=%t: import records: %v", allow, err)
+		}
+
+		want := services.ImportFailed
+		if allow {
+			want = services.ImportCreated
+		}
+		if results[0].Action != want {
+			t.Fatalf("allow_backdated=%t: got %s (%v), want %s", allow, results[0].Action, results[0].Err, want)
+		}
+	}
+}
+
+func assertFieldError(t *testing.T, err error, field, code string) {
+	t.Helper()
+
+	var svcErr *services.Error
+	if !errors.As(err, &svcErr) || svcErr.Code != services.CodeValidationFailed {
+		t.Fatalf("expected %s error, got %v", services.CodeValidationFailed, err)
+	}
+
+	for _, f := range svcErr.Fields {
+		if f.Field == field && f.Code == code {
+			return
+		}
+	}
+
+	t.Fatalf("expected %s error on %s, got %+v", code, field, svcErr.Fields)
+}
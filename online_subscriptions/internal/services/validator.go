@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxCategoryLength = 64
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// ValidationRules задает ограничения на записи подписок
+type ValidationRules struct {
+	MaxPrice              int
+	MaxServiceNameLength  int
+	MaxSubscriptionMonths int
+	// AllowBackdated разрешает исторические подписки, которые уже закончились
+	AllowBackdated bool
+}
+
+// RecordValidator нормализует и проверяет запись перед сохранением.
+// Все нарушения собираются в одну ошибку, чтобы клиент мог исправить их за один раз
+type RecordValidator struct {
+	rules ValidationRules
+}
+
+func NewRecordValidator(rules ValidationRules) *RecordValidator {
+	return &RecordValidator{rules: rules}
+}
+
+// Validate проверяет запись относительно today - текущей даты сервиса
+func (v *RecordValidator) Validate(record *entity.Record, today time.Time) error {
+	var violations []FieldError
+
+	add := func(field, code, message string) {
+		violations = append(violations, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	record.UserID = strings.ToLower(strings.TrimSpace(record.UserID))
+	if !uuidPattern.MatchString(record.UserID) {
+		add("user_id", "uuid", "must be a valid UUID")
+	}
+
+	if record.Price < 0 {
+		add("price", "min", "must not be negative")
+	} else if v.rules.MaxPrice > 0 && record.Price > v.rules.MaxPrice {
+		add("price", "max", fmt.Sprintf("must be at most %d", v.rules.MaxPrice))
+	}
+
+	record.ServiceName = normalizeServiceName(record.ServiceName)
+	switch length := utf8.RuneCountInString(record.ServiceName); {
+	case length == 0:
+		add("service_name", "required", "must not be blank")
+	case v.rules.MaxServiceNameLength > 0 && length > v.rules.MaxServiceNameLength:
+		add("service_name", "max", fmt.Sprintf("must be at most %d characters", v.rules.MaxServiceNameLength))
+	}
+
+	record.Category = normalizeCategory(record.Category)
+	if utf8.RuneCountInString(record.Category) > maxCategoryLength {
+		add("category", "max", fmt.Sprintf("must be at most %d characters", maxCategoryLength))
+	}
+
+	today = truncateToDate(today)
+
+	// дата начала по умолчанию - сегодняшний день
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = today
+	}
+	record.CreatedAt = truncateToDate(record.CreatedAt)
+	record.ExpiresAt = truncateToDate(record.ExpiresAt)
+
+	switch {
+	case !record.ExpiresAt.After(record.CreatedAt):
+		add("expires_at", "invalid_range", "expires date must be after created date")
+	case !v.rules.AllowBackdated && record.ExpiresAt.Before(today):
+		add("expires_at", "expired", "expires date must not be in the past")
+	case v.rules.MaxSubscriptionMonths > 0 &&
+		record.ExpiresAt.After(record.CreatedAt.AddDate(0, v.rules.MaxSubscriptionMonths, 0)):
+		add("expires_at", "max_length", fmt.Sprintf("subscription must not be longer than %d months", v.rules.MaxSubscriptionMonths))
+	}
+
+	if len(violations) > 0 {
+		return NewValidationError("invalid record", violations...)
+	}
+
+	return nil
+}
+
+// normalizeServiceName убирает пробелы по краям и схлопывает повторяющиеся пробелы внутри
+func normalizeServiceName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// normalizeCategory приводит категорию к нижнему регистру, чтобы Streaming и streaming считались одной
+func normalizeCategory(category string) string {
+	return strings.ToLower(normalizeServiceName(category))
+}
+
+func truncateToDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,541 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/outbox"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/webhook"
+	"gorm.io/gorm"
+	"log/slog"
+	mrand "math/rand/v2"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrWebhookFailed = errors.New("could not process webhook")
+
+const (
+	CodeWebhookNotFound  = "webhook_not_found"
+	CodeDeliveryNotFound = "delivery_not_found"
+	CodeWebhookFailed    = "webhook_failed"
+)
+
+// статусы доставки
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *entity.Webhook) error
+	ListWebhooks(ctx context.Context) ([]entity.Webhook, error)
+	GetWebhookByID(ctx context.Context, id uint) (*entity.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *entity.Webhook) error
+	DeleteWebhook(ctx context.Context, id uint) error
+	CreateDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, status string, now time.Time, lease time.Duration, limit int) ([]entity.WebhookDelivery, error)
+	SaveDeliveryAttempt(ctx context.Context, delivery *entity.WebhookDelivery, attempt *entity.WebhookAttempt) error
+	UpdateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookID uint, status string, limit, offset int) ([]entity.WebhookDelivery, error)
+	GetDeliveryByID(ctx context.Context, id uint) (*entity.WebhookDelivery, error)
+	ListDeliveryAttempts(ctx context.Context, deliveryID uint) ([]entity.WebhookAttempt, error)
+}
+
+// WebhookConfig - доставка событий. Неудачная попытка повторяется через BackoffBase * 2^(n-1),
+// но не реже BackoffMax; после MaxAttempts попыток доставка помечается failed
+type WebhookConfig struct {
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	BatchSize   int
+	Concurrency int
+	Lease       time.Duration
+	// Clock задает время постановки и повторов доставок, nil - системные часы
+	Clock clock.Clock
+}
+
+// WebhookService управляет подписками на события и доставляет события подписчикам
+type WebhookService struct {
+	log    *slog.Logger
+	repo   WebhookRepository
+	sender *webhook.Sender
+	config WebhookConfig
+}
+
+func NewWebhookService(log *slog.Logger, repo WebhookRepository, sender *webhook.Sender, config WebhookConfig) *WebhookService {
+	config.Clock = clock.OrSystem(config.Clock)
+
+	return &WebhookService{
+		log:    log,
+		repo:   repo,
+		sender: sender,
+		config: config,
+	}
+}
+
+// WebhookInput - данные нового вебхука. Пустой Secret генерируется
+type WebhookInput struct {
+	URL    string
+	Events []EventType
+	Secret string
+	Active bool
+}
+
+// WebhookPatch - изменение вебхука, nil-поля не меняются
+type WebhookPatch struct {
+	ID           uint
+	URL          *string
+	Events       []EventType
+	Active       *bool
+	RotateSecret bool
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, input WebhookInput) (*entity.Webhook, error) {
+	const op = "webhookService.CreateWebhook"
+
+	log := s.log.With(slog.String("operation", op))
+	log.Info("creating webhook...")
+
+	events, err := validateWebhook(input.URL, input.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+
+	wh := &entity.Webhook{
+		URL:    input.URL,
+		Secret: secret,
+		Events: events,
+		Active: input.Active,
+	}
+
+	if err := s.repo.CreateWebhook(ctx, wh); err != nil {
+		log.Error("failed to create webhook", slog.Any("error", err))
+		return nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	log.Info("webhook created", slog.Uint64("webhook_id", uint64(wh.ID)))
+
+	return wh, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]entity.Webhook, error) {
+	webhooks, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		s.log.Error("failed to list webhooks", slog.String("operation", "webhookService.ListWebhooks"), slog.Any("error", err))
+		return nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	return webhooks, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uint) (*entity.Webhook, error) {
+	wh, err := s.repo.GetWebhookByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errWebhookNotFound(err)
+	}
+	if err != nil {
+		s.log.Error("failed to get webhook", slog.String("operation", "webhookService.GetWebhook"), slog.Any("error", err))
+		return nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	return wh, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, patch WebhookPatch) (*entity.Webhook, error) {
+	const op = "webhookService.UpdateWebhook"
+
+	log := s.log.With(slog.String("operation", op), slog.Uint64("webhook_id", uint64(patch.ID)))
+	log.Info("updating webhook...")
+
+	wh, err := s.GetWebhook(ctx, patch.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.URL != nil {
+		wh.URL = *patch.URL
+	}
+
+	events := WebhookEvents(wh)
+	if patch.Events != nil {
+		events = patch.Events
+	}
+
+	if wh.Events, err = validateWebhook(wh.URL, events); err != nil {
+		return nil, err
+	}
+
+	if patch.Active != nil {
+		wh.Active = *patch.Active
+	}
+
+	if patch.RotateSecret {
+		wh.Secret = newWebhookSecret()
+	}
+
+	if err := s.repo.UpdateWebhook(ctx, wh); err != nil {
+		log.Error("failed to update webhook", slog.Any("error", err))
+		return nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	log.Info("webhook updated")
+
+	return wh, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uint) error {
+	const op = "webhookService.DeleteWebhook"
+
+	log := s.log.With(slog.String("operation", op), slog.Uint64("webhook_id", uint64(id)))
+
+	err := s.repo.DeleteWebhook(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errWebhookNotFound(err)
+	}
+	if err != nil {
+		log.Error("failed to delete webhook", slog.Any("error", err))
+		return NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	log.Info("webhook deleted")
+
+	return nil
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID uint, status string, limit, offset int) ([]entity.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.repo.ListDeliveries(ctx, webhookID, status, limit, offset)
+	if err != nil {
+		s.log.Error("failed to list deliveries", slog.String("operation", "webhookService.ListDeliveries"), slog.Any("error", err))
+		return nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	return deliveries, nil
+}
+
+// GetDelivery возвращает доставку вместе с журналом попыток
+func (s *WebhookService) GetDelivery(ctx context.Context, id uint) (*entity.WebhookDelivery, []entity.WebhookAttempt, error) {
+	delivery, err := s.repo.GetDeliveryByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, NewNotFoundError(CodeDeliveryNotFound, "delivery not found", err)
+	}
+	if err != nil {
+		s.log.Error("failed to get delivery", slog.String("operation", "webhookService.GetDelivery"), slog.Any("error", err))
+		return nil, nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	attempts, err := s.repo.ListDeliveryAttempts(ctx, id)
+	if err != nil {
+		s.log.Error("failed to list attempts", slog.String("operation", "webhookService.GetDelivery"), slog.Any("error", err))
+		return nil, nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	return delivery, attempts, nil
+}
+
+// Redeliver сразу отправляет доставку заново, независимо от ее статуса. Счетчик попыток
+// сбрасывается: при неудаче доставка снова повторяется по расписанию
+func (s *WebhookService) Redeliver(ctx context.Context, id uint) (*entity.WebhookDelivery, error) {
+	const op = "webhookService.Redeliver"
+
+	log := s.log.With(slog.String("operation", op), slog.Uint64("delivery_id", uint64(id)))
+	log.Info("redelivering...")
+
+	delivery, _, err := s.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wh, err := s.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Attempts = 0
+
+	if err := s.attempt(ctx, wh, delivery); err != nil {
+		log.Error("failed to save delivery attempt", slog.Any("error", err))
+		return nil, NewInternalError(CodeWebhookFailed, ErrWebhookFailed, err)
+	}
+
+	log.Info("redelivered", slog.String("status", delivery.Status))
+
+	return delivery, nil
+}
+
+// Name - имя приемника outbox
+func (s *WebhookService) Name() string {
+	return "webhooks"
+}
+
+// Publish ставит события outbox в очередь доставки всем активным вебхукам, подписанным на их тип.
+// Вебхук получает только события, произошедшие после его создания. Доставки создаются в транзакции
+// relay, поэтому вместе с позицией приемника: повтор пачки не создает повторных доставок
+func (s *WebhookService) Publish(ctx context.Context, messages []outbox.Message) error {
+	events := make([]Event, len(messages))
+	for i, message := range messages {
+		if err := json.Unmarshal(message.Payload, &events[i]); err != nil {
+			return fmt.Errorf("could not decode event %s: %w", message.EventID, err)
+		}
+	}
+
+	return s.enqueue(ctx, events)
+}
+
+func (s *WebhookService) enqueue(ctx context.Context, events []Event) error {
+	webhooks, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := s.config.Clock.Now()
+	var deliveries []entity.WebhookDelivery
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		for _, wh := range webhooks {
+			if !wh.Active || !subscribed(&wh, event.Type) || wh.CreatedAt.After(event.OccurredAt) {
+				continue
+			}
+
+			deliveries = append(deliveries, entity.WebhookDelivery{
+				WebhookID:     wh.ID,
+				EventID:       event.ID,
+				Event:         string(event.Type),
+				Payload:       string(payload),
+				Status:        DeliveryPending,
+				NextAttemptAt: now,
+			})
+		}
+	}
+
+	return s.repo.CreateDeliveries(ctx, deliveries)
+}
+
+// DeliverDue отправляет доставки, время которых пришло. Запускается планировщиком
+func (s *WebhookService) DeliverDue(ctx context.Context) error {
+	const op = "webhookService.DeliverDue"
+
+	log := s.log.With(slog.String("operation", op))
+
+	deliveries, err := s.repo.ClaimDueDeliveries(ctx, DeliveryPending, s.config.Clock.Now(), s.config.Lease, s.config.BatchSize)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	webhooks := make(map[uint]*entity.Webhook)
+	for _, delivery := range deliveries {
+		if _, ok := webhooks[delivery.WebhookID]; ok {
+			continue
+		}
+
+		wh, err := s.repo.GetWebhookByID(ctx, delivery.WebhookID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		webhooks[delivery.WebhookID] = wh
+	}
+
+	var (
+		wg        sync.WaitGroup
+		slots     = make(chan struct{}, max(s.config.Concurrency, 1))
+		mu        sync.Mutex
+		succeeded int
+	)
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		wh := webhooks[delivery.WebhookID]
+
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if wh == nil || !wh.Active {
+				// вебхук отключили после постановки в очередь; доставку можно отправить вручную позже
+				delivery.Status = DeliveryFailed
+				delivery.LastError = "webhook is disabled or deleted"
+				if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+					log.Error("failed to update delivery", slog.Uint64("delivery_id", uint64(delivery.ID)), slog.Any("error", err))
+				}
+				return
+			}
+
+			if err := s.attempt(ctx, wh, delivery); err != nil {
+				log.Error("failed to save delivery attempt", slog.Uint64("delivery_id", uint64(delivery.ID)), slog.Any("error", err))
+				return
+			}
+
+			if delivery.Status == DeliverySucceeded {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	log.Info("deliveries processed", slog.Int("claimed", len(deliveries)), slog.Int("succeeded", succeeded))
+
+	return nil
+}
+
+// attempt делает одну попытку доставки и сохраняет ее результат. Ошибка - только ошибка сохранения
+func (s *WebhookService) attempt(ctx context.Context, wh *entity.Webhook, delivery *entity.WebhookDelivery) error {
+	started := time.Now()
+
+	resp, sendErr := s.sender.Send(ctx, webhook.Request{
+		URL:        wh.URL,
+		Secret:     wh.Secret,
+		DeliveryID: delivery.ID,
+		Event:      delivery.Event,
+		Payload:    []byte(delivery.Payload),
+	})
+
+	delivery.Attempts++
+	delivery.LastStatusCode = resp.StatusCode
+	delivery.LastError = ""
+
+	attempt := &entity.WebhookAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.Attempts,
+		StatusCode: resp.StatusCode,
+		Duration:   time.Since(started),
+	}
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = DeliverySucceeded
+	case delivery.Attempts >= s.config.MaxAttempts:
+		delivery.Status = DeliveryFailed
+	default:
+		delivery.Status = DeliveryPending
+		delivery.NextAttemptAt = s.config.Clock.Now().Add(s.backoff(delivery.Attempts))
+	}
+
+	if sendErr != nil {
+		delivery.LastError = sendErr.Error()
+		attempt.Error = sendErr.Error()
+		if resp.Body != "" {
+			attempt.Error += ": " + resp.Body
+		}
+	}
+
+	// результат попытки сохраняем, даже если запуск отменяют при остановке
+	return s.repo.SaveDeliveryAttempt(context.WithoutCancel(ctx), delivery, attempt)
+}
+
+// backoff - экспоненциальная задержка перед попыткой attempt+1 со случайной добавкой до 20%,
+// чтобы повторы к одному получателю не шли волной
+func (s *WebhookService) backoff(attempt int) time.Duration {
+	delay := s.config.BackoffMax
+	if attempt-1 < 32 {
+		if d := s.config.BackoffBase << (attempt - 1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	return delay + time.Duration(mrand.Int64N(int64(delay)/5+1))
+}
+
+func validateWebhook(rawURL string, events []EventType) (string, error) {
+	var violations []FieldError
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		violations = append(violations, FieldError{Field: "url", Code: "url", Message: "must be an absolute http or https URL"})
+	}
+
+	if len(events) == 0 {
+		violations = append(violations, FieldError{Field: "events", Code: "required", Message: "is required"})
+	}
+
+	seen := make(map[EventType]bool, len(events))
+	names := make([]string, 0, len(events))
+
+	for i, event := range events {
+		if !event.Valid() {
+			violations = append(violations, FieldError{
+				Field:   fmt.Sprintf("events[%d]", i),
+				Code:    "oneof",
+				Message: "must be one of: record.created record.updated record.deleted record.expired",
+			})
+			continue
+		}
+
+		if !seen[event] {
+			seen[event] = true
+			names = append(names, string(event))
+		}
+	}
+
+	if len(violations) > 0 {
+		return "", NewValidationError("request validation failed", violations...)
+	}
+
+	return strings.Join(names, ","), nil
+}
+
+// WebhookEvents возвращает типы событий, на которые подписан вебхук
+func WebhookEvents(wh *entity.Webhook) []EventType {
+	var events []EventType
+	for _, name := range strings.Split(wh.Events, ",") {
+		if name != "" {
+			events = append(events, EventType(name))
+		}
+	}
+
+	return events
+}
+
+func subscribed(wh *entity.Webhook, eventType EventType) bool {
+	for _, event := range WebhookEvents(wh) {
+		if event == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+func errWebhookNotFound(err error) *Error {
+	return NewNotFoundError(CodeWebhookNotFound, "webhook not found", err)
+}
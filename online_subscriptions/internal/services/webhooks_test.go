@@ -0,0 +1,448 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/outbox"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/webhook"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWebhooks - WebhookRepository в памяти. Доставки разбираются, как в Postgres: статус и срок
+type fakeWebhooks struct {
+	mu         sync.Mutex
+	webhooks   map[uint]*entity.Webhook
+	deliveries map[uint]*entity.WebhookDelivery
+	attempts   []entity.WebhookAttempt
+}
+
+func newFakeWebhooks(webhooks ...entity.Webhook) *fakeWebhooks {
+	f := &fakeWebhooks{webhooks: make(map[uint]*entity.Webhook), deliveries: make(map[uint]*entity.WebhookDelivery)}
+	for _, wh := range webhooks {
+		_ = f.CreateWebhook(context.Background(), &wh)
+	}
+
+	return f
+}
+
+func (f *fakeWebhooks) CreateWebhook(ctx context.Context, wh *entity.Webhook) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	wh.ID = uint(len(f.webhooks) + 1)
+	stored := *wh
+	f.webhooks[wh.ID] = &stored
+
+	return nil
+}
+
+func (f *fakeWebhooks) ListWebhooks(ctx context.Context) ([]entity.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var webhooks []entity.Webhook
+	for _, wh := range f.webhooks {
+		webhooks = append(webhooks, *wh)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	return webhooks, nil
+}
+
+func (f *fakeWebhooks) GetWebhookByID(ctx context.Context, id uint) (*entity.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	wh, ok := f.webhooks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *wh
+
+	return &found, nil
+}
+
+func (f *fakeWebhooks) UpdateWebhook(ctx context.Context, wh *entity.Webhook) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := *wh
+	f.webhooks[wh.ID] = &stored
+
+	return nil
+}
+
+func (f *fakeWebhooks) DeleteWebhook(ctx context.Context, id uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.webhooks[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(f.webhooks, id)
+
+	return nil
+}
+
+func (f *fakeWebhooks) CreateDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		delivery.ID = uint(len(f.deliveries) + 1)
+		f.deliveries[delivery.ID] = &delivery
+	}
+
+	return nil
+}
+
+func (f *fakeWebhooks) ClaimDueDeliveries(ctx context.Context, status string, now time.Time, lease time.Duration, limit int) ([]entity.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var due []entity.WebhookDelivery
+	for _, delivery := range f.deliveries {
+		if delivery.Status == status && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, *delivery)
+			delivery.NextAttemptAt = now.Add(lease)
+		}
+	}
+
+	return due, nil
+}
+
+func (f *fakeWebhooks) SaveDeliveryAttempt(ctx context.Context, delivery *entity.WebhookDelivery, attempt *entity.WebhookAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := *delivery
+	f.deliveries[delivery.ID] = &stored
+	f.attempts = append(f.attempts, *attempt)
+
+	return nil
+}
+
+func (f *fakeWebhooks) UpdateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := *delivery
+	f.deliveries[delivery.ID] = &stored
+
+	return nil
+}
+
+func (f *fakeWebhooks) ListDeliveries(ctx context.Context, webhookID uint, status string, limit, offset int) ([]entity.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var deliveries []entity.WebhookDelivery
+	for _, delivery := range f.deliveries {
+		if delivery.WebhookID == webhookID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+
+	return deliveries, nil
+}
+
+func (f *fakeWebhooks) GetDeliveryByID(ctx context.Context, id uint) (*entity.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delivery, ok := f.deliveries[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *delivery
+
+	return &found, nil
+}
+
+func (f *fakeWebhooks) ListDeliveryAttempts(ctx context.Context, deliveryID uint) ([]entity.WebhookAttempt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var attempts []entity.WebhookAttempt
+	for _, attempt := range f.attempts {
+		if attempt.DeliveryID == deliveryID {
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	return attempts, nil
+}
+
+// endpoint - получатель вебхуков, отвечающий статусами из statuses по очереди, после них - 200
+type endpoint struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func newEndpoint(t *testing.T, statuses ...int) (*endpoint, string) {
+	t.Helper()
+
+	e := &endpoint{statuses: statuses}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		e.mu.Lock()
+		e.requests = append(e.requests, r)
+		e.bodies = append(e.bodies, string(body))
+		status := http.StatusOK
+		if len(e.statuses) > 0 {
+			status, e.statuses = e.statuses[0], e.statuses[1:]
+		}
+		e.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	return e, srv.URL
+}
+
+func (e *endpoint) calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.requests)
+}
+
+var webhookStart = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func newWebhookService(repo *fakeWebhooks, now *clock.Fake, config services.WebhookConfig) *services.WebhookService {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	config.Clock = now
+	if config.BatchSize == 0 {
+		config.BatchSize = 10
+	}
+
+	return services.NewWebhookService(log, repo, webhook.NewSender(time.Second, now), config)
+}
+
+// publish передает события сервису так же, как relay outbox
+func publish(t *testing.T, svc *services.WebhookService, events ...services.Event) {
+	t.Helper()
+
+	messages := make([]outbox.Message, len(events))
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages[i] = outbox.Message{Sequence: uint(i + 1), EventID: event.ID, Type: string(event.Type), Payload: payload}
+	}
+
+	if err := svc.Publish(context.Background(), messages); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+}
+
+func webhookEvent(id string, eventType services.EventType) services.Event {
+	return services.Event{ID: id, Type: eventType, OccurredAt: webhookStart, Record: entity.Record{ID: 1, ServiceName: "Netflix", Price: 400, UserID: recordUser}}
+}
+
+func TestWebhookPublishFiltersEvents(t *testing.T) {
+	repo := newFakeWebhooks(
+		entity.Webhook{URL: "http://created.test", Events: "record.created", Active: true, CreatedAt: webhookStart.Add(-time.Hour)},
+		entity.Webhook{URL: "http://all.test", Events: "record.created,record.deleted", Active: true, CreatedAt: webhookStart.Add(-time.Hour)},
+		entity.Webhook{URL: "http://inactive.test", Events: "record.created", Active: false, CreatedAt: webhookStart.Add(-time.Hour)},
+		// создан после события и не получает его
+		entity.Webhook{URL: "http://late.test", Events: "record.created", Active: true, CreatedAt: webhookStart.Add(time.Minute)},
+	)
+	svc := newWebhookService(repo, clock.NewFake(webhookStart), services.WebhookConfig{MaxAttempts: 3})
+
+	publish(t, svc, webhookEvent("evt_1", services.EventRecordCreated), webhookEvent("evt_2", services.EventRecordDeleted), webhookEvent("evt_3", services.EventRecordUpdated))
+
+	got := make(map[uint][]string)
+	for _, delivery := range repo.deliveries {
+		got[delivery.WebhookID] = append(got[delivery.WebhookID], delivery.EventID)
+		if delivery.Status != services.DeliveryPending || !delivery.NextAttemptAt.Equal(webhookStart) {
+			t.Fatalf("delivery not queued for now: %+v", *delivery)
+		}
+	}
+	for _, ids := range got {
+		sort.Strings(ids)
+	}
+
+	want := map[uint][]string{1: {"evt_1"}, 2: {"evt_1", "evt_2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("deliveries by webhook: got %v, want %v", got, want)
+	}
+}
+
+func TestDeliverDueRetriesWithBackoff(t *testing.T) {
+	ep, url := newEndpoint(t, http.StatusInternalServerError, http.StatusBadGateway)
+	repo := newFakeWebhooks(entity.Webhook{URL: url, Secret: "whsec_test", Events: "record.created", Active: true, CreatedAt: webhookStart.Add(-time.Hour)})
+	now := clock.NewFake(webhookStart)
+	svc := newWebhookService(repo, now, services.WebhookConfig{MaxAttempts: 5, BackoffBase: time.Minute, BackoffMax: time.Hour, Lease: time.Minute})
+
+	publish(t, svc, webhookEvent("evt_1", services.EventRecordCreated))
+	ctx := context.Background()
+
+	// задержка перед попыткой n+1 - BackoffBase * 2^(n-1) плюс до 20%
+	for attempt, delay := range []time.Duration{time.Minute, 2 * time.Minute} {
+		if err := svc.DeliverDue(ctx); err != nil {
+			t.Fatalf("deliver due: %v", err)
+		}
+
+		delivery := repo.deliveries[1]
+		if delivery.Status != services.DeliveryPending || delivery.Attempts != attempt+1 || delivery.LastStatusCode < 500 {
+			t.Fatalf("attempt %d: got %+v", attempt+1, *delivery)
+		}
+
+		wait := delivery.NextAttemptAt.Sub(now.Now())
+		if wait < delay || wait > delay+delay/5 {
+			t.Fatalf("attempt %d: retry in %s, want %s..%s", attempt+1, wait, delay, delay+delay/5)
+		}
+
+		// до срока повтора доставка не отправляется
+		now.Advance(delay - time.Second)
+		if err := svc.DeliverDue(ctx); err != nil {
+			t.Fatalf("deliver due: %v", err)
+		}
+		if ep.calls() != attempt+1 {
+			t.Fatalf("attempt %d: sent before the retry was due", attempt+1)
+		}
+
+		now.Set(delivery.NextAttemptAt)
+	}
+
+	if err := svc.DeliverDue(ctx); err != nil {
+		t.Fatalf("deliver due: %v", err)
+	}
+
+	delivery := repo.deliveries[1]
+	if delivery.Status != services.DeliverySucceeded || delivery.Attempts != 3 || delivery.LastError != "" {
+		t.Fatalf("got %+v, want succeeded on the third attempt", *delivery)
+	}
+
+	attempts, _ := repo.ListDeliveryAttempts(ctx, delivery.ID)
+	codes := make([]int, len(attempts))
+	for i, attempt := range attempts {
+		codes[i] = attempt.StatusCode
+	}
+	if len(codes) != 3 || codes[0] != 500 || codes[1] != 502 || codes[2] != 200 {
+		t.Fatalf("attempt log: got %v, want [500 502 200]", codes)
+	}
+
+	// каждая попытка подписана временем своей отправки
+	last := ep.requests[2]
+	if want := webhook.Sign([]byte("whsec_test"), now.Now(), []byte(ep.bodies[2])); last.Header.Get(webhook.SignatureHeader) != want {
+		t.Fatalf("signature: got %q, want %q", last.Header.Get(webhook.SignatureHeader), want)
+	}
+	if last.Header.Get(webhook.EventHeader) != "record.created" {
+		t.Fatalf("event header: got %q", last.Header.Get(webhook.EventHeader))
+	}
+}
+
+func TestDeliverDueGivesUp(t *testing.T) {
+	ep, url := newEndpoint(t, 500, 500, 500, 500, 500)
+	repo := newFakeWebhooks(entity.Webhook{URL: url, Events: "record.created", Active: true, CreatedAt: webhookStart.Add(-time.Hour)})
+	now := clock.NewFake(webhookStart)
+	svc := newWebhookService(repo, now, services.WebhookConfig{MaxAttempts: 4, BackoffBase: time.Minute, BackoffMax: 3 * time.Minute})
+
+	publish(t, svc, webhookEvent("evt_1", services.EventRecordCreated))
+	ctx := context.Background()
+
+	// 1m, 2m, затем потолок BackoffMax
+	for _, delay := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		if err := svc.DeliverDue(ctx); err != nil {
+			t.Fatalf("deliver due: %v", err)
+		}
+
+		delivery := repo.deliveries[1]
+		if wait := delivery.NextAttemptAt.Sub(now.Now()); wait < delay || wait > delay+delay/5 {
+			t.Fatalf("retry in %s, want %s..%s", wait, delay, delay+delay/5)
+		}
+
+		now.Set(delivery.NextAttemptAt)
+	}
+
+	if err := svc.DeliverDue(ctx); err != nil {
+		t.Fatalf("deliver due: %v", err)
+	}
+
+	delivery := repo.deliveries[1]
+	if delivery.Status != services.DeliveryFailed || delivery.Attempts != 4 || delivery.LastStatusCode != 500 || delivery.LastError == "" {
+		t.Fatalf("got %+v, want failed after 4 attempts", *delivery)
+	}
+
+	// проваленная доставка больше не отправляется
+	now.Advance(time.Hour)
+	if err := svc.DeliverDue(ctx); err != nil {
+		t.Fatalf("deliver due: %v", err)
+	}
+	if ep.calls() != 4 {
+		t.Fatalf("got %d requests, want 4", ep.calls())
+	}
+
+	// ручной повтор сбрасывает счетчик попыток
+	redelivered, err := svc.Redeliver(ctx, delivery.ID)
+	if err != nil {
+		t.Fatalf("redeliver: %v", err)
+	}
+	if redelivered.Status != services.DeliveryPending || redelivered.Attempts != 1 {
+		t.Fatalf("redelivered: got %+v, want pending after one attempt", *redelivered)
+	}
+}
+
+func TestDeliverDueSkipsDisabledWebhooks(t *testing.T) {
+	ep, url := newEndpoint(t)
+	repo := newFakeWebhooks(
+		entity.Webhook{URL: url, Events: "record.created", Active: true, CreatedAt: webhookStart.Add(-time.Hour)},
+		entity.Webhook{URL: url, Events: "record.created", Active: true, CreatedAt: webhookStart.Add(-time.Hour)},
+		entity.Webhook{URL: url, Events: "record.created", Active: true, CreatedAt: webhookStart.Add(-time.Hour)},
+	)
+	svc := newWebhookService(repo, clock.NewFake(webhookStart), services.WebhookConfig{MaxAttempts: 3, Concurrency: 2})
+	ctx := context.Background()
+
+	publish(t, svc, webhookEvent("evt_1", services.EventRecordCreated))
+
+	// после постановки в очередь первый вебхук отключили, второй удалили
+	inactive := false
+	if _, err := svc.UpdateWebhook(ctx, services.WebhookPatch{ID: 1, Active: &inactive}); err != nil {
+		t.Fatalf("disable webhook: %v", err)
+	}
+	if err := svc.DeleteWebhook(ctx, 2); err != nil {
+		t.Fatalf("delete webhook: %v", err)
+	}
+
+	if err := svc.DeliverDue(ctx); err != nil {
+		t.Fatalf("deliver due: %v", err)
+	}
+
+	if ep.calls() != 1 {
+		t.Fatalf("got %d requests, want only the active webhook", ep.calls())
+	}
+
+	for _, delivery := range repo.deliveries {
+		switch delivery.WebhookID {
+		case 3:
+			if delivery.Status != services.DeliverySucceeded {
+				t.Fatalf("active webhook: got %+v", *delivery)
+			}
+		default:
+			if delivery.Status != services.DeliveryFailed || delivery.Attempts != 0 || delivery.LastError != "webhook is disabled or deleted" {
+				t.Fatalf("webhook %d: got %+v, want failed without attempts", delivery.WebhookID, *delivery)
+			}
+		}
+	}
+}
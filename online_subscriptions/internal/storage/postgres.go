@@ -7,22 +7,159 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
+	"math"
+	"time"
 )
 
-var db *gorm.DB
+// postgresDriver - основное хранилище, поддерживает все возможности сервиса
+type postgresDriver struct{}
 
-func InitDB(cfg *config.Config) (*gorm.DB, error) {
+func (postgresDriver) Open(cfg *config.Config) (*gorm.DB, error) {
+	return openPostgres(cfg.DB, connection{
+		host:     cfg.DB.Host,
+		port:     cfg.DB.Port,
+		user:     cfg.DB.User,
+		password: cfg.DB.Password,
+		dbname:   cfg.DB.Dbname,
+		sslmode:  cfg.DB.Sslmode,
+	})
+}
+
+// connection - адрес и учетные данные сервера Postgres
+type connection struct {
+	host, port, user, password, dbname, sslmode string
+}
+
+// replicaConnection дополняет настройки реплики значениями основной базы
+func replicaConnection(cfg config.DBConfig) connection {
+	or := func(value, fallback string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	}
+
+	return connection{
+		host:     cfg.Replica.Host,
+		port:     or(cfg.Replica.Port, cfg.Port),
+		user:     or(cfg.Replica.User, cfg.User),
+		password: or(cfg.Replica.Password, cfg.Password),
+		dbname:   or(cfg.Replica.Dbname, cfg.Dbname),
+		sslmode:  or(cfg.Replica.Sslmode, cfg.Sslmode),
+	}
+}
+
+// openPostgres подключается к серверу, повторяя попытки по настройкам connect_*, и настраивает пул
+func openPostgres(cfg config.DBConfig, conn connection) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		cfg.DB.Host, cfg.DB.User, cfg.DB.Password, cfg.DB.Dbname, cfg.DB.Port, cfg.DB.Sslmode)
+		conn.host, conn.user, conn.password, conn.dbname, conn.port, conn.sslmode)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// неизвестные драйверу параметры передаются серверу как параметры сессии
+	if cfg.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", cfg.StatementTimeout.Milliseconds())
+	}
+
+	db, err := connectWithRetry(cfg, conn.host, func(timeout time.Duration) (*gorm.DB, error) {
+		// без connect_timeout попытка к недоступному хосту длится до системного таймаута TCP
+		attemptDSN := fmt.Sprintf("%s connect_timeout=%d", dsn, int(math.Ceil(timeout.Seconds())))
+
+		return gorm.Open(postgres.Open(attemptDSN), &gorm.Config{TranslateError: true})
+	})
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		return nil, err
 	}
 
-	if err := db.AutoMigrate(&entity.Record{}); err != nil {
-		log.Fatalf("Could not migrate table: %v", err)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
 	}
 
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
 	return db, nil
 }
+
+// connectWithRetry вызывает open, пока подключение не удастся или не истечет connect_timeout.
+// Пауза между попытками удваивается от connect_backoff до connect_backoff_max. open получает
+// время, оставшееся до истечения срока, чтобы одна попытка его не превысила
+func connectWithRetry(cfg config.DBConfig, host string, open func(timeout time.Duration) (*gorm.DB, error)) (*gorm.DB, error) {
+	deadline := time.Now().Add(cfg.ConnectTimeout)
+	backoff := cfg.ConnectBackoff
+
+	for attempt := 1; ; attempt++ {
+		db, err := open(time.Until(deadline))
+		if err == nil {
+			return db, nil
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("could not connect to %s after %d attempts in %s: %w", host, attempt, cfg.ConnectTimeout, err)
+		}
+
+		log.Printf("database %s is not available (attempt %d), retrying in %s: %v", host, attempt, backoff, err)
+		time.Sleep(backoff)
+
+		backoff = min(backoff*2, cfg.ConnectBackoffMax)
+	}
+}
+
+// Migrate применяет схему и ограничения, которые не выражаются тегами gorm
+func (postgresDriver) Migrate(db *gorm.DB, rejectOverlaps bool) error {
+	err := db.AutoMigrate(
+		&entity.Record{},
+		&entity.CalendarFeed{},
+		&entity.SentNotification{},
+		&entity.Webhook{},
+		&entity.WebhookDelivery{},
+		&entity.WebhookAttempt{},
+		&entity.OutboxEvent{},
+		&entity.RelayCheckpoint{},
+		&entity.LedgerEntry{},
+		&entity.Budget{},
+		&entity.BudgetCategoryLimit{},
+		&entity.BudgetAlert{},
+		&entity.PriceChange{},
+		&entity.MonthlyAggregate{},
+		&entity.AggregateState{},
+	)
+	if err != nil {
+		return fmt.Errorf("could not migrate tables: %w", err)
+	}
+
+	if rejectOverlaps {
+		// при наличии в таблице уже пересекающихся записей ограничение создать не получится,
+		// в этом случае пересечения отсекаются только проверкой в сервисе
+		if err := addOverlapConstraint(db); err != nil {
+			log.Printf("could not add overlap constraint, relying on service checks: %v", err)
+		}
+
+		return nil
+	}
+
+	return db.Exec(fmt.Sprintf("ALTER TABLE records DROP CONSTRAINT IF EXISTS %s", overlapConstraint)).Error
+}
+
+// addOverlapConstraint запрещает пересечение периодов подписок одного пользователя на один сервис
+func addOverlapConstraint(db *gorm.DB) error {
+	var exists bool
+
+	// ограничение ищется в текущей схеме: в других схемах той же базы может быть своя таблица records
+	err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = ? AND connamespace = (SELECT oid FROM pg_namespace WHERE nspname = current_schema()))", overlapConstraint).
+		Scan(&exists).Error
+	if err != nil || exists {
+		return err
+	}
+
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist").Error; err != nil {
+		return err
+	}
+
+	return db.Exec(fmt.Sprintf(`ALTER TABLE records ADD CONSTRAINT %s EXCLUDE USING gist (
+		user_id WITH =,
+		service_name WITH =,
+		daterange(created_at, expires_at) WITH &&
+	)`, overlapConstraint)).Error
+}
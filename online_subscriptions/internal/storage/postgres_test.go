@@ -0,0 +1,26 @@
+package storage_test
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/pgtest"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/storage"
+	"testing"
+)
+
+func TestMain(m *testing.M) { pgtest.Main(m) }
+
+func TestPostgresMigrateTwice(t *testing.T) {
+	db := pgtest.DB(t)
+
+	driver, err := storage.NewDriver(config.DriverPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := driver.Migrate(db, true); err != nil {
+		t.Fatalf("repeated migrate: %v", err)
+	}
+	if err := driver.Migrate(db, false); err != nil {
+		t.Fatalf("migrate without overlap constraint: %v", err)
+	}
+}
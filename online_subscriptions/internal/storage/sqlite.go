@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/entity"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// коды расширенных ошибок SQLite
+const (
+	sqliteConstraintCheck   = 275
+	sqliteConstraintTrigger = 1811
+)
+
+// sqliteDriver - файловое хранилище для локального запуска без Postgres. Поддерживает только записи:
+// отчеты, агрегаты, outbox и остальное используют SQL, специфичный для Postgres
+type sqliteDriver struct{}
+
+func (sqliteDriver) Open(cfg *config.Config) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)", cfg.Storage.SQLite.Path)
+
+	db, err := gorm.Open(&sqliteDialector{Dialector: sqlite.Open(dsn).(*sqlite.Dialector)}, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite допускает одного писателя: общее подключение исключает SQLITE_BUSY между транзакциями
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	// столбцы type:date в SQLite хранят время целиком, поэтому даты усекаются при записи, как это делает Postgres
+	if err := db.Callback().Create().Before("gorm:create").Register("storage:truncate_dates", truncateDates(true)); err != nil {
+		return nil, err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("storage:truncate_dates", truncateDates(false)); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate создает таблицу записей. Пересечение подписок запрещается триггерами вместо exclusion-ограничения
+func (sqliteDriver) Migrate(db *gorm.DB, rejectOverlaps bool) error {
+	if err := db.AutoMigrate(&entity.Record{}); err != nil {
+		return fmt.Errorf("could not migrate tables: %w", err)
+	}
+
+	for _, event := range []string{"insert", "update"} {
+		if err := db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s_%s", overlapConstraint, event)).Error; err != nil {
+			return err
+		}
+	}
+
+	if !rejectOverlaps {
+		return nil
+	}
+
+	// пустой период, как и пустой daterange в Postgres, ни с чем не пересекается
+	const overlapCondition = `NEW.created_at < NEW.expires_at AND EXISTS (
+		SELECT 1 FROM records
+		WHERE user_id = NEW.user_id
+			AND service_name = NEW.service_name
+			AND created_at < expires_at
+			AND created_at < NEW.expires_at
+			AND expires_at > NEW.created_at
+			%s
+	)`
+
+	triggers := map[string]string{
+		"insert": fmt.Sprintf(overlapCondition, ""),
+		"update": fmt.Sprintf(overlapCondition, "AND id <> NEW.id"),
+	}
+
+	for event, condition := range triggers {
+		err := db.Exec(fmt.Sprintf(`CREATE TRIGGER %[1]s_%[2]s BEFORE %[3]s ON records
+			WHEN %[4]s
+			BEGIN SELECT RAISE(ABORT, '%[1]s'); END`, overlapConstraint, event, strings.ToUpper(event), condition)).Error
+		if err != nil {
+			return fmt.Errorf("could not add overlap trigger: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sqliteDialector дополняет перевод ошибок: нарушение CHECK и срабатывание триггера пересечения
+// приводятся к тем же ошибкам gorm, что и в Postgres
+type sqliteDialector struct {
+	*sqlite.Dialector
+}
+
+func (d *sqliteDialector) Translate(err error) error {
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqliteConstraintCheck:
+			return gorm.ErrCheckConstraintViolated
+		case sqliteConstraintTrigger:
+			if strings.Contains(err.Error(), overlapConstraint) {
+				return fmt.Errorf("%w: %s", gorm.ErrDuplicatedKey, overlapConstraint)
+			}
+		}
+	}
+
+	return d.Dialector.Translate(err)
+}
+
+// truncateDates усекает значения столбцов type:date до даты в UTC. При создании (fillCreated)
+// пустая дата создания заполняется текущей, как default CURRENT_DATE
+func truncateDates(fillCreated bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil {
+			return
+		}
+
+		var fields []*schema.Field
+		for _, field := range db.Statement.Schema.Fields {
+			if field.DataType == "date" {
+				fields = append(fields, field)
+			}
+		}
+
+		if len(fields) == 0 {
+			return
+		}
+
+		targets := []reflect.Value{db.Statement.ReflectValue}
+		if dest := reflect.ValueOf(db.Statement.Dest); dest.Kind() == reflect.Ptr {
+			targets = append(targets, dest.Elem())
+		}
+
+		for _, target := range targets {
+			switch target.Kind() {
+			case reflect.Slice, reflect.Array:
+				for i := 0; i < target.Len(); i++ {
+					truncateValue(db, fields, fillCreated, reflect.Indirect(target.Index(i)))
+				}
+			case reflect.Struct:
+				truncateValue(db, fields, fillCreated, target)
+			}
+		}
+	}
+}
+
+func truncateValue(db *gorm.DB, fields []*schema.Field, fillCreated bool, value reflect.Value) {
+	if value.Type() != db.Statement.Schema.ModelType || !value.CanAddr() {
+		return
+	}
+
+	ctx := db.Statement.Context
+
+	for _, field := range fields {
+		current, zero := field.ValueOf(ctx, value)
+
+		date, ok := current.(time.Time)
+		if !ok {
+			continue
+		}
+
+		if zero {
+			if !fillCreated || field.AutoCreateTime == 0 {
+				continue
+			}
+			date = db.NowFunc()
+		}
+
+		y, m, d := date.Date()
+		if err := field.Set(ctx, value, time.Date(y, m, d, 0, 0, 0, 0, time.UTC)); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+	}
+}
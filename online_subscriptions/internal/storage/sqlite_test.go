@@ -0,0 +1,56 @@
+package storage_test
+
+import (
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/conformance"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/storage"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+// openSQLite открывает базу SQLite во временном каталоге теста и применяет схему
+func openSQLite(t *testing.T, policy string) *gorm.DB {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.Driver = config.DriverSQLite
+	cfg.Storage.SQLite.Path = filepath.Join(t.TempDir(), "subscriptions.db")
+	cfg.Overlap.Policy = policy
+
+	db, err := storage.InitDB(cfg, nil)
+	if err != nil {
+		t.Fatalf("init sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = storage.Close(db) })
+
+	return db
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	for _, policy := range []string{"warn", "reject"} {
+		t.Run(policy, func(t *testing.T) {
+			conformance.Run(t, func(t *testing.T) services.Repository {
+				return repository.NewRepository(openSQLite(t, policy))
+			})
+		})
+	}
+}
+
+func TestSQLiteMigrateTwice(t *testing.T) {
+	db := openSQLite(t, "reject")
+
+	driver, err := storage.NewDriver(config.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := driver.Migrate(db, true); err != nil {
+		t.Fatalf("repeated migrate: %v", err)
+	}
+	if err := driver.Migrate(db, false); err != nil {
+		t.Fatalf("migrate without overlap triggers: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/config"
+	"gorm.io/gorm"
+)
+
+// имя ограничения, запрещающего пересечение подписок; по нему ошибка записи распознается как конфликт
+const overlapConstraint = "records_no_overlap"
+
+// Driver - СУБД хранилища: подключение и схема. Схема включает то, что gorm не переносит
+// между диалектами: ограничение на пересечение подписок, значения по умолчанию и типы дат
+type Driver interface {
+	Open(cfg *config.Config) (*gorm.DB, error)
+	Migrate(db *gorm.DB, rejectOverlaps bool) error
+}
+
+// NewDriver возвращает драйвер по значению storage.driver
+func NewDriver(name string) (Driver, error) {
+	switch name {
+	case config.DriverPostgres:
+		return postgresDriver{}, nil
+	case config.DriverSQLite:
+		return sqliteDriver{}, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", name)
+	}
+}
+
+// InitDB подключается к хранилищу из storage.driver и применяет схему. Время, которое gorm
+// и репозиторий записывают сами (NowFunc), берется из clk
+func InitDB(cfg *config.Config, clk clock.Clock) (*gorm.DB, error) {
+	driver, err := NewDriver(cfg.Storage.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := driver.Open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	db.NowFunc = clock.OrSystem(clk).Now
+
+	if err := driver.Migrate(db, cfg.Overlap.Policy == "reject"); err != nil {
+		Close(db)
+		return nil, fmt.Errorf("could not migrate database: %w", err)
+	}
+
+	return db, nil
+}
+
+// InitReplica подключается к реплике Postgres из postgres.replica. Без реплики возвращает nil.
+// Схема на реплике не применяется: она приходит с основной базы
+func InitReplica(cfg *config.Config) (*gorm.DB, error) {
+	if cfg.Storage.Driver != config.DriverPostgres || cfg.DB.Replica.Host == "" {
+		return nil, nil
+	}
+
+	db, err := openPostgres(cfg.DB, replicaConnection(cfg.DB))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to replica: %w", err)
+	}
+
+	return db, nil
+}
+
+// Close закрывает пул подключений db, если он открыт
+func Close(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
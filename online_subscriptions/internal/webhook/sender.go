@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader: t=<unix time>,v1=<hex HMAC-SHA256(secret, "<t>.<body>")>.
+	// Время входит в подпись, чтобы получатель мог отбрасывать повторно отправленные старые запросы
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	// сколько тела ответа сохраняется в журнале попыток
+	maxResponseBody = 1 << 10
+)
+
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID uint
+	Event      string
+	Payload    []byte
+}
+
+type Response struct {
+	StatusCode int
+	Body       string
+}
+
+// Sender отправляет подписанные события, успехом считается любой ответ 2xx
+type Sender struct {
+	client *http.Client
+	clock  clock.Clock
+}
+
+// NewSender создает отправителя, clk задает время подписи, nil - системные часы
+func NewSender(timeout time.Duration, clk clock.Clock) *Sender {
+	return &Sender{client: &http.Client{Timeout: timeout}, clock: clock.OrSystem(clk)}
+}
+
+func (s *Sender) Send(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Payload))
+	if err != nil {
+		return Response{}, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "online-subscriptions-webhooks/1.0")
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(req.DeliveryID), 10))
+	httpReq.Header.Set(SignatureHeader, Sign([]byte(req.Secret), s.clock.Now(), req.Payload))
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	result := Response{StatusCode: resp.StatusCode, Body: string(body)}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return result, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return result, nil
+}
+
+// Sign подписывает тело запроса, получатель проверяет подпись тем же секретом
+func Sign(secret []byte, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package webhook_test
+
+import (
+	"context"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/clock"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256("whsec_test", `1700000000.{"id":"evt_1"}`), посчитан независимо от пакета
+	const want = "t=1700000000,v1=c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925"
+
+	got := webhook.Sign([]byte("whsec_test"), time.Unix(1700000000, 0), []byte(`{"id":"evt_1"}`))
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	// время входит в подпись
+	if other := webhook.Sign([]byte("whsec_test"), time.Unix(1700000001, 0), []byte(`{"id":"evt_1"}`)); other == want {
+		t.Fatal("signature does not depend on time")
+	}
+}
+
+func TestSenderSend(t *testing.T) {
+	now := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	payload := []byte(`{"id":"evt_1","type":"record.created"}`)
+
+	var got *http.Request
+	var body []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	sender := webhook.NewSender(time.Second, clock.NewFake(now))
+
+	resp, err := sender.Send(context.Background(), webhook.Request{
+		URL:        srv.URL,
+		Secret:     "whsec_test",
+		DeliveryID: 7,
+		Event:      "record.created",
+		Payload:    payload,
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status: got %d, want 202", resp.StatusCode)
+	}
+
+	if got.Method != http.MethodPost || string(body) != string(payload) {
+		t.Fatalf("request: got %s %q", got.Method, body)
+	}
+	if got.Header.Get(webhook.EventHeader) != "record.created" || got.Header.Get(webhook.DeliveryHeader) != "7" {
+		t.Fatalf("headers: event %q, delivery %q", got.Header.Get(webhook.EventHeader), got.Header.Get(webhook.DeliveryHeader))
+	}
+	if want := webhook.Sign([]byte("whsec_test"), now, payload); got.Header.Get(webhook.SignatureHeader) != want {
+		t.Fatalf("signature: got %q, want %q", got.Header.Get(webhook.SignatureHeader), want)
+	}
+}
+
+func TestSenderRejectedResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, strings.Repeat("x", 4096), http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	resp, err := webhook.NewSender(time.Second, nil).Send(context.Background(), webhook.Request{URL: srv.URL, Payload: []byte("{}")})
+	if err == nil {
+		t.Fatal("expected error for 503")
+	}
+
+	// тело ответа сохраняется для журнала попыток, но не целиком
+	if resp.StatusCode != http.StatusServiceUnavailable || len(resp.Body) != 1024 {
+		t.Fatalf("got status %d and %d bytes of body", resp.StatusCode, len(resp.Body))
+	}
+}
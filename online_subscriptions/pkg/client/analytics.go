@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AnalyticsQuery - период и фильтры отчетов. Нулевой To - текущий месяц, нулевой From - 11 месяцев до To
+type AnalyticsQuery struct {
+	From        time.Time
+	To          time.Time
+	UserID      string
+	ServiceName string
+}
+
+func (q AnalyticsQuery) query() url.Values {
+	query := url.Values{}
+
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(monthLayout))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.Format(monthLayout))
+	}
+	if q.UserID != "" {
+		query.Set("user_id", q.UserID)
+	}
+	if q.ServiceName != "" {
+		query.Set("service_name", q.ServiceName)
+	}
+
+	return query
+}
+
+// MRRPoint - выручка на конец месяца и изменение к предыдущему месяцу
+type MRRPoint struct {
+	Month         Month `json:"month"`
+	MRR           int   `json:"mrr"`
+	Change        int   `json:"change"`
+	Subscriptions int   `json:"subscriptions"`
+}
+
+// ChurnPoint - новые и завершившиеся подписки за месяц. ChurnRate - в процентах
+// от действующих на начало месяца
+type ChurnPoint struct {
+	Month         Month   `json:"month"`
+	New           int     `json:"new"`
+	Churned       int     `json:"churned"`
+	Net           int     `json:"net"`
+	Active        int     `json:"active"`
+	ChurnRate     float64 `json:"churn_rate"`
+	CumulativeNet int     `json:"cumulative_net"`
+}
+
+// ServiceRank - расходы и подписчики сервиса за период. Share - доля в общих расходах, в процентах
+type ServiceRank struct {
+	ServiceName     string  `json:"service_name"`
+	Spend           int     `json:"spend"`
+	Subscribers     int     `json:"subscribers"`
+	SpendRank       int     `json:"spend_rank"`
+	SubscribersRank int     `json:"subscribers_rank"`
+	Share           float64 `json:"share"`
+}
+
+// Lifetime - срок жизни подписок, в итоге ServiceName пуст
+type Lifetime struct {
+	ServiceName   string  `json:"service_name,omitempty"`
+	Subscriptions int     `json:"subscriptions"`
+	Active        int     `json:"active"`
+	AverageDays   float64 `json:"average_days"`
+	MedianDays    float64 `json:"median_days"`
+}
+
+type LifetimeReport struct {
+	Total    Lifetime   `json:"total"`
+	Services []Lifetime `json:"services"`
+}
+
+// Retention - удержание когорты через Offset месяцев после начала. Rate - в процентах
+type Retention struct {
+	Offset   int     `json:"offset"`
+	Retained int     `json:"retained"`
+	Rate     float64 `json:"rate"`
+}
+
+// Cohort - подписки, начавшиеся в одном месяце, и их удержание
+type Cohort struct {
+	Cohort    Month       `json:"cohort"`
+	Size      int         `json:"size"`
+	Retention []Retention `json:"retention"`
+}
+
+// сортировка топа сервисов
+const (
+	RankBySpend       = "spend"
+	RankBySubscribers = "subscribers"
+)
+
+func (c *Client) MRR(ctx context.Context, q AnalyticsQuery) ([]MRRPoint, error) {
+	var points []MRRPoint
+	if err := c.analytics(ctx, "/v1/analytics/mrr", q.query(), &points); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+func (c *Client) Churn(ctx context.Context, q AnalyticsQuery) ([]ChurnPoint, error) {
+	var points []ChurnPoint
+	if err := c.analytics(ctx, "/v1/analytics/churn", q.query(), &points); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// TopServices возвращает limit сервисов (по умолчанию 10) по расходам или числу подписчиков.
+// Пустой by - RankBySpend
+func (c *Client) TopServices(ctx context.Context, q AnalyticsQuery, by string, limit int) ([]ServiceRank, error) {
+	query := q.query()
+
+	if by != "" {
+		query.Set("by", by)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var ranks []ServiceRank
+	if err := c.analytics(ctx, "/v1/analytics/top-services", query, &ranks); err != nil {
+		return nil, err
+	}
+
+	return ranks, nil
+}
+
+func (c *Client) Lifetime(ctx context.Context, q AnalyticsQuery) (*LifetimeReport, error) {
+	var report LifetimeReport
+	if err := c.analytics(ctx, "/v1/analytics/lifetime", q.query(), &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func (c *Client) Cohorts(ctx context.Context, q AnalyticsQuery) ([]Cohort, error) {
+	var cohorts []Cohort
+	if err := c.analytics(ctx, "/v1/analytics/cohorts", q.query(), &cohorts); err != nil {
+		return nil, err
+	}
+
+	return cohorts, nil
+}
+
+func (c *Client) analytics(ctx context.Context, path string, query url.Values, out any) error {
+	_, err := c.doJSON(ctx, request{method: http.MethodGet, path: path, query: query}, out)
+	return err
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordPatch - частичное обновление записи ID, nil-поля не меняются
+type RecordPatch struct {
+	ID          uint
+	ServiceName *string
+	Price       *int
+	UserID      *string
+	Category    *string
+	CreatedAt   *time.Time
+	ExpiresAt   *time.Time
+}
+
+type patchRequest struct {
+	ID          uint    `json:"id,omitempty"`
+	ServiceName *string `json:"service_name,omitempty"`
+	Price       *int    `json:"price,omitempty"`
+	UserID      *string `json:"user_id,omitempty"`
+	Category    *string `json:"category,omitempty"`
+	ExpiresAt   *string `json:"expires_at,omitempty"`
+	CreatedAt   *string `json:"created_at,omitempty"`
+}
+
+func (p RecordPatch) request() patchRequest {
+	req := patchRequest{
+		ID:          p.ID,
+		ServiceName: p.ServiceName,
+		Price:       p.Price,
+		UserID:      p.UserID,
+		Category:    p.Category,
+	}
+
+	if p.ExpiresAt != nil {
+		value := p.ExpiresAt.Format(dateLayout)
+		req.ExpiresAt = &value
+	}
+
+	if p.CreatedAt != nil {
+		value := p.CreatedAt.Format(dateLayout)
+		req.CreatedAt = &value
+	}
+
+	return req
+}
+
+// BatchItem - результат одного элемента пакета. Status - HTTP-статус, который получил бы
+// элемент отдельным запросом; Error задан для неудавшихся элементов
+type BatchItem struct {
+	Index    int      `json:"index"`
+	Status   int      `json:"status"`
+	Record   *Record  `json:"record,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    *Error   `json:"error,omitempty"`
+}
+
+// BatchResult - итог пакетной операции. Для атомарного пакета Committed=false означает, что
+// ни один элемент не применен, причина - в Error элементов
+type BatchResult struct {
+	Atomic    bool        `json:"atomic"`
+	Committed bool        `json:"committed"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Items     []BatchItem `json:"items"`
+}
+
+// CreateRecords создает записи пакетом. atomic - все или ничего
+func (c *Client) CreateRecords(ctx context.Context, items []RecordInput, atomic bool) (*BatchResult, error) {
+	body := struct {
+		Items []recordRequest `json:"items"`
+	}{Items: make([]recordRequest, len(items))}
+
+	for i, item := range items {
+		body.Items[i] = item.request()
+	}
+
+	return c.batch(ctx, http.MethodPost, atomic, body)
+}
+
+// PatchRecords частично обновляет записи пакетом
+func (c *Client) PatchRecords(ctx context.Context, patches []RecordPatch, atomic bool) (*BatchResult, error) {
+	body := struct {
+		Items []patchRequest `json:"items"`
+	}{Items: make([]patchRequest, len(patches))}
+
+	for i, patch := range patches {
+		body.Items[i] = patch.request()
+	}
+
+	return c.batch(ctx, http.MethodPatch, atomic, body)
+}
+
+// DeleteRecords удаляет записи пакетом
+func (c *Client) DeleteRecords(ctx context.Context, ids []uint, atomic bool) (*BatchResult, error) {
+	body := struct {
+		IDs []uint `json:"ids"`
+	}{IDs: ids}
+
+	return c.batch(ctx, http.MethodDelete, atomic, body)
+}
+
+// batch вызывает /v1/subscriptions:batch. Отклоненный пакет сервер описывает тем же BatchResult
+// со статусом ошибки, поэтому он возвращается без ошибки; ошибка - только ответ problem+json
+func (c *Client) batch(ctx context.Context, method string, atomic bool, body any) (*BatchResult, error) {
+	req := request{
+		method: method,
+		path:   "/v1/subscriptions:batch",
+		query:  url.Values{"atomic": {strconv.FormatBool(atomic)}},
+		body:   body,
+	}
+
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 || strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") {
+		return nil, readError(resp)
+	}
+
+	var result BatchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode %s %s response: %w", method, req.path, err)
+	}
+
+	return &result, nil
+}
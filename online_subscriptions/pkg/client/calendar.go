@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// FeedToken - токен ленты календаря и готовая ссылка для подписки в календаре
+type FeedToken struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// IssueFeedToken выдает токен ленты календаря пользователя. Требует токен администратора:
+// WithHeader("Authorization", "Bearer <token>")
+func (c *Client) IssueFeedToken(ctx context.Context, userID string) (*FeedToken, error) {
+	var token FeedToken
+
+	req := request{method: http.MethodPost, path: "/v1/users/" + url.PathEscape(userID) + "/calendar/token"}
+	if _, err := c.doJSON(ctx, req, &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RevokeFeedToken отзывает все выданные токены ленты пользователя. Требует токен администратора
+func (c *Client) RevokeFeedToken(ctx context.Context, userID string) error {
+	req := request{method: http.MethodDelete, path: "/v1/users/" + url.PathEscape(userID) + "/calendar/token"}
+	_, err := c.doJSON(ctx, req, nil)
+
+	return err
+}
+
+// CalendarFeed возвращает ленту пользователя в формате iCalendar
+func (c *Client) CalendarFeed(ctx context.Context, userID, token string) ([]byte, error) {
+	req := request{
+		method: http.MethodGet,
+		path:   "/v1/users/" + url.PathEscape(userID) + "/calendar.ics",
+		query:  url.Values{"token": {token}},
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
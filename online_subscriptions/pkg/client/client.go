@@ -0,0 +1,293 @@
+// Package client - Go-клиент API онлайн подписок. Покрывает маршруты API v1 (routes.RegisterRoutes):
+// подписки, пакетные операции, импорт и выгрузку, прогноз, отчеты и ленту календаря.
+//
+// Пакет не зависит от внутренних пакетов сервиса, типы запросов и ответов объявлены здесь же.
+// Ошибки API возвращаются как *Error с кодом из ответа problem+json:
+//
+//	c, err := client.New("http://localhost:8080/api")
+//	record, err := c.GetRecord(ctx, 1)
+//	if client.IsNotFound(err) {
+//		...
+//	}
+//
+// Идемпотентные запросы (GET, PUT, DELETE) повторяются при сетевых ошибках и ответах 429, 502, 503, 504
+// по правилам RetryPolicy. POST не повторяется: повтор мог бы создать запись дважды
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// форматы дат API: даты - DD-MM-YYYY, месяцы - MM-YYYY
+const (
+	dateLayout  = "02-01-2006"
+	monthLayout = "01-2006"
+)
+
+// RetryPolicy - повтор идемпотентных запросов. Пауза перед n-й повторной попыткой выбирается
+// случайно от 0 до min(BaseDelay*2^(n-1), MaxDelay); заголовок Retry-After ее заменяет
+type RetryPolicy struct {
+	// MaxAttempts - общее число попыток, 1 - без повторов
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy - три попытки с паузой до 2 секунд
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retry      RetryPolicy
+	header     http.Header
+}
+
+type Option func(*Client)
+
+// WithHTTPClient задает HTTP-клиент, по умолчанию http.Client с таймаутом 30 секунд
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetry задает правила повтора запросов
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithHeader добавляет заголовок ко всем запросам, например для трассировки
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// New создает клиент. baseURL - адрес API вместе с префиксом, например http://localhost:8080/api
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base url %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(parsed.String(), "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+		header:     make(http.Header),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+
+	return c, nil
+}
+
+// request - описание вызова API. path задается относительно baseURL, сегменты уже экранированы
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	// body кодируется в JSON; для multipart вместо него задаются raw и contentType
+	body        any
+	raw         io.Reader
+	contentType string
+}
+
+// do выполняет запрос и возвращает ответ с кодом 2xx. Остальные ответы превращаются в *Error,
+// тело ответа закрывает вызывающий
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	return nil, readError(resp)
+}
+
+// doJSON выполняет запрос и декодирует ответ в out, если out задан
+func (c *Client) doJSON(ctx context.Context, req request, out any) (*http.Response, error) {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("decode %s %s response: %w", req.method, req.path, err)
+		}
+	}
+
+	return resp, nil
+}
+
+// send выполняет запрос с повторами и возвращает ответ с любым кодом
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("encode %s %s request: %w", req.method, req.path, err)
+		}
+	}
+
+	attempts := 1
+	if idempotent(req.method) && req.raw == nil {
+		attempts = c.retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		var body io.Reader
+		switch {
+		case req.raw != nil:
+			body = req.raw
+		case payload != nil:
+			body = bytes.NewReader(payload)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, values := range c.header {
+			httpReq.Header[key] = values
+		}
+
+		httpReq.Header.Set("Accept", "application/json, application/problem+json")
+		switch {
+		case req.raw != nil:
+			httpReq.Header.Set("Content-Type", req.contentType)
+		case payload != nil:
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+
+		if attempt >= attempts || ctx.Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := c.retry.delay(attempt, resp)
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryable сообщает, имеет ли смысл повторить запрос: сеть или перегрузка сервера
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	limit := p.BaseDelay << (attempt - 1)
+	if limit <= 0 || limit > p.MaxDelay {
+		limit = p.MaxDelay
+	}
+
+	if limit <= 0 {
+		return 0
+	}
+
+	return rand.N(limit + 1)
+}
+
+// warnings разбирает заголовки Warning вида 299 - "сообщение"
+func warnings(header http.Header) []string {
+	var result []string
+
+	for _, value := range header.Values("Warning") {
+		parts := strings.SplitN(value, " ", 3)
+		if len(parts) < 3 {
+			continue
+		}
+
+		message, err := strconv.Unquote(parts[2])
+		if err != nil {
+			message = parts[2]
+		}
+
+		result = append(result, message)
+	}
+
+	return result
+}
+
+func idPath(format string, ids ...uint) string {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,435 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/handlers"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/importer"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/repository/memory"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/routes"
+	"github.com/14kear/effective_mobile/online_subscriptions/internal/services"
+	"github.com/14kear/effective_mobile/online_subscriptions/pkg/client"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testUser = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// flakyHandler отвечает 503 на первые fail запросов с методом method и считает запросы по методам
+type flakyHandler struct {
+	next http.Handler
+
+	mu       sync.Mutex
+	method   string
+	fail     int
+	requests map[string]int
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests[r.Method]++
+	failing := r.Method == h.method && h.fail > 0
+	if failing {
+		h.fail--
+	}
+	h.mu.Unlock()
+
+	if failing {
+		http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// failNext заставляет следующие n запросов с методом method завершиться ответом 503
+func (h *flakyHandler) failNext(method string, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.method, h.fail = method, n
+	h.requests = make(map[string]int)
+}
+
+func (h *flakyHandler) count(method string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.requests[method]
+}
+
+// newClient поднимает операции с подписками API v1 над хранилищем в памяти и возвращает клиент к ним
+// с тремя попытками без пауз
+func newClient(t *testing.T) (*client.Client, *flakyHandler) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := services.NewRecordService(log, memory.New(false, nil), services.NewRecordValidator(services.ValidationRules{AllowBackdated: true}), services.RecordServiceConfig{})
+
+	r := gin.New()
+	r.NoRoute(handlers.NotFound)
+	routes.RegisterRecordRoutes(r.Group("/api/v1"), handlers.NewRecordHandler(svc, nil, nil), handlers.NewImportHandler(importer.NewImporter(log, svc, 0)))
+
+	flaky := &flakyHandler{next: r, requests: make(map[string]int)}
+
+	srv := httptest.NewServer(flaky)
+	t.Cleanup(srv.Close)
+
+	c, err := client.New(srv.URL+"/api", client.WithRetry(client.RetryPolicy{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	return c, flaky
+}
+
+func date(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	parsed, err := time.Parse("02-01-2006", value)
+	if err != nil {
+		t.Fatalf("parse date %q: %v", value, err)
+	}
+
+	return parsed
+}
+
+func input(t *testing.T, service string, price int, createdAt, expiresAt string) client.RecordInput {
+	t.Helper()
+
+	return client.RecordInput{
+		ServiceName: service,
+		Price:       price,
+		UserID:      testUser,
+		CreatedAt:   date(t, createdAt),
+		ExpiresAt:   date(t, expiresAt),
+	}
+}
+
+func create(t *testing.T, c *client.Client, in client.RecordInput) *client.Record {
+	t.Helper()
+
+	result, err := c.CreateRecord(context.Background(), in)
+	if err != nil {
+		t.Fatalf("create %s: %v", in.ServiceName, err)
+	}
+
+	return result.Record
+}
+
+func assertAPIError(t *testing.T, err error, status int, code string) *client.Error {
+	t.Helper()
+
+	apiErr, ok := client.AsError(err)
+	if !ok {
+		t.Fatalf("expected *client.Error, got %T: %v", err, err)
+	}
+	if apiErr.Status != status || apiErr.Code != code {
+		t.Fatalf("expected %d %s, got %d %s (%s)", status, code, apiErr.Status, apiErr.Code, apiErr.Detail)
+	}
+
+	return apiErr
+}
+
+func TestRecordCRUD(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := context.Background()
+
+	created := create(t, c, input(t, "Netflix", 400, "01-01-2025", "01-07-2025"))
+	if created.ID == 0 || created.ServiceName != "Netflix" || created.Price != 400 || created.UserID != testUser {
+		t.Fatalf("unexpected created record: %+v", created)
+	}
+
+	got, err := c.GetRecord(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("get record: %v", err)
+	}
+	if !got.CreatedAt.Equal(date(t, "01-01-2025")) || !got.ExpiresAt.Equal(date(t, "01-07-2025")) {
+		t.Fatalf("unexpected dates: %s - %s", got.CreatedAt, got.ExpiresAt)
+	}
+
+	if _, err := c.UpdateRecord(ctx, created.ID, input(t, "Netflix", 500, "01-01-2025", "01-08-2025")); err != nil {
+		t.Fatalf("update record: %v", err)
+	}
+
+	price := 600
+	patched, err := c.PatchRecord(ctx, client.RecordPatch{ID: created.ID, Price: &price})
+	if err != nil {
+		t.Fatalf("patch record: %v", err)
+	}
+	if patched.Record.Price != 600 || !patched.Record.ExpiresAt.Equal(date(t, "01-08-2025")) {
+		t.Fatalf("unexpected patched record: %+v", patched.Record)
+	}
+
+	if err := c.DeleteRecord(ctx, created.ID); err != nil {
+		t.Fatalf("delete record: %v", err)
+	}
+
+	_, err = c.GetRecord(ctx, created.ID)
+	if !client.IsNotFound(err) || !client.HasCode(err, client.CodeRecordNotFound) {
+		t.Fatalf("expected record_not_found after delete, got %v", err)
+	}
+
+	if err := c.DeleteRecord(ctx, created.ID); !client.IsNotFound(err) {
+		t.Fatalf("expected not found on second delete, got %v", err)
+	}
+}
+
+func TestFindRecord(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := context.Background()
+
+	create(t, c, input(t, "Netflix", 400, "01-01-2025", "01-03-2025"))
+	latest := create(t, c, input(t, "Netflix", 500, "01-04-2025", "01-06-2025"))
+	create(t, c, input(t, "Spotify", 200, "01-05-2025", "01-06-2025"))
+
+	found, err := c.FindRecord(ctx, testUser, "Netflix")
+	if err != nil {
+		t.Fatalf("find record: %v", err)
+	}
+	if found.ID != latest.ID {
+		t.Fatalf("expected latest record %d, got %+v", latest.ID, found)
+	}
+
+	_, err = c.FindRecord(ctx, testUser, "Okko")
+	assertAPIError(t, err, http.StatusNotFound, client.CodeRecordNotFound)
+}
+
+func TestSummary(t *testing.T) {
+	c, _ := newClient(t)
+
+	create(t, c, input(t, "Netflix", 100, "01-01-2025", "01-02-2025"))
+	create(t, c, input(t, "Spotify", 200, "01-03-2025", "01-04-2025"))
+	create(t, c, input(t, "YouTube", 400, "31-12-2024", "31-01-2025"))
+	create(t, c, input(t, "Okko", 800, "02-03-2025", "02-04-2025"))
+
+	tests := []struct {
+		name  string
+		query client.SummaryQuery
+		want  int
+	}{
+		{"inclusive bounds", client.SummaryQuery{From: date(t, "01-01-2025"), To: date(t, "01-03-2025"), UserID: testUser}, 300},
+		{"service filter", client.SummaryQuery{From: date(t, "01-01-2025"), To: date(t, "31-03-2025"), ServiceName: "Okko"}, 800},
+		{"empty period", client.SummaryQuery{From: date(t, "01-06-2025"), To: date(t, "30-06-2025")}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, err := c.Summary(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("summary: %v", err)
+			}
+			if total != tt.want {
+				t.Fatalf("got %d, want %d", total, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryPeriodReversed(t *testing.T) {
+	c, _ := newClient(t)
+
+	_, err := c.Summary(context.Background(), client.SummaryQuery{From: date(t, "01-03-2025"), To: date(t, "01-01-2025")})
+	if !client.IsValidation(err) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestProblemDecoding(t *testing.T) {
+	c, _ := newClient(t)
+
+	in := input(t, "Netflix", -1, "01-01-2025", "01-07-2025")
+	in.UserID = "not-a-uuid"
+
+	_, err := c.CreateRecord(context.Background(), in)
+
+	apiErr := assertAPIError(t, err, http.StatusBadRequest, client.CodeValidationFailed)
+	if !strings.HasPrefix(apiErr.Type, "urn:online-subscriptions:problem:") || apiErr.Title == "" {
+		t.Fatalf("unexpected problem type or title: %q, %q", apiErr.Type, apiErr.Title)
+	}
+	if !client.IsValidation(err) {
+		t.Fatal("expected IsValidation")
+	}
+
+	fields := make(map[string]string)
+	for _, f := range apiErr.Fields {
+		fields[f.Field] = f.Code
+	}
+	if fields["price"] != "min" || fields["user_id"] != "uuid" {
+		t.Fatalf("unexpected field errors: %+v", apiErr.Fields)
+	}
+
+	wrapped := errors.Join(errors.New("context"), err)
+	if !client.HasCode(wrapped, client.CodeValidationFailed) {
+		t.Fatal("expected HasCode to see through wrapping")
+	}
+}
+
+func TestNonProblemError(t *testing.T) {
+	c, flaky := newClient(t)
+
+	// ответ прокси без problem+json: после исчерпания попыток клиент возвращает статус и тело
+	flaky.failNext(http.MethodGet, 3)
+
+	_, err := c.GetRecord(context.Background(), 1)
+
+	apiErr := assertAPIError(t, err, http.StatusServiceUnavailable, "")
+	if apiErr.Detail != "upstream unavailable" {
+		t.Fatalf("unexpected detail: %q", apiErr.Detail)
+	}
+	if got := flaky.count(http.MethodGet); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	c, flaky := newClient(t)
+	ctx := context.Background()
+
+	record := create(t, c, input(t, "Netflix", 400, "01-01-2025", "01-07-2025"))
+
+	tests := []struct {
+		method string
+		call   func() error
+	}{
+		{http.MethodGet, func() error {
+			_, err := c.GetRecord(ctx, record.ID)
+			return err
+		}},
+		{http.MethodPut, func() error {
+			_, err := c.UpdateRecord(ctx, record.ID, input(t, "Netflix", 500, "01-01-2025", "01-07-2025"))
+			return err
+		}},
+		{http.MethodDelete, func() error {
+			return c.DeleteRecord(ctx, record.ID)
+		}},
+	}
+
+	for _, tt := range tests {
+		flaky.failNext(tt.method, 2)
+
+		if err := tt.call(); err != nil {
+			t.Fatalf("%s: expected success after retries, got %v", tt.method, err)
+		}
+		if got := flaky.count(tt.method); got != 3 {
+			t.Fatalf("%s: expected 3 attempts, got %d", tt.method, got)
+		}
+	}
+}
+
+func TestNoRetryNonIdempotent(t *testing.T) {
+	c, flaky := newClient(t)
+	ctx := context.Background()
+
+	record := create(t, c, input(t, "Netflix", 400, "01-01-2025", "01-07-2025"))
+
+	flaky.failNext(http.MethodPost, 1)
+
+	_, err := c.CreateRecord(ctx, input(t, "Spotify", 200, "01-01-2025", "01-07-2025"))
+	assertAPIError(t, err, http.StatusServiceUnavailable, "")
+	if got := flaky.count(http.MethodPost); got != 1 {
+		t.Fatalf("POST: expected 1 attempt, got %d", got)
+	}
+
+	price := 500
+	flaky.failNext(http.MethodPatch, 1)
+
+	_, err = c.PatchRecord(ctx, client.RecordPatch{ID: record.ID, Price: &price})
+	assertAPIError(t, err, http.StatusServiceUnavailable, "")
+	if got := flaky.count(http.MethodPatch); got != 1 {
+		t.Fatalf("PATCH: expected 1 attempt, got %d", got)
+	}
+}
+
+func TestRecordsPages(t *testing.T) {
+	c, flaky := newClient(t)
+	ctx := context.Background()
+
+	var want []uint
+	for month := 1; month <= 5; month++ {
+		createdAt := time.Date(2025, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		record := create(t, c, client.RecordInput{
+			ServiceName: "Service " + createdAt.Month().String(),
+			Price:       100,
+			UserID:      testUser,
+			CreatedAt:   createdAt,
+			ExpiresAt:   createdAt.AddDate(0, 1, 0),
+		})
+		// новые первыми
+		want = append([]uint{record.ID}, want...)
+	}
+
+	flaky.failNext("", 0)
+
+	var got []uint
+	for record, err := range c.Records(ctx, client.ListOptions{UserID: testUser, Limit: 2}) {
+		if err != nil {
+			t.Fatalf("records: %v", err)
+		}
+		got = append(got, record.ID)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	// страницы 2, 2 и 1 запись
+	if pages := flaky.count(http.MethodGet); pages != 3 {
+		t.Fatalf("expected 3 page requests, got %d", pages)
+	}
+
+	records, err := c.GetUserRecords(ctx, testUser)
+	if err != nil {
+		t.Fatalf("get user records: %v", err)
+	}
+	if len(records) != len(want) {
+		t.Fatalf("get user records: got %d records, want %d", len(records), len(want))
+	}
+}
+
+func TestRecordsStopsEarly(t *testing.T) {
+	c, flaky := newClient(t)
+
+	for month := 1; month <= 4; month++ {
+		createdAt := time.Date(2025, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		create(t, c, client.RecordInput{
+			ServiceName: "Service " + createdAt.Month().String(),
+			Price:       100,
+			UserID:      testUser,
+			CreatedAt:   createdAt,
+			ExpiresAt:   createdAt.AddDate(0, 1, 0),
+		})
+	}
+
+	flaky.failNext("", 0)
+
+	seen := 0
+	for _, err := range c.Records(context.Background(), client.ListOptions{UserID: testUser, Limit: 2}) {
+		if err != nil {
+			t.Fatalf("records: %v", err)
+		}
+		if seen++; seen == 1 {
+			break
+		}
+	}
+
+	if pages := flaky.count(http.MethodGet); pages != 1 {
+		t.Fatalf("expected 1 page request after break, got %d", pages)
+	}
+}
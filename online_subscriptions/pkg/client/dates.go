@@ -0,0 +1,48 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Month - месяц из ответа API (MM-YYYY), время - первое число месяца в UTC
+type Month struct {
+	time.Time
+}
+
+func (m *Month) UnmarshalJSON(data []byte) error {
+	return unmarshalTime(data, monthLayout, &m.Time)
+}
+
+func (m Month) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Format(monthLayout))
+}
+
+// Date - дата из ответа API (DD-MM-YYYY) в UTC
+type Date struct {
+	time.Time
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	return unmarshalTime(data, dateLayout, &d.Time)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(dateLayout))
+}
+
+func unmarshalTime(data []byte, layout string, dst *time.Time) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := time.Parse(layout, value)
+	if err != nil {
+		return err
+	}
+
+	*dst = parsed
+
+	return nil
+}
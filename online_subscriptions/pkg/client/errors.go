@@ -0,0 +1,170 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// коды ошибок API (поле code ответа problem+json)
+const (
+	CodeValidationFailed        = "validation_failed"
+	CodeMalformedRequest        = "malformed_request"
+	CodeRecordNotFound          = "record_not_found"
+	CodeRouteNotFound           = "route_not_found"
+	CodeConflict                = "conflict"
+	CodeForbidden               = "forbidden"
+	CodeUnauthorized            = "unauthorized"
+	CodeInternal                = "internal_error"
+	CodeOverlappingSubscription = "overlapping_subscription"
+	CodeBudgetExceeded          = "budget_exceeded"
+	CodeBatchAborted            = "batch_aborted"
+	CodeBatchTooLarge           = "batch_too_large"
+	CodePriceChangeNotFound     = "price_change_not_found"
+	CodePriceChangeExists       = "price_change_exists"
+	CodeInvalidFeedToken        = "invalid_feed_token"
+)
+
+// FieldError - ошибка в конкретном поле запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error - ошибка API в формате RFC 7807. Extensions - дополнительные члены ответа,
+// например record_id пересекающейся подписки
+type Error struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       string
+	Fields     []FieldError
+	Extensions map[string]any
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "subscriptions api: %d", e.Status)
+
+	if e.Code != "" {
+		b.WriteString(" " + e.Code)
+	}
+
+	if e.Detail != "" {
+		b.WriteString(": " + e.Detail)
+	}
+
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "; %s: %s", f.Field, f.Message)
+	}
+
+	return b.String()
+}
+
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var problem struct {
+		Type     string       `json:"type"`
+		Title    string       `json:"title"`
+		Status   int          `json:"status"`
+		Detail   string       `json:"detail"`
+		Instance string       `json:"instance"`
+		Code     string       `json:"code"`
+		Errors   []FieldError `json:"errors"`
+	}
+
+	if err := json.Unmarshal(data, &problem); err != nil {
+		return err
+	}
+
+	var members map[string]any
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	for _, known := range []string{"type", "title", "status", "detail", "instance", "code", "errors"} {
+		delete(members, known)
+	}
+
+	*e = Error{
+		Type:     problem.Type,
+		Title:    problem.Title,
+		Status:   problem.Status,
+		Detail:   problem.Detail,
+		Instance: problem.Instance,
+		Code:     problem.Code,
+		Fields:   problem.Errors,
+	}
+
+	if len(members) > 0 {
+		e.Extensions = members
+	}
+
+	return nil
+}
+
+// readError читает ответ об ошибке. Ответ не в формате problem+json (например, от прокси)
+// превращается в Error со статусом ответа и началом тела в Detail
+func readError(resp *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read error response: %w", err)
+	}
+
+	var apiErr Error
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Code != "" {
+		if apiErr.Status == 0 {
+			apiErr.Status = resp.StatusCode
+		}
+		return &apiErr
+	}
+
+	detail := strings.TrimSpace(string(body))
+	if len(detail) > 200 {
+		detail = detail[:200]
+	}
+
+	return &Error{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode), Detail: detail}
+}
+
+// AsError возвращает ошибку API из цепочки err
+func AsError(err error) (*Error, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+
+	return nil, false
+}
+
+// HasCode сообщает, является ли err ошибкой API с кодом code
+func HasCode(err error, code string) bool {
+	apiErr, ok := AsError(err)
+	return ok && apiErr.Code == code
+}
+
+// IsNotFound - запись или другой объект не найден
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict - пересечение подписок, превышение бюджета и другие конфликты
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsValidation - запрос отклонен из-за неверных данных, подробности в Fields
+func IsValidation(err error) bool {
+	return hasStatus(err, http.StatusBadRequest)
+}
+
+func hasStatus(err error, status int) bool {
+	apiErr, ok := AsError(err)
+	return ok && apiErr.Status == status
+}
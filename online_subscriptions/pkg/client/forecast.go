@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ForecastQuery - параметры прогноза. Нулевой From - текущий месяц, нулевой Months - 12 (макс. 60).
+// AutoRenew - считать подписки продленными после окончания
+type ForecastQuery struct {
+	From        time.Time
+	Months      int
+	UserID      string
+	ServiceName string
+	AutoRenew   bool
+}
+
+// ServiceContribution - вклад сервиса в расходы месяца
+type ServiceContribution struct {
+	ServiceName   string `json:"service_name"`
+	Amount        int    `json:"amount"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+// ForecastMonth - прогноз за месяц, сервисы по убыванию вклада
+type ForecastMonth struct {
+	Month    Month                 `json:"month"`
+	Total    int                   `json:"total"`
+	Services []ServiceContribution `json:"services"`
+}
+
+type Forecast struct {
+	From      Month           `json:"from"`
+	Months    int             `json:"months"`
+	AutoRenew bool            `json:"auto_renew"`
+	Total     int             `json:"total"`
+	Forecast  []ForecastMonth `json:"forecast"`
+}
+
+// Forecast возвращает прогноз расходов по месяцам с учетом запланированных изменений цен
+func (c *Client) Forecast(ctx context.Context, q ForecastQuery) (*Forecast, error) {
+	query := url.Values{}
+
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(monthLayout))
+	}
+	if q.Months > 0 {
+		query.Set("months", strconv.Itoa(q.Months))
+	}
+	if q.UserID != "" {
+		query.Set("user_id", q.UserID)
+	}
+	if q.ServiceName != "" {
+		query.Set("service_name", q.ServiceName)
+	}
+	if q.AutoRenew {
+		query.Set("auto_renew", "true")
+	}
+
+	var forecast Forecast
+
+	if _, err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/subscriptions/forecast", query: query}, &forecast); err != nil {
+		return nil, err
+	}
+
+	return &forecast, nil
+}
+
+// PriceChange - запланированное изменение цены подписки
+type PriceChange struct {
+	ID            uint      `json:"id"`
+	RecordID      uint      `json:"record_id"`
+	Price         int       `json:"price"`
+	EffectiveFrom Date      `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SchedulePriceChange планирует новую цену записи recordID с даты effectiveFrom
+func (c *Client) SchedulePriceChange(ctx context.Context, recordID uint, price int, effectiveFrom time.Time) (*PriceChange, error) {
+	body := struct {
+		Price         int    `json:"price"`
+		EffectiveFrom string `json:"effective_from"`
+	}{Price: price, EffectiveFrom: effectiveFrom.Format(dateLayout)}
+
+	var change PriceChange
+
+	req := request{method: http.MethodPost, path: idPath("/v1/subscriptions/%d/price-changes", recordID), body: body}
+	if _, err := c.doJSON(ctx, req, &change); err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// ListPriceChanges возвращает изменения цены записи по дате вступления в силу
+func (c *Client) ListPriceChanges(ctx context.Context, recordID uint) ([]PriceChange, error) {
+	var changes []PriceChange
+
+	req := request{method: http.MethodGet, path: idPath("/v1/subscriptions/%d/price-changes", recordID)}
+	if _, err := c.doJSON(ctx, req, &changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// DeletePriceChange отменяет запланированное изменение цены
+func (c *Client) DeletePriceChange(ctx context.Context, recordID, changeID uint) error {
+	req := request{method: http.MethodDelete, path: idPath("/v1/subscriptions/%d/price-changes/%d", recordID, changeID)}
+	_, err := c.doJSON(ctx, req, nil)
+
+	return err
+}
@@ -0,0 +1,273 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Record - запись подписки в том виде, в каком ее отдает API
+type Record struct {
+	ID          uint
+	ServiceName string
+	Price       int
+	UserID      string
+	Category    string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// RecordInput - данные для создания и обновления записи. Нулевой CreatedAt - сегодня
+type RecordInput struct {
+	ServiceName string
+	Price       int
+	UserID      string
+	Category    string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+type recordRequest struct {
+	ServiceName string `json:"service_name"`
+	Price       int    `json:"price"`
+	UserID      string `json:"user_id"`
+	ExpiresAt   string `json:"expires_at"`
+	CreatedAt   string `json:"created_at,omitempty"`
+	Category    string `json:"category,omitempty"`
+}
+
+func (in RecordInput) request() recordRequest {
+	req := recordRequest{
+		ServiceName: in.ServiceName,
+		Price:       in.Price,
+		UserID:      in.UserID,
+		ExpiresAt:   in.ExpiresAt.Format(dateLayout),
+		Category:    in.Category,
+	}
+
+	if !in.CreatedAt.IsZero() {
+		req.CreatedAt = in.CreatedAt.Format(dateLayout)
+	}
+
+	return req
+}
+
+// WriteResult - итог создания или обновления записи. Merged - запись объединена с существующей
+// (overlap.policy=merge), Warnings - предупреждения сервиса, например о превышении бюджета
+type WriteResult struct {
+	Record   *Record
+	Merged   bool
+	Warnings []string
+}
+
+// CreateRecord создает запись подписки
+func (c *Client) CreateRecord(ctx context.Context, in RecordInput) (*WriteResult, error) {
+	var record Record
+
+	resp, err := c.doJSON(ctx, request{method: http.MethodPost, path: "/v1/subscriptions", body: in.request()}, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WriteResult{
+		Record:   &record,
+		Merged:   resp.StatusCode == http.StatusOK,
+		Warnings: warnings(resp.Header),
+	}, nil
+}
+
+// UpdateRecord заменяет данные записи id. Возвращает предупреждения сервиса
+func (c *Client) UpdateRecord(ctx context.Context, id uint, in RecordInput) ([]string, error) {
+	resp, err := c.doJSON(ctx, request{method: http.MethodPut, path: idPath("/v1/subscriptions/%d", id), body: in.request()}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return warnings(resp.Header), nil
+}
+
+func (c *Client) DeleteRecord(ctx context.Context, id uint) error {
+	_, err := c.doJSON(ctx, request{method: http.MethodDelete, path: idPath("/v1/subscriptions/%d", id)}, nil)
+	return err
+}
+
+func (c *Client) GetRecord(ctx context.Context, id uint) (*Record, error) {
+	var record Record
+
+	if _, err := c.doJSON(ctx, request{method: http.MethodGet, path: idPath("/v1/subscriptions/%d", id)}, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// PatchRecord меняет только заданные поля записи patch.ID и возвращает запись после изменения
+func (c *Client) PatchRecord(ctx context.Context, patch RecordPatch) (*WriteResult, error) {
+	var record Record
+
+	body := patch.request()
+	body.ID = 0
+
+	resp, err := c.doJSON(ctx, request{method: http.MethodPatch, path: idPath("/v1/subscriptions/%d", patch.ID), body: body}, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WriteResult{Record: &record, Warnings: warnings(resp.Header)}, nil
+}
+
+// GetUserRecords возвращает все записи пользователя, запрашивая их постранично
+func (c *Client) GetUserRecords(ctx context.Context, userID string) ([]Record, error) {
+	var records []Record
+
+	for record, err := range c.Records(ctx, ListOptions{UserID: userID}) {
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// FindRecord возвращает подписку пользователя на сервис, при нескольких - самую позднюю.
+// Отсутствие подписки - *Error с кодом CodeRecordNotFound
+func (c *Client) FindRecord(ctx context.Context, userID, serviceName string) (*Record, error) {
+	records, err := c.ListRecords(ctx, ListOptions{UserID: userID, ServiceName: serviceName, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, &Error{
+			Title:  http.StatusText(http.StatusNotFound),
+			Status: http.StatusNotFound,
+			Detail: fmt.Sprintf("no subscription of user %s to %s", userID, serviceName),
+			Code:   CodeRecordNotFound,
+		}
+	}
+
+	return &records[0], nil
+}
+
+// MaxPageSize - наибольший размер страницы /v1/subscriptions, больший лимит сервер уменьшает до него
+const MaxPageSize = 100
+
+// ListOptions - фильтры и страница /v1/subscriptions. Нулевой Limit - значение сервера по умолчанию (20)
+type ListOptions struct {
+	UserID      string
+	ServiceName string
+	Limit       int
+	Offset      int
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+
+	if o.UserID != "" {
+		query.Set("user_id", o.UserID)
+	}
+	if o.ServiceName != "" {
+		query.Set("service_name", o.ServiceName)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+
+	return query
+}
+
+// ListRecords возвращает одну страницу записей, новые первыми
+func (c *Client) ListRecords(ctx context.Context, opts ListOptions) ([]Record, error) {
+	var records []Record
+
+	if _, err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/subscriptions", query: opts.query()}, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Records перебирает записи под фильтром opts, запрашивая страницы по opts.Limit (по умолчанию
+// MaxPageSize) начиная с opts.Offset. Перебор останавливается на первой ошибке. Страницы
+// задаются смещением, поэтому записи, созданные во время перебора, могут сдвинуть выдачу
+func (c *Client) Records(ctx context.Context, opts ListOptions) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		page := opts
+		if page.Limit <= 0 || page.Limit > MaxPageSize {
+			page.Limit = MaxPageSize
+		}
+
+		for {
+			records, err := c.ListRecords(ctx, page)
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+
+			for _, record := range records {
+				if !yield(record, nil) {
+					return
+				}
+			}
+
+			if len(records) < page.Limit {
+				return
+			}
+
+			page.Offset += len(records)
+		}
+	}
+}
+
+// источники суммы за период
+const (
+	// SourceRecords - цены записей, созданных в периоде
+	SourceRecords = "records"
+	// SourceLedger - начисления и корректировки журнала с датой списания в периоде, только Postgres
+	SourceLedger = "ledger"
+)
+
+// SummaryQuery - период и фильтры суммы. Пустой Source - SourceRecords
+type SummaryQuery struct {
+	From        time.Time
+	To          time.Time
+	UserID      string
+	ServiceName string
+	Source      string
+}
+
+// Summary возвращает сумму платежей за период
+func (c *Client) Summary(ctx context.Context, q SummaryQuery) (int, error) {
+	query := url.Values{
+		"start_time": {q.From.Format(dateLayout)},
+		"end_time":   {q.To.Format(dateLayout)},
+	}
+
+	if q.UserID != "" {
+		query.Set("user_id", q.UserID)
+	}
+	if q.ServiceName != "" {
+		query.Set("service_name", q.ServiceName)
+	}
+	if q.Source != "" {
+		query.Set("source", q.Source)
+	}
+
+	var summary struct {
+		TotalPrice int `json:"total_price"`
+	}
+
+	if _, err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/subscriptions/summary", query: query}, &summary); err != nil {
+		return 0, err
+	}
+
+	return summary.TotalPrice, nil
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// форматы выгрузки и импорта
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+// ExportOptions - формат и фильтры выгрузки. Пустой Format - CSV
+type ExportOptions struct {
+	Format      string
+	UserID      string
+	ServiceName string
+}
+
+// Export выгружает записи файлом. Файл передается потоком, тело закрывает вызывающий.
+// Ошибка на середине выгрузки проявится как ошибка чтения: сервер уже отправил статус 200
+func (c *Client) Export(ctx context.Context, opts ExportOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+
+	if opts.Format != "" {
+		query.Set("format", opts.Format)
+	}
+	if opts.UserID != "" {
+		query.Set("user_id", opts.UserID)
+	}
+	if opts.ServiceName != "" {
+		query.Set("service_name", opts.ServiceName)
+	}
+
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/subscriptions/export", query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// ImportOptions - параметры импорта. Пустой Format определяется сервером по расширению Filename,
+// Mapping - соответствие полей API колонкам файла, например {"price": "Стоимость"}
+type ImportOptions struct {
+	Filename  string
+	Format    string
+	Mapping   map[string]string
+	Sheet     string
+	Delimiter string
+	DryRun    bool
+}
+
+// ImportRow - результат импорта строки файла
+type ImportRow struct {
+	Line     int      `json:"line"`
+	Action   string   `json:"action"`
+	RecordID uint     `json:"record_id,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    *Error   `json:"error,omitempty"`
+}
+
+// ImportReport - отчет об импорте файла
+type ImportReport struct {
+	DryRun    bool        `json:"dry_run"`
+	Total     int         `json:"total"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Merged    int         `json:"merged"`
+	Unchanged int         `json:"unchanged"`
+	Failed    int         `json:"failed"`
+	Rows      []ImportRow `json:"rows"`
+}
+
+// Import загружает CSV или XLSX из file. Запрос не повторяется: file читается один раз
+func (c *Client) Import(ctx context.Context, file io.Reader, opts ImportOptions) (*ImportReport, error) {
+	filename := opts.Filename
+	if filename == "" {
+		filename = "import." + opts.Format
+	}
+
+	fields := map[string]string{
+		"format":    opts.Format,
+		"sheet":     opts.Sheet,
+		"delimiter": opts.Delimiter,
+	}
+
+	if len(opts.Mapping) > 0 {
+		mapping, err := json.Marshal(opts.Mapping)
+		if err != nil {
+			return nil, err
+		}
+		fields["mapping"] = string(mapping)
+	}
+
+	// тело пишется в pipe по мере отправки, файл целиком в память не читается
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+
+	go func() {
+		writer.CloseWithError(writeImportForm(form, file, filename, fields))
+	}()
+	defer body.Close()
+
+	req := request{
+		method:      http.MethodPost,
+		path:        "/v1/subscriptions/import",
+		query:       url.Values{"dry_run": {strconv.FormatBool(opts.DryRun)}},
+		raw:         body,
+		contentType: form.FormDataContentType(),
+	}
+
+	var report ImportReport
+
+	if _, err := c.doJSON(ctx, req, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func writeImportForm(form *multipart.Writer, file io.Reader, filename string, fields map[string]string) error {
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+
+	return form.Close()
+}